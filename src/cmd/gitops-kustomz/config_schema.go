@@ -0,0 +1,21 @@
+package main
+
+import (
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/spf13/cobra"
+)
+
+// newConfigSchemaCmd creates the command printing the JSON schema of compliance-config.yaml
+func newConfigSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "config-schema",
+		Short: "Print the JSON schema of compliance-config.yaml",
+		Long: `config-schema prints the JSON schema of compliance-config.yaml,
+point your editor at it to get validation and completion while authoring policies, e.g.
+  gitops-kustomz config-schema > compliance-config.schema.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, err := cmd.OutOrStdout().Write(policy.ComplianceConfigSchema())
+			return err
+		},
+	}
+}
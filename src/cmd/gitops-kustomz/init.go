@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/scaffold"
+	"github.com/spf13/cobra"
+)
+
+// newInitCmd creates the command scaffolding an example policies and templates directory
+func newInitCmd() *cobra.Command {
+	var policiesPath, templatesPath string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Scaffold an example policies and templates directory",
+		Long: `init writes a working example compliance-config.yaml, a sample rego policy with its tests,
+and the default markdown templates, ready to be used by gitops-kustomz.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			written, err := scaffold.Init(policiesPath, templatesPath, force)
+			if err != nil {
+				return err
+			}
+			for _, path := range written {
+				fmt.Fprintf(cmd.OutOrStdout(), "created %s\n", path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&policiesPath, "policies-path", "./policies",
+		"Path to the policies directory to scaffold")
+	cmd.Flags().StringVar(&templatesPath, "templates-path", "./templates",
+		"Path to the templates directory to scaffold")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing files")
+
+	return cmd
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/spf13/cobra"
+)
+
+// newListPoliciesCmd creates the command listing the configured policies and their current enforcement level
+func newListPoliciesCmd() *cobra.Command {
+	var policiesPath string
+
+	cmd := &cobra.Command{
+		Use:   "list-policies",
+		Short: "List the configured policies and their current enforcement level",
+		Long: `list-policies lists the policies of compliance-config.yaml with their enforcement level as of today
+and their override command, without evaluating any manifest.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			evaluator := policy.NewPolicyEvaluator(policiesPath)
+			if err := evaluator.LoadAndValidate(); err != nil {
+				return fmt.Errorf("failed to load policy config: %w", err)
+			}
+			levels, err := evaluator.PolicyLevels()
+			if err != nil {
+				return fmt.Errorf("failed to determine enforcement levels: %w", err)
+			}
+			return writePolicyLevels(cmd.OutOrStdout(), levels)
+		},
+	}
+
+	cmd.Flags().StringVar(&policiesPath, "policies-path", "./policies",
+		"Path to policies directory (contains compliance-config.yaml)")
+
+	return cmd
+}
+
+// writePolicyLevels prints the policies and their level as a table
+func writePolicyLevels(out io.Writer, levels []models.PolicyLevel) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POLICY ID\tPOLICY NAME\tLEVEL\tOVERRIDE COMMAND")
+	for _, l := range levels {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", l.PolicyId, l.PolicyName, orDash(l.Level), orDash(l.OverrideCommand))
+	}
+	return w.Flush()
+}
+
+// orDash returns "-" for an empty table cell, e.g. a policy without enforcement date or override command
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestListPoliciesCmd tests the printed enforcement levels of a config with mixed enforcement dates
+func TestListPoliciesCmd(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"compliance-config.yaml": `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2000-01-01T00:00:00Z
+      isWarningAfter: 2000-01-01T00:00:00Z
+      isBlockingAfter: 2000-01-01T00:00:00Z
+      override:
+        comment: /sp-override-ha
+  tls:
+    name: Ingress TLS
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2000-01-01T00:00:00Z
+      isWarningAfter: 2000-01-01T00:00:00Z
+      isBlockingAfter: 2999-01-01T00:00:00Z
+  quota:
+    name: Resource Quota
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2999-01-01T00:00:00Z
+`,
+		"ha.rego":      "package main\n",
+		"ha_test.rego": "package main\n\ntest_ha if { true }\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	var out bytes.Buffer
+	cmd := newListPoliciesCmd()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--policies-path", dir})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("list-policies error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	want := [][]string{
+		{"POLICY", "ID", "POLICY", "NAME", "LEVEL", "OVERRIDE", "COMMAND"},
+		{"ha", "Service", "High", "Availability", "BLOCK", "/sp-override-ha"},
+		{"quota", "Resource", "Quota", "NOT_IN_EFFECT", "-"},
+		{"tls", "Ingress", "TLS", "WARNING", "-"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("list-policies printed %d lines, want %d:\n%s", len(lines), len(want), out.String())
+	}
+	for i, w := range want {
+		if got := strings.Fields(lines[i]); strings.Join(got, " ") != strings.Join(w, " ") {
+			t.Errorf("line %d = %q, want fields %q", i, lines[i], w)
+		}
+	}
+}
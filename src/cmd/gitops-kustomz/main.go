@@ -5,6 +5,10 @@ import (
 	"os"
 
 	"github.com/gh-nvat/gitops-kustomz/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
 	"github.com/spf13/cobra"
 )
 
@@ -43,35 +47,169 @@ It builds kustomize manifests, diffs them, evaluates OPA policies, and posts det
 	// Common flags
 	cmd.Flags().StringVar(&opts.Service, "service", "", "Service name (required)")
 	cmd.Flags().StringSliceVar(&opts.Environments, "environments", []string{},
-		"Environments to check (comma-separated, e.g., stg,prod) (required)")
+		"Environments to check (comma-separated, e.g., stg,prod), or \"all\" for every overlay of the service on the head (required)")
+	cmd.Flags().IntVar(&opts.MaxEnvironments, "max-environments", runner.MAX_ENVIRONMENTS_DEFAULT,
+		"Maximum number of environments, a longer list is rejected before building anything to protect CI from a malformed list")
 	cmd.Flags().StringVar(&opts.PoliciesPath, "policies-path", "./policies",
 		"Path to policies directory (contains compliance-config.yaml)")
 	cmd.Flags().StringVar(&opts.TemplatesPath, "templates-path", "./templates",
 		"Path to templates directory")
 	cmd.Flags().BoolVar(&opts.Debug, "debug", false, "Debug mode")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 0,
+		"Deadline of the whole run (clone, build, evaluation, API calls), e.g. 10m (no deadline if 0)")
+	cmd.Flags().IntVar(&opts.Retries, "retries", 0,
+		"Re-run the whole process up to this many times on a transient error (network, GitHub rate limit or server error) with exponential backoff, "+
+			"never on a deterministic error like a policy failure or an invalid config")
 
 	cmd.Flags().StringVar(&opts.OutputDir, "output-dir", "./output",
 		"Output directory in case the tool need to export files. In local mode, the tool will export the report to this directory.")
 	cmd.Flags().BoolVar(&opts.EnableExportReport, "enable-export-report", false, "Enable export report (json file to output dir)")
 	cmd.Flags().BoolVar(&opts.EnableExportPerformanceReport, "enable-export-performance-report", false, "Enable export performance report (json file to output dir)")
+	cmd.Flags().BoolVar(&opts.CompressReport, "compress-report", false,
+		"Write the exported report gzip-compressed as report.json.gz instead of report.json")
+	cmd.Flags().BoolVar(&opts.CompressReportMarkdown, "compress-report-markdown", false,
+		"Write the markdown report gzip-compressed as report.md.gz instead of report.md")
+	cmd.Flags().BoolVar(&opts.CompressPerformanceReport, "compress-performance-report", false,
+		"Write the performance report gzip-compressed as performance-report.json.gz instead of performance-report.json")
+	cmd.Flags().StringVar(&opts.ExportCSV, "export-csv", "",
+		"Write the policy matrix as a flat CSV (service, environment, policyId, policyName, level, passing, failMessage) to this path, one row per fail message or per passing policy")
+	cmd.Flags().StringVar(&opts.MetricsFile, "metrics-file", "",
+		"Write the run metrics (build and evaluation durations, failed policies, diff lines) in Prometheus textfile format to this path after each run, e.g. for the node_exporter textfile collector")
+	cmd.Flags().StringArrayVar(&opts.EnvOverlays, "env-overlays", []string{},
+		"Overlays built and concatenated into the manifest of an environment before diff and evaluation, so cross-resource policies see e.g. an app and its CRDs together (repeatable, e.g. --env-overlays stg=stg,stg-crds, the overlay named after the environment if not set)")
+	cmd.Flags().BoolVar(&opts.StrictYaml, "strict-yaml", false, "Fail if a built manifest contains duplicate YAML keys")
+	cmd.Flags().BoolVar(&opts.IncludeFullManifest, "include-full-manifest", false,
+		"Include the full rendered head manifest of each environment as a collapsed section in the report")
+	cmd.Flags().BoolVar(&opts.NoEmoji, "no-emoji", false,
+		"Print text labels like [CHECK], [PASS], [FAIL] instead of emoji in the report, for screen readers and markdown renderers without emoji support")
+	cmd.Flags().StringSliceVar(&opts.CommentLevels, "comment-levels", nil,
+		"Enforcement levels of the policies rendered in the comment and markdown report, e.g. block,warning to leave out recommend (all levels if empty), report.json keeps every level")
+	cmd.Flags().IntVar(&opts.MaxFailMessageLength, "max-fail-message-length", template.DefaultMaxFailMessageLength,
+		"Truncate fail messages longer than this many characters in the markdown report, report.json keeps them whole (0 to disable)")
+	cmd.Flags().BoolVar(&opts.ShowPolicySource, "show-policy-source", false,
+		"Include the rego source of each failing policy as a collapsed section in the report")
+	cmd.Flags().StringSliceVar(&opts.IncludeKinds, "include-kinds", []string{},
+		"Only diff and evaluate these resource kinds (comma-separated, e.g., Deployment,HorizontalPodAutoscaler)")
+	cmd.Flags().StringSliceVar(&opts.ExcludeKinds, "exclude-kinds", []string{},
+		"Drop these resource kinds before diff and evaluation (comma-separated, e.g., ConfigMap)")
+	cmd.Flags().BoolVar(&opts.RequireCleanBase, "require-clean-base", false,
+		"Also evaluate the base manifests and flag violations already present on the base, apart from the ones introduced by the PR")
+	cmd.Flags().BoolVar(&opts.ReportFixed, "report-fixed", false,
+		"Also evaluate the base manifests and list their violations resolved by the PR as \"✅ Fixed by this PR\"")
+	cmd.Flags().StringVar(&opts.Baseline, "baseline", "",
+		"report.json (or report.json.gz) of a previous run, e.g. of main: failing policies whose violations are all in it are omitted as pre-existing instead of gating, only new violations gate")
+	cmd.Flags().StringVar(&opts.DiffTool, "diff-tool", "",
+		"External diff tool called with the before and after manifest files, e.g. \"dyff between --omit-header --output github\" for YAML-aware output, lines starting with \"+ \"/\"- \" are counted as changes (the built-in Go unified diff if empty)")
+	cmd.Flags().BoolVar(&opts.DiffIgnoreWhitespace, "diff-ignore-whitespace", false,
+		"Ignore changes in the amount of whitespace (reindented YAML, trailing spaces), whitespace-only changes report as no change (like diff -b, not applied to --diff-tool)")
+	cmd.Flags().StringVar(&opts.DiffTempExt, "diff-temp-ext", diff.DEFAULT_TEMP_EXT,
+		"Extension of the before/after manifest temp files passed to --diff-tool, for YAML-aware tools keying their behavior off it (e.g. .yml), unused by the built-in Go unified diff")
+	cmd.Flags().IntVar(&opts.DiffContextLines, "diff-context-lines", diff.DEFAULT_CONTEXT_LINES,
+		"Unchanged lines shown around each change of the built-in unified diff (like diff -U<n>), lower it to shorten the comments of large changes (not applied to --diff-tool)")
+	cmd.Flags().BoolVar(&opts.BuildSplitOutput, "build-split-output", false,
+		"Build one file per resource (kustomize build -o) and diff the files one by one, reported in git-patch format")
+	cmd.Flags().BoolVar(&opts.DiffPerResource, "diff-per-resource", false,
+		"Render the diff of each changed resource in its own collapsible section, matched by kind, namespace and name, instead of a single diff")
+	cmd.Flags().StringVar(&opts.DiffFormat, "diff-format", diff.DIFF_FORMAT_UNIFIED,
+		"Diff output format: unified (single diff of the manifests) or git (git patch with a/ b/ file headers per resource, e.g. a/Deployment/my-ns/my-app.yaml, applicable with git apply)")
+	cmd.Flags().StringArrayVar(&opts.DiffMaskPatterns, "diff-mask-pattern", []string{},
+		"Regular expression of sensitive values (tokens, connection strings) replaced by *** in the posted diff and full manifest, line counts are preserved (repeatable, e.g. --diff-mask-pattern 'password=\\S+')")
+	cmd.Flags().StringSliceVar(&opts.NoDiffEnvs, "no-diff-env", []string{},
+		"Environment whose diff content (and full manifest) is left out of the report, e.g. a sensitive prod, line counts and policy results are still shown (repeatable)")
+	cmd.Flags().StringVar(&opts.NoDiffLink, "no-diff-link", "",
+		"Link shown instead of the diff of the --no-diff-env environments, e.g. to a protected artifact")
+	cmd.Flags().StringArrayVar(&opts.DiffIgnorePaths, "diff-ignore-path", []string{},
+		"YAML path of fields removed from every document before diffing, so their changes do not show: dot-separated keys, quoted if they contain dots, * and ? globs, list item indexes (repeatable, e.g. --diff-ignore-path 'metadata.annotations.\"checksum/*\"'). The diffed manifests are re-serialized")
+	cmd.Flags().StringArrayVar(&opts.DiffUnorderedFields, "diff-unordered-field", []string{},
+		"Field whose list items are sorted by name (or key, mountPath, containerPort) before diffing, so a reordering without semantic change does not show (repeatable, e.g. --diff-unordered-field env --diff-unordered-field volumes)")
+	cmd.Flags().BoolVar(&opts.NormalizeGeneratedNames, "normalize-generated-names", false,
+		"Replace the content hash suffix of configMapGenerator/secretGenerator names (e.g. my-config-5t8f9k2h6m) by a placeholder before diffing, so only the actual data change shows")
+	cmd.Flags().StringVar(&opts.PolicyBackend, "policy-backend", policy.POLICY_BACKEND_CONFTEST,
+		"Policy evaluation backend: conftest (local CLI) or opa-server (REST data API of a running OPA server)")
+	cmd.Flags().StringVar(&opts.OpaURL, "opa-url", "",
+		"OPA server base URL, e.g. http://localhost:8181 [opa-server backend]")
+	cmd.Flags().BoolVar(&opts.EmptyResultsAsPass, "empty-results-as-pass", false,
+		"Treat an empty conftest result (no document to check, e.g. everything filtered out), or a missing OPA server result [opa-server backend], as a pass instead of an error")
+	cmd.Flags().BoolVar(&opts.ConftestBatch, "conftest-batch", false,
+		"Evaluate policies in batched conftest calls filtered by --namespace instead of one call per policy, a batch holds at most one policy per rego package (policies with external data are still evaluated one by one)")
+	cmd.Flags().IntVar(&opts.PolicyConcurrency, "policy-concurrency", policy.POLICY_CONCURRENCY_DEFAULT,
+		"Maximum number of policies evaluated in parallel (conftest calls or OPA server queries), 1 to evaluate them one at a time")
+	cmd.Flags().BoolVar(&opts.FailFast, "fail-fast", false,
+		"Stop evaluating policies after the first blocking failure and post a partial report, trading completeness for speed on large policy sets")
+	cmd.Flags().Float64Var(&opts.MinPolicyCoverage, "min-policy-coverage", 0,
+		"Fail the validation if the rego test coverage of a policy, measured with opa test --coverage, is below this percent (0 to disable, requires opa)")
+	cmd.Flags().BoolVar(&opts.UseRegoSeverity, "use-rego-severity", false,
+		"Take the enforcement level of failing policies from their rego rules instead of only the compliance config dates: deny/violation block, warn warns, a severity in the result metadata (block, warning, recommend) wins. Overridden policies and policies not in effect keep their level [conftest backend]")
+	cmd.Flags().BoolVar(&opts.SkipUnchanged, "skip-unchanged", false,
+		"Skip the policy evaluation and report a concise \"no manifest changes\" comment when the base and head manifests of every environment are identical, e.g. a PR only changing a README")
+	cmd.Flags().StringVar(&opts.PolicyCacheDir, "policy-cache-dir", "",
+		"Directory to cache policy evaluation results across runs, keyed by policy and manifest hashes (in-memory only if empty or with the opa-server backend)")
+	cmd.Flags().StringVar(&opts.ExpectedKustomizeVersion, "expected-kustomize-version", "",
+		"Expected kustomize version, or version prefix (e.g., 5.4), checked before running (not checked if empty)")
+	cmd.Flags().StringVar(&opts.ExpectedConftestVersion, "expected-conftest-version", "",
+		"Expected conftest version, or version prefix (e.g., 0.56), checked before running (not checked if empty)")
+	cmd.Flags().BoolVar(&opts.StrictToolVersions, "strict-tool-versions", false,
+		"Fail instead of warning when an installed tool version does not match the expected one")
+	cmd.Flags().BoolVar(&opts.StrictRender, "strict-render", false,
+		"Fail instead of warning when the rendered report has an unclosed <details> tag or code fence, which would swallow the rest of the comment")
+	cmd.Flags().StringVar(&opts.TempPrefix, "temp-prefix", "",
+		"Prefix of the temp manifest files, to attribute and clean up stray files of parallel runs sharing a temp directory (gitops-kustomz-<service>-<$GITHUB_RUN_ID>- if empty)")
 
 	// GitHub mode flags
 	cmd.Flags().StringVar(&opts.GhRepo, "gh-repo", "",
 		"GitHub repository (e.g., org/repo) [github mode]")
 	cmd.Flags().IntVar(&opts.GhPrNumber, "gh-pr-number", 0,
 		"GitHub PR number [github mode]")
+	cmd.Flags().StringVar(&opts.GhCommit, "gh-commit", "",
+		"Commit SHA to evaluate as is instead of a PR, e.g. for a scheduled audit of main: writes the report without posting a comment, unless on an issue with --comment-target issue [github mode]")
 	cmd.Flags().StringVar(&opts.ManifestsPath, "manifests-path", "./services",
 		"Path to services directory containing service folders [github mode]")
+	cmd.Flags().StringVar(&opts.DiffBase, "diff-base", runner.DIFF_BASE_MERGE_BASE,
+		"Commit to diff the PR head against: merge-base (like GitHub's \"Files changed\") or base-ref (tip of the base branch) [github mode]")
+	cmd.Flags().BoolVar(&opts.CommentOnSuccess, "comment-on-success", true,
+		"Post the PR comment even when there are no manifest changes and no failing or errored policy, if false the previous comment is deleted instead [github mode]")
+	cmd.Flags().BoolVar(&opts.EmitCommentURL, "emit-comment-url", false,
+		"Print the URL of the posted comment, also written as the posted-comment-url step output when $GITHUB_OUTPUT is set [github mode]")
+	cmd.Flags().StringVar(&opts.CommentTarget, "comment-target", runner.COMMENT_TARGET_PR,
+		"Where the report comment is posted and updated: pr, or issue to track compliance on --issue-number instead of the PR [github mode]")
+	cmd.Flags().IntVar(&opts.IssueNumber, "issue-number", 0,
+		"Tracking issue the report comment is posted on with --comment-target issue [github mode]")
+	cmd.Flags().BoolVar(&opts.CommentPerEnvironment, "comment-per-environment", false,
+		"Post one comment per environment, each with its own marker, instead of a single combined comment, e.g. to gate prod apart from stg [github mode]")
+	cmd.Flags().IntVar(&opts.MaxInlineDiffLines, "max-inline-diff-lines", 0,
+		"Upload the diff of an environment over this number of lines as a workflow artifact linked in the comment instead of inlining it, only the comment length limit applies if 0 [github mode]")
+	cmd.Flags().BoolVar(&opts.OverrideApprovedReviewsOnly, "override-approved-reviews-only", false,
+		"Only honor override commands in the body of approving PR reviews, not of reviews left as comments or requesting changes [github mode]")
+	cmd.Flags().BoolVar(&opts.AssumeYes, "assume-yes", false,
+		"Confirm destructive operations such as deleting the previous comment without prompting, required in non-interactive runs (CI) where they are skipped otherwise [github mode]")
 
 	// Local mode flags
 	cmd.Flags().StringVar(&opts.LcBeforeManifestsPath, "lc-before-manifests-path", "",
 		"Path to before/base services directory [local mode]")
 	cmd.Flags().StringVar(&opts.LcAfterManifestsPath, "lc-after-manifests-path", "",
 		"Path to after/head services directory [local mode]")
+	cmd.Flags().BoolVar(&opts.LcTimestampedReports, "timestamped-reports", false,
+		"Write report-<UTC timestamp>.json/.md, e.g. report-20251001T120000.123456789Z.json, instead of overwriting report.json/.md [local mode]")
+	cmd.Flags().IntVar(&opts.LcMaxReports, "max-reports", 10,
+		"Number of timestamped reports to retain, older ones are pruned [local mode]")
+	cmd.Flags().BoolVar(&opts.LcWatch, "watch", false,
+		"Keep running and re-run build/diff/evaluate on every change of the manifests, policies or templates directories, printing a summary after each run [local mode]")
+	cmd.Flags().BoolVar(&opts.LcSkipEvalUnchanged, "skip-eval-when-unchanged", false,
+		"Skip the policy evaluation of each environment whose base and head manifests are identical, reported as unchanged and not re-evaluated, to focus on the diff review [local mode]")
+	cmd.Flags().StringVar(&opts.LcGitDir, "git-dir", "",
+		"Git checkout holding --lc-after-manifests-path, the --compare-branch is fetched in [local mode]")
+	cmd.Flags().StringVar(&opts.LcCompareBranch, "compare-branch", "",
+		"Branch fetched from origin in --git-dir and checked out as the before manifests instead of --lc-before-manifests-path, e.g. main [local mode]")
 
 	// Mark required flags
 	_ = cmd.MarkFlagRequired("service")
 	_ = cmd.MarkFlagRequired("environments")
 
+	cmd.AddCommand(newEnforcementScheduleCmd())
+	cmd.AddCommand(newInitCmd())
+	cmd.AddCommand(newConfigSchemaCmd())
+	cmd.AddCommand(newListPoliciesCmd())
+	cmd.AddCommand(newPrintManifestCmd(kustomize.NewBuilder()))
+
 	return cmd
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/spf13/cobra"
+)
+
+// newPrintManifestCmd creates the command printing the built manifest of a service, without diff nor policy evaluation
+func newPrintManifestCmd(builder *kustomize.Builder) *cobra.Command {
+	var service, manifestsPath string
+	var environments []string
+
+	cmd := &cobra.Command{
+		Use:   "print-manifest",
+		Short: "Print the built manifest of a service for debugging",
+		Long: `print-manifest builds the overlays of a service with kustomize and prints the manifests to stdout,
+without diff nor policy evaluation. The manifests of several environments are separated by a comment naming them.`,
+		// stdout is the manifest, a build error must not print the usage to it
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return printManifests(cmd, builder, filepath.Join(manifestsPath, service), environments)
+		},
+	}
+
+	cmd.Flags().StringVar(&service, "service", "", "Service name (required)")
+	cmd.Flags().StringSliceVar(&environments, "environments", []string{},
+		"Environments to build (comma-separated, e.g., stg,prod) (required)")
+	cmd.Flags().StringVar(&manifestsPath, "manifests-path", "./services", "Path to services directory")
+	_ = cmd.MarkFlagRequired("service")
+	_ = cmd.MarkFlagRequired("environments")
+
+	return cmd
+}
+
+// printManifests builds the overlay of each environment of the service at path and prints it,
+// a missing overlay is an error instead of an empty manifest
+func printManifests(cmd *cobra.Command, builder *kustomize.Builder, path string, environments []string) error {
+	for _, env := range environments {
+		if !builder.OverlayExists(path, env) {
+			return fmt.Errorf("environment %s has no overlay: %s not found",
+				env, filepath.Join(path, kustomize.KUSTOMIZE_OVERLAY_DIR_NAME, env))
+		}
+	}
+
+	out := cmd.OutOrStdout()
+	for i, env := range environments {
+		manifest, err := builder.Build(cmd.Context(), path, env)
+		if err != nil {
+			return fmt.Errorf("failed to build environment %s: %w", env, err)
+		}
+		if len(environments) > 1 {
+			if i > 0 {
+				fmt.Fprintln(out, "---")
+			}
+			fmt.Fprintf(out, "# Environment: %s\n", env)
+		}
+		if err := writeManifest(out, manifest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeManifest writes the manifest ending with a newline
+func writeManifest(out io.Writer, manifest []byte) error {
+	if _, err := out.Write(manifest); err != nil {
+		return err
+	}
+	if len(manifest) > 0 && !strings.HasSuffix(string(manifest), "\n") {
+		_, err := fmt.Fprintln(out)
+		return err
+	}
+	return nil
+}
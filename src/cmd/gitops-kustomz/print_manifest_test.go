@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+)
+
+// TestPrintManifestCmd tests that the built manifests are printed and a missing overlay is reported
+func TestPrintManifestCmd(t *testing.T) {
+	manifestsPath := t.TempDir()
+	for _, sub := range []string{"base", "environments/stg", "environments/prod"} {
+		dir := filepath.Join(manifestsPath, "my-app", sub)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatalf("failed to write kustomization in %s: %v", dir, err)
+		}
+	}
+	// kustomize prints the overlay name as the manifest
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{Stdout: []byte("kind: ConfigMap\nmetadata:\n  name: " + filepath.Base(args[len(args)-1]) + "\n")}, nil
+		},
+	}
+
+	tests := []struct {
+		name         string
+		environments string
+		want         string
+		wantErr      string
+	}{
+		{
+			name:         "single environment",
+			environments: "prod",
+			want:         "kind: ConfigMap\nmetadata:\n  name: prod\n",
+		},
+		{
+			name:         "several environments",
+			environments: "stg,prod",
+			want: "# Environment: stg\nkind: ConfigMap\nmetadata:\n  name: stg\n" +
+				"---\n# Environment: prod\nkind: ConfigMap\nmetadata:\n  name: prod\n",
+		},
+		{
+			name:         "missing overlay",
+			environments: "stg,dev",
+			wantErr:      "environment dev has no overlay: " + filepath.Join(manifestsPath, "my-app", "environments", "dev") + " not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			cmd := newPrintManifestCmd(kustomize.NewBuilderWithExecutor(fake))
+			cmd.SetOut(&out)
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs([]string{"--service", "my-app", "--environments", tt.environments, "--manifests-path", manifestsPath})
+
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("print-manifest error = %v, want %q", err, tt.wantErr)
+				}
+				if out.Len() != 0 {
+					t.Errorf("print-manifest printed %q, want nothing on error", out.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("print-manifest error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("print-manifest printed\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/gh-nvat/gitops-kustomz/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/github"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/trace"
@@ -28,9 +35,36 @@ func createRunner(ctx context.Context, opts *runner.Options) (runner.RunnerInter
 	logger.WithField("opts", opts).Debug("Creating runner..")
 
 	builder := kustomize.NewBuilder()
-	differ := diff.NewDiffer()
-	evaluator := policy.NewPolicyEvaluator(opts.PoliciesPath)
-	renderer := template.NewRenderer()
+	differ := diff.NewDifferWithOptions(diff.DifferOptions{
+		Tool:             opts.DiffTool,
+		IgnoreWhitespace: opts.DiffIgnoreWhitespace,
+		Format:           opts.DiffFormat,
+		TempPrefix:       tempPrefix(opts),
+		TempExt:          opts.DiffTempExt,
+		ContextLines:     &opts.DiffContextLines,
+	})
+	evaluator := policy.NewPolicyEvaluatorWithOptions(opts.PoliciesPath, policy.EvaluatorOptions{
+		CacheDir:           opts.PolicyCacheDir,
+		ShowPolicySource:   opts.ShowPolicySource,
+		RequireCleanBase:   opts.RequireCleanBase,
+		ReportFixed:        opts.ReportFixed,
+		Backend:            opts.PolicyBackend,
+		OpaURL:             opts.OpaURL,
+		EmptyResultsAsPass: opts.EmptyResultsAsPass,
+		ServiceConfigPath:  serviceConfigPath(opts),
+		BatchConftest:      opts.ConftestBatch,
+		TempPrefix:         tempPrefix(opts),
+		UseRegoSeverity:    opts.UseRegoSeverity,
+		Concurrency:        opts.PolicyConcurrency,
+		FailFast:           opts.FailFast,
+		MinCoverage:        opts.MinPolicyCoverage,
+		BaselinePath:       opts.Baseline,
+	})
+	renderer := template.NewRendererWithOptions(template.RendererOptions{
+		NoEmoji:              opts.NoEmoji,
+		MaxFailMessageLength: opts.MaxFailMessageLength,
+		StrictRender:         opts.StrictRender,
+	})
 
 	switch opts.RunMode {
 	case RUN_MODE_GITHUB:
@@ -57,6 +91,30 @@ func createRunner(ctx context.Context, opts *runner.Options) (runner.RunnerInter
 	}
 }
 
+// tempPrefix returns the prefix of the temp file names, the configured one
+// or "gitops-kustomz-<service>-<run id>-" so stray files of parallel CI runs can be attributed
+func tempPrefix(opts *runner.Options) string {
+	if opts.TempPrefix != "" {
+		return opts.TempPrefix
+	}
+	prefix := "gitops-kustomz-" + opts.Service + "-"
+	if runId := os.Getenv("GITHUB_RUN_ID"); runId != "" {
+		prefix += runId + "-"
+	}
+	return prefix
+}
+
+// serviceConfigPath returns the path of the optional service compliance config, in the service directory.
+// It is read from the trusted tree so a PR cannot loosen its own enforcement:
+// the workflow's checkout in github mode, the before (base) tree in local mode
+func serviceConfigPath(opts *runner.Options) string {
+	root := opts.ManifestsPath
+	if opts.RunMode == RUN_MODE_LOCAL {
+		root = opts.LcBeforeManifestsPath
+	}
+	return filepath.Join(root, opts.Service, policy.COMPLIANCE_CONFIG_FILENAME)
+}
+
 func initialize(ctx context.Context, opts *runner.Options) (runner.RunnerInterface, error) {
 	runner, err := createRunner(ctx, opts)
 	if err != nil {
@@ -74,8 +132,12 @@ func run(ctx context.Context, opts *runner.Options) error {
 		log.SetLevel(log.DebugLevel)
 	}
 
-	// Initialize tracer
-	shutdown, err := trace.InitTracer("gitops-kustomz", opts.EnableExportPerformanceReport, opts.OutputDir)
+	// Initialize tracer, the run metrics are derived from its spans, the performance report is only written if enabled
+	performanceReportDir := ""
+	if opts.EnableExportPerformanceReport {
+		performanceReportDir = opts.OutputDir
+	}
+	shutdown, err := trace.InitTracer("gitops-kustomz", opts.EnableExportPerformanceReport || opts.MetricsFile != "", performanceReportDir, opts.CompressPerformanceReport)
 	if err != nil {
 		return fmt.Errorf("failed to initialize tracer: %w", err)
 	}
@@ -86,18 +148,77 @@ func run(ctx context.Context, opts *runner.Options) error {
 		return fmt.Errorf("invalid options: %w", err)
 	}
 
+	// The before manifests of the compare branch, fixed for the whole run, also in watch mode
+	if opts.LcCompareBranch != "" {
+		beforePath, cleanup, err := runner.CheckoutCompareBranch(ctx, command.NewExecutor(), opts.LcGitDir, opts.LcCompareBranch, opts.LcAfterManifestsPath)
+		if err != nil {
+			return fmt.Errorf("failed to check out compare branch: %w", err)
+		}
+		defer cleanup()
+		opts.LcBeforeManifestsPath = beforePath
+	}
+
+	if opts.LcWatch {
+		return runWatch(ctx, opts, os.Stdout)
+	}
+
+	return runWithTimeout(ctx, opts.Timeout, func(ctx context.Context) error {
+		return processWithRetries(ctx, opts)
+	})
+}
+
+// retryBackoff is the wait before the first retry of a transient error, doubled on each retry, replaced in tests
+var retryBackoff = 5 * time.Second
+
+// processWithRetries processes the service, re-running it up to opts.Retries times on a transient error
+// with exponential backoff, a terminal error is returned at once
+func processWithRetries(ctx context.Context, opts *runner.Options) error {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		_, err := processService(ctx, opts)
+		if err == nil || attempt >= opts.Retries || !github.IsTransientError(err) {
+			return err
+		}
+		logger.WithError(err).WithField("retry", attempt+1).WithField("backoff", backoff).Warn("Transient error, retrying the run")
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// process initializes a runner and processes the service once, returns the runner for its results
+func process(ctx context.Context, opts *runner.Options) (runner.RunnerInterface, error) {
 	// Initialize runner
 	appRunner, err := initialize(ctx, opts)
 	if err != nil {
-		return fmt.Errorf("failed to initialize: %w", err)
+		return nil, fmt.Errorf("failed to initialize: %w", err)
 	}
 
 	err = appRunner.Process()
 	if err != nil {
-		return fmt.Errorf("failed to process: %w", err)
+		return nil, fmt.Errorf("failed to process: %w", err)
 	}
 
-	return nil
+	return appRunner, nil
+}
+
+// runWithTimeout runs fn with a context cancelled after timeout (no deadline if timeout is zero),
+// an error caused by the exceeded deadline is reported as a timeout
+func runWithTimeout(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("run timed out after %s: %w", timeout, err)
+	}
+	return err
 }
 
 func validateOptions(opts *runner.Options) error {
@@ -109,6 +230,93 @@ func validateOptions(opts *runner.Options) error {
 	if len(opts.Environments) == 0 {
 		return fmt.Errorf("at least one environment is required")
 	}
+	if len(opts.Environments) > 1 && slices.Contains(opts.Environments, runner.ENVIRONMENTS_ALL) {
+		return fmt.Errorf("environment %q discovers every overlay and cannot be combined with other environments", runner.ENVIRONMENTS_ALL)
+	}
+
+	if opts.MaxEnvironments < 1 {
+		return fmt.Errorf("max environments must be at least 1, got: %d", opts.MaxEnvironments)
+	}
+	if len(opts.Environments) > opts.MaxEnvironments {
+		return fmt.Errorf("%d environments exceed the maximum of %d, check the --environments list or raise --max-environments",
+			len(opts.Environments), opts.MaxEnvironments)
+	}
+
+	if opts.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative, got: %s", opts.Timeout)
+	}
+
+	if opts.Retries < 0 {
+		return fmt.Errorf("retries must not be negative, got: %d", opts.Retries)
+	}
+
+	if opts.MinPolicyCoverage < 0 || opts.MinPolicyCoverage > 100 {
+		return fmt.Errorf("minimum policy coverage must be between 0 and 100, got: %g", opts.MinPolicyCoverage)
+	}
+
+	if opts.MaxInlineDiffLines < 0 {
+		return fmt.Errorf("max inline diff lines must not be negative, got: %d", opts.MaxInlineDiffLines)
+	}
+
+	if opts.MaxFailMessageLength < 0 {
+		return fmt.Errorf("max fail message length must not be negative, got: %d", opts.MaxFailMessageLength)
+	}
+
+	if _, err := runner.ParseCommentLevels(opts.CommentLevels); err != nil {
+		return err
+	}
+
+	if opts.PolicyConcurrency < 1 {
+		return fmt.Errorf("policy concurrency must be at least 1, got: %d", opts.PolicyConcurrency)
+	}
+
+	if err := manifest.ValidateIgnorePaths(opts.DiffIgnorePaths); err != nil {
+		return err
+	}
+
+	if err := diff.ValidateTempExt(opts.DiffTempExt); err != nil {
+		return err
+	}
+
+	if err := diff.ValidateContextLines(opts.DiffContextLines); err != nil {
+		return err
+	}
+
+	if opts.BuildSplitOutput && opts.DiffTool != "" {
+		return fmt.Errorf("--build-split-output cannot be used with --diff-tool")
+	}
+
+	if _, err := diff.CompileMaskPatterns(opts.DiffMaskPatterns); err != nil {
+		return err
+	}
+
+	if _, err := runner.ParseEnvOverlays(opts.EnvOverlays); err != nil {
+		return err
+	}
+
+	switch opts.DiffFormat {
+	case diff.DIFF_FORMAT_UNIFIED:
+	case diff.DIFF_FORMAT_GIT:
+		if opts.DiffTool != "" {
+			return fmt.Errorf("diff-format %s cannot be used with --diff-tool", diff.DIFF_FORMAT_GIT)
+		}
+	default:
+		return fmt.Errorf("diff-format must be '%s' or '%s', got: %s", diff.DIFF_FORMAT_UNIFIED, diff.DIFF_FORMAT_GIT, opts.DiffFormat)
+	}
+
+	// Validate policy backend
+	switch opts.PolicyBackend {
+	case policy.POLICY_BACKEND_CONFTEST:
+	case policy.POLICY_BACKEND_OPA_SERVER:
+		if opts.OpaURL == "" {
+			return fmt.Errorf("policy-backend %s requires --opa-url", policy.POLICY_BACKEND_OPA_SERVER)
+		}
+		if opts.UseRegoSeverity {
+			return fmt.Errorf("--use-rego-severity cannot be used with policy-backend %s", policy.POLICY_BACKEND_OPA_SERVER)
+		}
+	default:
+		return fmt.Errorf("policy-backend must be '%s' or '%s', got: %s", policy.POLICY_BACKEND_CONFTEST, policy.POLICY_BACKEND_OPA_SERVER, opts.PolicyBackend)
+	}
 
 	// Validate run mode
 	if opts.RunMode != "github" && opts.RunMode != "local" {
@@ -117,16 +325,58 @@ func validateOptions(opts *runner.Options) error {
 
 	// Validate mode-specific options
 	if opts.RunMode == "local" {
-		if opts.LcBeforeManifestsPath == "" || opts.LcAfterManifestsPath == "" {
-			return fmt.Errorf("local mode requires --lc-before-manifests-path and --lc-after-manifests-path")
+		if opts.LcCompareBranch != "" {
+			if opts.LcGitDir == "" {
+				return fmt.Errorf("--compare-branch requires --git-dir")
+			}
+			if opts.LcBeforeManifestsPath != "" {
+				return fmt.Errorf("--compare-branch and --lc-before-manifests-path are mutually exclusive")
+			}
+		} else if opts.LcGitDir != "" {
+			return fmt.Errorf("--git-dir requires --compare-branch")
+		} else if opts.LcBeforeManifestsPath == "" {
+			return fmt.Errorf("local mode requires --lc-before-manifests-path or --compare-branch")
+		}
+		if opts.LcAfterManifestsPath == "" {
+			return fmt.Errorf("local mode requires --lc-after-manifests-path")
+		}
+		if opts.LcTimestampedReports && opts.LcMaxReports < 1 {
+			return fmt.Errorf("max-reports must be at least 1, got: %d", opts.LcMaxReports)
 		}
 	} else {
+		if opts.LcWatch {
+			return fmt.Errorf("--watch is only supported in local mode")
+		}
+		if opts.LcSkipEvalUnchanged {
+			return fmt.Errorf("--skip-eval-when-unchanged is only supported in local mode")
+		}
+		if opts.LcGitDir != "" || opts.LcCompareBranch != "" {
+			return fmt.Errorf("--git-dir and --compare-branch are only supported in local mode")
+		}
 		// GitHub mode
 		if opts.GhRepo == "" {
 			return fmt.Errorf("github mode requires --gh-repo")
 		}
-		if opts.GhPrNumber == 0 {
-			return fmt.Errorf("github mode requires --gh-pr-number")
+		if opts.GhPrNumber == 0 && opts.GhCommit == "" {
+			return fmt.Errorf("github mode requires --gh-pr-number or --gh-commit")
+		}
+		if opts.GhPrNumber != 0 && opts.GhCommit != "" {
+			return fmt.Errorf("--gh-pr-number and --gh-commit are mutually exclusive")
+		}
+		switch opts.CommentTarget {
+		case runner.COMMENT_TARGET_PR:
+			if opts.IssueNumber != 0 {
+				return fmt.Errorf("--issue-number requires --comment-target %s", runner.COMMENT_TARGET_ISSUE)
+			}
+		case runner.COMMENT_TARGET_ISSUE:
+			if opts.IssueNumber <= 0 {
+				return fmt.Errorf("comment-target %s requires --issue-number", runner.COMMENT_TARGET_ISSUE)
+			}
+		default:
+			return fmt.Errorf("comment-target must be '%s' or '%s', got: %s", runner.COMMENT_TARGET_PR, runner.COMMENT_TARGET_ISSUE, opts.CommentTarget)
+		}
+		if opts.DiffBase != runner.DIFF_BASE_MERGE_BASE && opts.DiffBase != runner.DIFF_BASE_BASE_REF {
+			return fmt.Errorf("diff-base must be '%s' or '%s', got: %s", runner.DIFF_BASE_MERGE_BASE, runner.DIFF_BASE_BASE_REF, opts.DiffBase)
 		}
 	}
 
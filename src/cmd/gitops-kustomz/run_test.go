@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+)
+
+// TestRunWithTimeout tests that an exceeded global timeout aborts a stuck external tool
+func TestRunWithTimeout(t *testing.T) {
+	// kustomize hangs until its context is cancelled
+	stuck := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	fn := func(ctx context.Context) error {
+		if _, err := kustomize.NewBuilderWithExecutor(stuck).Version(ctx); err != nil {
+			return fmt.Errorf("failed to initialize: %w", err)
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		fn      func(ctx context.Context) error
+		wantErr string
+	}{
+		{
+			name:    "exceeded timeout",
+			timeout: 10 * time.Millisecond,
+			fn:      fn,
+			wantErr: "run timed out after 10ms",
+		},
+		{
+			name:    "completed within timeout",
+			timeout: time.Minute,
+			fn:      func(ctx context.Context) error { return nil },
+		},
+		{
+			name:    "other error within timeout",
+			timeout: time.Minute,
+			fn:      func(ctx context.Context) error { return fmt.Errorf("boom") },
+			wantErr: "boom",
+		},
+		{
+			name:    "no timeout",
+			timeout: 0,
+			fn: func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); ok {
+					return fmt.Errorf("unexpected deadline")
+				}
+				return nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runWithTimeout(context.Background(), tt.timeout, tt.fn)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("runWithTimeout() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runWithTimeout() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			if strings.HasPrefix(tt.wantErr, "run timed out") && !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("runWithTimeout() error = %v, want wrapping context.DeadlineExceeded", err)
+			}
+		})
+	}
+}
+
+// TestProcessWithRetries tests that a transient error re-runs the process while a terminal error fails at once
+func TestProcessWithRetries(t *testing.T) {
+	origProcess, origBackoff := processService, retryBackoff
+	defer func() { processService, retryBackoff = origProcess, origBackoff }()
+	retryBackoff = time.Millisecond
+
+	transient := fmt.Errorf("failed to initialize: failed to clone: git clone: exit status 128\nStderr: fatal: Could not resolve host: github.com")
+	terminal := fmt.Errorf("failed to initialize: policy ha: name is required")
+
+	tests := []struct {
+		name      string
+		retries   int
+		errs      []error
+		wantRuns  int
+		wantError error
+	}{
+		{
+			name:     "transient error is retried",
+			retries:  2,
+			errs:     []error{transient, nil},
+			wantRuns: 2,
+		},
+		{
+			name:      "config error is not retried",
+			retries:   2,
+			errs:      []error{terminal, nil},
+			wantRuns:  1,
+			wantError: terminal,
+		},
+		{
+			name:      "retries exhausted",
+			retries:   2,
+			errs:      []error{transient, transient, transient, nil},
+			wantRuns:  3,
+			wantError: transient,
+		},
+		{
+			name:      "no retries by default",
+			errs:      []error{transient, nil},
+			wantRuns:  1,
+			wantError: transient,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := 0
+			processService = func(ctx context.Context, opts *runner.Options) (runner.RunnerInterface, error) {
+				err := tt.errs[runs]
+				runs++
+				return nil, err
+			}
+
+			err := processWithRetries(context.Background(), &runner.Options{Retries: tt.retries})
+			if err != tt.wantError {
+				t.Errorf("processWithRetries() error = %v, want %v", err, tt.wantError)
+			}
+			if runs != tt.wantRuns {
+				t.Errorf("processWithRetries() ran %d times, want %d", runs, tt.wantRuns)
+			}
+		})
+	}
+}
+
+// TestValidateOptions_MaxEnvironments tests that an environment list over the cap is rejected, run validates the
+// options before creating the runner so no build starts
+func TestValidateOptions_MaxEnvironments(t *testing.T) {
+	envs := func(n int) []string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("env%d", i+1)
+		}
+		return list
+	}
+
+	tests := []struct {
+		name            string
+		environments    []string
+		maxEnvironments int
+		wantErr         string
+	}{
+		{
+			name:            "within the cap",
+			environments:    envs(2),
+			maxEnvironments: runner.MAX_ENVIRONMENTS_DEFAULT,
+		},
+		{
+			name:            "at the cap",
+			environments:    envs(3),
+			maxEnvironments: 3,
+		},
+		{
+			name:            "over the default cap",
+			environments:    envs(1000),
+			maxEnvironments: runner.MAX_ENVIRONMENTS_DEFAULT,
+			wantErr:         "1000 environments exceed the maximum of 50, check the --environments list or raise --max-environments",
+		},
+		{
+			name:            "invalid cap",
+			environments:    envs(1),
+			maxEnvironments: 0,
+			wantErr:         "max environments must be at least 1, got: 0",
+		},
+		{
+			name:            "all environments",
+			environments:    []string{runner.ENVIRONMENTS_ALL},
+			maxEnvironments: runner.MAX_ENVIRONMENTS_DEFAULT,
+		},
+		{
+			name:            "all combined with other environments",
+			environments:    []string{"stg", runner.ENVIRONMENTS_ALL},
+			maxEnvironments: runner.MAX_ENVIRONMENTS_DEFAULT,
+			wantErr:         `environment "all" discovers every overlay and cannot be combined with other environments`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &runner.Options{
+				RunMode:               RUN_MODE_LOCAL,
+				Service:               "my-app",
+				Environments:          tt.environments,
+				MaxEnvironments:       tt.maxEnvironments,
+				PolicyConcurrency:     policy.POLICY_CONCURRENCY_DEFAULT,
+				DiffFormat:            diff.DIFF_FORMAT_UNIFIED,
+				PolicyBackend:         policy.POLICY_BACKEND_CONFTEST,
+				LcBeforeManifestsPath: "before",
+				LcAfterManifestsPath:  "after",
+			}
+			err := validateOptions(opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateOptions() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateOptions() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestValidateOptions_CompareBranch tests the options of the before manifests checked out from a branch
+func TestValidateOptions_CompareBranch(t *testing.T) {
+	tests := []struct {
+		name          string
+		runMode       string
+		beforePath    string
+		gitDir        string
+		compareBranch string
+		wantErr       string
+	}{
+		{
+			name:          "compare branch",
+			runMode:       RUN_MODE_LOCAL,
+			gitDir:        ".",
+			compareBranch: "main",
+		},
+		{
+			name:       "before manifests path",
+			runMode:    RUN_MODE_LOCAL,
+			beforePath: "before",
+		},
+		{
+			name:    "no before manifests",
+			runMode: RUN_MODE_LOCAL,
+			wantErr: "local mode requires --lc-before-manifests-path or --compare-branch",
+		},
+		{
+			name:          "compare branch without git dir",
+			runMode:       RUN_MODE_LOCAL,
+			compareBranch: "main",
+			wantErr:       "--compare-branch requires --git-dir",
+		},
+		{
+			name:       "git dir without compare branch",
+			runMode:    RUN_MODE_LOCAL,
+			beforePath: "before",
+			gitDir:     ".",
+			wantErr:    "--git-dir requires --compare-branch",
+		},
+		{
+			name:          "compare branch and before manifests path",
+			runMode:       RUN_MODE_LOCAL,
+			beforePath:    "before",
+			gitDir:        ".",
+			compareBranch: "main",
+			wantErr:       "--compare-branch and --lc-before-manifests-path are mutually exclusive",
+		},
+		{
+			name:          "github mode",
+			runMode:       RUN_MODE_GITHUB,
+			gitDir:        ".",
+			compareBranch: "main",
+			wantErr:       "--git-dir and --compare-branch are only supported in local mode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &runner.Options{
+				RunMode:               tt.runMode,
+				Service:               "my-app",
+				Environments:          []string{"stg"},
+				MaxEnvironments:       runner.MAX_ENVIRONMENTS_DEFAULT,
+				PolicyConcurrency:     policy.POLICY_CONCURRENCY_DEFAULT,
+				DiffFormat:            diff.DIFF_FORMAT_UNIFIED,
+				PolicyBackend:         policy.POLICY_BACKEND_CONFTEST,
+				LcBeforeManifestsPath: tt.beforePath,
+				LcAfterManifestsPath:  "after",
+				LcGitDir:              tt.gitDir,
+				LcCompareBranch:       tt.compareBranch,
+			}
+			err := validateOptions(opts)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateOptions() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("validateOptions() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCreateRunner_RunMode tests the runner created per run mode, a missing GitHub token is reported as such
+func TestCreateRunner_RunMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		runMode  string
+		token    string
+		wantType string
+		wantErr  string
+	}{
+		{
+			name:     "github",
+			runMode:  RUN_MODE_GITHUB,
+			token:    "ghp_test",
+			wantType: "*runner.RunnerGitHub",
+		},
+		{
+			name:    "github without token",
+			runMode: RUN_MODE_GITHUB,
+			wantErr: "GitHub authentication failed: GitHub token not found",
+		},
+		{
+			name:     "local",
+			runMode:  RUN_MODE_LOCAL,
+			wantType: "*runner.RunnerLocal",
+		},
+		{
+			name:    "invalid",
+			runMode: "remote",
+			wantErr: "invalid run mode: remote",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GH_TOKEN", tt.token)
+			t.Setenv("GITHUB_TOKEN", "")
+			opts := &runner.Options{
+				RunMode:      tt.runMode,
+				Service:      "my-app",
+				Environments: []string{"stg"},
+				GhRepo:       "owner/repo",
+				GhPrNumber:   1,
+			}
+			got, err := createRunner(context.Background(), opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("createRunner() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("createRunner() error = %v", err)
+			}
+			if gotType := fmt.Sprintf("%T", got); gotType != tt.wantType {
+				t.Errorf("createRunner() = %s, want %s", gotType, tt.wantType)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/spf13/cobra"
+)
+
+// newEnforcementScheduleCmd creates the command listing upcoming enforcement level transitions
+func newEnforcementScheduleCmd() *cobra.Command {
+	var policiesPath string
+
+	cmd := &cobra.Command{
+		Use:   "enforcement-schedule",
+		Short: "List upcoming policy enforcement level transitions",
+		Long: `enforcement-schedule lists which policies move to RECOMMEND, WARNING or BLOCK and when,
+computed from the enforcement dates in compliance-config.yaml without evaluating any manifest.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			evaluator := policy.NewPolicyEvaluator(policiesPath)
+			if err := evaluator.LoadAndValidate(); err != nil {
+				return fmt.Errorf("failed to load policy config: %w", err)
+			}
+			return writeEnforcementSchedule(cmd.OutOrStdout(), evaluator.EnforcementSchedule())
+		},
+	}
+
+	cmd.Flags().StringVar(&policiesPath, "policies-path", "./policies",
+		"Path to policies directory (contains compliance-config.yaml)")
+
+	return cmd
+}
+
+// writeEnforcementSchedule prints the transitions as a table
+func writeEnforcementSchedule(out io.Writer, transitions []models.EnforcementTransition) error {
+	if len(transitions) == 0 {
+		_, err := fmt.Fprintln(out, "No upcoming enforcement transitions.")
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATE\tPOLICY ID\tPOLICY NAME\tLEVEL")
+	for _, t := range transitions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.At.Format("2006-01-02 15:04:05 MST"), t.PolicyId, t.PolicyName, t.Level)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/watch"
+)
+
+// newWatcher creates the watcher of the watched directories, replaced in tests
+var newWatcher = func(roots []string, ignored []string) (watch.Watcher, error) {
+	return watch.NewFsWatcher(roots, ignored)
+}
+
+// processService processes the service once, replaced in tests
+var processService = process
+
+// runWatch processes the service, then again on every change of the manifests, policies or templates, until interrupted.
+// A failed run is reported and the watch goes on, so a half-written file does not end the session
+func runWatch(ctx context.Context, opts *runner.Options, out io.Writer) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	roots := watchedDirs(opts)
+	watcher, err := newWatcher(roots, []string{opts.OutputDir})
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	check := func() {
+		now := time.Now()
+		err := runWithTimeout(ctx, opts.Timeout, func(ctx context.Context) error {
+			appRunner, err := processService(ctx, opts)
+			if err != nil {
+				return err
+			}
+			if localRunner, ok := appRunner.(*runner.RunnerLocal); ok && localRunner.LastReport() != nil {
+				writeWatchSummary(out, now, localRunner.LastReport(), opts.OutputDir)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(out, "[%s] run failed: %v\n", now.Format(time.TimeOnly), err)
+		}
+	}
+
+	check()
+	fmt.Fprintf(out, "Watching %s for changes, press Ctrl+C to stop\n", strings.Join(roots, ", "))
+	return watch.Run(ctx, watcher, watch.DEFAULT_DEBOUNCE, check)
+}
+
+// watchedDirs returns the distinct directories whose changes trigger a run
+func watchedDirs(opts *runner.Options) []string {
+	dirs := []string{}
+	seen := make(map[string]bool)
+	for _, dir := range []string{opts.LcBeforeManifestsPath, opts.LcAfterManifestsPath, opts.PoliciesPath, opts.TemplatesPath} {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// writeWatchSummary writes a short summary of the report per environment, e.g. "stg: 4/5 policies passing, 1 failed (1 blocking)"
+func writeWatchSummary(out io.Writer, at time.Time, data *models.ReportData, outputDir string) {
+	fmt.Fprintf(out, "[%s] %s: report written to %s\n", at.Format(time.TimeOnly), data.Service, outputDir)
+	if data.ManifestsUnchanged {
+		fmt.Fprintln(out, "  no manifest changes, policy evaluation skipped")
+		return
+	}
+	for _, env := range data.Environments {
+		summary, ok := data.PolicyEvaluation.EnvironmentSummary[env]
+		if !ok {
+			continue
+		}
+		if summary.Unchanged {
+			fmt.Fprintf(out, "  %s: unchanged, not re-evaluated\n", env)
+			continue
+		}
+		counts := summary.PolicyCounts
+		fmt.Fprintf(out, "  %s: %d/%d policies passing, %d failed (%d blocking), %d omitted\n",
+			env, counts.TotalSuccess, counts.TotalCount, counts.TotalFailed, counts.BlockingFailedCount, counts.TotalOmitted)
+	}
+}
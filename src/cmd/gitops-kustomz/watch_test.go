@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/runner"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/watch"
+)
+
+// fakeWatcher reports the paths sent on its events channel
+type fakeWatcher struct {
+	events chan string
+	errors chan error
+}
+
+func (w *fakeWatcher) Events() <-chan string { return w.events }
+func (w *fakeWatcher) Errors() <-chan error  { return w.errors }
+func (w *fakeWatcher) Close() error          { return nil }
+
+// syncBuffer is a bytes.Buffer safe to read while the watch loop writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestRunWatch tests that a simulated file change re-runs the checks, a failed run does not end the watch
+func TestRunWatch(t *testing.T) {
+	watcher := &fakeWatcher{events: make(chan string), errors: make(chan error)}
+	var gotRoots, gotIgnored []string
+	origWatcher, origProcess := newWatcher, processService
+	defer func() { newWatcher, processService = origWatcher, origProcess }()
+	newWatcher = func(roots []string, ignored []string) (watch.Watcher, error) {
+		gotRoots, gotIgnored = roots, ignored
+		return watcher, nil
+	}
+	runs := make(chan int, 10)
+	count := 0
+	processService = func(ctx context.Context, opts *runner.Options) (runner.RunnerInterface, error) {
+		count++
+		runs <- count
+		if count == 1 {
+			return nil, fmt.Errorf("invalid kustomization")
+		}
+		return nil, nil
+	}
+
+	opts := &runner.Options{
+		Service:               "my-app",
+		LcBeforeManifestsPath: "before",
+		LcAfterManifestsPath:  "after",
+		PoliciesPath:          "policies",
+		TemplatesPath:         "policies",
+		OutputDir:             "output",
+	}
+	out := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- runWatch(ctx, opts, out) }()
+
+	waitRun := func(want int) {
+		t.Helper()
+		select {
+		case got := <-runs:
+			if got != want {
+				t.Fatalf("runWatch() run %d, want %d", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("runWatch() did not run %d times", want)
+		}
+	}
+	waitRun(1)
+	watcher.events <- "after/my-app/base/deployment.yaml"
+	waitRun(2)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("runWatch() error = %v", err)
+	}
+
+	if want := "before,after,policies"; strings.Join(gotRoots, ",") != want {
+		t.Errorf("runWatch() watched %v, want %s", gotRoots, want)
+	}
+	if len(gotIgnored) != 1 || gotIgnored[0] != "output" {
+		t.Errorf("runWatch() ignored %v, want [output]", gotIgnored)
+	}
+	if !strings.Contains(out.String(), "run failed: invalid kustomization") {
+		t.Errorf("runWatch() output = %q, want the failed run reported", out.String())
+	}
+}
+
+// TestWriteWatchSummary tests the summary printed after each run in watch mode
+func TestWriteWatchSummary(t *testing.T) {
+	at := time.Date(2025, 6, 1, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		data *models.ReportData
+		want string
+	}{
+		{
+			name: "evaluated",
+			data: &models.ReportData{
+				Service:      "my-app",
+				Environments: []string{"stg", "prod"},
+				PolicyEvaluation: models.PolicyEvaluation{
+					EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{
+						"stg":  {PolicyCounts: models.PolicyCounts{TotalCount: 5, TotalSuccess: 4, TotalFailed: 1, BlockingFailedCount: 1}},
+						"prod": {PolicyCounts: models.PolicyCounts{TotalCount: 5, TotalSuccess: 5}},
+					},
+				},
+			},
+			want: "[15:04:05] my-app: report written to output\n" +
+				"  stg: 4/5 policies passing, 1 failed (1 blocking), 0 omitted\n" +
+				"  prod: 5/5 policies passing, 0 failed (0 blocking), 0 omitted\n",
+		},
+		{
+			name: "unchanged",
+			data: &models.ReportData{Service: "my-app", Environments: []string{"stg"}, ManifestsUnchanged: true},
+			want: "[15:04:05] my-app: report written to output\n" +
+				"  no manifest changes, policy evaluation skipped\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeWatchSummary(&out, at, tt.data, "output")
+			if out.String() != tt.want {
+				t.Errorf("writeWatchSummary() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -1,15 +1,22 @@
 package runner
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/logctx"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
@@ -34,6 +41,15 @@ type RunnerBase struct {
 	Renderer  *template.Renderer
 
 	Instance RunnerInterface
+
+	// non-fatal issues met while processing, surfaced in the report notes
+	warnings []string
+
+	// when the runner was created, the run metrics only count the spans started since
+	startedAt time.Time
+
+	// environments of the run, the overlays discovered by BuildManifests with ENVIRONMENTS_ALL
+	environments []string
 }
 
 // make RunnerLocal implement RunnerInterface
@@ -48,13 +64,14 @@ func NewRunnerBase(
 	renderer *template.Renderer,
 ) (*RunnerBase, error) {
 	runner := &RunnerBase{
-		Context:   ctx,
+		Context:   logctx.WithFields(ctx, log.Fields{"service": options.Service}),
 		Options:   options,
 		RunMode:   options.RunMode,
 		Builder:   builder,
 		Differ:    differ,
 		Evaluator: evaluator,
 		Renderer:  renderer,
+		startedAt: time.Now(),
 	}
 	return runner, nil
 }
@@ -73,42 +90,190 @@ func (r *RunnerBase) Initialize() error {
 	if err != nil {
 		return fmt.Errorf("failed to load policy config: %w", err)
 	}
+	if err := r.Evaluator.CheckCoverage(r.Context); err != nil {
+		return fmt.Errorf("failed to check policy test coverage: %w", err)
+	}
+
+	if err := r.checkToolVersions(); err != nil {
+		return err
+	}
+
+	if err := r.Evaluator.DetectCacheVersion(r.Context); err != nil {
+		return fmt.Errorf("failed to detect the policy engine version: %w", err)
+	}
+
+	if err := r.Differ.ValidateTool(); err != nil {
+		return err
+	}
 
 	logger.Info("Initalize runner: done.")
 	return nil
 }
 
+// toolVersionCheck is an installed tool version to compare with the expected one
+type toolVersionCheck struct {
+	tool     string
+	expected string
+	version  func(ctx context.Context) (string, error)
+}
+
+// checkToolVersions compares the installed kustomize and conftest versions with the expected ones if set,
+// a mismatch fails the run with StrictToolVersions and is only warned about otherwise
+func (r *RunnerBase) checkToolVersions() error {
+	checks := []toolVersionCheck{
+		{tool: "kustomize", expected: r.Options.ExpectedKustomizeVersion, version: r.Builder.Version},
+	}
+	if r.Options.PolicyBackend != policy.POLICY_BACKEND_OPA_SERVER {
+		checks = append(checks, toolVersionCheck{tool: "conftest", expected: r.Options.ExpectedConftestVersion, version: r.Evaluator.ConftestVersion})
+	}
+
+	for _, check := range checks {
+		if check.expected == "" {
+			continue
+		}
+		version, err := check.version(r.Context)
+		if err != nil {
+			return err
+		}
+		lg := logger.WithField("tool", check.tool).WithField("version", version).WithField("expected", check.expected)
+		if command.VersionMatches(version, check.expected) {
+			lg.Info("Tool version matches the expected one")
+			continue
+		}
+		if r.Options.StrictToolVersions {
+			return fmt.Errorf("%s version %s does not match the expected version %s", check.tool, version, check.expected)
+		}
+		r.AddWarning("%s version %s does not match the expected version %s, results may differ", check.tool, version, check.expected)
+	}
+	return nil
+}
+
+// AddWarning records a non-fatal issue from any stage, it is logged and surfaced in the report notes
+func (r *RunnerBase) AddWarning(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logger.Warn(msg)
+	r.warnings = append(r.warnings, msg)
+}
+
+// Warnings returns the recorded warnings in order, nil if there are none
+func (r *RunnerBase) Warnings() []string {
+	return r.warnings
+}
+
+// envLogger returns the context of the work on an environment and its logger, both carrying the service and
+// environment log fields so the interleaved logs of different environments stay attributable
+func (r *RunnerBase) envLogger(ctx context.Context, env string) (context.Context, *log.Entry) {
+	ctx = logctx.WithFields(ctx, log.Fields{"service": r.Options.Service, "environment": env})
+	return ctx, logctx.Entry(ctx, logger)
+}
+
 func (r *RunnerBase) BuildManifests(beforePath, afterPath string) (*models.BuildManifestResult, error) {
 	ctx, span := trace.StartSpan(r.Context, "BuildManifests")
 	defer span.End()
 
 	logger.Info("BuildManifests: starting...")
 
+	envs, err := r.resolveEnvironments(afterPath)
+	if err != nil {
+		return nil, err
+	}
+	r.environments = envs
+
 	results := make(map[string]models.BuildEnvManifestResult)
-	envs := r.Options.Environments
 	for _, env := range envs {
 		envCtx, envSpan := trace.StartSpan(ctx, fmt.Sprintf("BuildManifests.%s", env))
+		envCtx, lg := r.envLogger(envCtx, env)
+
+		// A missing overlay means the service is not deployed to the environment on that side, it builds as empty
+		overlays, err := r.overlaysOf(env)
+		if err != nil {
+			envSpan.End()
+			return nil, err
+		}
+		beforeExists, afterExists := r.anyOverlayExists(beforePath, overlays), r.anyOverlayExists(afterPath, overlays)
+		switch {
+		case !beforeExists && !afterExists:
+			r.AddWarning("Environment %s has no overlay on the base nor the head, skipped", env)
+		case !beforeExists:
+			r.AddWarning("Environment %s has no overlay on the base, the head is diffed against an empty manifest", env)
+		case !afterExists:
+			r.AddWarning("Environment %s has no overlay on the head, it is diffed as removed and no policy is evaluated", env)
+		}
 
-		logger.WithField("env", env).WithField("beforePath", beforePath).Info("Building before manifest...")
-		beforeManifest, err := r.Builder.Build(envCtx, beforePath, env)
+		var beforeManifest, afterManifest []byte
+		var beforeResources, afterResources map[string][]byte
+		var afterWarnings []string
+		if beforeExists {
+			lg.WithField("beforePath", beforePath).Info("Building before manifest...")
+			if r.Options.BuildSplitOutput {
+				beforeResources, _, err = r.buildOverlayResources(envCtx, beforePath, overlays)
+			} else {
+				beforeManifest, _, err = r.buildOverlays(envCtx, beforePath, overlays)
+			}
+			if err != nil {
+				envSpan.End()
+				return nil, err
+			}
+		}
+		if r.Options.BuildSplitOutput {
+			beforeResources, err = r.filterResources(env, beforeResources)
+			beforeManifest = manifest.JoinResources(beforeResources)
+		} else {
+			beforeManifest, err = r.filterManifest(env, beforeManifest)
+		}
 		if err != nil {
 			envSpan.End()
 			return nil, err
 		}
 
-		logger.WithField("env", env).WithField("afterPath", afterPath).Info("Building after manifest...")
-		afterManifest, err := r.Builder.Build(envCtx, afterPath, env)
+		if afterExists {
+			lg.WithField("afterPath", afterPath).Info("Building after manifest...")
+			if r.Options.BuildSplitOutput {
+				afterResources, afterWarnings, err = r.buildOverlayResources(envCtx, afterPath, overlays)
+				afterManifest = manifest.JoinResources(afterResources)
+			} else {
+				afterManifest, afterWarnings, err = r.buildOverlays(envCtx, afterPath, overlays)
+			}
+			if err != nil {
+				envSpan.End()
+				return nil, err
+			}
+		}
+		overlayPath := ""
+		if afterExists {
+			overlayPath, err = r.overlayPathOf(afterPath, overlays)
+		} else if beforeExists {
+			overlayPath, err = r.overlayPathOf(beforePath, overlays)
+		}
+		if err != nil {
+			envSpan.End()
+			return nil, err
+		}
+		if err := r.validateManifest(env, afterManifest); err != nil {
+			envSpan.End()
+			return nil, err
+		}
+		if r.Options.BuildSplitOutput {
+			afterResources, err = r.filterResources(env, afterResources)
+			afterManifest = manifest.JoinResources(afterResources)
+		} else {
+			afterManifest, err = r.filterManifest(env, afterManifest)
+		}
 		if err != nil {
 			envSpan.End()
 			return nil, err
 		}
 		results[env] = models.BuildEnvManifestResult{
-			Environment:    env,
-			BeforeManifest: beforeManifest,
-			AfterManifest:  afterManifest,
+			Environment:     env,
+			BeforeManifest:  beforeManifest,
+			AfterManifest:   afterManifest,
+			OverlayPath:     overlayPath,
+			AfterWarnings:   afterWarnings,
+			BeforeResources: beforeResources,
+			AfterResources:  afterResources,
 		}
-		logger.WithField("env", env).WithField("beforeManifest", string(beforeManifest)).Debug("Built Manifest")
-		logger.WithField("env", env).WithField("afterManifest", string(afterManifest)).Debug("Built Manifest")
+		lg.WithField("beforeManifest", string(beforeManifest)).Debug("Built Manifest")
+		lg.WithField("afterManifest", string(afterManifest)).Debug("Built Manifest")
 
 		envSpan.End()
 	}
@@ -116,36 +281,278 @@ func (r *RunnerBase) BuildManifests(beforePath, afterPath string) (*models.Build
 	logger.Info("BuildManifests: done.")
 	return &models.BuildManifestResult{
 		EnvManifestBuild: results,
+		Environments:     envs,
 	}, nil
 }
 
+// resolveEnvironments returns the configured environments, or with ENVIRONMENTS_ALL the overlays of the service
+// at afterPath, the head, capped by MaxEnvironments
+func (r *RunnerBase) resolveEnvironments(afterPath string) ([]string, error) {
+	if !IsAllEnvironments(r.Options.Environments) {
+		return r.Options.Environments, nil
+	}
+	overlays, err := r.Builder.ListOverlays(afterPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover environments: %w", err)
+	}
+	if len(overlays) == 0 {
+		return nil, fmt.Errorf("no overlay found in '%s' for --environments %s", afterPath, ENVIRONMENTS_ALL)
+	}
+	if len(overlays) > r.Options.MaxEnvironments {
+		return nil, fmt.Errorf("%d discovered environments exceed the maximum of %d, raise --max-environments or list the environments",
+			len(overlays), r.Options.MaxEnvironments)
+	}
+	logger.WithField("environments", overlays).Info("Discovered environments")
+	return overlays, nil
+}
+
+// IsAllEnvironments reports whether envs asks for every overlay of the service, i.e. is ENVIRONMENTS_ALL
+func IsAllEnvironments(envs []string) bool {
+	return len(envs) == 1 && envs[0] == ENVIRONMENTS_ALL
+}
+
+// Environments returns the environments of the run, the discovered ones with ENVIRONMENTS_ALL once built
+func (r *RunnerBase) Environments() []string {
+	if r.environments != nil {
+		return r.environments
+	}
+	return r.Options.Environments
+}
+
+// overlaysOf returns the overlays whose outputs form the manifest of env, the overlay named env if not configured
+func (r *RunnerBase) overlaysOf(env string) ([]string, error) {
+	envOverlays, err := ParseEnvOverlays(r.Options.EnvOverlays)
+	if err != nil {
+		return nil, err
+	}
+	if overlays, ok := envOverlays[env]; ok {
+		return overlays, nil
+	}
+	return []string{env}, nil
+}
+
+// anyOverlayExists returns true if one of the overlays exists under path
+func (r *RunnerBase) anyOverlayExists(path string, overlays []string) bool {
+	for _, overlay := range overlays {
+		if r.Builder.OverlayExists(path, overlay) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlayPathOf returns the paths of the overlays existing under path, comma-separated, for reviewers to check
+// the right overlays were built
+func (r *RunnerBase) overlayPathOf(path string, overlays []string) (string, error) {
+	paths := []string{}
+	for _, overlay := range overlays {
+		if !r.Builder.OverlayExists(path, overlay) {
+			continue
+		}
+		overlayPath, err := r.Builder.OverlayPath(path, overlay)
+		if err != nil {
+			return "", err
+		}
+		paths = append(paths, overlayPath)
+	}
+	return strings.Join(paths, ","), nil
+}
+
+// buildOverlays builds the overlays existing under path and concatenates their outputs into one manifest,
+// so policies see resources spread over several overlays (e.g. an app and its CRDs) together
+func (r *RunnerBase) buildOverlays(ctx context.Context, path string, overlays []string) ([]byte, []string, error) {
+	if len(overlays) == 1 {
+		return r.Builder.BuildWithWarnings(ctx, path, overlays[0])
+	}
+
+	documents := []string{}
+	warnings := []string{}
+	for _, overlay := range overlays {
+		if !r.Builder.OverlayExists(path, overlay) {
+			logctx.Entry(ctx, logger).WithField("path", path).WithField("overlay", overlay).Info("Overlay not found, skipped")
+			continue
+		}
+		built, overlayWarnings, err := r.Builder.BuildWithWarnings(ctx, path, overlay)
+		if err != nil {
+			return nil, nil, fmt.Errorf("overlay %s: %w", overlay, err)
+		}
+		documents = append(documents, manifest.SplitDocuments(built)...)
+		warnings = append(warnings, overlayWarnings...)
+	}
+	return manifest.JoinDocuments(documents), warnings, nil
+}
+
+// buildOverlayResources is like buildOverlays with a split build, one file per resource. The files of several
+// overlays are prefixed with the overlay name, kustomize names them after the resource only
+func (r *RunnerBase) buildOverlayResources(ctx context.Context, path string, overlays []string) (map[string][]byte, []string, error) {
+	resources := map[string][]byte{}
+	warnings := []string{}
+	for _, overlay := range overlays {
+		if !r.Builder.OverlayExists(path, overlay) {
+			logctx.Entry(ctx, logger).WithField("path", path).WithField("overlay", overlay).Info("Overlay not found, skipped")
+			continue
+		}
+		built, overlayWarnings, err := r.Builder.BuildResources(ctx, path, overlay)
+		if err != nil {
+			if len(overlays) == 1 {
+				return nil, nil, err
+			}
+			return nil, nil, fmt.Errorf("overlay %s: %w", overlay, err)
+		}
+		for name, content := range built {
+			if len(overlays) > 1 {
+				name = overlay + "/" + name
+			}
+			resources[name] = content
+		}
+		warnings = append(warnings, overlayWarnings...)
+	}
+	return resources, warnings, nil
+}
+
+// evaluationSkipped returns true if the policy evaluation can be skipped as enabled by the SkipUnchanged option,
+// the base and head manifests of every environment being identical
+func (r *RunnerBase) evaluationSkipped(result *models.BuildManifestResult) bool {
+	if !r.Options.SkipUnchanged {
+		return false
+	}
+	for _, envResult := range result.EnvManifestBuild {
+		if !bytes.Equal(envResult.BeforeManifest, envResult.AfterManifest) {
+			return false
+		}
+	}
+	logger.Info("Base and head manifests are identical for every environment, skipping the policy evaluation")
+	return true
+}
+
+// BuildWarnings returns the kustomize warnings of the after manifests per environment, nil if there are none
+func (r *RunnerBase) BuildWarnings(result *models.BuildManifestResult) map[string][]string {
+	var warnings map[string][]string
+	for env, envResult := range result.EnvManifestBuild {
+		if len(envResult.AfterWarnings) == 0 {
+			continue
+		}
+		if warnings == nil {
+			warnings = make(map[string][]string)
+		}
+		warnings[env] = envResult.AfterWarnings
+	}
+	return warnings
+}
+
+// validateManifest checks the built manifest of an environment according to the options
+// In strict YAML mode, documents with duplicate keys are rejected as policies could miss them
+func (r *RunnerBase) validateManifest(env string, builtManifest []byte) error {
+	if !r.Options.StrictYaml {
+		return nil
+	}
+	if err := manifest.ValidateNoDuplicateKeys(builtManifest); err != nil {
+		return fmt.Errorf("environment %s: strict YAML validation failed: %w", env, err)
+	}
+	return nil
+}
+
+// filterManifest drops the resource kinds not selected by the include/exclude kinds options,
+// so they are neither diffed nor evaluated
+func (r *RunnerBase) filterManifest(env string, builtManifest []byte) ([]byte, error) {
+	filtered, err := manifest.FilterKinds(builtManifest, r.Options.IncludeKinds, r.Options.ExcludeKinds)
+	if err != nil {
+		return nil, fmt.Errorf("environment %s: failed to filter manifest kinds: %w", env, err)
+	}
+	return filtered, nil
+}
+
+// filterResources is like filterManifest for the files of a split build, a file left empty is dropped
+func (r *RunnerBase) filterResources(env string, resources map[string][]byte) (map[string][]byte, error) {
+	if resources == nil {
+		return nil, nil
+	}
+	filtered := make(map[string][]byte, len(resources))
+	for name, content := range resources {
+		content, err := r.filterManifest(env, content)
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(content)) > 0 {
+			filtered[name] = content
+		}
+	}
+	return filtered, nil
+}
+
 func (r *RunnerBase) DiffManifests(result *models.BuildManifestResult) (map[string]models.EnvironmentDiff, error) {
 	ctx, span := trace.StartSpan(r.Context, "DiffManifests")
 	defer span.End()
 
 	logger.Info("DiffManifests: starting...")
 
+	maskPatterns, err := diff.CompileMaskPatterns(r.Options.DiffMaskPatterns)
+	if err != nil {
+		return nil, err
+	}
+
 	results := make(map[string]models.EnvironmentDiff)
 
 	for env, envResult := range result.EnvManifestBuild {
-		_, envSpan := trace.StartSpan(ctx, fmt.Sprintf("DiffManifests.%s", env))
-
-		diffContent, err := r.Differ.Diff(envResult.BeforeManifest, envResult.AfterManifest)
+		envCtx, envSpan := trace.StartSpan(ctx, fmt.Sprintf("DiffManifests.%s", env))
+		_, lg := r.envLogger(envCtx, env)
+
+		var before, after []byte
+		var diffContent string
+		if envResult.BeforeResources != nil || envResult.AfterResources != nil {
+			// split build, diffed file by file, the manifests are only used for the resource stats
+			var beforeResources, afterResources map[string][]byte
+			beforeResources, afterResources, err = r.normalizeResourcesForDiff(env, envResult.BeforeResources, envResult.AfterResources)
+			if err == nil {
+				before, after = manifest.JoinResources(beforeResources), manifest.JoinResources(afterResources)
+				diffContent, err = r.Differ.DiffResources(beforeResources, afterResources)
+			}
+		} else {
+			before, after, err = r.normalizeForDiff(env, envResult.BeforeManifest, envResult.AfterManifest)
+			if err == nil {
+				diffContent, err = r.Differ.Diff(before, after)
+			}
+		}
 		if err != nil {
-			logger.WithField("env", envResult.Environment).WithField("error", err).Error("Failed to diff manifests")
+			lg.WithField("error", err).Error("Failed to diff manifests")
 			envSpan.End()
 			return nil, err
 		}
-		logger.WithField("env", envResult.Environment).WithField("diffContent", diffContent).Debug("Diffed Manifest")
-
 		addedLines, deletedLines, totalLines := diff.CalcLineChangesFromDiffContent(diffContent)
-		results[env] = models.EnvironmentDiff{
+		resourceStats := diff.CalcResourceStats(before, after, diffContent)
+		var perResource map[string]string
+		if r.Options.DiffPerResource {
+			perResource, err = r.Differ.DiffPerResource(before, after)
+			if err != nil {
+				envSpan.End()
+				return nil, fmt.Errorf("environment %s: failed to diff manifests per resource: %w", env, err)
+			}
+		}
+
+		// sensitive values are masked before the diff is logged, rendered or uploaded
+		diffContent = diff.MaskContent(diffContent, maskPatterns)
+		for id, resourceDiff := range perResource {
+			perResource[id] = diff.MaskContent(resourceDiff, maskPatterns)
+		}
+		lg.WithField("diffContent", diffContent).Debug("Diffed Manifest")
+
+		envDiff := models.EnvironmentDiff{
 			ContentType:      models.DiffContentTypeText,
 			LineCount:        totalLines,
 			AddedLineCount:   addedLines,
 			DeletedLineCount: deletedLines,
 			Content:          diffContent,
+			ResourceStats:    resourceStats,
+			OverlayPath:      envResult.OverlayPath,
+			PerResource:      perResource,
 		}
+		if slices.Contains(r.Options.NoDiffEnvs, env) {
+			lg.Info("Diff content suppressed for the environment")
+			envDiff.ContentType = models.DiffContentTypeSuppressed
+			envDiff.Content = r.Options.NoDiffLink
+			envDiff.PerResource = nil
+		}
+		results[env] = envDiff
 
 		envSpan.End()
 	}
@@ -154,6 +561,124 @@ func (r *RunnerBase) DiffManifests(result *models.BuildManifestResult) (map[stri
 	return results, nil
 }
 
+// normalizeForDiff neutralizes the hash suffix of kustomize generated names on both sides if enabled,
+// so a generator change only diffs on the changed data, removes the ignored fields, and sorts the lists of the
+// unordered fields so a reordering alone does not diff. Policies still evaluate the built manifests
+func (r *RunnerBase) normalizeForDiff(env string, before, after []byte) ([]byte, []byte, error) {
+	if r.Options.NormalizeGeneratedNames {
+		var err error
+		before, err = manifest.NormalizeGeneratedNames(before)
+		if err != nil {
+			return nil, nil, fmt.Errorf("environment %s: failed to normalize generated names of the base manifest: %w", env, err)
+		}
+		after, err = manifest.NormalizeGeneratedNames(after)
+		if err != nil {
+			return nil, nil, fmt.Errorf("environment %s: failed to normalize generated names of the head manifest: %w", env, err)
+		}
+	}
+	if len(r.Options.DiffIgnorePaths) > 0 {
+		var err error
+		before, err = manifest.StripPaths(before, r.Options.DiffIgnorePaths)
+		if err != nil {
+			return nil, nil, fmt.Errorf("environment %s: failed to remove ignored fields of the base manifest: %w", env, err)
+		}
+		after, err = manifest.StripPaths(after, r.Options.DiffIgnorePaths)
+		if err != nil {
+			return nil, nil, fmt.Errorf("environment %s: failed to remove ignored fields of the head manifest: %w", env, err)
+		}
+	}
+	if len(r.Options.DiffUnorderedFields) > 0 {
+		var err error
+		before, err = manifest.SortUnorderedFields(before, r.Options.DiffUnorderedFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("environment %s: failed to sort unordered fields of the base manifest: %w", env, err)
+		}
+		after, err = manifest.SortUnorderedFields(after, r.Options.DiffUnorderedFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("environment %s: failed to sort unordered fields of the head manifest: %w", env, err)
+		}
+	}
+	return before, after, nil
+}
+
+// normalizeResourcesForDiff is like normalizeForDiff for the files of split builds, a generated name is normalized
+// in every file of its side referencing it
+func (r *RunnerBase) normalizeResourcesForDiff(env string, before, after map[string][]byte) (map[string][]byte, map[string][]byte, error) {
+	normalize := func(side string, resources map[string][]byte) (map[string][]byte, error) {
+		var err error
+		if r.Options.NormalizeGeneratedNames {
+			resources, err = manifest.NormalizeGeneratedNamesOfResources(resources)
+			if err != nil {
+				return nil, fmt.Errorf("environment %s: failed to normalize generated names of the %s resources: %w", env, side, err)
+			}
+		}
+		if len(r.Options.DiffIgnorePaths) == 0 && len(r.Options.DiffUnorderedFields) == 0 {
+			return resources, nil
+		}
+		normalized := make(map[string][]byte, len(resources))
+		for name, content := range resources {
+			content, err = manifest.StripPaths(content, r.Options.DiffIgnorePaths)
+			if err != nil {
+				return nil, fmt.Errorf("environment %s: failed to remove ignored fields of the %s resource %s: %w", env, side, name, err)
+			}
+			normalized[name], err = manifest.SortUnorderedFields(content, r.Options.DiffUnorderedFields)
+			if err != nil {
+				return nil, fmt.Errorf("environment %s: failed to sort unordered fields of the %s resource %s: %w", env, side, name, err)
+			}
+		}
+		return normalized, nil
+	}
+	before, err := normalize("base", before)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err = normalize("head", after)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// writeReportMarkdown writes the rendered markdown report to fileName in the output directory,
+// gzip-compressed if fileName ends with fileutil.GZIP_EXT
+func (r *RunnerBase) writeReportMarkdown(fileName, renderedMarkdown string) error {
+	if err := os.MkdirAll(r.Options.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filePath := filepath.Join(r.Options.OutputDir, fileName)
+	if err := fileutil.WriteOutputFile(filePath, []byte(renderedMarkdown), 0644); err != nil {
+		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write markdown report to file")
+		return err
+	}
+
+	logger.WithField("filePath", filePath).Info("Written markdown report to file")
+	return nil
+}
+
+// FullManifests returns the full head manifest per environment if enabled, nil otherwise
+func (r *RunnerBase) FullManifests(result *models.BuildManifestResult) (map[string]models.FullManifest, error) {
+	if !r.Options.IncludeFullManifest {
+		return nil, nil
+	}
+	maskPatterns, err := diff.CompileMaskPatterns(r.Options.DiffMaskPatterns)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]models.FullManifest)
+	for env, envResult := range result.EnvManifestBuild {
+		if slices.Contains(r.Options.NoDiffEnvs, env) {
+			continue // the full manifest would show what the suppressed diff hides
+		}
+		// masked like the diff, the full manifest is rendered and uploaded as well
+		results[env] = models.FullManifest{
+			ContentType: models.DiffContentTypeText,
+			Content:     diff.MaskManifest(string(envResult.AfterManifest), maskPatterns),
+		}
+	}
+	return results, nil
+}
+
 func (r *RunnerBase) EvaluatePolicies(mf *models.BuildManifestResult) (*models.PolicyEvaluateResult, error) {
 	ctx, span := trace.StartSpan(r.Context, "EvaluatePolicies")
 	defer span.End()
@@ -202,7 +727,12 @@ func (r *RunnerBase) Process() error {
 	}
 	logger.WithField("results", diffs).Debug("Diffed Manifests")
 
-	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(r.Context, *rs, []string{})
+	fullManifests, err := r.FullManifests(rs)
+	if err != nil {
+		return err
+	}
+
+	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(r.Context, *rs, []*models.Comment{})
 	if err != nil {
 		return err
 	}
@@ -213,9 +743,12 @@ func (r *RunnerBase) Process() error {
 		Timestamp:        time.Now(),
 		BaseCommit:       "base",
 		HeadCommit:       "head",
-		Environments:     r.Options.Environments,
+		Environments:     r.Environments(),
+		BuildWarnings:    r.BuildWarnings(rs),
+		Warnings:         r.Warnings(),
 		ManifestChanges:  diffs,
 		PolicyEvaluation: *policyEval,
+		FullManifests:    fullManifests,
 	}
 
 	if err := r.Output(&reportData); err != nil {
@@ -232,6 +765,12 @@ func (r *RunnerBase) Output(data *models.ReportData) error {
 	if err := r.outputReportJson(data); err != nil {
 		return err
 	}
+	if err := r.exportPolicyMatrixCSV(data); err != nil {
+		return err
+	}
+	if err := r.exportMetrics(data); err != nil {
+		return err
+	}
 	logger.Info("Output: done.")
 	return nil
 }
@@ -252,8 +791,8 @@ func (r *RunnerBase) outputReportJson(data *models.ReportData) error {
 	if err != nil {
 		return err
 	}
-	filePath := filepath.Join(r.Options.OutputDir, "report.json")
-	if err := os.WriteFile(filePath, resultsJson, 0644); err != nil {
+	filePath := filepath.Join(r.Options.OutputDir, "report"+fileutil.OutputExt(".json", r.Options.CompressReport))
+	if err := fileutil.WriteOutputFile(filePath, resultsJson, 0644); err != nil {
 		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
 		return err
 	}
@@ -0,0 +1,1071 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
+)
+
+const duplicateKeyManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 3
+  replicas: 1
+`
+
+// TestRunnerBase_validateManifest tests strict and lenient YAML handling of built manifests
+func TestRunnerBase_validateManifest(t *testing.T) {
+	tests := []struct {
+		name       string
+		strictYaml bool
+		wantErr    bool
+	}{
+		{
+			name:       "lenient mode accepts duplicate keys",
+			strictYaml: false,
+			wantErr:    false,
+		},
+		{
+			name:       "strict mode rejects duplicate keys",
+			strictYaml: true,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RunnerBase{Options: &Options{StrictYaml: tt.strictYaml}}
+			err := r.validateManifest("prod", []byte(duplicateKeyManifest))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "environment prod") {
+				t.Errorf("validateManifest() error = %v, want error mentioning the environment", err)
+			}
+		})
+	}
+}
+
+// newTestServiceDir creates a service directory with a base and the given overlays, returns its path
+func newTestServiceDir(t *testing.T, overlays ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	subDirs := []string{kustomize.KUSTOMIZE_BASE_DIR}
+	for _, overlay := range overlays {
+		subDirs = append(subDirs, filepath.Join(kustomize.KUSTOMIZE_OVERLAY_DIR_NAME, overlay))
+	}
+	for _, sub := range subDirs {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", sub, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, sub, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatalf("failed to write kustomization in %s: %v", sub, err)
+		}
+	}
+	return dir
+}
+
+// newFakeKustomizeExecutor returns an executor building beforeManifest for paths under beforeDir, afterManifest otherwise
+func newFakeKustomizeExecutor(beforeDir, beforeManifest, afterManifest string) *testutil.FakeExecutor {
+	return &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if strings.HasPrefix(args[len(args)-1], beforeDir) {
+				return &command.Result{Stdout: []byte(beforeManifest)}, nil
+			}
+			return &command.Result{Stdout: []byte(afterManifest)}, nil
+		},
+	}
+}
+
+// TestRunnerBase_BuildManifests_KindFilters tests that filtered kinds are neither diffed nor evaluated
+func TestRunnerBase_BuildManifests_KindFilters(t *testing.T) {
+	const before = `kind: ConfigMap
+metadata:
+  name: my-app-config
+data:
+  key: old
+---
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 2
+`
+	const after = `kind: ConfigMap
+metadata:
+  name: my-app-config
+data:
+  key: new
+---
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 3
+`
+	tests := []struct {
+		name         string
+		includeKinds []string
+		excludeKinds []string
+		wantPresent  []string
+		wantAbsent   []string
+	}{
+		{
+			name:        "no filter",
+			wantPresent: []string{"ConfigMap", "Deployment"},
+		},
+		{
+			name:         "exclude ConfigMap",
+			excludeKinds: []string{"ConfigMap"},
+			wantPresent:  []string{"Deployment"},
+			wantAbsent:   []string{"ConfigMap"},
+		},
+		{
+			name:         "include Deployment",
+			includeKinds: []string{"Deployment"},
+			wantPresent:  []string{"Deployment"},
+			wantAbsent:   []string{"ConfigMap"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beforeDir := newTestServiceDir(t, "stg")
+			afterDir := newTestServiceDir(t, "stg")
+			r := &RunnerBase{
+				Context: context.Background(),
+				Options: &Options{
+					Environments: []string{"stg"},
+					IncludeKinds: tt.includeKinds,
+					ExcludeKinds: tt.excludeKinds,
+				},
+				Builder: kustomize.NewBuilderWithExecutor(newFakeKustomizeExecutor(beforeDir, before, after)),
+				Differ:  diff.NewDiffer(),
+			}
+
+			rs, err := r.BuildManifests(beforeDir, afterDir)
+			if err != nil {
+				t.Fatalf("BuildManifests() error = %v", err)
+			}
+			diffs, err := r.DiffManifests(rs)
+			if err != nil {
+				t.Fatalf("DiffManifests() error = %v", err)
+			}
+
+			evalInput := string(rs.EnvManifestBuild["stg"].AfterManifest)
+			diffContent := diffs["stg"].Content
+			for _, kind := range tt.wantPresent {
+				if !strings.Contains(evalInput, "kind: "+kind) {
+					t.Errorf("evaluated manifest should contain %s:\n%s", kind, evalInput)
+				}
+			}
+			for _, kind := range tt.wantAbsent {
+				if strings.Contains(evalInput, "kind: "+kind) {
+					t.Errorf("evaluated manifest should not contain %s:\n%s", kind, evalInput)
+				}
+				if strings.Contains(diffContent, kind) {
+					t.Errorf("diff should not contain %s:\n%s", kind, diffContent)
+				}
+			}
+			if !strings.Contains(diffContent, "+  replicas: 3") {
+				t.Errorf("diff should contain the Deployment change:\n%s", diffContent)
+			}
+		})
+	}
+}
+
+// TestRunnerBase_DiffManifests_IgnorePaths tests that changes of the ignored fields alone do not diff
+func TestRunnerBase_DiffManifests_IgnorePaths(t *testing.T) {
+	deployment := func(checksum, replicas string) string {
+		return "kind: Deployment\nmetadata:\n  annotations:\n    checksum/config: " + checksum +
+			"\n  name: my-app\nspec:\n  replicas: " + replicas + "\n"
+	}
+	tests := []struct {
+		name          string
+		paths         []string
+		after         string
+		wantLineCount int
+	}{
+		{name: "checksum change without ignored path", after: deployment("def456", "2"), wantLineCount: 2},
+		{name: "checksum change", paths: []string{`metadata.annotations."checksum/*"`}, after: deployment("def456", "2"), wantLineCount: 0},
+		{name: "checksum and replicas change", paths: []string{`metadata.annotations."checksum/*"`}, after: deployment("def456", "3"), wantLineCount: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &RunnerBase{
+				Context: context.Background(),
+				Options: &Options{DiffIgnorePaths: tt.paths},
+				Differ:  diff.NewDiffer(),
+			}
+			diffs, err := r.DiffManifests(&models.BuildManifestResult{
+				EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+					"stg": {BeforeManifest: []byte(deployment("abc123", "2")), AfterManifest: []byte(tt.after)},
+				},
+			})
+			if err != nil {
+				t.Fatalf("DiffManifests() error = %v", err)
+			}
+			stg := diffs["stg"]
+			if stg.LineCount != tt.wantLineCount {
+				t.Errorf("DiffManifests() LineCount = %d, want %d, diff:\n%s", stg.LineCount, tt.wantLineCount, stg.Content)
+			}
+			if len(tt.paths) > 0 && strings.Contains(stg.Content, "checksum") {
+				t.Errorf("DiffManifests() diff =\n%s\nwant the ignored field left out", stg.Content)
+			}
+		})
+	}
+}
+
+// TestRunnerBase_DiffManifests_UnorderedFields tests that a reordering of an unordered field alone does not diff
+func TestRunnerBase_DiffManifests_UnorderedFields(t *testing.T) {
+	deployment := func(env string) string {
+		return `kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - env:
+` + env + `        name: my-app
+`
+	}
+	before := deployment(`        - name: LOG_LEVEL
+          value: info
+        - name: PORT
+          value: "8080"
+`)
+	tests := []struct {
+		name          string
+		fields        []string
+		after         string
+		wantLineCount int
+		wantContains  string
+	}{
+		{
+			name: "reordered without unordered field",
+			after: deployment(`        - name: PORT
+          value: "8080"
+        - name: LOG_LEVEL
+          value: info
+`),
+			wantLineCount: 4,
+		},
+		{
+			name:   "reordered",
+			fields: []string{"env"},
+			after: deployment(`        - name: PORT
+          value: "8080"
+        - name: LOG_LEVEL
+          value: info
+`),
+			wantLineCount: 0,
+		},
+		{
+			name:   "reordered and value change",
+			fields: []string{"env"},
+			after: deployment(`        - name: PORT
+          value: "9090"
+        - name: LOG_LEVEL
+          value: info
+`),
+			wantLineCount: 2,
+			wantContains:  `+          value: "9090"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beforeDir := newTestServiceDir(t, "stg")
+			afterDir := newTestServiceDir(t, "stg")
+			r := &RunnerBase{
+				Context: context.Background(),
+				Options: &Options{
+					Environments:        []string{"stg"},
+					DiffUnorderedFields: tt.fields,
+				},
+				Builder: kustomize.NewBuilderWithExecutor(newFakeKustomizeExecutor(beforeDir, before, tt.after)),
+				Differ:  diff.NewDiffer(),
+			}
+
+			rs, err := r.BuildManifests(beforeDir, afterDir)
+			if err != nil {
+				t.Fatalf("BuildManifests() error = %v", err)
+			}
+			diffs, err := r.DiffManifests(rs)
+			if err != nil {
+				t.Fatalf("DiffManifests() error = %v", err)
+			}
+
+			got := diffs["stg"]
+			if got.LineCount != tt.wantLineCount {
+				t.Errorf("DiffManifests() LineCount = %d, want %d:\n%s", got.LineCount, tt.wantLineCount, got.Content)
+			}
+			if tt.wantContains != "" && !strings.Contains(got.Content, tt.wantContains) {
+				t.Errorf("DiffManifests() should contain %q:\n%s", tt.wantContains, got.Content)
+			}
+		})
+	}
+}
+
+// TestRunnerBase_DiffManifests_NormalizeGeneratedNames tests that a generated hash change alone does not diff
+func TestRunnerBase_DiffManifests_NormalizeGeneratedNames(t *testing.T) {
+	generated := func(hash, logLevel string) string {
+		return `kind: ConfigMap
+metadata:
+  name: my-app-config-` + hash + `
+data:
+  LOG_LEVEL: ` + logLevel + `
+---
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - envFrom:
+        - configMapRef:
+            name: my-app-config-` + hash + `
+`
+	}
+	tests := []struct {
+		name          string
+		normalize     bool
+		after         string
+		wantLineCount int
+		wantContains  string
+	}{
+		{
+			name:          "hash-only change without normalization",
+			after:         generated("b2c4d6f8gk", "info"),
+			wantLineCount: 4,
+			wantContains:  "+            name: my-app-config-b2c4d6f8gk",
+		},
+		{
+			name:          "hash-only change",
+			normalize:     true,
+			after:         generated("b2c4d6f8gk", "info"),
+			wantLineCount: 0,
+		},
+		{
+			name:          "data change",
+			normalize:     true,
+			after:         generated("b2c4d6f8gk", "debug"),
+			wantLineCount: 2,
+			wantContains:  "+  LOG_LEVEL: debug",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beforeDir := newTestServiceDir(t, "stg")
+			afterDir := newTestServiceDir(t, "stg")
+			r := &RunnerBase{
+				Context: context.Background(),
+				Options: &Options{
+					Environments:            []string{"stg"},
+					NormalizeGeneratedNames: tt.normalize,
+				},
+				Builder: kustomize.NewBuilderWithExecutor(newFakeKustomizeExecutor(beforeDir, generated("5t8f9k2h6m", "info"), tt.after)),
+				Differ:  diff.NewDiffer(),
+			}
+
+			rs, err := r.BuildManifests(beforeDir, afterDir)
+			if err != nil {
+				t.Fatalf("BuildManifests() error = %v", err)
+			}
+			diffs, err := r.DiffManifests(rs)
+			if err != nil {
+				t.Fatalf("DiffManifests() error = %v", err)
+			}
+
+			got := diffs["stg"]
+			if got.LineCount != tt.wantLineCount {
+				t.Errorf("DiffManifests() LineCount = %d, want %d:\n%s", got.LineCount, tt.wantLineCount, got.Content)
+			}
+			if tt.wantContains != "" && !strings.Contains(got.Content, tt.wantContains) {
+				t.Errorf("DiffManifests() should contain %q:\n%s", tt.wantContains, got.Content)
+			}
+			if !strings.Contains(string(rs.EnvManifestBuild["stg"].AfterManifest), "my-app-config-b2c4d6f8gk") {
+				t.Errorf("evaluated manifest should keep the generated names")
+			}
+		})
+	}
+}
+
+// newFakeSplitKustomizeExecutor returns an executor writing the before files to the -o directory for paths under
+// beforeDir, the after files otherwise
+func newFakeSplitKustomizeExecutor(beforeDir string, beforeFiles, afterFiles map[string]string) *testutil.FakeExecutor {
+	return &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			files := afterFiles
+			if strings.HasPrefix(args[len(args)-1], beforeDir) {
+				files = beforeFiles
+			}
+			for file, content := range files {
+				if err := os.WriteFile(filepath.Join(args[2], file), []byte(content), 0644); err != nil {
+					return nil, err
+				}
+			}
+			return &command.Result{}, nil
+		},
+	}
+}
+
+// TestRunnerBase_DiffManifests_SplitOutput tests that the files of a split build are diffed file by file,
+// generated names normalized across files
+func TestRunnerBase_DiffManifests_SplitOutput(t *testing.T) {
+	configMap := func(hash, logLevel string) string {
+		return "kind: ConfigMap\nmetadata:\n  name: my-app-config-" + hash + "\ndata:\n  LOG_LEVEL: " + logLevel + "\n"
+	}
+	deployment := func(hash string, replicas string) string {
+		return "kind: Deployment\nmetadata:\n  name: my-app\nspec:\n  replicas: " + replicas +
+			"\n  template:\n    spec:\n      containers:\n      - envFrom:\n        - configMapRef:\n            name: my-app-config-" + hash + "\n"
+	}
+	beforeFiles := map[string]string{
+		"configmap_my-app-config-5t8f9k2h6m.yaml": configMap("5t8f9k2h6m", "info"),
+		"deployment_my-app.yaml":                  deployment("5t8f9k2h6m", "2"),
+		"service_my-app.yaml":                     "kind: Service\nmetadata:\n  name: my-app\n",
+	}
+	afterFiles := map[string]string{
+		"configmap_my-app-config-b2c4d6f8gk.yaml": configMap("b2c4d6f8gk", "info"),
+		"deployment_my-app.yaml":                  deployment("b2c4d6f8gk", "3"),
+		"service_my-app.yaml":                     "kind: Service\nmetadata:\n  name: my-app\n",
+	}
+
+	beforeDir := newTestServiceDir(t, "stg")
+	afterDir := newTestServiceDir(t, "stg")
+	r := &RunnerBase{
+		Context: context.Background(),
+		Options: &Options{
+			Environments:            []string{"stg"},
+			NormalizeGeneratedNames: true,
+			BuildSplitOutput:        true,
+		},
+		Builder: kustomize.NewBuilderWithExecutor(newFakeSplitKustomizeExecutor(beforeDir, beforeFiles, afterFiles)),
+		Differ:  diff.NewDiffer(),
+	}
+
+	rs, err := r.BuildManifests(beforeDir, afterDir)
+	if err != nil {
+		t.Fatalf("BuildManifests() error = %v", err)
+	}
+	if got := len(rs.EnvManifestBuild["stg"].AfterResources); got != len(afterFiles) {
+		t.Errorf("BuildManifests() built %d resource files, want %d", got, len(afterFiles))
+	}
+	if after := string(rs.EnvManifestBuild["stg"].AfterManifest); !strings.Contains(after, "kind: Service") || !strings.Contains(after, "my-app-config-b2c4d6f8gk") {
+		t.Errorf("BuildManifests() AfterManifest should join the resource files with their generated names:\n%s", after)
+	}
+
+	diffs, err := r.DiffManifests(rs)
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	got := diffs["stg"]
+	// only the replicas change, the renamed ConfigMap is the same resource once normalized
+	if got.LineCount != 2 {
+		t.Errorf("DiffManifests() LineCount = %d, want 2:\n%s", got.LineCount, got.Content)
+	}
+	if n := strings.Count(got.Content, "diff --git "); n != 1 || !strings.Contains(got.Content, "diff --git a/Deployment/my-app.yaml b/Deployment/my-app.yaml") {
+		t.Errorf("DiffManifests() should diff the Deployment file only:\n%s", got.Content)
+	}
+	if !strings.Contains(got.Content, "+  replicas: 3") {
+		t.Errorf("DiffManifests() should contain the replicas change:\n%s", got.Content)
+	}
+}
+
+// newFakeVersionExecutor returns an executor answering the version commands of kustomize and conftest
+func newFakeVersionExecutor(kustomizeVersion, conftestVersion string) *testutil.FakeExecutor {
+	return &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if name == "kustomize" {
+				return &command.Result{Stdout: []byte(kustomizeVersion + "\n")}, nil
+			}
+			return &command.Result{Stdout: []byte("Conftest: " + conftestVersion + "\nOPA: 0.69.0\n")}, nil
+		},
+	}
+}
+
+// TestRunnerBase_checkToolVersions tests matching and mismatching tool versions in warn and strict modes
+func TestRunnerBase_checkToolVersions(t *testing.T) {
+	tests := []struct {
+		name              string
+		expectedKustomize string
+		expectedConftest  string
+		strict            bool
+		wantErr           string
+		wantCalls         int
+	}{
+		{
+			name:      "not pinned",
+			wantCalls: 0,
+		},
+		{
+			name:              "matching versions",
+			expectedKustomize: "v5.4.3",
+			expectedConftest:  "0.56",
+			strict:            true,
+			wantCalls:         2,
+		},
+		{
+			name:              "mismatching version is only warned",
+			expectedKustomize: "5.3.0",
+			expectedConftest:  "0.56.0",
+			wantCalls:         2,
+		},
+		{
+			name:             "mismatching version fails in strict mode",
+			expectedConftest: "0.50.0",
+			strict:           true,
+			wantErr:          "conftest version 0.56.0 does not match the expected version 0.50.0",
+			wantCalls:        1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := newFakeVersionExecutor("v5.4.3", "0.56.0")
+			evaluator := policy.NewPolicyEvaluator("")
+			evaluator.SetExecutor(executor)
+			r := &RunnerBase{
+				Context: context.Background(),
+				Options: &Options{
+					ExpectedKustomizeVersion: tt.expectedKustomize,
+					ExpectedConftestVersion:  tt.expectedConftest,
+					StrictToolVersions:       tt.strict,
+				},
+				Builder:   kustomize.NewBuilderWithExecutor(executor),
+				Evaluator: evaluator,
+			}
+
+			err := r.checkToolVersions()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("checkToolVersions() error = %v, want error containing %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("checkToolVersions() error = %v", err)
+			}
+			if len(executor.Calls()) != tt.wantCalls {
+				t.Errorf("checkToolVersions() ran %d commands, want %d", len(executor.Calls()), tt.wantCalls)
+			}
+		})
+	}
+}
+
+// TestRunnerBase_DiffManifests_MaskPatterns tests that masked values never reach the diff content, with unchanged line counts
+func TestRunnerBase_DiffManifests_MaskPatterns(t *testing.T) {
+	result := &models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {
+				Environment:    "stg",
+				BeforeManifest: []byte("kind: Secret\nstringData:\n  token: tok_old123\n  user: app\n"),
+				AfterManifest:  []byte("kind: Secret\nstringData:\n  token: tok_new456\n  user: app\n"),
+			},
+		},
+	}
+
+	diffWith := func(patterns []string) models.EnvironmentDiff {
+		t.Helper()
+		r := &RunnerBase{
+			Context: context.Background(),
+			Options: &Options{DiffMaskPatterns: patterns},
+			Differ:  diff.NewDiffer(),
+		}
+		got, err := r.DiffManifests(result)
+		if err != nil {
+			t.Fatalf("DiffManifests() error = %v", err)
+		}
+		return got["stg"]
+	}
+
+	plain := diffWith(nil)
+	masked := diffWith([]string{`tok_[a-z0-9]+`})
+
+	if strings.Contains(masked.Content, "tok_old123") || strings.Contains(masked.Content, "tok_new456") {
+		t.Errorf("DiffManifests() content = %q, want the tokens masked", masked.Content)
+	}
+	for _, want := range []string{"-  token: ***", "+  token: ***", "   user: app"} {
+		if !strings.Contains(masked.Content, want) {
+			t.Errorf("DiffManifests() content = %q, want it to contain %q", masked.Content, want)
+		}
+	}
+	if masked.LineCount != plain.LineCount || masked.AddedLineCount != plain.AddedLineCount || masked.DeletedLineCount != plain.DeletedLineCount {
+		t.Errorf("DiffManifests() masked line counts = %d/+%d/-%d, want %d/+%d/-%d", masked.LineCount, masked.AddedLineCount, masked.DeletedLineCount, plain.LineCount, plain.AddedLineCount, plain.DeletedLineCount)
+	}
+}
+
+// TestRunnerBase_FullManifests_MaskPatterns tests that masked values never reach the full manifest either
+func TestRunnerBase_FullManifests_MaskPatterns(t *testing.T) {
+	result := &models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {
+				Environment:    "stg",
+				BeforeManifest: []byte("kind: Secret\nstringData:\n  token: tok_old123\n"),
+				AfterManifest:  []byte("kind: Secret\nstringData:\n  token: tok_new456\n"),
+			},
+		},
+	}
+	r := &RunnerBase{
+		Context: context.Background(),
+		Options: &Options{
+			DiffMaskPatterns:    []string{`tok_[a-z0-9]+`},
+			IncludeFullManifest: true,
+		},
+		Differ: diff.NewDiffer(),
+	}
+
+	got, err := r.FullManifests(result)
+	if err != nil {
+		t.Fatalf("FullManifests() error = %v", err)
+	}
+	want := "kind: Secret\nstringData:\n  token: ***\n"
+	if got["stg"].Content != want {
+		t.Errorf("FullManifests() stg content = %q, want %q", got["stg"].Content, want)
+	}
+}
+
+// TestRunnerBase_DiffManifests_NoDiffEnvs tests that the diff body of a suppressed environment is left out of the
+// report, with its line counts kept and the optional link shown instead, while other environments keep theirs
+func TestRunnerBase_DiffManifests_NoDiffEnvs(t *testing.T) {
+	manifests := func(env string) models.BuildEnvManifestResult {
+		return models.BuildEnvManifestResult{
+			Environment:    env,
+			BeforeManifest: []byte("kind: Secret\nstringData:\n  password: " + env + "-old\n"),
+			AfterManifest:  []byte("kind: Secret\nstringData:\n  password: " + env + "-new\n"),
+		}
+	}
+	result := &models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{"stg": manifests("stg"), "prod": manifests("prod")},
+	}
+	r := &RunnerBase{
+		Context: context.Background(),
+		Options: &Options{
+			NoDiffEnvs:          []string{"prod"},
+			NoDiffLink:          "https://vault.example.com/diffs",
+			IncludeFullManifest: true,
+		},
+		Differ: diff.NewDiffer(),
+	}
+
+	diffs, err := r.DiffManifests(result)
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	fullManifests, err := r.FullManifests(result)
+	if err != nil {
+		t.Fatalf("FullManifests() error = %v", err)
+	}
+
+	prod, stg := diffs["prod"], diffs["stg"]
+	if prod.ContentType != models.DiffContentTypeSuppressed || prod.Content != "https://vault.example.com/diffs" {
+		t.Errorf("DiffManifests() prod = %s %q, want the suppressed diff linked", prod.ContentType, prod.Content)
+	}
+	if prod.AddedLineCount != 1 || prod.DeletedLineCount != 1 {
+		t.Errorf("DiffManifests() prod line counts = +%d/-%d, want +1/-1", prod.AddedLineCount, prod.DeletedLineCount)
+	}
+	if stg.ContentType != models.DiffContentTypeText || !strings.Contains(stg.Content, "+  password: stg-new") {
+		t.Errorf("DiffManifests() stg = %s %q, want the diff content", stg.ContentType, stg.Content)
+	}
+	if _, ok := fullManifests["prod"]; ok {
+		t.Error("FullManifests() includes prod, want it left out with its diff")
+	}
+	if _, ok := fullManifests["stg"]; !ok {
+		t.Error("FullManifests() misses stg")
+	}
+
+	rendered, err := template.NewRenderer().RenderWithTemplates("../../templates", &models.ReportData{
+		Service:         "my-app",
+		Environments:    []string{"stg", "prod"},
+		ManifestChanges: diffs,
+	})
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	for _, want := range []string{"+  password: stg-new", "Diff not displayed for this environment.", "[here](https://vault.example.com/diffs)"} {
+		if !strings.Contains(rendered, want) {
+			t.Errorf("rendered report misses %q:\n%s", want, rendered)
+		}
+	}
+	if strings.Contains(rendered, "prod-new") {
+		t.Errorf("rendered report shows the prod diff:\n%s", rendered)
+	}
+}
+
+// TestRunnerBase_DiffManifests_PerResource tests the masked diff of each changed resource, left out of a suppressed diff
+func TestRunnerBase_DiffManifests_PerResource(t *testing.T) {
+	const before = "kind: Deployment\nmetadata:\n  name: my-app\nspec:\n  replicas: 2\n---\nkind: Secret\nmetadata:\n  name: my-secret\nstringData:\n  password: old\n"
+	const after = "kind: Deployment\nmetadata:\n  name: my-app\nspec:\n  replicas: 3\n---\nkind: Secret\nmetadata:\n  name: my-secret\nstringData:\n  password: new\n---\nkind: Service\nmetadata:\n  name: my-app\n"
+	build := models.BuildEnvManifestResult{BeforeManifest: []byte(before), AfterManifest: []byte(after)}
+	r := &RunnerBase{
+		Context: context.Background(),
+		Options: &Options{
+			DiffPerResource:  true,
+			DiffMaskPatterns: []string{`password: .*`},
+			NoDiffEnvs:       []string{"prod"},
+		},
+		Differ: diff.NewDiffer(),
+	}
+
+	diffs, err := r.DiffManifests(&models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{"stg": build, "prod": build},
+	})
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+
+	stg := diffs["stg"].PerResource
+	for _, id := range []string{"Deployment/my-app", "Secret/my-secret", "Service/my-app"} {
+		if _, ok := stg[id]; !ok {
+			t.Errorf("DiffManifests() stg PerResource misses %s: %v", id, stg)
+		}
+	}
+	if len(stg) != 3 {
+		t.Errorf("DiffManifests() stg PerResource = %v, want the 3 changed resources", stg)
+	}
+	if !strings.Contains(stg["Deployment/my-app"], "+  replicas: 3") || strings.Contains(stg["Deployment/my-app"], "Secret") {
+		t.Errorf("DiffManifests() Deployment diff = %q, want its own change only", stg["Deployment/my-app"])
+	}
+	if strings.Contains(stg["Secret/my-secret"], "new") {
+		t.Errorf("DiffManifests() Secret diff = %q, want the password masked", stg["Secret/my-secret"])
+	}
+	if diffs["prod"].PerResource != nil {
+		t.Errorf("DiffManifests() prod PerResource = %v, want none for a suppressed diff", diffs["prod"].PerResource)
+	}
+}
+
+// TestRunnerBase_BuildManifests_AllEnvironments tests that "all" expands to the overlays present on the head, capped
+// by the maximum number of environments
+func TestRunnerBase_BuildManifests_AllEnvironments(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxEnvironments int
+		wantEnvs        []string
+		wantErr         string
+	}{
+		{
+			name:            "overlays of the head",
+			maxEnvironments: MAX_ENVIRONMENTS_DEFAULT,
+			wantEnvs:        []string{"prod", "sandbox", "stg"},
+		},
+		{
+			name:            "over the cap",
+			maxEnvironments: 2,
+			wantErr:         "3 discovered environments exceed the maximum of 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beforeDir := newTestServiceDir(t, "stg", "prod")
+			afterDir := newTestServiceDir(t, "stg", "prod", "sandbox")
+			// not an overlay
+			if err := os.WriteFile(filepath.Join(afterDir, kustomize.KUSTOMIZE_OVERLAY_DIR_NAME, "README.md"), []byte("# overlays\n"), 0644); err != nil {
+				t.Fatalf("failed to write README.md: %v", err)
+			}
+			r := &RunnerBase{
+				Context: context.Background(),
+				Options: &Options{Environments: []string{ENVIRONMENTS_ALL}, MaxEnvironments: tt.maxEnvironments},
+				Builder: kustomize.NewBuilderWithExecutor(newFakeKustomizeExecutor(beforeDir, "kind: Deployment\n", "kind: Deployment\n")),
+			}
+
+			rs, err := r.BuildManifests(beforeDir, afterDir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("BuildManifests() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildManifests() error = %v", err)
+			}
+			var built []string
+			for env := range rs.EnvManifestBuild {
+				built = append(built, env)
+			}
+			slices.Sort(built)
+			if !slices.Equal(built, tt.wantEnvs) {
+				t.Errorf("BuildManifests() built %v, want %v", built, tt.wantEnvs)
+			}
+			if got := r.Environments(); !slices.Equal(got, tt.wantEnvs) {
+				t.Errorf("Environments() = %v, want %v", got, tt.wantEnvs)
+			}
+		})
+	}
+}
+
+// TestParseEnvOverlays tests the parsing of the environment overlays option
+func TestParseEnvOverlays(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name:   "several environments",
+			values: []string{"stg=stg,stg-crds", "prod = prod , prod-crds"},
+			want:   map[string][]string{"stg": {"stg", "stg-crds"}, "prod": {"prod", "prod-crds"}},
+		},
+		{
+			name:    "missing environment",
+			values:  []string{"stg,stg-crds"},
+			wantErr: "expected env=overlay1,overlay2",
+		},
+		{
+			name:    "no overlay",
+			values:  []string{"stg= ,"},
+			wantErr: "no overlay set",
+		},
+		{
+			name:    "environment set twice",
+			values:  []string{"stg=stg", "stg=stg-crds"},
+			wantErr: "more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseEnvOverlays(tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseEnvOverlays() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseEnvOverlays() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEnvOverlays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newTestPoliciesDir writes a policies directory with the given compliance config and an empty
+// <name>.rego / trivial <name>_test.rego pair per policy file name, returns the directory path
+func newTestPoliciesDir(t *testing.T, complianceConfig string, policyNames ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{policy.COMPLIANCE_CONFIG_FILENAME: complianceConfig}
+	for _, name := range policyNames {
+		files[name+".rego"] = "package main\n"
+		files[name+"_test.rego"] = "package main\n\ntest_" + name + " if { true }\n"
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestRunnerBase_BuildManifests_MultipleOverlays tests that the overlays of an environment are concatenated
+// before evaluation, so a policy needing resources of both overlays passes
+func TestRunnerBase_BuildManifests_MultipleOverlays(t *testing.T) {
+	const appManifest = `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+`
+	const crdsManifest = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+`
+	const complianceConfig = `policies:
+  widget-crd:
+    name: Widget CRD
+    type: opa
+    filePath: widget.rego
+    enforcement:
+      isBlockingAfter: 2000-01-01T00:00:00Z
+`
+	policiesDir := newTestPoliciesDir(t, complianceConfig, "widget")
+
+	// kustomize builds each overlay, conftest stands for a policy denying widgets without their CRD in the manifest
+	executor := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if name == "kustomize" {
+				if filepath.Base(args[len(args)-1]) == "stg-crds" {
+					return &command.Result{Stdout: []byte(crdsManifest)}, nil
+				}
+				return &command.Result{Stdout: []byte(appManifest)}, nil
+			}
+			manifestPath := args[slices.Index(args, "--policy")+2]
+			content, err := os.ReadFile(manifestPath)
+			if err != nil {
+				return nil, err
+			}
+			failures := `[]`
+			if !strings.Contains(string(content), "kind: CustomResourceDefinition") {
+				failures = `[{"msg": "Widget my-widget has no CRD"}]`
+			}
+			return &command.Result{Stdout: []byte(`[{"filename": "Combined", "namespace": "main", "failures": ` + failures + `}]`)}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		envOverlays []string
+		wantFail    bool
+	}{
+		{
+			name:     "single overlay misses the CRD",
+			wantFail: true,
+		},
+		{
+			name:        "concatenated overlays",
+			envOverlays: []string{"stg=stg,stg-crds"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beforeDir := newTestServiceDir(t, "stg", "stg-crds")
+			afterDir := newTestServiceDir(t, "stg", "stg-crds")
+			evaluator := policy.NewPolicyEvaluator(policiesDir)
+			evaluator.SetExecutor(executor)
+			if err := evaluator.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			r := &RunnerBase{
+				Context:   context.Background(),
+				Options:   &Options{Environments: []string{"stg"}, EnvOverlays: tt.envOverlays},
+				Builder:   kustomize.NewBuilderWithExecutor(executor),
+				Evaluator: evaluator,
+			}
+
+			rs, err := r.BuildManifests(beforeDir, afterDir)
+			if err != nil {
+				t.Fatalf("BuildManifests() error = %v", err)
+			}
+			afterManifest := string(rs.EnvManifestBuild["stg"].AfterManifest)
+			if !tt.wantFail && afterManifest != appManifest+"---\n"+crdsManifest {
+				t.Errorf("after manifest = %q, want the app and CRDs overlays concatenated", afterManifest)
+			}
+
+			results, err := evaluator.Evaluate(context.Background(), rs.EnvManifestBuild["stg"].AfterManifest)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if gotFail := len(results["widget-crd"]) > 0; gotFail != tt.wantFail {
+				t.Errorf("policy widget-crd failing = %v (%v), want %v", gotFail, results["widget-crd"], tt.wantFail)
+			}
+		})
+	}
+}
+
+// TestRunnerBase_CustomResources tests that a custom resource flows through the build, the diff and the policy
+// evaluation like a built-in kind: passed as is to conftest, and identified by its group, kind and name
+func TestRunnerBase_CustomResources(t *testing.T) {
+	const before = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  replicas: 2
+---
+apiVersion: keda.sh/v1alpha1
+kind: ScaledObject
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  scaleTargetRef:
+    name: my-app
+  maxReplicaCount: 10
+`
+	after := strings.Replace(before, "maxReplicaCount: 10", "maxReplicaCount: 200", 1)
+	const complianceConfig = `policies:
+  keda-max-replicas:
+    name: KEDA Max Replicas
+    type: opa
+    filePath: keda.rego
+    scope: namespaced
+    enforcement:
+      isBlockingAfter: 2000-01-01T00:00:00Z
+`
+	beforeDir := newTestServiceDir(t, "stg")
+	afterDir := newTestServiceDir(t, "stg")
+	kustomizeExecutor := newFakeKustomizeExecutor(beforeDir, before, after)
+	// conftest stands for a policy capping the replicas of a ScaledObject
+	var evaluated string
+	executor := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if name == "kustomize" {
+				return kustomizeExecutor.Handler(ctx, dir, name, args...)
+			}
+			content, err := os.ReadFile(args[slices.Index(args, "--policy")+2])
+			if err != nil {
+				return nil, err
+			}
+			evaluated = string(content)
+			failures := `[]`
+			if strings.Contains(evaluated, "maxReplicaCount: 200") {
+				failures = `[{"msg": "ScaledObject my-app: maxReplicaCount 200 is over 100"}]`
+			}
+			return &command.Result{Stdout: []byte(`[{"filename": "Combined", "namespace": "main", "failures": ` + failures + `}]`)}, nil
+		},
+	}
+	evaluator := policy.NewPolicyEvaluator(newTestPoliciesDir(t, complianceConfig, "keda"))
+	evaluator.SetExecutor(executor)
+	if err := evaluator.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	r := &RunnerBase{
+		Context:   context.Background(),
+		Options:   &Options{Environments: []string{"stg"}},
+		Builder:   kustomize.NewBuilderWithExecutor(executor),
+		Differ:    diff.NewDiffer(),
+		Evaluator: evaluator,
+	}
+
+	rs, err := r.BuildManifests(beforeDir, afterDir)
+	if err != nil {
+		t.Fatalf("BuildManifests() error = %v", err)
+	}
+	diffs, err := r.DiffManifests(rs)
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	wantStats := []models.ResourceStat{{Group: "keda.sh", Kind: "ScaledObject", Namespace: "my-ns", Name: "my-app", Added: 1, Deleted: 1}}
+	gotStats := diffs["stg"].ResourceStats
+	for i := range gotStats {
+		gotStats[i].LineRanges = nil
+	}
+	if !reflect.DeepEqual(gotStats, wantStats) {
+		t.Errorf("DiffManifests() resource stats = %+v, want %+v", gotStats, wantStats)
+	}
+
+	eval, err := evaluator.GeneratePolicyEvalResultForManifests(context.Background(), *rs, nil)
+	if err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+	if !strings.Contains(evaluated, "kind: ScaledObject") {
+		t.Errorf("conftest evaluated %q, want the ScaledObject passed through", evaluated)
+	}
+	blocking := eval.PolicyMatrix["stg"].BlockingPolicies
+	wantMsgs := []string{"ScaledObject my-app: maxReplicaCount 200 is over 100"}
+	if len(blocking) != 1 || !reflect.DeepEqual(blocking[0].FailMessages, wantMsgs) {
+		t.Errorf("blocking policies = %+v, want keda-max-replicas failing with %v", blocking, wantMsgs)
+	}
+}
@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+)
+
+// --comment-levels values -> enforcement levels of the policy matrix
+var commentLevels = map[string]string{
+	"block":     policy.POLICY_LEVEL_BLOCK,
+	"warning":   policy.POLICY_LEVEL_WARNING,
+	"recommend": policy.POLICY_LEVEL_RECOMMEND,
+}
+
+// ParseCommentLevels parses the --comment-levels values, e.g. "block" and "warning", and returns the enforcement
+// levels left out of the comment, e.g. RECOMMEND -> true. Every level is shown if empty
+func ParseCommentLevels(levels []string) (map[string]bool, error) {
+	if len(levels) == 0 {
+		return nil, nil
+	}
+	hidden := map[string]bool{}
+	for _, level := range commentLevels {
+		hidden[level] = true
+	}
+	for _, value := range levels {
+		level, ok := commentLevels[strings.ToLower(strings.TrimSpace(value))]
+		if !ok {
+			return nil, fmt.Errorf("invalid comment level %q, must be one of block, warning, recommend", value)
+		}
+		delete(hidden, level)
+	}
+	return hidden, nil
+}
+
+// reportForCommentLevels returns a copy of the report rendered in the comment, without the policies of the hidden
+// enforcement levels in the policy matrix. The report itself is left whole for report.json
+func reportForCommentLevels(data *models.ReportData, hidden map[string]bool) *models.ReportData {
+	if len(hidden) == 0 {
+		return data
+	}
+	levelData := *data
+	levelData.HiddenPolicyLevels = hidden
+	levelData.PolicyEvaluation.PolicyMatrix = make(map[string]models.PolicyMatrix, len(data.PolicyEvaluation.PolicyMatrix))
+	for env, matrix := range data.PolicyEvaluation.PolicyMatrix {
+		if hidden[policy.POLICY_LEVEL_BLOCK] {
+			matrix.BlockingPolicies = nil
+		}
+		if hidden[policy.POLICY_LEVEL_WARNING] {
+			matrix.WarningPolicies = nil
+		}
+		if hidden[policy.POLICY_LEVEL_RECOMMEND] {
+			matrix.RecommendPolicies = nil
+		}
+		levelData.PolicyEvaluation.PolicyMatrix[env] = matrix
+	}
+	return &levelData
+}
+
+// commentReport returns the report rendered in the comment and markdown report, see reportForCommentLevels
+func (r *RunnerBase) commentReport(data *models.ReportData) (*models.ReportData, error) {
+	hidden, err := ParseCommentLevels(r.Options.CommentLevels)
+	if err != nil {
+		return nil, err
+	}
+	return reportForCommentLevels(data, hidden), nil
+}
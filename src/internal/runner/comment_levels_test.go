@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
+)
+
+// TestParseCommentLevels tests the enforcement levels left out of the comment per --comment-levels
+func TestParseCommentLevels(t *testing.T) {
+	tests := []struct {
+		name    string
+		levels  []string
+		want    map[string]bool
+		wantErr string
+	}{
+		{
+			name:   "empty shows every level",
+			levels: nil,
+			want:   nil,
+		},
+		{
+			name:   "block and warning",
+			levels: []string{"block", "warning"},
+			want:   map[string]bool{policy.POLICY_LEVEL_RECOMMEND: true},
+		},
+		{
+			name:   "case and spaces",
+			levels: []string{" BLOCK "},
+			want:   map[string]bool{policy.POLICY_LEVEL_WARNING: true, policy.POLICY_LEVEL_RECOMMEND: true},
+		},
+		{
+			name:   "every level",
+			levels: []string{"block", "warning", "recommend"},
+			want:   map[string]bool{},
+		},
+		{
+			name:    "unknown level",
+			levels:  []string{"block", "info"},
+			wantErr: `invalid comment level "info", must be one of block, warning, recommend`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCommentLevels(tt.levels)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("ParseCommentLevels() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCommentLevels() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseCommentLevels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunnerLocal_Output_CommentLevels tests that RECOMMEND policies are left out of report.md
+// but kept in report.json
+func TestRunnerLocal_Output_CommentLevels(t *testing.T) {
+	data := newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = models.EnvironmentSummaryEnv{
+		PolicyCounts: models.PolicyCounts{TotalCount: 2, TotalFailed: 2, BlockingFailedCount: 1, RecommendFailedCount: 1},
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies:  []models.PolicyResult{{PolicyId: "ha", PolicyName: "High Availability", FailMessages: []string{"replicas too low"}}},
+		RecommendPolicies: []models.PolicyResult{{PolicyId: "labels", PolicyName: "Team Labels", FailMessages: []string{"missing team label"}}},
+	}
+	outputDir := t.TempDir()
+	r := &RunnerLocal{RunnerBase: RunnerBase{
+		Context: context.Background(),
+		Options: &Options{
+			OutputDir:          outputDir,
+			TemplatesPath:      filepath.Join("..", "..", "templates"),
+			EnableExportReport: true,
+			CommentLevels:      []string{"block", "warning"},
+		},
+		Renderer: template.NewRenderer(),
+	}}
+
+	if err := r.Output(data); err != nil {
+		t.Fatalf("Output() error = %v", err)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(outputDir, "report.md"))
+	if err != nil {
+		t.Fatalf("failed to read report.md: %v", err)
+	}
+	for _, want := range []string{"High Availability", "replicas too low", "RECOMMEND Policies: not shown in this comment"} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("report.md missing %q", want)
+		}
+	}
+	for _, unwanted := range []string{"Team Labels", "missing team label"} {
+		if strings.Contains(string(markdown), unwanted) {
+			t.Errorf("report.md contains %q of a RECOMMEND policy, want it left out", unwanted)
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "report.json"))
+	if err != nil {
+		t.Fatalf("failed to read report.json: %v", err)
+	}
+	var report models.ReportData
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("failed to parse report.json: %v", err)
+	}
+	recommend := report.PolicyEvaluation.PolicyMatrix["stg"].RecommendPolicies
+	if len(recommend) != 1 || recommend[0].PolicyId != "labels" {
+		t.Errorf("report.json stg RecommendPolicies = %+v, want the labels policy", recommend)
+	}
+	if len(data.PolicyEvaluation.PolicyMatrix["stg"].RecommendPolicies) != 1 {
+		t.Error("Output() modified the RECOMMEND policies of the report data")
+	}
+}
@@ -0,0 +1,105 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// Remote the compare branch is fetched from
+const COMPARE_BRANCH_REMOTE = "origin"
+
+// CheckoutCompareBranch fetches branch in the git checkout gitDir and checks it out in a temporary worktree,
+// for comparing a checkout of a feature branch against e.g. main in local mode. A shallow checkout, e.g. of CI,
+// fetches the tip of the branch only. Returns the manifests directory of the worktree, at the path of afterPath in
+// the checkout, and the cleanup removing the worktree.
+// It does the following commands:
+// 1. git rev-parse --show-toplevel
+// 2. git rev-parse --is-shallow-repository
+// 3. git fetch --no-tags [--depth 1] origin branch
+// 4. git rev-parse FETCH_HEAD
+// 5. git worktree add --detach worktree commit
+func CheckoutCompareBranch(ctx context.Context, executor command.CommandExecutor, gitDir, branch, afterPath string) (string, func(), error) {
+	lg := logger.WithField("gitDir", gitDir).WithField("branch", branch)
+	git := func(args ...string) (string, error) {
+		res, err := executor.Run(ctx, gitDir, "git", args...)
+		if err != nil {
+			stderr := ""
+			if res != nil {
+				stderr = strings.TrimSpace(string(res.Stderr))
+			}
+			return "", fmt.Errorf("git %s: %w: %s", args[0], err, stderr)
+		}
+		return strings.TrimSpace(string(res.Stdout)), nil
+	}
+
+	// the worktree holds the whole repository, the manifests are at the same path relative to its root
+	topLevel, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", nil, fmt.Errorf("%s is not a git checkout: %w", gitDir, err)
+	}
+	absAfterPath, err := filepath.Abs(afterPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get absolute path of %s: %w", afterPath, err)
+	}
+	// git resolves the symlinks of the top level, e.g. /tmp -> /private/tmp on macOS, so are both paths
+	realTopLevel, err := filepath.EvalSymlinks(topLevel)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve the git checkout %s: %w", topLevel, err)
+	}
+	realAfterPath, err := filepath.EvalSymlinks(absAfterPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve after manifests path %s: %w", afterPath, err)
+	}
+	manifestsPath, err := filepath.Rel(realTopLevel, realAfterPath)
+	if err != nil || manifestsPath == ".." || strings.HasPrefix(manifestsPath, ".."+string(filepath.Separator)) {
+		return "", nil, fmt.Errorf("after manifests path %s is not in the git checkout %s", afterPath, topLevel)
+	}
+
+	shallow, err := git("rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return "", nil, err
+	}
+	fetchArgs := []string{"fetch", "--no-tags"}
+	if shallow == "true" {
+		// deepening a shallow checkout would fetch the whole history
+		fetchArgs = append(fetchArgs, "--depth", "1")
+	}
+	lg.Info("Fetching compare branch...")
+	if _, err := git(append(fetchArgs, COMPARE_BRANCH_REMOTE, branch)...); err != nil {
+		return "", nil, fmt.Errorf("failed to fetch branch %s: %w", branch, err)
+	}
+	commit, err := git("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+
+	tmpdir, err := os.MkdirTemp("", "gitops-kustomz-compare-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create the compare branch directory: %w", err)
+	}
+	removeTmpdir := func() {
+		if err := os.RemoveAll(tmpdir); err != nil {
+			lg.WithError(err).Warn("Failed to remove the compare branch directory")
+		}
+	}
+	// git creates the worktree directory, it must not exist
+	worktree := filepath.Join(tmpdir, "worktree")
+	if _, err := git("worktree", "add", "--detach", worktree, commit); err != nil {
+		removeTmpdir()
+		return "", nil, fmt.Errorf("failed to check out branch %s: %w", branch, err)
+	}
+	cleanup := func() {
+		// a worktree removed from disk only stays registered in the checkout until pruned
+		if _, err := git("worktree", "remove", "--force", worktree); err != nil {
+			lg.WithError(err).Warn("Failed to remove the compare branch worktree")
+		}
+		removeTmpdir()
+	}
+	lg.WithField("commit", commit).WithField("worktree", worktree).Info("Checked out compare branch")
+	return filepath.Join(worktree, manifestsPath), cleanup, nil
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// newFakeGitExecutor returns a git runner of the checkout topLevel whose compare branch holds the given files,
+// written to the worktree on "git worktree add"
+func newFakeGitExecutor(topLevel string, shallow bool, files map[string]string, fetchErr error) *testutil.FakeExecutor {
+	return &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			switch strings.Join(args[:2], " ") {
+			case "rev-parse --show-toplevel":
+				return &command.Result{Stdout: []byte(topLevel + "\n")}, nil
+			case "rev-parse --is-shallow-repository":
+				return &command.Result{Stdout: []byte(fmt.Sprintf("%v\n", shallow))}, nil
+			case "fetch --no-tags":
+				if fetchErr != nil {
+					return &command.Result{Stderr: []byte("fatal: couldn't find remote ref")}, fetchErr
+				}
+				return &command.Result{}, nil
+			case "rev-parse FETCH_HEAD":
+				return &command.Result{Stdout: []byte("abc1234\n")}, nil
+			case "worktree add":
+				worktree := args[3]
+				for path, content := range files {
+					if err := os.MkdirAll(filepath.Dir(filepath.Join(worktree, path)), 0755); err != nil {
+						return nil, err
+					}
+					if err := os.WriteFile(filepath.Join(worktree, path), []byte(content), 0644); err != nil {
+						return nil, err
+					}
+				}
+				return &command.Result{}, nil
+			case "worktree remove":
+				return &command.Result{}, os.RemoveAll(args[3])
+			}
+			return nil, fmt.Errorf("unexpected git %v", args)
+		},
+	}
+}
+
+// TestCheckoutCompareBranch tests that the compare branch is fetched and its manifests checked out at the path
+// of the head manifests in the checkout
+func TestCheckoutCompareBranch(t *testing.T) {
+	files := map[string]string{"services/my-app/base/kustomization.yaml": "resources: []\n"}
+
+	tests := []struct {
+		name      string
+		shallow   bool
+		afterPath string
+		symlink   bool
+		fetchErr  error
+		wantFetch string
+		wantErr   string
+	}{
+		{
+			name:      "full checkout",
+			afterPath: "services",
+			wantFetch: "git fetch --no-tags origin main",
+		},
+		{
+			name:      "shallow checkout",
+			shallow:   true,
+			afterPath: "services",
+			wantFetch: "git fetch --no-tags --depth 1 origin main",
+		},
+		{
+			name:      "manifests through a symlink to the checkout",
+			afterPath: "services",
+			symlink:   true,
+			wantFetch: "git fetch --no-tags origin main",
+		},
+		{
+			name:      "manifests outside the checkout",
+			afterPath: "..",
+			wantErr:   "is not in the git checkout",
+		},
+		{
+			name:      "fetch failure",
+			afterPath: "services",
+			fetchErr:  fmt.Errorf("exit status 128"),
+			wantErr:   "failed to fetch branch main: git fetch: exit status 128: fatal: couldn't find remote ref",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topLevel := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(topLevel, "services"), 0755); err != nil {
+				t.Fatalf("failed to create manifests dir: %v", err)
+			}
+			fake := newFakeGitExecutor(topLevel, tt.shallow, files, tt.fetchErr)
+			checkout := topLevel
+			if tt.symlink {
+				// git reports the resolved top level, the after path keeps the symlink
+				checkout = filepath.Join(t.TempDir(), "checkout")
+				if err := os.Symlink(topLevel, checkout); err != nil {
+					t.Fatalf("failed to create symlink: %v", err)
+				}
+			}
+
+			beforePath, cleanup, err := CheckoutCompareBranch(context.Background(), fake, checkout, "main", filepath.Join(checkout, tt.afterPath))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckoutCompareBranch() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckoutCompareBranch() error = %v", err)
+			}
+
+			if filepath.Base(beforePath) != "services" {
+				t.Errorf("CheckoutCompareBranch() = %s, want the services directory of the worktree", beforePath)
+			}
+			if _, err := os.Stat(filepath.Join(beforePath, "my-app", "base", "kustomization.yaml")); err != nil {
+				t.Errorf("CheckoutCompareBranch() manifests of the compare branch missing: %v", err)
+			}
+			var commands []string
+			for _, call := range fake.Calls() {
+				commands = append(commands, call.String())
+			}
+			if !strings.Contains(strings.Join(commands, "\n"), tt.wantFetch+"\n") {
+				t.Errorf("CheckoutCompareBranch() ran %q, want %q", commands, tt.wantFetch)
+			}
+
+			cleanup()
+			tmpdir := filepath.Dir(filepath.Dir(beforePath))
+			if _, err := os.Stat(tmpdir); !os.IsNotExist(err) {
+				t.Errorf("cleanup() left %s, want it removed", tmpdir)
+			}
+			if last := commands[len(commands)-1]; !strings.HasPrefix(last, "git worktree add --detach ") || !strings.HasSuffix(last, " abc1234") {
+				t.Errorf("CheckoutCompareBranch() last command = %q, want the worktree of the fetched commit", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Confirmer asks for confirmation before destructive actions, e.g. deleting a PR comment
+type Confirmer struct {
+	in          io.Reader
+	out         io.Writer
+	interactive bool
+	assumeYes   bool
+}
+
+// NewConfirmer returns a confirmer prompting on the terminal. When stdin is not a terminal (CI),
+// nothing is prompted and only assumeYes confirms
+func NewConfirmer(assumeYes bool) *Confirmer {
+	return &Confirmer{
+		in:          os.Stdin,
+		out:         os.Stderr,
+		interactive: isTerminal(os.Stdin),
+		assumeYes:   assumeYes,
+	}
+}
+
+// Confirm reports whether the action is confirmed: always with assumeYes, by a "y"/"yes" answer
+// in interactive mode, never otherwise
+func (c *Confirmer) Confirm(question string) bool {
+	if c == nil {
+		return false
+	}
+	if c.assumeYes {
+		return true
+	}
+	if !c.interactive {
+		logger.WithField("question", question).Info("Not confirmed: non-interactive run without --assume-yes")
+		return false
+	}
+
+	fmt.Fprintf(c.out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(c.in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// isTerminal reports whether the file is a character device, i.e. an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestConfirmer_Confirm tests the confirmation of destructive operations in interactive and non-interactive runs
+func TestConfirmer_Confirm(t *testing.T) {
+	tests := []struct {
+		name        string
+		interactive bool
+		assumeYes   bool
+		input       string
+		want        bool
+		wantPrompt  bool
+	}{
+		{
+			name:  "non-interactive without --assume-yes is skipped",
+			input: "y\n",
+			want:  false,
+		},
+		{
+			name:      "non-interactive with --assume-yes",
+			assumeYes: true,
+			want:      true,
+		},
+		{
+			name:        "interactive with --assume-yes is not prompted",
+			interactive: true,
+			assumeYes:   true,
+			want:        true,
+		},
+		{
+			name:        "interactive yes",
+			interactive: true,
+			input:       "Yes\n",
+			want:        true,
+			wantPrompt:  true,
+		},
+		{
+			name:        "interactive no",
+			interactive: true,
+			input:       "n\n",
+			want:        false,
+			wantPrompt:  true,
+		},
+		{
+			name:        "interactive default is no",
+			interactive: true,
+			input:       "\n",
+			want:        false,
+			wantPrompt:  true,
+		},
+		{
+			name:        "interactive closed input",
+			interactive: true,
+			want:        false,
+			wantPrompt:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			c := &Confirmer{in: strings.NewReader(tt.input), out: out, interactive: tt.interactive, assumeYes: tt.assumeYes}
+			if got := c.Confirm("Delete the comment?"); got != tt.want {
+				t.Errorf("Confirm() = %v, want %v", got, tt.want)
+			}
+			if prompted := strings.Contains(out.String(), "Delete the comment? [y/N]"); prompted != tt.wantPrompt {
+				t.Errorf("Confirm() prompted = %v, want %v", prompted, tt.wantPrompt)
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_deleteGitHubComment tests that the outdated comment is only deleted once confirmed
+func TestRunnerGitHub_deleteGitHubComment(t *testing.T) {
+	tests := []struct {
+		name        string
+		assumeYes   bool
+		wantDeleted int
+		wantWarning bool
+	}{
+		{
+			name:        "non-interactive without --assume-yes",
+			wantDeleted: 0,
+			wantWarning: true,
+		},
+		{
+			name:        "non-interactive with --assume-yes",
+			assumeYes:   true,
+			wantDeleted: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{GhRepo: "owner/repo", GhPrNumber: 7, AssumeYes: tt.assumeYes}
+			client := &fakeGitHubClient{existing: &models.Comment{ID: 42}}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts},
+				options:    opts,
+				ghclient:   client,
+				confirmer:  &Confirmer{in: strings.NewReader(""), out: &bytes.Buffer{}, assumeYes: tt.assumeYes},
+			}
+
+			if err := r.deleteGitHubComment(github.CommentMarker("")); err != nil {
+				t.Fatalf("deleteGitHubComment() error = %v", err)
+			}
+			if len(client.deleted) != tt.wantDeleted {
+				t.Errorf("deleteGitHubComment() deleted %d comments, want %d", len(client.deleted), tt.wantDeleted)
+			}
+			if warned := len(r.Warnings()) > 0; warned != tt.wantWarning {
+				t.Errorf("deleteGitHubComment() warnings = %v, want warning %v", r.Warnings(), tt.wantWarning)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+)
+
+// policyCSVHeader is the header row of the --export-csv file
+var policyCSVHeader = []string{"service", "environment", "policyId", "policyName", "level", "passing", "failMessage"}
+
+// writePolicyMatrixCSV writes the policy matrix as a flat CSV, one row per fail message, or one row per policy
+// when it passes. Rows are ordered by environment, level (most severe first) then policy id
+func writePolicyMatrixCSV(w io.Writer, data *models.ReportData) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(policyCSVHeader); err != nil {
+		return err
+	}
+	for _, env := range data.Environments {
+		matrix, ok := data.PolicyEvaluation.PolicyMatrix[env]
+		if !ok {
+			continue
+		}
+		levels := []struct {
+			level   string
+			results []models.PolicyResult
+		}{
+			{policy.POLICY_LEVEL_BLOCK, matrix.BlockingPolicies},
+			{policy.POLICY_LEVEL_WARNING, matrix.WarningPolicies},
+			{policy.POLICY_LEVEL_RECOMMEND, matrix.RecommendPolicies},
+			{policy.POLICY_LEVEL_OVERRIDE, matrix.OverriddenPolicies},
+			{policy.POLICY_LEVEL_NOT_IN_EFFECT, matrix.NotInEffectPolicies},
+		}
+		for _, level := range levels {
+			results := append([]models.PolicyResult{}, level.results...)
+			sort.Slice(results, func(i, j int) bool { return results[i].PolicyId < results[j].PolicyId })
+			for _, result := range results {
+				row := []string{data.Service, env, result.PolicyId, result.PolicyName, level.level, strconv.FormatBool(result.IsPassing)}
+				if len(result.FailMessages) == 0 {
+					if err := writer.Write(append(row, "")); err != nil {
+						return err
+					}
+					continue
+				}
+				for _, msg := range result.FailMessages {
+					if err := writer.Write(append(row, msg)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportPolicyMatrixCSV writes the policy matrix CSV to the --export-csv path, no-op if not set
+func (r *RunnerBase) exportPolicyMatrixCSV(data *models.ReportData) error {
+	if r.Options.ExportCSV == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := writePolicyMatrixCSV(&buf, data); err != nil {
+		return fmt.Errorf("failed to write policy matrix CSV: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Options.ExportCSV), 0755); err != nil {
+		return fmt.Errorf("failed to create CSV directory: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(r.Options.ExportCSV, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write policy matrix CSV: %w", err)
+	}
+	logger.WithField("filePath", r.Options.ExportCSV).Info("Written policy matrix CSV")
+	return nil
+}
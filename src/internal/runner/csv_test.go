@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestRunnerBase_exportPolicyMatrixCSV tests the CSV header and rows of a mixed-result evaluation
+func TestRunnerBase_exportPolicyMatrixCSV(t *testing.T) {
+	data := &models.ReportData{
+		Service:      "my-app",
+		Environments: []string{"stg", "prod"},
+		PolicyEvaluation: models.PolicyEvaluation{
+			PolicyMatrix: map[string]models.PolicyMatrix{
+				"stg": {
+					BlockingPolicies: []models.PolicyResult{
+						{PolicyId: "pdb", PolicyName: "Pod Disruption Budget", IsPassing: true},
+						{PolicyId: "ha", PolicyName: "Service High Availability", FailMessages: []string{"replicas too low", "no anti-affinity, found: 0"}},
+					},
+					WarningPolicies: []models.PolicyResult{
+						{PolicyId: "labels", PolicyName: "Required Labels", FailMessages: []string{`missing "team" label`}},
+					},
+				},
+				"prod": {
+					NotInEffectPolicies: []models.PolicyResult{
+						{PolicyId: "tls", PolicyName: "Ingress TLS", IsPassing: true},
+					},
+				},
+			},
+		},
+	}
+	want := `service,environment,policyId,policyName,level,passing,failMessage
+my-app,stg,ha,Service High Availability,BLOCK,false,replicas too low
+my-app,stg,ha,Service High Availability,BLOCK,false,"no anti-affinity, found: 0"
+my-app,stg,pdb,Pod Disruption Budget,BLOCK,true,
+my-app,stg,labels,Required Labels,WARNING,false,"missing ""team"" label"
+my-app,prod,tls,Ingress TLS,NOT_IN_EFFECT,true,
+`
+
+	t.Run("enabled", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reports", "policies.csv")
+		r := &RunnerBase{Options: &Options{ExportCSV: path}}
+		if err := r.exportPolicyMatrixCSV(data); err != nil {
+			t.Fatalf("exportPolicyMatrixCSV() error = %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read CSV: %v", err)
+		}
+		if string(got) != want {
+			t.Errorf("exportPolicyMatrixCSV() wrote\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		dir := t.TempDir()
+		r := &RunnerBase{Options: &Options{OutputDir: dir}}
+		if err := r.exportPolicyMatrixCSV(data); err != nil {
+			t.Fatalf("exportPolicyMatrixCSV() error = %v", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("failed to read dir: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("exportPolicyMatrixCSV() wrote %d files, want none when disabled", len(entries))
+		}
+	})
+}
@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/github"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
@@ -21,17 +23,27 @@ const (
 	// GitHub Comment body length limit is 65536 characters, the default Markdown comment is about 2k characters.
 	// 10k is a reasonable limit for the diff content, as it is arguably humanly impossible to read a diff that is longer.
 	GH_COMMENT_MAX_DIFF_LENGTH = 10_000
+
+	// Step output holding the URL of the posted comment, with --emit-comment-url
+	GH_OUTPUT_POSTED_COMMENT_URL = "posted-comment-url"
+
+	// GitHub Actions rejects a job summary over 1MiB per step
+	GH_STEP_SUMMARY_MAX_SIZE = 1024 * 1024
+	// Appended to a job summary truncated to GH_STEP_SUMMARY_MAX_SIZE
+	GH_STEP_SUMMARY_TRUNCATED_NOTE = "\n\n_Report truncated to fit the job summary size limit._\n"
 )
 
 var (
 	githubCommentMaxDiffLength = GH_COMMENT_MAX_DIFF_LENGTH
+	githubStepSummaryMaxSize   = GH_STEP_SUMMARY_MAX_SIZE
 )
 
 type RunnerGitHub struct {
 	RunnerBase
 
-	options  *Options
-	ghclient *github.Client
+	options   *Options
+	ghclient  github.GitHubClient
+	confirmer *Confirmer // confirms destructive operations, e.g. deleting the previous comment
 
 	runId    int
 	prInfo   *models.PullRequest
@@ -41,7 +53,7 @@ type RunnerGitHub struct {
 func NewRunnerGitHub(
 	ctx context.Context,
 	options *Options,
-	ghclient *github.Client,
+	ghclient github.GitHubClient,
 	builder *kustomize.Builder,
 	differ *diff.Differ,
 	evaluator *policy.PolicyEvaluator,
@@ -58,6 +70,7 @@ func NewRunnerGitHub(
 		RunnerBase: *baseRunner,
 		ghclient:   ghclient,
 		options:    options,
+		confirmer:  NewConfirmer(options.AssumeYes),
 	}
 	return runner, nil
 }
@@ -66,7 +79,9 @@ func (r *RunnerGitHub) Initialize() error {
 	lg := logger.WithField("func", "RunnerGitHub.Initialize()")
 	lg.Info("Initializing runner: starting...")
 
-	if err := r.fetchAndSetPullRequestInfo(); err != nil {
+	if r.options.GhCommit != "" {
+		lg.WithField("commit", r.options.GhCommit).Info("Evaluating a commit, there is no pull request to fetch")
+	} else if err := r.fetchAndSetPullRequestInfo(); err != nil {
 		return fmt.Errorf("failed to fetch pull request info: %w", err)
 	}
 	r.runId = 0
@@ -140,6 +155,27 @@ func (r *RunnerGitHub) fetchAndSetPullRequestInfo() error {
 	return nil
 }
 
+// overrideComments returns the PR comments and review bodies searched for override commands,
+// reviews other than approvals are left out with OverrideApprovedReviewsOnly
+func (r *RunnerGitHub) overrideComments() ([]*models.Comment, error) {
+	comments, err := r.ghclient.GetComments(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get comments: %w", err)
+	}
+	reviews, err := r.ghclient.GetReviews(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+	for _, review := range reviews {
+		if r.options.OverrideApprovedReviewsOnly && review.ReviewState != github.REVIEW_STATE_APPROVED {
+			logger.WithField("user", review.User).WithField("state", review.ReviewState).Debug("Ignoring review not approving the PR")
+			continue
+		}
+		comments = append(comments, review)
+	}
+	return comments, nil
+}
+
 func (r *RunnerGitHub) BuildManifests(beforePath, afterPath string) (*models.BuildManifestResult, error) {
 	return r.RunnerBase.BuildManifests(beforePath, afterPath)
 }
@@ -152,38 +188,40 @@ func (r *RunnerGitHub) DiffManifests(result *models.BuildManifestResult) (map[st
 	}
 
 	for env, envDiff := range diffs {
-		if len(envDiff.Content) > githubCommentMaxDiffLength {
+		if envDiff.ContentType != models.DiffContentTypeText {
+			continue
+		}
+		diffLines := strings.Count(strings.TrimSuffix(envDiff.Content, "\n"), "\n") + 1
+		tooLong := len(envDiff.Content) > githubCommentMaxDiffLength
+		tooManyLines := r.options.MaxInlineDiffLines > 0 && diffLines > r.options.MaxInlineDiffLines
+		if tooLong || tooManyLines {
+			if tooLong {
+				r.AddWarning("Environment %s: the diff (%d characters) exceeds the comment limit of %d characters, it is uploaded as a workflow artifact",
+					env, len(envDiff.Content), githubCommentMaxDiffLength)
+			} else {
+				r.AddWarning("Environment %s: the diff (%d lines) exceeds the inline limit of %d lines, it is uploaded as a workflow artifact",
+					env, diffLines, r.options.MaxInlineDiffLines)
+			}
 			logger.WithFields(map[string]interface{}{
-				"env":        env,
-				"diffLength": len(envDiff.Content),
-				"maxLength":  githubCommentMaxDiffLength,
+				"env":            env,
+				"diffLength":     len(envDiff.Content),
+				"maxLength":      githubCommentMaxDiffLength,
+				"diffLines":      diffLines,
+				"maxInlineLines": r.options.MaxInlineDiffLines,
 			}).Info("Diff is too long, uploading as artifact")
 
 			// Create filename for this diff
-			filename := fmt.Sprintf("diff-pr%d-%s-%s.txt", r.options.GhPrNumber, env, r.options.Service)
-
-			// Save diff content to file
-			outputDir := r.Options.OutputDir
-			if err := os.MkdirAll(outputDir, 0755); err != nil {
-				return nil, fmt.Errorf("failed to create output directory: %w", err)
-			}
-
-			filepath := filepath.Join(outputDir, filename)
-			if err := os.WriteFile(filepath, []byte(envDiff.Content), 0644); err != nil {
-				return nil, fmt.Errorf("failed to write diff file: %w", err)
-			}
-
-			// Upload file as artifact and get URL
-			artifactURL, err := github.GetWorkflowRunUrl(r.options.GhRepo, r.runId)
+			filename := fmt.Sprintf("diff-%s-%s-%s.txt", r.artifactRef(), env, r.options.Service)
+			filepath, artifactURL, err := r.exportArtifact(filename, envDiff.Content)
 			if err != nil {
-				logger.WithField("error", err).Error("Failed to get workflow run URL, leaving content as text")
-				artifactURL = ""
+				return nil, err
 			}
 
 			// Update the diff result to point to the artifact URL
 			envDiff.ContentGHFilePath = &filepath
 			envDiff.ContentType = models.DiffContentTypeGHArtifact
 			envDiff.Content = artifactURL
+			envDiff.PerResource = nil
 			diffs[env] = envDiff
 
 			logger.WithFields(map[string]interface{}{
@@ -197,17 +235,90 @@ func (r *RunnerGitHub) DiffManifests(result *models.BuildManifestResult) (map[st
 	return diffs, nil
 }
 
+// FullManifests returns the full head manifest per environment if enabled,
+// manifests that are too long for the comment are written to the output directory as artifacts
+func (r *RunnerGitHub) FullManifests(result *models.BuildManifestResult) (map[string]models.FullManifest, error) {
+	manifests, err := r.RunnerBase.FullManifests(result)
+	if err != nil {
+		return nil, err
+	}
+
+	for env, mf := range manifests {
+		if len(mf.Content) <= githubCommentMaxDiffLength {
+			continue
+		}
+		r.AddWarning("Environment %s: the full manifest (%d characters) exceeds the comment limit of %d characters, it is uploaded as a workflow artifact",
+			env, len(mf.Content), githubCommentMaxDiffLength)
+		logger.WithFields(map[string]interface{}{
+			"env":            env,
+			"manifestLength": len(mf.Content),
+			"maxLength":      githubCommentMaxDiffLength,
+		}).Info("Full manifest is too long, uploading as artifact")
+
+		filename := fmt.Sprintf("manifest-%s-%s-%s.yaml", r.artifactRef(), env, r.options.Service)
+		filePath, artifactURL, err := r.exportArtifact(filename, mf.Content)
+		if err != nil {
+			return nil, err
+		}
+		mf.ContentGHFilePath = &filePath
+		mf.ContentType = models.DiffContentTypeGHArtifact
+		mf.Content = artifactURL
+		manifests[env] = mf
+	}
+	return manifests, nil
+}
+
+// artifactRef names what the run checks in artifact file names, "pr<number>" or "commit-<short sha>"
+func (r *RunnerGitHub) artifactRef() string {
+	if r.options.GhCommit != "" {
+		return "commit-" + shortSHA(r.options.GhCommit)
+	}
+	return fmt.Sprintf("pr%d", r.options.GhPrNumber)
+}
+
+// shortSHA returns the 7 character abbreviation of a commit SHA
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// exportArtifact writes content to filename in the output directory to be uploaded as a workflow artifact
+// returns the written file path and the workflow run URL, which is empty if it could not be determined
+func (r *RunnerGitHub) exportArtifact(filename, content string) (string, string, error) {
+	outputDir := r.Options.OutputDir
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	filePath := filepath.Join(outputDir, filename)
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return "", "", fmt.Errorf("failed to write artifact file: %w", err)
+	}
+
+	artifactURL, err := github.GetWorkflowRunUrl(r.options.GhRepo, r.runId)
+	if err != nil {
+		logger.WithField("error", err).Error("Failed to get workflow run URL, leaving content as text")
+		r.AddWarning("The workflow run URL could not be determined, %s is only available in the workflow artifacts", filename)
+		artifactURL = ""
+	}
+	return filePath, artifactURL, nil
+}
+
 func (r *RunnerGitHub) Process() error {
 	ctx, span := trace.StartSpan(r.Context, "Process")
 	defer span.End()
 
 	logger.Info("Process: starting...")
 
-	logger.WithField("repo", r.options.GhRepo).WithField("branch", r.prInfo.BaseRef).Debug("Process: Calling SparseCheckoutAtPath for base commit")
+	if r.options.GhCommit != "" {
+		return r.processCommit(ctx)
+	}
+
 	_, checkoutBaseSpan := trace.StartSpan(ctx, "GitCheckout.Base")
 	beforePathToSparseCheckout := filepath.Join(r.options.ManifestsPath, r.options.Service)
-	checkedOutBeforePath, err := r.ghclient.SparseCheckoutAtPath(
-		r.Context, r.options.GhRepo, r.prInfo.BaseRef, beforePathToSparseCheckout)
+	checkedOutBeforePath, baseCommit, err := r.checkoutBase(beforePathToSparseCheckout)
 	if err != nil {
 		checkoutBaseSpan.End()
 		return fmt.Errorf("failed to sparse checkout base commit: %w", err)
@@ -234,6 +345,31 @@ func (r *RunnerGitHub) Process() error {
 	}()
 	afterPath := filepath.Join(checkedOutAfterPath, r.options.ManifestsPath, r.options.Service)
 
+	return r.buildAndReport(ctx, beforePath, afterPath, baseCommit, r.prInfo.HeadSHA)
+}
+
+// processCommit sparse checks out the commit and evaluates it as is, both sides of the diff are the commit,
+// so the report has no manifest change and there is no PR to comment on
+func (r *RunnerGitHub) processCommit(ctx context.Context) error {
+	logger.WithField("repo", r.options.GhRepo).WithField("commit", r.options.GhCommit).Info("Sparse checking out manifests")
+	_, checkoutSpan := trace.StartSpan(ctx, "GitCheckout.Commit")
+	checkedOutPath, err := r.ghclient.SparseCheckoutAtCommit(
+		r.Context, r.options.GhRepo, r.options.GhCommit, filepath.Join(r.options.ManifestsPath, r.options.Service))
+	if err != nil {
+		checkoutSpan.End()
+		return fmt.Errorf("failed to sparse checkout commit %s: %w", r.options.GhCommit, err)
+	}
+	checkoutSpan.End()
+	defer func() {
+		_ = os.RemoveAll(checkedOutPath)
+	}()
+	path := filepath.Join(checkedOutPath, r.options.ManifestsPath, r.options.Service)
+
+	return r.buildAndReport(ctx, path, path, r.options.GhCommit, r.options.GhCommit)
+}
+
+// buildAndReport builds, diffs and evaluates the checked out base and head manifests, then outputs the report
+func (r *RunnerGitHub) buildAndReport(ctx context.Context, beforePath, afterPath, baseCommit, headCommit string) error {
 	rs, err := r.BuildManifests(beforePath, afterPath)
 	if err != nil {
 		return err
@@ -246,32 +382,49 @@ func (r *RunnerGitHub) Process() error {
 	}
 	logger.WithField("results", diffs).Debug("Diffed Manifests")
 
-	ghComments, err := r.ghclient.GetComments(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	fullManifests, err := r.FullManifests(rs)
 	if err != nil {
-		return fmt.Errorf("failed to get comments: %w", err)
-	}
-	ghCommentStrings := make([]string, len(ghComments))
-	for i, comment := range ghComments {
-		ghCommentStrings[i] = comment.Body
+		return err
 	}
 
-	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
-	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, ghCommentStrings)
-	if err != nil {
+	// a commit has no PR, hence no override comment nor changed files
+	var ghComments []*models.Comment
+	var changedFiles []string
+	if r.options.GhCommit == "" {
+		ghComments, err = r.overrideComments()
+		if err != nil {
+			return err
+		}
+		changedFiles = r.listServiceChangedFiles()
+	}
+	// a commit is evaluated as is, its identical sides are not a reason to skip it
+	manifestsUnchanged := r.options.GhCommit == "" && r.evaluationSkipped(rs)
+	policyEval := &models.PolicyEvaluation{}
+	if !manifestsUnchanged {
+		_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
+		policyEval, err = r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, ghComments)
+		if err != nil {
+			evalSpan.End()
+			return err
+		}
 		evalSpan.End()
-		return err
+		logger.WithField("results", policyEval).Debug("Evaluated Policies")
 	}
-	evalSpan.End()
-	logger.WithField("results", policyEval).Debug("Evaluated Policies")
 
 	reportData := models.ReportData{
 		Service:          r.Options.Service,
 		Timestamp:        time.Now(),
-		BaseCommit:       r.prInfo.BaseSHA,
-		HeadCommit:       r.prInfo.HeadSHA,
-		Environments:     r.Options.Environments,
+		BaseCommit:       baseCommit,
+		HeadCommit:       headCommit,
+		Environments:     r.Environments(),
+		BuildWarnings:    r.BuildWarnings(rs),
+		Warnings:         r.Warnings(),
+		ChangedFiles:     changedFiles,
 		ManifestChanges:  diffs,
 		PolicyEvaluation: *policyEval,
+		FullManifests:    fullManifests,
+
+		ManifestsUnchanged: manifestsUnchanged,
 	}
 
 	if err := r.Output(&reportData); err != nil {
@@ -280,6 +433,31 @@ func (r *RunnerGitHub) Process() error {
 	return nil
 }
 
+// listServiceChangedFiles returns the PR's changed files located under the service path
+// Failures are not fatal, since the list is only used for traceability in the report
+func (r *RunnerGitHub) listServiceChangedFiles() []string {
+	files, err := r.ghclient.ListChangedFiles(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	if err != nil {
+		r.AddWarning("Failed to list the PR changed files, the report does not include them: %v", err)
+		return nil
+	}
+	servicePath := filepath.Join(r.options.ManifestsPath, r.options.Service)
+	return github.FilterFilesUnderPath(files, servicePath)
+}
+
+// checkoutBase sparse checks out the commit to diff against at path, depending on the DiffBase option
+// returns the checked out directory and the base commit SHA
+func (r *RunnerGitHub) checkoutBase(path string) (string, string, error) {
+	if r.options.DiffBase == DIFF_BASE_BASE_REF {
+		logger.WithField("repo", r.options.GhRepo).WithField("branch", r.prInfo.BaseRef).Debug("Process: Calling SparseCheckoutAtPath for base commit")
+		dir, err := r.ghclient.SparseCheckoutAtPath(r.Context, r.options.GhRepo, r.prInfo.BaseRef, path)
+		return dir, r.prInfo.BaseSHA, err
+	}
+
+	logger.WithField("repo", r.options.GhRepo).WithField("branch", r.prInfo.BaseRef).WithField("headSHA", r.prInfo.HeadSHA).Debug("Process: Calling SparseCheckoutAtMergeBase for base commit")
+	return r.ghclient.SparseCheckoutAtMergeBase(r.Context, r.options.GhRepo, r.prInfo.BaseRef, r.prInfo.HeadSHA, path)
+}
+
 func (r *RunnerGitHub) Output(data *models.ReportData) error {
 	_, span := trace.StartSpan(r.Context, "Output")
 	defer span.End()
@@ -288,7 +466,34 @@ func (r *RunnerGitHub) Output(data *models.ReportData) error {
 	if err := r.outputReportJson(data); err != nil {
 		return err
 	}
-	if err := r.outputGitHubComment(data); err != nil {
+	if err := r.exportPolicyMatrixCSV(data); err != nil {
+		return err
+	}
+	if err := r.exportMetrics(data); err != nil {
+		return err
+	}
+
+	// Render the markdown using templates, the same content is archived and posted
+	commentData, err := r.commentReport(data)
+	if err != nil {
+		return err
+	}
+	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, commentData)
+	if err != nil {
+		logger.WithField("error", err).Error("Failed to render markdown template")
+		return err
+	}
+	logger.WithField("renderedMarkdown", renderedMarkdown).Debug("Rendered markdown")
+	writeStepSummary(renderedMarkdown)
+
+	if r.Options.EnableExportReport {
+		if err := r.writeReportMarkdown("report"+fileutil.OutputExt(".md", r.Options.CompressReportMarkdown), renderedMarkdown); err != nil {
+			return err
+		}
+	}
+	if r.options.GhCommit != "" && r.options.CommentTarget != COMMENT_TARGET_ISSUE {
+		logger.WithField("commit", r.options.GhCommit).Info("OutputGitHubComment: evaluating a commit, there is no pull request to comment on")
+	} else if err := r.outputGitHubComments(commentData, renderedMarkdown); err != nil {
 		return err
 	}
 	logger.Info("Output: done.")
@@ -311,8 +516,8 @@ func (r *RunnerGitHub) outputReportJson(data *models.ReportData) error {
 	if err != nil {
 		return err
 	}
-	filePath := filepath.Join(r.Options.OutputDir, "report.json")
-	if err := os.WriteFile(filePath, resultsJson, 0644); err != nil {
+	filePath := filepath.Join(r.Options.OutputDir, "report"+fileutil.OutputExt(".json", r.Options.CompressReport))
+	if err := fileutil.WriteOutputFile(filePath, resultsJson, 0644); err != nil {
 		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
 		return err
 	}
@@ -320,42 +525,198 @@ func (r *RunnerGitHub) outputReportJson(data *models.ReportData) error {
 	return nil
 }
 
-// Post comment to GitHub PR
-func (r *RunnerGitHub) outputGitHubComment(data *models.ReportData) error {
-	logger.Info("OutputGitHubComment: starting...")
+// commentNumber returns the number of the PR or issue the report comment is posted on, depending on the comment target
+func (r *RunnerGitHub) commentNumber() int {
+	if r.options.CommentTarget == COMMENT_TARGET_ISSUE {
+		return r.options.IssueNumber
+	}
+	return r.options.GhPrNumber
+}
 
-	// Render the markdown using templates
-	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, data)
-	if err != nil {
-		logger.WithField("error", err).Error("Failed to render markdown template")
-		return err
+// outputGitHubComments posts the combined report comment, or with --comment-per-environment one comment per environment
+// rendered from the report of that environment alone, so each can be gated independently
+func (r *RunnerGitHub) outputGitHubComments(data *models.ReportData, renderedMarkdown string) error {
+	if !r.options.CommentPerEnvironment {
+		return r.outputGitHubComment(data, renderedMarkdown, github.CommentMarker(""))
+	}
+	for _, env := range data.Environments {
+		envData := reportForEnvironment(data, env)
+		envMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, envData)
+		if err != nil {
+			return fmt.Errorf("failed to render the comment of environment %s: %w", env, err)
+		}
+		if err := r.outputGitHubComment(envData, envMarkdown, github.CommentMarker(env)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportForEnvironment returns a copy of the report restricted to one environment, the warnings and
+// policy sources are kept as is
+func reportForEnvironment(data *models.ReportData, env string) *models.ReportData {
+	envData := *data
+	envData.Environments = []string{env}
+	envData.BuildWarnings = filterEnvironment(data.BuildWarnings, env)
+	envData.ManifestChanges = filterEnvironment(data.ManifestChanges, env)
+	envData.FullManifests = filterEnvironment(data.FullManifests, env)
+	envData.PolicyEvaluation.EnvironmentSummary = filterEnvironment(data.PolicyEvaluation.EnvironmentSummary, env)
+	envData.PolicyEvaluation.PolicyMatrix = filterEnvironment(data.PolicyEvaluation.PolicyMatrix, env)
+	return &envData
+}
+
+// filterEnvironment returns the entry of env of a per-environment map, nil if the map is nil
+func filterEnvironment[V any](byEnv map[string]V, env string) map[string]V {
+	if byEnv == nil {
+		return nil
+	}
+	filtered := make(map[string]V, 1)
+	if value, ok := byEnv[env]; ok {
+		filtered[env] = value
+	}
+	return filtered
+}
+
+// Post comment to the GitHub PR, or the tracking issue with the issue comment target, found and updated by its marker
+func (r *RunnerGitHub) outputGitHubComment(data *models.ReportData, renderedMarkdown, marker string) error {
+	logger.WithField("marker", marker).Info("OutputGitHubComment: starting...")
+
+	if !r.options.CommentOnSuccess && isCleanPass(data) {
+		return r.deleteGitHubComment(marker)
 	}
-	logger.WithField("renderedMarkdown", renderedMarkdown).Debug("Rendered markdown")
 
 	// Add the comment marker
-	finalComment := template.ToolCommentSignature + "\n\n" + renderedMarkdown
+	finalComment := marker + "\n\n" + renderedMarkdown
 
 	// Check if there's an existing comment from this tool
-	existingComment, err := r.ghclient.FindToolComment(r.Context, r.options.GhRepo, r.options.GhPrNumber)
+	number := r.commentNumber()
+	existingComment, err := r.ghclient.FindToolComment(r.Context, r.options.GhRepo, number, marker)
 	if err != nil {
 		logger.WithField("error", err).Warn("Failed to find existing comment, will create new one")
 	}
 
+	var posted *models.Comment
 	if existingComment != nil {
 		// Update existing comment
-		if err := r.ghclient.UpdateComment(r.Context, r.options.GhRepo, existingComment.ID, finalComment); err != nil {
+		posted, err = r.ghclient.UpdateComment(r.Context, r.options.GhRepo, existingComment.ID, finalComment)
+		if err != nil {
 			logger.WithField("error", err).Error("Failed to update existing comment")
 			return err
 		}
-		logger.Info("Updated existing GitHub comment")
+		logger.WithField("url", posted.HTMLURL).Info("Updated existing GitHub comment")
 	} else {
 		// Create new comment
-		if _, err := r.ghclient.CreateComment(r.Context, r.options.GhRepo, r.options.GhPrNumber, finalComment); err != nil {
+		posted, err = r.ghclient.CreateComment(r.Context, r.options.GhRepo, number, finalComment)
+		if err != nil {
 			logger.WithField("error", err).Error("Failed to create new comment")
 			return err
 		}
-		logger.Info("Created new GitHub comment")
+		logger.WithField("url", posted.HTMLURL).Info("Created new GitHub comment")
+	}
+
+	if r.options.EmitCommentURL {
+		return emitCommentURL(posted.HTMLURL)
+	}
+	return nil
+}
+
+// emitCommentURL prints the URL of the posted comment for chaining CI steps,
+// and writes it as the posted-comment-url step output when run in GitHub Actions
+func emitCommentURL(url string) error {
+	fmt.Println(url)
+
+	outputPath := os.Getenv("GITHUB_OUTPUT")
+	if outputPath == "" {
+		return nil
+	}
+	f, err := os.OpenFile(outputPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open GITHUB_OUTPUT: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.WithField("error", err).Warn("Failed to close GITHUB_OUTPUT")
+		}
+	}()
+	if _, err := fmt.Fprintf(f, "%s=%s\n", GH_OUTPUT_POSTED_COMMENT_URL, url); err != nil {
+		return fmt.Errorf("failed to write GITHUB_OUTPUT: %w", err)
+	}
+	return nil
+}
+
+// writeStepSummary appends the rendered report to the job summary when run in GitHub Actions ($GITHUB_STEP_SUMMARY set),
+// so the check shows in the Actions run too. A report over the size limit is truncated at a line boundary.
+// Failures are only logged, the summary is a convenience next to the comment and the report files
+func writeStepSummary(renderedMarkdown string) {
+	summaryPath := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summaryPath == "" {
+		return
+	}
+	summary := renderedMarkdown
+	if len(summary) > githubStepSummaryMaxSize {
+		summary = summary[:githubStepSummaryMaxSize-len(GH_STEP_SUMMARY_TRUNCATED_NOTE)]
+		if i := strings.LastIndex(summary, "\n"); i >= 0 {
+			summary = summary[:i]
+		}
+		summary += GH_STEP_SUMMARY_TRUNCATED_NOTE
+	} else if !strings.HasSuffix(summary, "\n") {
+		summary += "\n"
+	}
+
+	f, err := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to open GITHUB_STEP_SUMMARY")
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.WithField("error", err).Warn("Failed to close GITHUB_STEP_SUMMARY")
+		}
+	}()
+	if _, err := f.WriteString(summary); err != nil {
+		logger.WithField("error", err).Warn("Failed to write GITHUB_STEP_SUMMARY")
+		return
 	}
+	logger.WithField("filePath", summaryPath).Info("Written report to the job summary")
+}
 
+// Delete the previous comment from this tool with the marker, if any, as it is outdated by a clean run
+func (r *RunnerGitHub) deleteGitHubComment(marker string) error {
+	logger.Info("OutputGitHubComment: no manifest changes and no failing policy, skipping the comment")
+
+	number := r.commentNumber()
+	existingComment, err := r.ghclient.FindToolComment(r.Context, r.options.GhRepo, number, marker)
+	if err != nil {
+		logger.WithField("error", err).Warn("Failed to find existing comment, it will not be deleted")
+		return nil
+	}
+	if existingComment == nil {
+		return nil
+	}
+	if !r.confirmer.Confirm(fmt.Sprintf("Delete the outdated comment %d on %s#%d?", existingComment.ID, r.options.GhRepo, number)) {
+		r.AddWarning("The outdated comment was not deleted as it was not confirmed, pass --assume-yes to delete it in non-interactive runs")
+		return nil
+	}
+	if err := r.ghclient.DeleteComment(r.Context, r.options.GhRepo, existingComment.ID); err != nil {
+		logger.WithField("error", err).Error("Failed to delete existing comment")
+		return err
+	}
+	logger.Info("Deleted outdated GitHub comment")
 	return nil
 }
+
+// isCleanPass reports whether the report has no manifest changes and no failing or errored policy in any environment
+func isCleanPass(data *models.ReportData) bool {
+	for _, diff := range data.ManifestChanges {
+		if diff.LineCount > 0 {
+			return false
+		}
+	}
+	for _, summary := range data.PolicyEvaluation.EnvironmentSummary {
+		// errored policies fail the blocking check, the comment explains why
+		if summary.PolicyCounts.TotalFailed > 0 || summary.PolicyCounts.TotalErrored > 0 {
+			return false
+		}
+	}
+	return true
+}
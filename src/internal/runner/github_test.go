@@ -0,0 +1,867 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/github"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
+)
+
+// fakeGitHubClient records the comments posted by the runner, other methods are not implemented
+type fakeGitHubClient struct {
+	github.GitHubClient
+
+	existing *models.Comment
+	markers  []string                   // markers comments were looked up by
+	byMarker map[string]*models.Comment // existing comments by marker, existing is returned for any marker if nil
+	created  []string
+	updated  []string
+	deleted  []int64
+	numbers  []int // PR or issue numbers comments were looked up or created on
+
+	checkoutDir string   // directory returned by SparseCheckoutAtCommit
+	checkedOut  []string // commits checked out
+
+	comments []*models.Comment // PR comments returned by GetComments
+	reviews  []*models.Comment // PR review bodies returned by GetReviews
+}
+
+func (f *fakeGitHubClient) GetComments(ctx context.Context, repo string, number int) ([]*models.Comment, error) {
+	return f.comments, nil
+}
+
+func (f *fakeGitHubClient) GetReviews(ctx context.Context, repo string, number int) ([]*models.Comment, error) {
+	return f.reviews, nil
+}
+
+func (f *fakeGitHubClient) FindToolComment(ctx context.Context, repo string, prNumber int, marker string) (*models.Comment, error) {
+	f.numbers = append(f.numbers, prNumber)
+	f.markers = append(f.markers, marker)
+	if f.byMarker != nil {
+		return f.byMarker[marker], nil
+	}
+	return f.existing, nil
+}
+
+func (f *fakeGitHubClient) CreateComment(ctx context.Context, repo string, number int, body string) (*models.Comment, error) {
+	f.numbers = append(f.numbers, number)
+	f.created = append(f.created, body)
+	return &models.Comment{ID: 1, Body: body, HTMLURL: fmt.Sprintf("https://github.com/%s/pull/%d#issuecomment-1", repo, number)}, nil
+}
+
+func (f *fakeGitHubClient) UpdateComment(ctx context.Context, repo string, commentID int64, body string) (*models.Comment, error) {
+	f.updated = append(f.updated, body)
+	return &models.Comment{ID: commentID, Body: body, HTMLURL: fmt.Sprintf("https://github.com/%s/pull/7#issuecomment-%d", repo, commentID)}, nil
+}
+
+func (f *fakeGitHubClient) DeleteComment(ctx context.Context, repo string, commentID int64) error {
+	f.deleted = append(f.deleted, commentID)
+	return nil
+}
+
+func (f *fakeGitHubClient) SparseCheckoutAtCommit(ctx context.Context, repo, sha, path string) (string, error) {
+	f.checkedOut = append(f.checkedOut, sha)
+	return f.checkoutDir, nil
+}
+
+// TestRunnerGitHub_FullManifests tests the full manifest section and its artifact fallback
+func TestRunnerGitHub_FullManifests(t *testing.T) {
+	defer func(prev int) { githubCommentMaxDiffLength = prev }(githubCommentMaxDiffLength)
+	githubCommentMaxDiffLength = 20
+
+	result := &models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg":  {Environment: "stg", AfterManifest: []byte("kind: Service")},
+			"prod": {Environment: "prod", AfterManifest: []byte("kind: Deployment\nspec:\n  replicas: 3")},
+		},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		opts := &Options{OutputDir: t.TempDir()}
+		r := &RunnerGitHub{RunnerBase: RunnerBase{Options: opts}, options: opts}
+
+		got, err := r.FullManifests(result)
+		if err != nil {
+			t.Fatalf("FullManifests() error = %v", err)
+		}
+		if got != nil {
+			t.Errorf("FullManifests() = %v, want nil when disabled", got)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		opts := &Options{
+			OutputDir:           t.TempDir(),
+			GhRepo:              "owner/repo",
+			GhPrNumber:          7,
+			Service:             "my-app",
+			IncludeFullManifest: true,
+		}
+		r := &RunnerGitHub{RunnerBase: RunnerBase{Options: opts}, options: opts, runId: 42}
+
+		got, err := r.FullManifests(result)
+		if err != nil {
+			t.Fatalf("FullManifests() error = %v", err)
+		}
+
+		stg := got["stg"]
+		if stg.ContentType != models.DiffContentTypeText || stg.Content != "kind: Service" {
+			t.Errorf("FullManifests()[stg] = %+v, want inline text", stg)
+		}
+
+		prod := got["prod"]
+		if prod.ContentType != models.DiffContentTypeGHArtifact {
+			t.Fatalf("FullManifests()[prod].ContentType = %q, want %q", prod.ContentType, models.DiffContentTypeGHArtifact)
+		}
+		if !strings.Contains(prod.Content, "/actions/runs/42") {
+			t.Errorf("FullManifests()[prod].Content = %q, want workflow run URL", prod.Content)
+		}
+		if prod.ContentGHFilePath == nil {
+			t.Fatal("FullManifests()[prod].ContentGHFilePath = nil, want artifact file path")
+		}
+		written, err := os.ReadFile(*prod.ContentGHFilePath)
+		if err != nil {
+			t.Fatalf("failed to read artifact file: %v", err)
+		}
+		if string(written) != string(result.EnvManifestBuild["prod"].AfterManifest) {
+			t.Errorf("artifact file content = %q, want the full manifest", written)
+		}
+	})
+}
+
+// TestIsCleanPass tests which reports skip the PR comment when --comment-on-success=false
+func TestIsCleanPass(t *testing.T) {
+	tests := []struct {
+		name    string
+		changes map[string]models.EnvironmentDiff
+		failed  int
+		errored int
+		want    bool
+	}{
+		{
+			name:    "pass without changes skips the comment",
+			changes: map[string]models.EnvironmentDiff{"stg": {}, "prod": {}},
+			want:    true,
+		},
+		{
+			name:    "pass with changes posts the comment",
+			changes: map[string]models.EnvironmentDiff{"stg": {}, "prod": {LineCount: 2, AddedLineCount: 1, DeletedLineCount: 1}},
+			want:    false,
+		},
+		{
+			name:    "failing policy posts the comment",
+			changes: map[string]models.EnvironmentDiff{"stg": {}, "prod": {}},
+			failed:  1,
+			want:    false,
+		},
+		{
+			name:    "errored policy posts the comment",
+			changes: map[string]models.EnvironmentDiff{"stg": {}, "prod": {}},
+			errored: 1,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &models.ReportData{
+				ManifestChanges: tt.changes,
+				PolicyEvaluation: models.PolicyEvaluation{
+					EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{
+						"stg":  {},
+						"prod": {PolicyCounts: models.PolicyCounts{TotalFailed: tt.failed, TotalErrored: tt.errored}},
+					},
+				},
+			}
+			if got := isCleanPass(data); got != tt.want {
+				t.Errorf("isCleanPass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_Output_ReportMarkdown tests that report.md archives the posted comment body
+func TestRunnerGitHub_Output_ReportMarkdown(t *testing.T) {
+	tests := []struct {
+		name               string
+		enableExportReport bool
+		wantReport         bool
+	}{
+		{name: "export enabled", enableExportReport: true, wantReport: true},
+		{name: "export disabled", enableExportReport: false, wantReport: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				OutputDir:          t.TempDir(),
+				TemplatesPath:      "../../templates",
+				EnableExportReport: tt.enableExportReport,
+				CommentOnSuccess:   true,
+				GhRepo:             "owner/repo",
+				GhPrNumber:         7,
+			}
+			client := &fakeGitHubClient{}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts, Renderer: template.NewRenderer()},
+				options:    opts,
+				ghclient:   client,
+			}
+
+			if err := r.Output(newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+			if len(client.created) != 1 {
+				t.Fatalf("Output() posted %d comments, want 1", len(client.created))
+			}
+
+			report, err := os.ReadFile(filepath.Join(opts.OutputDir, "report.md"))
+			if !tt.wantReport {
+				if err == nil {
+					t.Error("Output() wrote report.md, want none when export is disabled")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Output() did not write report.md: %v", err)
+			}
+			posted := strings.TrimPrefix(client.created[0], template.ToolCommentSignature+"\n\n")
+			if string(report) != posted {
+				t.Errorf("report.md = %q, want the posted comment body %q", report, posted)
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_DiffManifests_MaxInlineDiffLines tests that a diff over the line limit is written as an artifact
+// linked in the comment, a smaller one is kept inline
+func TestRunnerGitHub_DiffManifests_MaxInlineDiffLines(t *testing.T) {
+	const before = "kind: Deployment\nspec:\n  replicas: 2\n"
+	const after = "kind: Deployment\nspec:\n  replicas: 3\n"
+	result := &models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {Environment: "stg", BeforeManifest: []byte(before), AfterManifest: []byte(after)},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		maxLines     int
+		wantArtifact bool
+	}{
+		{name: "no limit", maxLines: 0},
+		{name: "within the limit", maxLines: 100},
+		{name: "over the limit", maxLines: 3, wantArtifact: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				OutputDir:          t.TempDir(),
+				Service:            "my-app",
+				GhRepo:             "owner/repo",
+				GhPrNumber:         7,
+				MaxInlineDiffLines: tt.maxLines,
+			}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts, Differ: diff.NewDiffer()},
+				options:    opts,
+				runId:      42,
+			}
+
+			diffs, err := r.DiffManifests(result)
+			if err != nil {
+				t.Fatalf("DiffManifests() error = %v", err)
+			}
+			stg := diffs["stg"]
+			if !tt.wantArtifact {
+				if stg.ContentType != models.DiffContentTypeText || !strings.Contains(stg.Content, "+  replicas: 3") {
+					t.Errorf("DiffManifests() stg = %s %q, want the diff inline", stg.ContentType, stg.Content)
+				}
+				return
+			}
+			if stg.ContentType != models.DiffContentTypeGHArtifact || stg.Content != "https://github.com/owner/repo/actions/runs/42" {
+				t.Errorf("DiffManifests() stg = %s %q, want the workflow run URL", stg.ContentType, stg.Content)
+			}
+			if stg.ContentGHFilePath == nil {
+				t.Fatal("DiffManifests() stg ContentGHFilePath = nil, want the artifact file path")
+			}
+			written, err := os.ReadFile(*stg.ContentGHFilePath)
+			if err != nil {
+				t.Fatalf("failed to read artifact file: %v", err)
+			}
+			if !strings.Contains(string(written), "+  replicas: 3") {
+				t.Errorf("artifact file = %q, want the full diff", written)
+			}
+			if warnings := r.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "exceeds the inline limit of 3 lines") {
+				t.Errorf("Warnings() = %v, want the inline limit warning", warnings)
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_Warnings tests that a skipped environment and a truncated diff are surfaced in the notes footer
+func TestRunnerGitHub_Warnings(t *testing.T) {
+	defer func(prev int) { githubCommentMaxDiffLength = prev }(githubCommentMaxDiffLength)
+	githubCommentMaxDiffLength = 20
+
+	beforeDir := newTestServiceDir(t, "stg")
+	afterDir := newTestServiceDir(t, "stg")
+	opts := &Options{
+		Environments:  []string{"stg", "prod"},
+		OutputDir:     t.TempDir(),
+		TemplatesPath: "../../templates",
+		GhRepo:        "owner/repo",
+		GhPrNumber:    7,
+	}
+	executor := newFakeKustomizeExecutor(beforeDir, "kind: Deployment\nspec:\n  replicas: 2\n", "kind: Deployment\nspec:\n  replicas: 3\n")
+	r := &RunnerGitHub{
+		RunnerBase: RunnerBase{
+			Context:  context.Background(),
+			Options:  opts,
+			Builder:  kustomize.NewBuilderWithExecutor(executor),
+			Differ:   diff.NewDiffer(),
+			Renderer: template.NewRenderer(),
+		},
+		options: opts,
+	}
+
+	rs, err := r.BuildManifests(beforeDir, afterDir)
+	if err != nil {
+		t.Fatalf("BuildManifests() error = %v", err)
+	}
+	if prod := rs.EnvManifestBuild["prod"]; len(prod.BeforeManifest) != 0 || len(prod.AfterManifest) != 0 {
+		t.Errorf("BuildManifests() skipped environment should build as empty, got %+v", prod)
+	}
+	for _, call := range executor.Calls() {
+		if strings.HasSuffix(call.String(), filepath.Join("overlays", "prod")) {
+			t.Errorf("BuildManifests() should not build the missing overlay, ran %q", call.String())
+		}
+	}
+	diffs, err := r.DiffManifests(rs)
+	if err != nil {
+		t.Fatalf("DiffManifests() error = %v", err)
+	}
+	if diffs["stg"].ContentType != models.DiffContentTypeGHArtifact {
+		t.Errorf("DiffManifests() stg ContentType = %q, want %q", diffs["stg"].ContentType, models.DiffContentTypeGHArtifact)
+	}
+
+	data := newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	data.ManifestChanges = diffs
+	data.Warnings = r.Warnings()
+	got, err := r.Renderer.RenderWithTemplates(opts.TemplatesPath, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	footer := got[strings.Index(got, "## ⚠️ Notes"):]
+	for _, want := range []string{
+		"* Environment prod has no overlay on the base nor the head, skipped",
+		"* Environment stg: the diff (",
+		"exceeds the comment limit of 20 characters, it is uploaded as a workflow artifact",
+	} {
+		if !strings.Contains(footer, want) {
+			t.Errorf("RenderWithTemplates() notes footer missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+// TestRunnerBase_AddWarning tests that warnings accumulate in order and are not rendered when empty
+func TestRunnerBase_AddWarning(t *testing.T) {
+	r := &RunnerBase{}
+	if r.Warnings() != nil {
+		t.Errorf("Warnings() = %v, want nil", r.Warnings())
+	}
+	r.AddWarning("first %s", "warning")
+	r.AddWarning("second warning")
+	if got := r.Warnings(); len(got) != 2 || got[0] != "first warning" || got[1] != "second warning" {
+		t.Errorf("Warnings() = %v, want [first warning second warning]", got)
+	}
+
+	got, err := template.NewRenderer().RenderWithTemplates("../../templates", newTestReportData(time.Now()))
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if strings.Contains(got, "Notes") {
+		t.Errorf("RenderWithTemplates() should not render notes without warnings:\n%s", got)
+	}
+}
+
+// TestRunnerGitHub_Output_EmitCommentURL tests that the URL of the created or updated comment is written to the step outputs
+func TestRunnerGitHub_Output_EmitCommentURL(t *testing.T) {
+	tests := []struct {
+		name           string
+		existing       *models.Comment
+		emitCommentURL bool
+		wantOutput     string
+	}{
+		{
+			name:           "created comment",
+			emitCommentURL: true,
+			wantOutput:     "posted-comment-url=https://github.com/owner/repo/pull/7#issuecomment-1\n",
+		},
+		{
+			name:           "updated comment",
+			existing:       &models.Comment{ID: 42},
+			emitCommentURL: true,
+			wantOutput:     "posted-comment-url=https://github.com/owner/repo/pull/7#issuecomment-42\n",
+		},
+		{
+			name:       "disabled",
+			wantOutput: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "github_output")
+			t.Setenv("GITHUB_OUTPUT", outputPath)
+			opts := &Options{
+				TemplatesPath:    "../../templates",
+				CommentOnSuccess: true,
+				EmitCommentURL:   tt.emitCommentURL,
+				GhRepo:           "owner/repo",
+				GhPrNumber:       7,
+			}
+			client := &fakeGitHubClient{existing: tt.existing}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts, Renderer: template.NewRenderer()},
+				options:    opts,
+				ghclient:   client,
+			}
+
+			if err := r.Output(newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+			got, err := os.ReadFile(outputPath)
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatalf("failed to read GITHUB_OUTPUT: %v", err)
+			}
+			if string(got) != tt.wantOutput {
+				t.Errorf("GITHUB_OUTPUT = %q, want %q", got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_Output_CommentTarget tests that the report comment is looked up, created or deleted
+// on the PR or on the configured tracking issue
+func TestRunnerGitHub_Output_CommentTarget(t *testing.T) {
+	tests := []struct {
+		name             string
+		commentTarget    string
+		commentOnSuccess bool
+		existing         *models.Comment
+		wantNumbers      []int
+		wantCreated      int
+		wantUpdated      int
+		wantDeleted      int
+	}{
+		{
+			name:             "pr",
+			commentTarget:    COMMENT_TARGET_PR,
+			commentOnSuccess: true,
+			wantNumbers:      []int{7, 7},
+			wantCreated:      1,
+		},
+		{
+			name:             "issue",
+			commentTarget:    COMMENT_TARGET_ISSUE,
+			commentOnSuccess: true,
+			wantNumbers:      []int{42, 42},
+			wantCreated:      1,
+		},
+		{
+			name:             "issue with an existing comment",
+			commentTarget:    COMMENT_TARGET_ISSUE,
+			commentOnSuccess: true,
+			existing:         &models.Comment{ID: 3},
+			wantNumbers:      []int{42},
+			wantUpdated:      1,
+		},
+		{
+			name:          "issue comment deleted on a clean pass",
+			commentTarget: COMMENT_TARGET_ISSUE,
+			existing:      &models.Comment{ID: 3},
+			wantNumbers:   []int{42},
+			wantDeleted:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_STEP_SUMMARY", "")
+			opts := &Options{
+				TemplatesPath:    "../../templates",
+				CommentOnSuccess: tt.commentOnSuccess,
+				AssumeYes:        true,
+				GhRepo:           "owner/repo",
+				GhPrNumber:       7,
+				CommentTarget:    tt.commentTarget,
+				IssueNumber:      42,
+			}
+			client := &fakeGitHubClient{existing: tt.existing}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts, Renderer: template.NewRenderer()},
+				options:    opts,
+				ghclient:   client,
+				confirmer:  NewConfirmer(opts.AssumeYes),
+			}
+
+			if err := r.Output(newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+			if !reflect.DeepEqual(client.numbers, tt.wantNumbers) {
+				t.Errorf("Output() commented on %v, want %v", client.numbers, tt.wantNumbers)
+			}
+			if len(client.created) != tt.wantCreated || len(client.updated) != tt.wantUpdated || len(client.deleted) != tt.wantDeleted {
+				t.Errorf("Output() created %d, updated %d, deleted %d comments, want %d, %d, %d",
+					len(client.created), len(client.updated), len(client.deleted), tt.wantCreated, tt.wantUpdated, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_Output_CommentPerEnvironment tests that each environment is found and created or updated by its own marker
+func TestRunnerGitHub_Output_CommentPerEnvironment(t *testing.T) {
+	stgMarker, prodMarker := github.CommentMarker("stg"), github.CommentMarker("prod")
+
+	tests := []struct {
+		name                  string
+		commentPerEnvironment bool
+		byMarker              map[string]*models.Comment
+		wantMarkers           []string
+		wantCreated           []string // marker of each created comment
+		wantUpdated           []string // marker of each updated comment
+	}{
+		{
+			name:        "combined comment",
+			wantMarkers: []string{github.GH_COMMENT_MARKER},
+			wantCreated: []string{github.GH_COMMENT_MARKER},
+		},
+		{
+			name:                  "one comment per environment",
+			commentPerEnvironment: true,
+			wantMarkers:           []string{stgMarker, prodMarker},
+			wantCreated:           []string{stgMarker, prodMarker},
+		},
+		{
+			name:                  "existing comment of one environment",
+			commentPerEnvironment: true,
+			byMarker:              map[string]*models.Comment{prodMarker: {ID: 3}},
+			wantMarkers:           []string{stgMarker, prodMarker},
+			wantCreated:           []string{stgMarker},
+			wantUpdated:           []string{prodMarker},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GITHUB_STEP_SUMMARY", "")
+			opts := &Options{
+				TemplatesPath:         "../../templates",
+				CommentOnSuccess:      true,
+				GhRepo:                "owner/repo",
+				GhPrNumber:            7,
+				CommentTarget:         COMMENT_TARGET_PR,
+				CommentPerEnvironment: tt.commentPerEnvironment,
+			}
+			client := &fakeGitHubClient{byMarker: tt.byMarker}
+			if client.byMarker == nil {
+				client.byMarker = map[string]*models.Comment{}
+			}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts, Renderer: template.NewRenderer()},
+				options:    opts,
+				ghclient:   client,
+				confirmer:  NewConfirmer(opts.AssumeYes),
+			}
+
+			data := newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+			data.Environments = []string{"stg", "prod"}
+			if err := r.Output(data); err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+			if !reflect.DeepEqual(client.markers, tt.wantMarkers) {
+				t.Errorf("Output() looked up comments by %v, want %v", client.markers, tt.wantMarkers)
+			}
+			for _, posted := range []struct {
+				kind   string
+				bodies []string
+				want   []string
+			}{
+				{"created", client.created, tt.wantCreated},
+				{"updated", client.updated, tt.wantUpdated},
+			} {
+				if len(posted.bodies) != len(posted.want) {
+					t.Fatalf("Output() %s %d comments, want %d", posted.kind, len(posted.bodies), len(posted.want))
+				}
+				for i, body := range posted.bodies {
+					if !strings.HasPrefix(body, posted.want[i]+"\n") {
+						t.Errorf("Output() %s comment %d starts with %q, want the marker %q", posted.kind, i, strings.SplitN(body, "\n", 2)[0], posted.want[i])
+					}
+				}
+			}
+			if tt.commentPerEnvironment {
+				// each environment comment only reports its environment
+				for i, env := range []string{"stg", "prod"} {
+					other := map[string]string{"stg": "prod", "prod": "stg"}[env]
+					body := append(append([]string{}, client.created...), client.updated...)[i]
+					if !strings.Contains(body, "`"+env+"`") || strings.Contains(body, "`"+other+"`") {
+						t.Errorf("Output() comment of %s does not report only its environment:\n%s", env, body)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestRunnerGitHub_Output_StepSummary tests that the rendered report is appended to the job summary when run in GitHub Actions
+func TestRunnerGitHub_Output_StepSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		setEnv     bool
+		wantReport bool
+	}{
+		{name: "in GitHub Actions", setEnv: true, wantReport: true},
+		{name: "outside GitHub Actions", setEnv: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summaryPath := filepath.Join(t.TempDir(), "step_summary")
+			if err := os.WriteFile(summaryPath, []byte("## Previous step\n"), 0644); err != nil {
+				t.Fatalf("failed to write summary: %v", err)
+			}
+			if tt.setEnv {
+				t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+			} else {
+				t.Setenv("GITHUB_STEP_SUMMARY", "")
+			}
+			opts := &Options{
+				TemplatesPath:    "../../templates",
+				CommentOnSuccess: true,
+				GhRepo:           "owner/repo",
+				GhPrNumber:       7,
+			}
+			r := &RunnerGitHub{
+				RunnerBase: RunnerBase{Context: context.Background(), Options: opts, Renderer: template.NewRenderer()},
+				options:    opts,
+				ghclient:   &fakeGitHubClient{},
+			}
+
+			if err := r.Output(newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))); err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+			got, err := os.ReadFile(summaryPath)
+			if err != nil {
+				t.Fatalf("failed to read summary: %v", err)
+			}
+			if !strings.HasPrefix(string(got), "## Previous step\n") {
+				t.Errorf("job summary = %q, want the previous steps kept", got)
+			}
+			if gotReport := strings.Contains(string(got), "my-app"); gotReport != tt.wantReport {
+				t.Errorf("job summary contains the report = %v, want %v:\n%s", gotReport, tt.wantReport, got)
+			}
+		})
+	}
+}
+
+// TestWriteStepSummary_Truncated tests that a report over the size limit is truncated at a line boundary
+func TestWriteStepSummary_Truncated(t *testing.T) {
+	defer func(prev int) { githubStepSummaryMaxSize = prev }(githubStepSummaryMaxSize)
+	githubStepSummaryMaxSize = 100
+	summaryPath := filepath.Join(t.TempDir(), "step_summary")
+	t.Setenv("GITHUB_STEP_SUMMARY", summaryPath)
+
+	writeStepSummary(strings.Repeat("0123456789\n", 20))
+
+	got, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatalf("failed to read summary: %v", err)
+	}
+	if len(got) > githubStepSummaryMaxSize {
+		t.Errorf("job summary has %d bytes, want at most %d", len(got), githubStepSummaryMaxSize)
+	}
+	if !strings.HasSuffix(string(got), "0123456789"+GH_STEP_SUMMARY_TRUNCATED_NOTE) {
+		t.Errorf("job summary = %q, want whole lines then the truncation note", got)
+	}
+}
+
+// TestRunnerGitHub_Process_Commit tests that a commit-based run evaluates the checked out commit,
+// writes the report and posts nothing, without fetching any PR
+func TestRunnerGitHub_Process_Commit(t *testing.T) {
+	const sha = "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+	const complianceConfig = `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2000-01-01T00:00:00Z
+`
+	t.Setenv("GITHUB_STEP_SUMMARY", "")
+	checkoutDir := t.TempDir()
+	serviceDir := newTestServiceDir(t, "stg")
+	if err := os.MkdirAll(filepath.Join(checkoutDir, "services"), 0755); err != nil {
+		t.Fatalf("failed to create services dir: %v", err)
+	}
+	if err := os.Rename(serviceDir, filepath.Join(checkoutDir, "services", "my-app")); err != nil {
+		t.Fatalf("failed to move service dir: %v", err)
+	}
+
+	executor := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if name == "conftest" {
+				return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`)},
+					fmt.Errorf("exit status 1")
+			}
+			return &command.Result{Stdout: []byte("kind: Deployment\nmetadata:\n  name: my-app\n")}, nil
+		},
+	}
+	evaluator := policy.NewPolicyEvaluator(newTestPoliciesDir(t, complianceConfig, "ha"))
+	evaluator.SetExecutor(executor)
+	if err := evaluator.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	opts := &Options{
+		Service:            "my-app",
+		Environments:       []string{"stg"},
+		TemplatesPath:      "../../templates",
+		OutputDir:          t.TempDir(),
+		EnableExportReport: true,
+		CommentOnSuccess:   true,
+		SkipUnchanged:      true,
+		GhRepo:             "owner/repo",
+		GhCommit:           sha,
+		ManifestsPath:      "services",
+	}
+	client := &fakeGitHubClient{checkoutDir: checkoutDir}
+	r, err := NewRunnerGitHub(context.Background(), opts, client,
+		kustomize.NewBuilderWithExecutor(executor), diff.NewDiffer(), evaluator, template.NewRenderer())
+	if err != nil {
+		t.Fatalf("NewRunnerGitHub() error = %v", err)
+	}
+
+	if err := r.Process(); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if len(client.checkedOut) != 1 || client.checkedOut[0] != sha {
+		t.Errorf("Process() checked out %v, want [%s]", client.checkedOut, sha)
+	}
+	if len(client.created)+len(client.updated)+len(client.deleted) != 0 {
+		t.Errorf("Process() posted comments (created %d, updated %d, deleted %d), want none",
+			len(client.created), len(client.updated), len(client.deleted))
+	}
+	content, err := os.ReadFile(filepath.Join(opts.OutputDir, "report.json"))
+	if err != nil {
+		t.Fatalf("failed to read report.json: %v", err)
+	}
+	var report models.ReportData
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("failed to parse report.json: %v", err)
+	}
+	if report.HeadCommit != sha || report.BaseCommit != sha {
+		t.Errorf("report.json commits = %s..%s, want %s on both sides", report.BaseCommit, report.HeadCommit, sha)
+	}
+	if report.ManifestsUnchanged {
+		t.Error("report.json ManifestsUnchanged = true, want the commit evaluated despite --skip-unchanged")
+	}
+	if got := report.PolicyEvaluation.EnvironmentSummary["stg"].PolicyCounts.BlockingFailedCount; got != 1 {
+		t.Errorf("report.json stg BlockingFailedCount = %d, want 1", got)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "report.md")); err != nil {
+		t.Errorf("Process() did not write report.md: %v", err)
+	}
+}
+
+// TestRunnerGitHub_overrideComments tests that an override command in the body of a PR review is honored,
+// only on an approving review with OverrideApprovedReviewsOnly
+func TestRunnerGitHub_overrideComments(t *testing.T) {
+	const complianceConfig = `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2000-01-01T00:00:00Z
+      override:
+        comment: /sp-override-ha
+`
+	evaluator := policy.NewPolicyEvaluator(newTestPoliciesDir(t, complianceConfig, "ha"))
+	evaluator.SetExecutor(&testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","successes":1}]`)}, nil
+		},
+	})
+	if err := evaluator.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+
+	tests := []struct {
+		name                string
+		reviewState         string
+		approvedReviewsOnly bool
+		wantLevel           string
+	}{
+		{
+			name:        "approved review",
+			reviewState: github.REVIEW_STATE_APPROVED,
+			wantLevel:   policy.POLICY_LEVEL_OVERRIDE,
+		},
+		{
+			name:                "approved review with approved reviews only",
+			reviewState:         github.REVIEW_STATE_APPROVED,
+			approvedReviewsOnly: true,
+			wantLevel:           policy.POLICY_LEVEL_OVERRIDE,
+		},
+		{
+			name:        "commented review",
+			reviewState: "COMMENTED",
+			wantLevel:   policy.POLICY_LEVEL_OVERRIDE,
+		},
+		{
+			name:                "commented review with approved reviews only",
+			reviewState:         "COMMENTED",
+			approvedReviewsOnly: true,
+			wantLevel:           policy.POLICY_LEVEL_BLOCK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{GhRepo: "owner/repo", GhPrNumber: 7, OverrideApprovedReviewsOnly: tt.approvedReviewsOnly}
+			client := &fakeGitHubClient{
+				comments: []*models.Comment{{ID: 1, Body: "LGTM", User: "alice"}},
+				reviews:  []*models.Comment{{ID: 2, Body: "/sp-override-ha", User: "bob", ReviewState: tt.reviewState}},
+			}
+			r := &RunnerGitHub{RunnerBase: RunnerBase{Context: context.Background(), Options: opts}, options: opts, ghclient: client}
+
+			comments, err := r.overrideComments()
+			if err != nil {
+				t.Fatalf("overrideComments() error = %v", err)
+			}
+			levels, err := evaluator.DetermineEnforcementLevel(comments, "")
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.wantLevel {
+				t.Errorf("enforcement level of ha = %q, want %q", levels["ha"], tt.wantLevel)
+			}
+		})
+	}
+}
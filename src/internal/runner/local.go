@@ -1,14 +1,17 @@
 package runner
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
@@ -18,6 +21,9 @@ import (
 
 type RunnerLocal struct {
 	RunnerBase
+
+	// report of the last Process, nil before
+	lastReport *models.ReportData
 }
 
 // make RunnerLocal implement RunnerInterface
@@ -73,24 +79,43 @@ func (r *RunnerLocal) Process() error {
 	}
 	logger.WithField("results", diffs).Debug("Diffed Manifests")
 
-	_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
-	policyEval, err := r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *rs, []string{})
+	fullManifests, err := r.FullManifests(rs)
 	if err != nil {
-		evalSpan.End()
 		return err
 	}
-	evalSpan.End()
-	logger.WithField("results", policyEval).Debug("Evaluated Policies")
+
+	manifestsUnchanged := r.evaluationSkipped(rs)
+	policyEval := &models.PolicyEvaluation{}
+	if !manifestsUnchanged {
+		toEvaluate, unchangedEnvs := r.changedEnvironments(rs)
+		_, evalSpan := trace.StartSpan(ctx, "EvaluatePolicies")
+		policyEval, err = r.Evaluator.GeneratePolicyEvalResultForManifests(ctx, *toEvaluate, []*models.Comment{})
+		if err != nil {
+			evalSpan.End()
+			return err
+		}
+		evalSpan.End()
+		for _, env := range unchangedEnvs {
+			policyEval.EnvironmentSummary[env] = models.EnvironmentSummaryEnv{Unchanged: true}
+		}
+		logger.WithField("results", policyEval).Debug("Evaluated Policies")
+	}
 
 	reportData := models.ReportData{
 		Service:          r.Options.Service,
 		Timestamp:        time.Now(),
 		BaseCommit:       "base",
 		HeadCommit:       "head",
-		Environments:     r.Options.Environments,
+		Environments:     r.Environments(),
+		BuildWarnings:    r.BuildWarnings(rs),
+		Warnings:         r.Warnings(),
 		ManifestChanges:  diffs,
 		PolicyEvaluation: *policyEval,
+		FullManifests:    fullManifests,
+
+		ManifestsUnchanged: manifestsUnchanged,
 	}
+	r.lastReport = &reportData
 
 	if err := r.Output(&reportData); err != nil {
 		return err
@@ -98,6 +123,34 @@ func (r *RunnerLocal) Process() error {
 	return nil
 }
 
+// changedEnvironments returns the build of the environments to evaluate and, with LcSkipEvalUnchanged, the sorted
+// environments left out as their base and head manifests are identical
+func (r *RunnerLocal) changedEnvironments(result *models.BuildManifestResult) (*models.BuildManifestResult, []string) {
+	if !r.Options.LcSkipEvalUnchanged {
+		return result, nil
+	}
+	changed := &models.BuildManifestResult{
+		EnvManifestBuild: make(map[string]models.BuildEnvManifestResult),
+		Environments:     result.Environments,
+	}
+	var unchanged []string
+	for env, envResult := range result.EnvManifestBuild {
+		if bytes.Equal(envResult.BeforeManifest, envResult.AfterManifest) {
+			logger.WithField("environment", env).Info("Base and head manifests are identical, skipping the policy evaluation")
+			unchanged = append(unchanged, env)
+			continue
+		}
+		changed.EnvManifestBuild[env] = envResult
+	}
+	sort.Strings(unchanged)
+	return changed, unchanged
+}
+
+// LastReport returns the report data of the last Process, nil if not processed yet
+func (r *RunnerLocal) LastReport() *models.ReportData {
+	return r.lastReport
+}
+
 func (r *RunnerLocal) Output(data *models.ReportData) error {
 	_, span := trace.StartSpan(r.Context, "Output")
 	defer span.End()
@@ -106,6 +159,12 @@ func (r *RunnerLocal) Output(data *models.ReportData) error {
 	if err := r.outputReportJson(data); err != nil {
 		return err
 	}
+	if err := r.exportPolicyMatrixCSV(data); err != nil {
+		return err
+	}
+	if err := r.exportMetrics(data); err != nil {
+		return err
+	}
 	if err := r.outputReportMarkdown(data); err != nil {
 		return err
 	}
@@ -129,13 +188,14 @@ func (r *RunnerLocal) outputReportJson(data *models.ReportData) error {
 	if err != nil {
 		return err
 	}
-	filePath := filepath.Join(r.Options.OutputDir, "report.json")
-	if err := os.WriteFile(filePath, resultsJson, 0644); err != nil {
+	ext := fileutil.OutputExt(".json", r.Options.CompressReport)
+	filePath := filepath.Join(r.Options.OutputDir, r.reportFileName(data, ext))
+	if err := fileutil.WriteOutputFile(filePath, resultsJson, 0644); err != nil {
 		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write report data to file")
 		return err
 	}
 	logger.WithField("filePath", filePath).Info("Written report data to file")
-	return nil
+	return r.pruneReports(ext)
 }
 
 // Exporting report markdown file to output directory
@@ -143,19 +203,51 @@ func (r *RunnerLocal) outputReportMarkdown(data *models.ReportData) error {
 	logger.Info("OutputMarkdown: starting...")
 
 	// Render the markdown using templates
-	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, data)
+	commentData, err := r.commentReport(data)
+	if err != nil {
+		return err
+	}
+	renderedMarkdown, err := r.Renderer.RenderWithTemplates(r.Options.TemplatesPath, commentData)
 	if err != nil {
 		logger.WithField("error", err).Error("Failed to render markdown template")
 		return err
 	}
+	writeStepSummary(renderedMarkdown)
 
-	// Write the rendered markdown to file
-	filePath := filepath.Join(r.Options.OutputDir, "report.md")
-	if err := os.WriteFile(filePath, []byte(renderedMarkdown), 0644); err != nil {
-		logger.WithField("filePath", filePath).WithField("error", err).Error("Failed to write markdown report to file")
+	ext := fileutil.OutputExt(".md", r.Options.CompressReportMarkdown)
+	if err := r.writeReportMarkdown(r.reportFileName(data, ext), renderedMarkdown); err != nil {
 		return err
 	}
+	return r.pruneReports(ext)
+}
 
-	logger.WithField("filePath", filePath).Info("Written markdown report to file")
+// reportFileName returns "report<ext>", or "report-<UTC timestamp><ext>" if timestamped reports are enabled,
+// e.g. report-20251001T120000.123456789Z.json
+func (r *RunnerLocal) reportFileName(data *models.ReportData, ext string) string {
+	if !r.Options.LcTimestampedReports {
+		return "report" + ext
+	}
+	return "report-" + data.Timestamp.UTC().Format(REPORT_TIMESTAMP_LAYOUT) + ext
+}
+
+// pruneReports removes the oldest timestamped reports with the given extension, keeping the last LcMaxReports
+// REPORT_TIMESTAMP_LAYOUT timestamps are fixed width, so file names are sorted to find the oldest ones
+func (r *RunnerLocal) pruneReports(ext string) error {
+	if !r.Options.LcTimestampedReports {
+		return nil
+	}
+	matches, err := filepath.Glob(filepath.Join(r.Options.OutputDir, "report-*"+ext))
+	if err != nil {
+		return fmt.Errorf("failed to list reports: %w", err)
+	}
+	sort.Strings(matches)
+
+	for len(matches) > r.Options.LcMaxReports {
+		if err := os.Remove(matches[0]); err != nil {
+			return fmt.Errorf("failed to prune report: %w", err)
+		}
+		logger.WithField("filePath", matches[0]).Info("Pruned old report")
+		matches = matches[1:]
+	}
 	return nil
 }
@@ -0,0 +1,619 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/diff"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/kustomize"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
+)
+
+// newTestReportData returns a minimal report renderable with the default templates
+func newTestReportData(timestamp time.Time) *models.ReportData {
+	return &models.ReportData{
+		Service:      "my-app",
+		Timestamp:    timestamp,
+		Environments: []string{"stg", "prod"},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{"stg": {}, "prod": {}},
+			PolicyMatrix:       map[string]models.PolicyMatrix{"stg": {}, "prod": {}},
+		},
+	}
+}
+
+// listFiles returns the sorted file names in dir
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestRunnerLocal_Output_TimestampedReports tests report file naming and pruning
+func TestRunnerLocal_Output_TimestampedReports(t *testing.T) {
+	start := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		timestamped bool
+		maxReports  int
+		runs        int
+		interval    time.Duration // between the runs, a minute if zero
+		wantFiles   []string
+	}{
+		{
+			name:      "default overwrites report",
+			runs:      3,
+			wantFiles: []string{"report.json", "report.md"},
+		},
+		{
+			name:        "timestamped under the limit",
+			timestamped: true,
+			maxReports:  5,
+			runs:        2,
+			wantFiles: []string{
+				"report-20251001T120000.000000000Z.json", "report-20251001T120000.000000000Z.md",
+				"report-20251001T120100.000000000Z.json", "report-20251001T120100.000000000Z.md",
+			},
+		},
+		{
+			name:        "timestamped runs in the same second",
+			timestamped: true,
+			maxReports:  2,
+			runs:        3,
+			interval:    250 * time.Millisecond,
+			wantFiles: []string{
+				"report-20251001T120000.250000000Z.json", "report-20251001T120000.250000000Z.md",
+				"report-20251001T120000.500000000Z.json", "report-20251001T120000.500000000Z.md",
+			},
+		},
+		{
+			name:        "timestamped pruned to the limit",
+			timestamped: true,
+			maxReports:  2,
+			runs:        4,
+			wantFiles: []string{
+				"report-20251001T120200.000000000Z.json", "report-20251001T120200.000000000Z.md",
+				"report-20251001T120300.000000000Z.json", "report-20251001T120300.000000000Z.md",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+			opts := &Options{
+				OutputDir:            outputDir,
+				TemplatesPath:        filepath.Join("..", "..", "templates"),
+				EnableExportReport:   true,
+				LcTimestampedReports: tt.timestamped,
+				LcMaxReports:         tt.maxReports,
+			}
+			r := &RunnerLocal{RunnerBase: RunnerBase{
+				Context:  context.Background(),
+				Options:  opts,
+				Renderer: template.NewRenderer(),
+			}}
+
+			interval := tt.interval
+			if interval == 0 {
+				interval = time.Minute
+			}
+			for i := 0; i < tt.runs; i++ {
+				// non-UTC timestamps are named in UTC
+				ts := start.Add(time.Duration(i) * interval).In(time.FixedZone("ICT", 7*60*60))
+				if err := r.Output(newTestReportData(ts)); err != nil {
+					t.Fatalf("Output() error = %v", err)
+				}
+			}
+
+			got := listFiles(t, outputDir)
+			if len(got) != len(tt.wantFiles) {
+				t.Fatalf("output files = %v, want %v", got, tt.wantFiles)
+			}
+			for i := range got {
+				if got[i] != tt.wantFiles[i] {
+					t.Errorf("output files = %v, want %v", got, tt.wantFiles)
+					break
+				}
+			}
+		})
+	}
+}
+
+// TestRunnerLocal_Process_SkipUnchanged tests that identical base and head manifests short-circuit the policy evaluation
+func TestRunnerLocal_Process_SkipUnchanged(t *testing.T) {
+	const manifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 2
+`
+	const complianceConfig = `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`
+
+	tests := []struct {
+		name          string
+		skipUnchanged bool
+		after         string
+		wantSkipped   bool
+	}{
+		{
+			name:          "identical manifests are short-circuited",
+			skipUnchanged: true,
+			after:         manifest,
+			wantSkipped:   true,
+		},
+		{
+			name:          "changed manifests are evaluated",
+			skipUnchanged: true,
+			after:         strings.Replace(manifest, "replicas: 2", "replicas: 3", 1),
+		},
+		{
+			name:  "identical manifests are evaluated if disabled",
+			after: manifest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// base and head trees hold the service under the same name
+			beforeDir := newTestServiceDir(t, "stg")
+			afterDir := filepath.Join(t.TempDir(), filepath.Base(beforeDir))
+			if err := os.Rename(newTestServiceDir(t, "stg"), afterDir); err != nil {
+				t.Fatalf("failed to move the head service: %v", err)
+			}
+			conftestCalls := 0
+			executor := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					if name == "conftest" {
+						conftestCalls++
+						return &command.Result{Stdout: []byte(`[{"filename": "Combined", "namespace": "main", "failures": []}]`)}, nil
+					}
+					if strings.HasPrefix(args[len(args)-1], beforeDir) {
+						return &command.Result{Stdout: []byte(manifest)}, nil
+					}
+					return &command.Result{Stdout: []byte(tt.after)}, nil
+				},
+			}
+			evaluator := policy.NewPolicyEvaluator(newTestPoliciesDir(t, complianceConfig, "ha"))
+			evaluator.SetExecutor(executor)
+			if err := evaluator.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			outputDir := t.TempDir()
+			r, err := NewRunnerLocal(context.Background(), &Options{
+				Service:               filepath.Base(beforeDir),
+				Environments:          []string{"stg"},
+				TemplatesPath:         "../../templates",
+				OutputDir:             outputDir,
+				EnableExportReport:    true,
+				SkipUnchanged:         tt.skipUnchanged,
+				LcBeforeManifestsPath: filepath.Dir(beforeDir),
+				LcAfterManifestsPath:  filepath.Dir(afterDir),
+			}, kustomize.NewBuilderWithExecutor(executor), diff.NewDiffer(), evaluator, template.NewRenderer())
+			if err != nil {
+				t.Fatalf("NewRunnerLocal() error = %v", err)
+			}
+
+			if err := r.Process(); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if gotSkipped := conftestCalls == 0; gotSkipped != tt.wantSkipped {
+				t.Errorf("policy evaluation skipped = %v (%d conftest calls), want %v", gotSkipped, conftestCalls, tt.wantSkipped)
+			}
+			report, err := os.ReadFile(filepath.Join(outputDir, "report.md"))
+			if err != nil {
+				t.Fatalf("failed to read report.md: %v", err)
+			}
+			if gotConcise := strings.Contains(string(report), "No manifest changes"); gotConcise != tt.wantSkipped {
+				t.Errorf("report.md has the concise no manifest changes comment = %v, want %v:\n%s", gotConcise, tt.wantSkipped, report)
+			}
+			if gotPolicies := strings.Contains(string(report), "Policy Evaluation"); gotPolicies == tt.wantSkipped {
+				t.Errorf("report.md has the policy evaluation = %v, want %v:\n%s", gotPolicies, !tt.wantSkipped, report)
+			}
+		})
+	}
+}
+
+// TestRunnerLocal_Process_SkipEvalUnchanged tests that only the environments with manifest changes are evaluated
+func TestRunnerLocal_Process_SkipEvalUnchanged(t *testing.T) {
+	const manifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 2
+`
+	const complianceConfig = `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`
+
+	tests := []struct {
+		name              string
+		skipEvalUnchanged bool
+		wantConftestCalls int
+		wantUnchanged     map[string]bool
+	}{
+		{
+			name:              "unchanged environment is not re-evaluated",
+			skipEvalUnchanged: true,
+			wantConftestCalls: 1,
+			wantUnchanged:     map[string]bool{"stg": true, "prod": false},
+		},
+		{
+			name:              "all environments are evaluated if disabled",
+			wantConftestCalls: 2,
+			wantUnchanged:     map[string]bool{"stg": false, "prod": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// base and head trees hold the service under the same name, only prod changes
+			beforeDir := newTestServiceDir(t, "stg", "prod")
+			afterDir := filepath.Join(t.TempDir(), filepath.Base(beforeDir))
+			if err := os.Rename(newTestServiceDir(t, "stg", "prod"), afterDir); err != nil {
+				t.Fatalf("failed to move the head service: %v", err)
+			}
+			conftestCalls := 0
+			executor := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					if name == "conftest" {
+						conftestCalls++
+						return &command.Result{Stdout: []byte(`[{"filename": "Combined", "namespace": "main", "failures": []}]`)}, nil
+					}
+					overlay := args[len(args)-1]
+					if strings.HasPrefix(overlay, afterDir) && filepath.Base(overlay) == "prod" {
+						return &command.Result{Stdout: []byte(strings.Replace(manifest, "replicas: 2", "replicas: 3", 1))}, nil
+					}
+					return &command.Result{Stdout: []byte(manifest)}, nil
+				},
+			}
+			evaluator := policy.NewPolicyEvaluator(newTestPoliciesDir(t, complianceConfig, "ha"))
+			evaluator.SetExecutor(executor)
+			if err := evaluator.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			outputDir := t.TempDir()
+			r, err := NewRunnerLocal(context.Background(), &Options{
+				Service:               filepath.Base(beforeDir),
+				Environments:          []string{"stg", "prod"},
+				TemplatesPath:         "../../templates",
+				OutputDir:             outputDir,
+				EnableExportReport:    true,
+				LcBeforeManifestsPath: filepath.Dir(beforeDir),
+				LcAfterManifestsPath:  filepath.Dir(afterDir),
+				LcSkipEvalUnchanged:   tt.skipEvalUnchanged,
+			}, kustomize.NewBuilderWithExecutor(executor), diff.NewDiffer(), evaluator, template.NewRenderer())
+			if err != nil {
+				t.Fatalf("NewRunnerLocal() error = %v", err)
+			}
+
+			if err := r.Process(); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if conftestCalls != tt.wantConftestCalls {
+				t.Errorf("conftest calls = %d, want %d", conftestCalls, tt.wantConftestCalls)
+			}
+			summary := r.LastReport().PolicyEvaluation.EnvironmentSummary
+			for env, wantUnchanged := range tt.wantUnchanged {
+				got, ok := summary[env]
+				if !ok {
+					t.Fatalf("no summary for environment %s", env)
+				}
+				if got.Unchanged != wantUnchanged {
+					t.Errorf("environment %s unchanged = %v, want %v", env, got.Unchanged, wantUnchanged)
+				}
+				if !got.Unchanged && got.PolicyCounts.TotalCount != 1 {
+					t.Errorf("environment %s evaluated %d policies, want 1", env, got.PolicyCounts.TotalCount)
+				}
+			}
+			report, err := os.ReadFile(filepath.Join(outputDir, "report.md"))
+			if err != nil {
+				t.Fatalf("failed to read report.md: %v", err)
+			}
+			if got, want := strings.Contains(string(report), "| `stg` | unchanged, not re-evaluated |"), tt.skipEvalUnchanged; got != want {
+				t.Errorf("report.md marks stg as unchanged = %v, want %v:\n%s", got, want, report)
+			}
+		})
+	}
+}
+
+// TestRunnerLocal_Process_OverlayPath tests that the overlay built for each environment is written to report.json
+func TestRunnerLocal_Process_OverlayPath(t *testing.T) {
+	const complianceConfig = `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`
+	beforeDir := newTestServiceDir(t, "stg", "prod")
+	afterDir := filepath.Join(t.TempDir(), filepath.Base(beforeDir))
+	if err := os.Rename(newTestServiceDir(t, "stg", "prod", "prod-crds"), afterDir); err != nil {
+		t.Fatalf("failed to move the head service: %v", err)
+	}
+	executor := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if name == "conftest" {
+				return &command.Result{Stdout: []byte(`[{"filename": "Combined", "namespace": "main", "failures": []}]`)}, nil
+			}
+			return &command.Result{Stdout: []byte("kind: ConfigMap\nmetadata:\n  name: " + filepath.Base(args[len(args)-1]) + "\n")}, nil
+		},
+	}
+	evaluator := policy.NewPolicyEvaluator(newTestPoliciesDir(t, complianceConfig, "ha"))
+	evaluator.SetExecutor(executor)
+	if err := evaluator.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	outputDir := t.TempDir()
+	r, err := NewRunnerLocal(context.Background(), &Options{
+		Service:               filepath.Base(beforeDir),
+		Environments:          []string{"stg", "prod"},
+		EnvOverlays:           []string{"prod=prod,prod-crds"},
+		TemplatesPath:         "../../templates",
+		OutputDir:             outputDir,
+		EnableExportReport:    true,
+		LcBeforeManifestsPath: filepath.Dir(beforeDir),
+		LcAfterManifestsPath:  filepath.Dir(afterDir),
+	}, kustomize.NewBuilderWithExecutor(executor), diff.NewDiffer(), evaluator, template.NewRenderer())
+	if err != nil {
+		t.Fatalf("NewRunnerLocal() error = %v", err)
+	}
+
+	if err := r.Process(); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "report.json"))
+	if err != nil {
+		t.Fatalf("failed to read report.json: %v", err)
+	}
+	var report models.ReportData
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("failed to parse report.json: %v", err)
+	}
+	overlaysDir := filepath.Join(afterDir, kustomize.KUSTOMIZE_OVERLAY_DIR_NAME)
+	want := map[string]string{
+		"stg":  filepath.Join(overlaysDir, "stg"),
+		"prod": filepath.Join(overlaysDir, "prod") + "," + filepath.Join(overlaysDir, "prod-crds"),
+	}
+	for env, wantPath := range want {
+		if got := report.ManifestChanges[env].OverlayPath; got != wantPath {
+			t.Errorf("report.json %s overlayPath = %q, want %q", env, got, wantPath)
+		}
+	}
+}
+
+// TestRunnerLocal_Output_FailMessageTruncated tests that an oversized fail message is truncated in report.md
+// but kept whole in report.json
+func TestRunnerLocal_Output_FailMessageTruncated(t *testing.T) {
+	longMsg := "Deployment my-app: " + strings.Repeat("x", 600)
+	data := newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = models.EnvironmentSummaryEnv{
+		PolicyCounts: models.PolicyCounts{TotalCount: 1, TotalFailed: 1, BlockingFailedCount: 1},
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", FailMessages: []string{longMsg}}},
+	}
+	outputDir := t.TempDir()
+	r := &RunnerLocal{RunnerBase: RunnerBase{
+		Context:  context.Background(),
+		Options:  &Options{OutputDir: outputDir, TemplatesPath: filepath.Join("..", "..", "templates"), EnableExportReport: true},
+		Renderer: template.NewRendererWithOptions(template.RendererOptions{MaxFailMessageLength: 100}),
+	}}
+
+	if err := r.Output(data); err != nil {
+		t.Fatalf("Output() error = %v", err)
+	}
+
+	markdown, err := os.ReadFile(filepath.Join(outputDir, "report.md"))
+	if err != nil {
+		t.Fatalf("failed to read report.md: %v", err)
+	}
+	if strings.Contains(string(markdown), longMsg) {
+		t.Error("report.md contains the whole oversized fail message, want it truncated")
+	}
+	if want := "  * " + longMsg[:100] + "… (truncated from 619 characters"; !strings.Contains(string(markdown), want) {
+		t.Errorf("report.md missing %q", want)
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "report.json"))
+	if err != nil {
+		t.Fatalf("failed to read report.json: %v", err)
+	}
+	var report models.ReportData
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("failed to parse report.json: %v", err)
+	}
+	if got := report.PolicyEvaluation.PolicyMatrix["stg"].BlockingPolicies[0].FailMessages[0]; got != longMsg {
+		t.Errorf("report.json fail message has %d characters, want the whole %d", len(got), len(longMsg))
+	}
+}
+
+// TestRunnerLocal_Output_CompressReport tests that the gzip-compressed reports decompress to the uncompressed ones
+func TestRunnerLocal_Output_CompressReport(t *testing.T) {
+	data := newTestReportData(time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+	output := func(compress bool) string {
+		outputDir := t.TempDir()
+		r := &RunnerLocal{RunnerBase: RunnerBase{
+			Context: context.Background(),
+			Options: &Options{
+				OutputDir:              outputDir,
+				TemplatesPath:          filepath.Join("..", "..", "templates"),
+				EnableExportReport:     true,
+				CompressReport:         compress,
+				CompressReportMarkdown: compress,
+			},
+			Renderer: template.NewRenderer(),
+		}}
+		if err := r.Output(data); err != nil {
+			t.Fatalf("Output() error = %v", err)
+		}
+		return outputDir
+	}
+	plainDir, compressedDir := output(false), output(true)
+
+	if got, want := listFiles(t, compressedDir), []string{"report.json.gz", "report.md.gz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Output() wrote %v, want %v", got, want)
+	}
+	for _, name := range []string{"report.json", "report.md"} {
+		want, err := os.ReadFile(filepath.Join(plainDir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		got, err := fileutil.ReadOutputFile(filepath.Join(compressedDir, name+fileutil.GZIP_EXT))
+		if err != nil {
+			t.Fatalf("failed to read %s%s: %v", name, fileutil.GZIP_EXT, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s%s decompressed =\n%s\nwant\n%s", name, fileutil.GZIP_EXT, got, want)
+		}
+	}
+
+	content, err := fileutil.ReadOutputFile(filepath.Join(compressedDir, "report.json.gz"))
+	if err != nil {
+		t.Fatalf("failed to read report.json.gz: %v", err)
+	}
+	var report models.ReportData
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("report.json.gz is not valid JSON: %v", err)
+	}
+	if report.Service != data.Service {
+		t.Errorf("report.json.gz service = %q, want %q", report.Service, data.Service)
+	}
+}
+
+// TestRunnerLocal_Process_FailFastOrder tests that with --fail-fast the environments are evaluated in the configured
+// order and an environment left unevaluated is rendered as such, not as passing
+func TestRunnerLocal_Process_FailFastOrder(t *testing.T) {
+	const complianceConfig = `policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`
+	tests := []struct {
+		name         string
+		failingEnv   string
+		wantRow      string
+		wantSummary  string
+		notEvaluated string
+	}{
+		{
+			name:       "later configured environment fails",
+			failingEnv: "prod",
+			wantRow:    "| [PASS] | [FAIL] |",
+		},
+		{
+			name:         "first configured environment fails",
+			failingEnv:   "stg",
+			wantRow:      "| [FAIL] |  |",
+			wantSummary:  "| `prod` | not evaluated, stopped after a blocking failure |",
+			notEvaluated: "prod",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			beforeDir := newTestServiceDir(t, "stg", "prod")
+			afterDir := filepath.Join(t.TempDir(), filepath.Base(beforeDir))
+			if err := os.Rename(newTestServiceDir(t, "stg", "prod"), afterDir); err != nil {
+				t.Fatalf("failed to move the head service: %v", err)
+			}
+			executor := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					if name == "conftest" {
+						content, err := os.ReadFile(args[5])
+						if err != nil {
+							return nil, err
+						}
+						if strings.Contains(string(content), tt.failingEnv) {
+							return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`)}, fmt.Errorf("exit status 1")
+						}
+						return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[]}]`)}, nil
+					}
+					return &command.Result{Stdout: []byte("kind: ConfigMap\nmetadata:\n  name: " + filepath.Base(args[len(args)-1]) + "\n")}, nil
+				},
+			}
+			evaluator := policy.NewPolicyEvaluatorWithOptions(newTestPoliciesDir(t, complianceConfig, "ha"), policy.EvaluatorOptions{FailFast: true})
+			evaluator.SetExecutor(executor)
+			if err := evaluator.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			outputDir := t.TempDir()
+			r, err := NewRunnerLocal(context.Background(), &Options{
+				Service:               filepath.Base(beforeDir),
+				Environments:          []string{"stg", "prod"},
+				TemplatesPath:         "../../templates",
+				OutputDir:             outputDir,
+				LcBeforeManifestsPath: filepath.Dir(beforeDir),
+				LcAfterManifestsPath:  filepath.Dir(afterDir),
+			}, kustomize.NewBuilderWithExecutor(executor), diff.NewDiffer(), evaluator,
+				template.NewRendererWithOptions(template.RendererOptions{NoEmoji: true}))
+			if err != nil {
+				t.Fatalf("NewRunnerLocal() error = %v", err)
+			}
+
+			if err := r.Process(); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			markdown, err := os.ReadFile(filepath.Join(outputDir, "report.md"))
+			if err != nil {
+				t.Fatalf("failed to read report.md: %v", err)
+			}
+			var row string
+			for _, line := range strings.Split(string(markdown), "\n") {
+				if strings.HasPrefix(line, "| Service High Availability |") {
+					row = line
+				}
+			}
+			if !strings.HasSuffix(row, tt.wantRow) {
+				t.Errorf("report.md matrix row = %q, want it ending with %q", row, tt.wantRow)
+			}
+			if tt.wantSummary != "" && !strings.Contains(string(markdown), tt.wantSummary) {
+				t.Errorf("report.md missing %q", tt.wantSummary)
+			}
+			summary := r.LastReport().PolicyEvaluation.EnvironmentSummary
+			for _, env := range []string{"stg", "prod"} {
+				if got := summary[env].NotEvaluated; got != (env == tt.notEvaluated) {
+					t.Errorf("%s NotEvaluated = %v, want %v", env, got, env == tt.notEvaluated)
+				}
+			}
+		})
+	}
+}
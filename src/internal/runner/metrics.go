@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/trace"
+)
+
+const (
+	METRIC_BUILD_DURATION  = "gitops_kustomz_build_duration_seconds"
+	METRIC_EVAL_DURATION   = "gitops_kustomz_eval_duration_seconds"
+	METRIC_POLICIES_FAILED = "gitops_kustomz_policies_failed"
+	METRIC_DIFF_LINES      = "gitops_kustomz_diff_lines"
+	METRIC_LAST_RUN        = "gitops_kustomz_last_run_timestamp_seconds"
+)
+
+// metricSample is a value of a metric with its labels, in label order
+type metricSample struct {
+	labels [][2]string
+	value  float64
+}
+
+// metricFamily is a metric with its help text and samples, written as a gauge
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+// writeMetrics writes the run metrics of the report in Prometheus textfile format. Durations are the spans of the run by
+// name: the build of an environment is BuildManifests.<env>, the evaluation of all environments EvaluatePolicies.
+// A metric not measured in the run, e.g. the evaluation skipped on unchanged manifests, has no sample
+func writeMetrics(w io.Writer, data *models.ReportData, durations map[string]time.Duration) error {
+	service := [2]string{"service", data.Service}
+	build := metricFamily{name: METRIC_BUILD_DURATION, help: "Duration of the kustomize build of the base and head manifests of an environment."}
+	eval := metricFamily{name: METRIC_EVAL_DURATION, help: "Duration of the policy evaluation of all environments."}
+	failed := metricFamily{name: METRIC_POLICIES_FAILED, help: "Number of failed policies of an environment, omitted policies excluded."}
+	diffLines := metricFamily{name: METRIC_DIFF_LINES, help: "Number of changed lines of the manifest diff of an environment."}
+	lastRun := metricFamily{name: METRIC_LAST_RUN, help: "Unix time of the run."}
+
+	for _, env := range data.Environments {
+		labels := [][2]string{service, {"env", env}}
+		if duration, ok := durations[fmt.Sprintf("BuildManifests.%s", env)]; ok {
+			build.samples = append(build.samples, metricSample{labels: labels, value: duration.Seconds()})
+		}
+		if summary, ok := data.PolicyEvaluation.EnvironmentSummary[env]; ok && !summary.Unchanged && !summary.NotEvaluated {
+			failed.samples = append(failed.samples, metricSample{labels: labels, value: float64(summary.PolicyCounts.TotalFailed)})
+		}
+		if change, ok := data.ManifestChanges[env]; ok {
+			diffLines.samples = append(diffLines.samples, metricSample{labels: labels, value: float64(change.LineCount)})
+		}
+	}
+	if duration, ok := durations["EvaluatePolicies"]; ok {
+		eval.samples = append(eval.samples, metricSample{labels: [][2]string{service}, value: duration.Seconds()})
+	}
+	lastRun.samples = append(lastRun.samples, metricSample{labels: [][2]string{service}, value: float64(data.Timestamp.Unix())})
+
+	for _, family := range []metricFamily{build, eval, failed, diffLines, lastRun} {
+		if len(family.samples) == 0 {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", family.name, family.help, family.name); err != nil {
+			return err
+		}
+		for _, sample := range family.samples {
+			pairs := make([]string, 0, len(sample.labels))
+			for _, label := range sample.labels {
+				pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", label[0], escapeLabelValue(label[1])))
+			}
+			if _, err := fmt.Fprintf(w, "%s{%s} %s\n", family.name, strings.Join(pairs, ","),
+				strconv.FormatFloat(sample.value, 'f', -1, 64)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// escapeLabelValue escapes a label value for the Prometheus text format
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// exportMetrics writes the run metrics to the --metrics-file path, no-op if not set. The file is replaced atomically,
+// the textfile collector never reads a partial file
+func (r *RunnerBase) exportMetrics(data *models.ReportData) error {
+	if r.Options.MetricsFile == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, data, trace.SpanDurations(r.startedAt)); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Options.MetricsFile), 0755); err != nil {
+		return fmt.Errorf("failed to create metrics directory: %w", err)
+	}
+	if err := fileutil.WriteFileAtomic(r.Options.MetricsFile, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	logger.WithField("filePath", r.Options.MetricsFile).Info("Written run metrics")
+	return nil
+}
@@ -0,0 +1,145 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestWriteMetrics tests the metric names, labels and values derived from the run spans and the report
+func TestWriteMetrics(t *testing.T) {
+	data := &models.ReportData{
+		Service:      "my-app",
+		Environments: []string{"stg", "prod"},
+		Timestamp:    time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC),
+		ManifestChanges: map[string]models.EnvironmentDiff{
+			"stg":  {LineCount: 4},
+			"prod": {LineCount: 0},
+		},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{
+				"stg":  {PolicyCounts: models.PolicyCounts{TotalFailed: 2}},
+				"prod": {Unchanged: true},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		durations map[string]time.Duration
+		want      string
+	}{
+		{
+			name: "measured run",
+			durations: map[string]time.Duration{
+				"BuildManifests":      2 * time.Second,
+				"BuildManifests.stg":  500 * time.Millisecond,
+				"BuildManifests.prod": 1500 * time.Millisecond,
+				"EvaluatePolicies":    1250 * time.Millisecond,
+			},
+			want: `# HELP gitops_kustomz_build_duration_seconds Duration of the kustomize build of the base and head manifests of an environment.
+# TYPE gitops_kustomz_build_duration_seconds gauge
+gitops_kustomz_build_duration_seconds{service="my-app",env="stg"} 0.5
+gitops_kustomz_build_duration_seconds{service="my-app",env="prod"} 1.5
+# HELP gitops_kustomz_eval_duration_seconds Duration of the policy evaluation of all environments.
+# TYPE gitops_kustomz_eval_duration_seconds gauge
+gitops_kustomz_eval_duration_seconds{service="my-app"} 1.25
+# HELP gitops_kustomz_policies_failed Number of failed policies of an environment, omitted policies excluded.
+# TYPE gitops_kustomz_policies_failed gauge
+gitops_kustomz_policies_failed{service="my-app",env="stg"} 2
+# HELP gitops_kustomz_diff_lines Number of changed lines of the manifest diff of an environment.
+# TYPE gitops_kustomz_diff_lines gauge
+gitops_kustomz_diff_lines{service="my-app",env="stg"} 4
+gitops_kustomz_diff_lines{service="my-app",env="prod"} 0
+# HELP gitops_kustomz_last_run_timestamp_seconds Unix time of the run.
+# TYPE gitops_kustomz_last_run_timestamp_seconds gauge
+gitops_kustomz_last_run_timestamp_seconds{service="my-app"} 1759320000
+`,
+		},
+		{
+			name:      "durations not measured",
+			durations: map[string]time.Duration{},
+			want: `# HELP gitops_kustomz_policies_failed Number of failed policies of an environment, omitted policies excluded.
+# TYPE gitops_kustomz_policies_failed gauge
+gitops_kustomz_policies_failed{service="my-app",env="stg"} 2
+# HELP gitops_kustomz_diff_lines Number of changed lines of the manifest diff of an environment.
+# TYPE gitops_kustomz_diff_lines gauge
+gitops_kustomz_diff_lines{service="my-app",env="stg"} 4
+gitops_kustomz_diff_lines{service="my-app",env="prod"} 0
+# HELP gitops_kustomz_last_run_timestamp_seconds Unix time of the run.
+# TYPE gitops_kustomz_last_run_timestamp_seconds gauge
+gitops_kustomz_last_run_timestamp_seconds{service="my-app"} 1759320000
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeMetrics(&buf, data, tt.durations); err != nil {
+				t.Fatalf("writeMetrics() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("writeMetrics() wrote\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+// TestEscapeLabelValue tests the escaping of backslashes, quotes and newlines in label values
+func TestEscapeLabelValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "my-app", want: "my-app"},
+		{value: `a "quoted" \ value`, want: `a \"quoted\" \\ value`},
+		{value: "two\nlines", want: `two\nlines`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLabelValue(tt.value); got != tt.want {
+			t.Errorf("escapeLabelValue(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestRunnerBase_exportMetrics tests that the metrics file is written only when --metrics-file is set
+func TestRunnerBase_exportMetrics(t *testing.T) {
+	data := &models.ReportData{Service: "my-app", Environments: []string{"stg"}}
+
+	t.Run("enabled", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "textfile", "gitops-kustomz.prom")
+		r := &RunnerBase{Options: &Options{MetricsFile: path}}
+		if err := r.exportMetrics(data); err != nil {
+			t.Fatalf("exportMetrics() error = %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("failed to read metrics: %v", err)
+		}
+		if !strings.Contains(string(got), `gitops_kustomz_last_run_timestamp_seconds{service="my-app"}`) {
+			t.Errorf("exportMetrics() wrote\n%s\nwant the last run timestamp", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		dir := t.TempDir()
+		r := &RunnerBase{Options: &Options{OutputDir: dir}}
+		if err := r.exportMetrics(data); err != nil {
+			t.Fatalf("exportMetrics() error = %v", err)
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("failed to read dir: %v", err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("exportMetrics() wrote %d files, want none when disabled", len(entries))
+		}
+	})
+}
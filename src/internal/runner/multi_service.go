@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"sort"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// AggregateServiceReports consolidates the reports of several services checked in the same PR,
+// a service passes if every environment passes its blocking policies, the overall gate if every service passes
+func AggregateServiceReports(reports []models.ReportData) models.MultiServiceReportData {
+	result := models.MultiServiceReportData{
+		Services:          make([]models.ServiceReport, 0, len(reports)),
+		PassBlockingCheck: true,
+	}
+
+	for _, report := range reports {
+		if report.Timestamp.After(result.Timestamp) {
+			result.Timestamp = report.Timestamp
+		}
+		if result.BaseCommit == "" {
+			result.BaseCommit, result.HeadCommit = report.BaseCommit, report.HeadCommit
+		}
+
+		service := models.ServiceReport{
+			Service:            report.Service,
+			PassBlockingCheck:  true,
+			FailedEnvironments: []string{},
+			Report:             report,
+		}
+		for _, env := range report.Environments {
+			if report.ManifestChanges[env].LineCount > 0 {
+				service.HasChanges = true
+			}
+			// environments without summary, unchanged or not evaluated had no policy evaluated
+			summary, ok := report.PolicyEvaluation.EnvironmentSummary[env]
+			if !ok || summary.Unchanged || summary.NotEvaluated {
+				continue
+			}
+			service.BlockingFailedCount += summary.PolicyCounts.BlockingFailedCount
+			if !summary.PassingStatus.PassBlockingCheck {
+				service.PassBlockingCheck = false
+				service.FailedEnvironments = append(service.FailedEnvironments, env)
+			}
+		}
+
+		if !service.PassBlockingCheck {
+			result.PassBlockingCheck = false
+		}
+		result.Services = append(result.Services, service)
+	}
+
+	sort.Slice(result.Services, func(i, j int) bool {
+		return result.Services[i].Service < result.Services[j].Service
+	})
+	return result
+}
@@ -0,0 +1,140 @@
+package runner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
+)
+
+// newTestServiceReport returns the report of a service whose prod environment fails blockingFailed policies
+func newTestServiceReport(service string, blockingFailed int, changedLines int) models.ReportData {
+	summary := func(failed int) models.EnvironmentSummaryEnv {
+		return models.EnvironmentSummaryEnv{
+			PassingStatus: models.EnforcementPassingStatus{PassBlockingCheck: failed == 0, PassWarningCheck: true, PassRecommendCheck: true},
+			PolicyCounts:  models.PolicyCounts{TotalCount: 1, TotalFailed: failed, BlockingFailedCount: failed},
+		}
+	}
+	blocking := []models.PolicyResult{{PolicyId: "ha", PolicyName: "Service High Availability", IsPassing: true}}
+	prodBlocking := []models.PolicyResult{{PolicyId: "ha", PolicyName: "Service High Availability", IsPassing: blockingFailed == 0}}
+	if blockingFailed > 0 {
+		prodBlocking[0].FailMessages = []string{"replicas too low"}
+	}
+
+	return models.ReportData{
+		Service:      service,
+		Timestamp:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		BaseCommit:   "base",
+		HeadCommit:   "head",
+		Environments: []string{"stg", "prod"},
+		ManifestChanges: map[string]models.EnvironmentDiff{
+			"stg":  {ContentType: models.DiffContentTypeText},
+			"prod": {ContentType: models.DiffContentTypeText, LineCount: changedLines, Content: strings.Repeat("+  replicas: 1\n", changedLines)},
+		},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{
+				"stg":  summary(0),
+				"prod": summary(blockingFailed),
+			},
+			PolicyMatrix: map[string]models.PolicyMatrix{
+				"stg":  {BlockingPolicies: blocking},
+				"prod": {BlockingPolicies: prodBlocking},
+			},
+		},
+	}
+}
+
+// TestAggregateServiceReports tests the per-service and overall gates
+func TestAggregateServiceReports(t *testing.T) {
+	tests := []struct {
+		name        string
+		reports     []models.ReportData
+		wantPass    bool
+		wantOrder   []string
+		wantFailed  map[string][]string
+		wantChanges map[string]bool
+	}{
+		{
+			name:        "all services passing",
+			reports:     []models.ReportData{newTestServiceReport("payments", 0, 1), newTestServiceReport("checkout", 0, 0)},
+			wantPass:    true,
+			wantOrder:   []string{"checkout", "payments"},
+			wantFailed:  map[string][]string{"checkout": {}, "payments": {}},
+			wantChanges: map[string]bool{"checkout": false, "payments": true},
+		},
+		{
+			name:        "one service failing",
+			reports:     []models.ReportData{newTestServiceReport("payments", 0, 0), newTestServiceReport("checkout", 1, 2)},
+			wantPass:    false,
+			wantOrder:   []string{"checkout", "payments"},
+			wantFailed:  map[string][]string{"checkout": {"prod"}, "payments": {}},
+			wantChanges: map[string]bool{"checkout": true, "payments": false},
+		},
+		{
+			name:       "no service",
+			wantPass:   true,
+			wantOrder:  []string{},
+			wantFailed: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AggregateServiceReports(tt.reports)
+			if got.PassBlockingCheck != tt.wantPass {
+				t.Errorf("AggregateServiceReports() PassBlockingCheck = %v, want %v", got.PassBlockingCheck, tt.wantPass)
+			}
+			order := []string{}
+			for _, svc := range got.Services {
+				order = append(order, svc.Service)
+				if !reflect.DeepEqual(svc.FailedEnvironments, tt.wantFailed[svc.Service]) {
+					t.Errorf("AggregateServiceReports() [%s] FailedEnvironments = %v, want %v", svc.Service, svc.FailedEnvironments, tt.wantFailed[svc.Service])
+				}
+				if svc.PassBlockingCheck != (len(tt.wantFailed[svc.Service]) == 0) {
+					t.Errorf("AggregateServiceReports() [%s] PassBlockingCheck = %v", svc.Service, svc.PassBlockingCheck)
+				}
+				if svc.HasChanges != tt.wantChanges[svc.Service] {
+					t.Errorf("AggregateServiceReports() [%s] HasChanges = %v, want %v", svc.Service, svc.HasChanges, tt.wantChanges[svc.Service])
+				}
+			}
+			if !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("AggregateServiceReports() services = %v, want %v", order, tt.wantOrder)
+			}
+		})
+	}
+}
+
+// TestAggregateServiceReports_CombinedComment tests that a passing and a failing service produce a single
+// comment with the per-service matrix and details, and an overall failing gate
+func TestAggregateServiceReports_CombinedComment(t *testing.T) {
+	data := AggregateServiceReports([]models.ReportData{
+		newTestServiceReport("payments", 0, 0),
+		newTestServiceReport("checkout", 1, 2),
+	})
+
+	got, err := template.NewRenderer().RenderMultiServiceWithTemplates("../../templates", &data)
+	if err != nil {
+		t.Fatalf("RenderMultiServiceWithTemplates() error = %v", err)
+	}
+	for _, want := range []string{
+		"# 🔍 GitOps Policy Check: 2 services",
+		"| head | ❌ FAIL",
+		"| `checkout` | `stg`, `prod` | ✏️ Changed | `1`🚫 | ❌ FAIL (`prod`) |",
+		"| `payments` | `stg`, `prod` | ✅ None | `0`🚫 | ✅ PASS |",
+		"<details> <summary> ❌ Service <code>checkout</code> </summary>",
+		"<details> <summary> ✅ Service <code>payments</code> </summary>",
+		"# 🔍 GitOps Policy Check: checkout",
+		"# 🔍 GitOps Policy Check: payments",
+		"  * replicas too low",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderMultiServiceWithTemplates() missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "Service <code>checkout</code>") > strings.Index(got, "Service <code>payments</code>") {
+		t.Errorf("RenderMultiServiceWithTemplates() services should be sorted:\n%s", got)
+	}
+}
@@ -1,25 +1,147 @@
 package runner
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	DIFF_BASE_MERGE_BASE = "merge-base" // diff against the merge-base of the PR head and base, like GitHub's "Files changed"
+	DIFF_BASE_BASE_REF   = "base-ref"   // diff against the tip of the PR base branch
+
+	COMMENT_TARGET_PR    = "pr"    // post the report comment on the PR
+	COMMENT_TARGET_ISSUE = "issue" // post the report comment on a tracking issue, see --issue-number
+
+	MAX_ENVIRONMENTS_DEFAULT = 50    // sanity cap on the number of environments, each one is built and evaluated
+	ENVIRONMENTS_ALL         = "all" // --environments value checking every overlay of the service, discovered on the head
+
+	// UTC timestamp of the timestamped report names: nanoseconds so runs in the same second do not overwrite each other,
+	// fixed width so the names sort chronologically, and without ':' which Windows and upload-artifact reject
+	REPORT_TIMESTAMP_LAYOUT = "20060102T150405.000000000Z"
+)
+
 type Options struct {
 	// Run mode
-	RunMode string // "github" or "local"
-	Debug   bool   // Debug mode
+	RunMode string        // "github" or "local"
+	Debug   bool          // Debug mode
+	Timeout time.Duration // Deadline of the whole run, no deadline if zero
+	Retries int           // Re-runs of the whole process on a transient error, e.g. network or rate limit, none if zero
 
 	// Common options
 	Service                       string
 	Environments                  []string // Support multiple environments
+	MaxEnvironments               int      // Maximum number of environments, guards against a runaway environment list
 	PoliciesPath                  string
 	TemplatesPath                 string
 	OutputDir                     string
 	EnableExportReport            bool
 	EnableExportPerformanceReport bool
+	CompressReport                bool     // Write report.json.gz instead of report.json
+	CompressReportMarkdown        bool     // Write report.md.gz instead of report.md
+	CompressPerformanceReport     bool     // Write performance-report.json.gz instead of performance-report.json
+	ExportCSV                     string   // Path of the policy matrix CSV, one row per fail message or passing policy, not written if empty
+	MetricsFile                   string   // Path of the Prometheus textfile of the run metrics, e.g. for the node_exporter textfile collector, not written if empty
+	StrictYaml                    bool     // Reject built manifests containing duplicate YAML keys
+	PolicyCacheDir                string   // Persist policy evaluation results across runs, in-memory only if empty or with the opa-server backend
+	IncludeFullManifest           bool     // Include the full rendered head manifest per environment in the report
+	NoEmoji                       bool     // Print text labels like [PASS] instead of emoji in the report, for screen readers
+	MaxFailMessageLength          int      // Fail messages longer than this are truncated in the markdown report, 0 keeps them whole
+	CommentLevels                 []string // Enforcement levels rendered in the comment, e.g. block,warning, all if empty. report.json has every level
+	ShowPolicySource              bool     // Include the rego source of failing policies in the report
+	IncludeKinds                  []string // Only diff and evaluate these resource kinds, all kinds if empty
+	ExcludeKinds                  []string // Drop these resource kinds before diff and evaluation
+	RequireCleanBase              bool     // Evaluate the base manifests too, reporting pre-existing violations apart from new ones
+	ReportFixed                   bool     // Evaluate the base manifests too, reporting their violations resolved by the PR
+	Baseline                      string   // report.json of a previous run, failing policies with only violations already in it do not gate, not used if empty
+	NormalizeGeneratedNames       bool     // Neutralize the hash suffix of kustomize generated ConfigMap/Secret names before diffing
+	DiffTool                      string   // External diff tool command line, e.g. "dyff between", the built-in Go unified diff if empty
+	DiffIgnoreWhitespace          bool     // Ignore changes in the amount of whitespace in diffs (diff -b)
+	DiffFormat                    string   // "unified" or "git", a git patch with one file per resource
+	DiffTempExt                   string   // Extension of the before/after temp files passed to DiffTool, ".yaml" if empty
+	DiffContextLines              int      // Unchanged lines shown around each change of the unified diff
+	BuildSplitOutput              bool     // Build one file per resource (kustomize build -o) and diff them file by file
+	DiffPerResource               bool     // Render the diff of each changed resource apart instead of the whole diff
+	DiffMaskPatterns              []string // Regular expressions of sensitive values replaced by *** in the diff and full manifest content
+	DiffUnorderedFields           []string // Fields whose list items are sorted by name before diffing, e.g. env, so a reordering does not diff
+	DiffIgnorePaths               []string // YAML paths of fields removed before diffing, e.g. metadata.annotations."checksum/*", so their changes do not diff
+	NoDiffEnvs                    []string // Environments whose diff content and full manifest are left out of the report, line counts and policy results are kept
+	NoDiffLink                    string   // Link shown instead of the diff of the NoDiffEnvs, e.g. to a protected artifact, none if empty
+	PolicyBackend                 string   // "conftest" or "opa-server"
+	OpaURL                        string   // OPA server base URL, required by the opa-server backend
+	EmptyResultsAsPass            bool     // Treat an empty conftest result, e.g. a manifest without documents, or a missing OPA server result, as a pass
+	ConftestBatch                 bool     // Evaluate policies of distinct rego packages in one conftest call per batch
+	UseRegoSeverity               bool     // Take the enforcement level of failing policies from their rego rule category/severity
+	PolicyConcurrency             int      // Maximum number of policies evaluated in parallel
+	FailFast                      bool     // Stop evaluating policies after the first blocking failure, the report is partial
+	MinPolicyCoverage             float64  // Minimum rego test coverage in percent of every policy, not checked if 0
+	ExpectedKustomizeVersion      string   // Expected kustomize version (or prefix), not checked if empty
+	ExpectedConftestVersion       string   // Expected conftest version (or prefix), not checked if empty
+	StrictToolVersions            bool     // Fail instead of warning when a tool version does not match the expected one
+	StrictRender                  bool     // Fail instead of warning when the rendered report has an unclosed <details> tag or code fence
+	SkipUnchanged                 bool     // Skip the policy evaluation when the base and head manifests of every environment are identical
+	TempPrefix                    string   // Prefix of the temp file names, "gitops-kustomz-<service>-<run id>-" if empty
+	EnvOverlays                   []string // "env=overlay1,overlay2": overlays concatenated into the manifest of env, the overlay named env if not set
 
 	// GitHub mode options
 	GhRepo        string
 	GhPrNumber    int
+	GhCommit      string // Commit SHA evaluated as is instead of a PR, e.g. for a scheduled audit, commented only on an issue target
 	ManifestsPath string // Path to services directory (default: ./services)
+	DiffBase      string // "merge-base" or "base-ref"
+	// Post the comment even when there are no manifest changes and no failing or errored policy,
+	// if disabled such runs delete the previous comment instead
+	CommentOnSuccess bool
+	// Confirm destructive operations, e.g. deleting the previous comment, without prompting.
+	// Required in non-interactive runs (CI), where they are skipped otherwise
+	AssumeYes bool
+	// Print the URL of the posted comment, also written to $GITHUB_OUTPUT as posted-comment-url if set
+	EmitCommentURL bool
+	// "pr" or "issue": where the report comment is posted, found and updated by its marker
+	CommentTarget string
+	// Issue the report comment is posted on with the "issue" comment target
+	IssueNumber int
+	// Only honor override commands of PR review bodies on approving reviews, those of issue comments are always honored
+	OverrideApprovedReviewsOnly bool
+	// Post one comment per environment, each found and updated by its own marker, instead of a single combined comment
+	CommentPerEnvironment bool
+	// Diffs of more lines are uploaded as a workflow artifact and linked in the comment instead of inlined,
+	// only the comment length limit applies if 0
+	MaxInlineDiffLines int
 
 	// Local mode options
 	LcBeforeManifestsPath string
 	LcAfterManifestsPath  string
+	LcTimestampedReports  bool // Write report-<UTC timestamp>.json/.md instead of overwriting report.json/.md
+	LcMaxReports          int  // Number of timestamped reports to retain, older ones are pruned
+	LcWatch               bool // Re-run the checks on every change of the manifests, policies or templates
+	LcSkipEvalUnchanged   bool // Skip the policy evaluation of each environment whose base and head manifests are identical
+
+	// Before manifests checked out from a branch instead of LcBeforeManifestsPath
+	LcGitDir        string // Git checkout holding LcAfterManifestsPath, the compare branch is fetched in
+	LcCompareBranch string // Branch fetched from origin and checked out as the before manifests, e.g. main
+}
+
+// ParseEnvOverlays parses "env=overlay1,overlay2" values into the overlays of each environment
+func ParseEnvOverlays(values []string) (map[string][]string, error) {
+	overlays := make(map[string][]string)
+	for _, value := range values {
+		env, list, ok := strings.Cut(value, "=")
+		env = strings.TrimSpace(env)
+		if !ok || env == "" {
+			return nil, fmt.Errorf("invalid environment overlays %q, expected env=overlay1,overlay2", value)
+		}
+		if _, ok := overlays[env]; ok {
+			return nil, fmt.Errorf("environment %s has overlays set more than once", env)
+		}
+		for _, overlay := range strings.Split(list, ",") {
+			if overlay = strings.TrimSpace(overlay); overlay != "" {
+				overlays[env] = append(overlays[env], overlay)
+			}
+		}
+		if len(overlays[env]) == 0 {
+			return nil, fmt.Errorf("invalid environment overlays %q, no overlay set", value)
+		}
+	}
+	return overlays, nil
 }
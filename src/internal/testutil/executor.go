@@ -0,0 +1,53 @@
+package testutil
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// FakeExecutor is a command.CommandExecutor for tests, it records every call
+// and delegates the result to Handler (empty success result if Handler is nil)
+type FakeExecutor struct {
+	Handler func(ctx context.Context, dir string, name string, args ...string) (*command.Result, error)
+
+	mu    sync.Mutex
+	calls []FakeCall
+}
+
+// FakeCall is a recorded call to FakeExecutor.Run
+type FakeCall struct {
+	Dir  string
+	Name string
+	Args []string
+}
+
+// String returns the call as a command line, e.g. "git checkout main"
+func (c FakeCall) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// Ensure FakeExecutor implements CommandExecutor
+var _ command.CommandExecutor = (*FakeExecutor)(nil)
+
+func (f *FakeExecutor) Run(ctx context.Context, dir string, name string, args ...string) (*command.Result, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, FakeCall{Dir: dir, Name: name, Args: args})
+	f.mu.Unlock()
+
+	if f.Handler == nil {
+		return &command.Result{}, nil
+	}
+	return f.Handler(ctx, dir, name, args...)
+}
+
+// Calls returns a copy of the recorded calls
+func (f *FakeExecutor) Calls() []FakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := make([]FakeCall, len(f.calls))
+	copy(calls, f.calls)
+	return calls
+}
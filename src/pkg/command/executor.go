@@ -0,0 +1,53 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+)
+
+// CommandExecutor defines the interface for running external commands (git, kustomize, conftest, ...)
+// It allows injecting a fake executor in tests
+type CommandExecutor interface {
+	// Run runs the command in dir (current directory if empty) and returns its captured output
+	// Result is returned even on error, so callers can inspect stderr and the exit code
+	Run(ctx context.Context, dir string, name string, args ...string) (*Result, error)
+}
+
+// Result holds the captured output of a command, stdout and stderr are kept separated
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// Executor runs commands with os/exec
+type Executor struct{}
+
+// Ensure Executor implements CommandExecutor
+var _ CommandExecutor = (*Executor)(nil)
+
+// NewExecutor creates a new command executor
+func NewExecutor() *Executor {
+	return &Executor{}
+}
+
+// Run runs the command and captures stdout and stderr separately
+func (e *Executor) Run(ctx context.Context, dir string, name string, args ...string) (*Result, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := &Result{
+		Stdout: stdout.Bytes(),
+		Stderr: stderr.Bytes(),
+	}
+	if cmd.ProcessState != nil {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	return result, err
+}
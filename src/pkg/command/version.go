@@ -0,0 +1,28 @@
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// versionPattern matches the first semantic version of a tool's version output, e.g. "v5.4.3" or "0.56.0"
+var versionPattern = regexp.MustCompile(`v?(\d+\.\d+\.\d+)`)
+
+// ParseVersion extracts the version of a tool from its version output, without the "v" prefix
+// e.g. "v5.4.3", "{Version:kustomize/v4.5.7 GitCommit:...}" or "Conftest: 0.56.0\nOPA: 0.69.0"
+func ParseVersion(output string) (string, error) {
+	match := versionPattern.FindStringSubmatch(output)
+	if match == nil {
+		return "", fmt.Errorf("no version found in output: %q", strings.TrimSpace(output))
+	}
+	return match[1], nil
+}
+
+// VersionMatches reports whether version satisfies expected, both with or without the "v" prefix
+// expected can be a version prefix, e.g. "5.4" matches "5.4.3" but not "5.40.0"
+func VersionMatches(version, expected string) bool {
+	version = strings.TrimPrefix(version, "v")
+	expected = strings.TrimPrefix(expected, "v")
+	return version == expected || strings.HasPrefix(version, expected+".")
+}
@@ -0,0 +1,53 @@
+package command
+
+import "testing"
+
+// TestParseVersion tests extracting the version from tools' version outputs
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "kustomize v5", output: "v5.4.3\n", want: "5.4.3"},
+		{name: "kustomize v4", output: "{Version:kustomize/v4.5.7 GitCommit:56d82a8378dfc8dc3b3b1085e5a6e67b82966bd7 BuildDate:2022-08-02T16:28:01Z GoOs:linux GoArch:amd64}\n", want: "4.5.7"},
+		{name: "conftest", output: "Conftest: 0.56.0\nOPA: 0.69.0\n", want: "0.56.0"},
+		{name: "no version", output: "command not found", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVersion(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseVersion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestVersionMatches tests exact and prefix version matching
+func TestVersionMatches(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected string
+		want     bool
+	}{
+		{version: "5.4.3", expected: "5.4.3", want: true},
+		{version: "5.4.3", expected: "v5.4.3", want: true},
+		{version: "5.4.3", expected: "5.4", want: true},
+		{version: "5.40.0", expected: "5.4", want: false},
+		{version: "5.4.2", expected: "5.4.3", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+"~"+tt.expected, func(t *testing.T) {
+			if got := VersionMatches(tt.version, tt.expected); got != tt.want {
+				t.Errorf("VersionMatches(%q, %q) = %v, want %v", tt.version, tt.expected, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,31 @@ package diff
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
+	"github.com/pmezard/go-difflib/difflib"
 )
 
+// DEFAULT_TEMP_EXT is the extension of the before/after temp files passed to the external diff tool
+const DEFAULT_TEMP_EXT = ".yaml"
+
+// DEFAULT_CONTEXT_LINES is the number of unchanged lines shown around each change of the built-in unified diff, like "diff -u"
+const DEFAULT_CONTEXT_LINES = 3
+
+// whitespacePattern matches the whitespace runs made a single space by ignoreWhitespace
+var whitespacePattern = regexp.MustCompile(`[ \t\f\v\r]+`)
+
+// tempExtPattern is the accepted shape of a temp file extension, e.g. ".yaml" or "yml"
+var tempExtPattern = regexp.MustCompile(`^\.?[A-Za-z0-9]+$`)
+
 // ManifestDiffer defines the interface for comparing Kubernetes manifests
 type ManifestDiffer interface {
 	// Diff compares two manifests and returns a unified diff
@@ -16,14 +35,113 @@ type ManifestDiffer interface {
 }
 
 // Differ handles manifest diffing
-type Differ struct{}
+type Differ struct {
+	executor command.CommandExecutor
+	// external diff tool and its arguments, e.g. ["dyff", "between"], the built-in Go unified diff if empty
+	tool []string
+	// ignore changes in the amount of whitespace (like diff -b), e.g. reindented or trailing spaces
+	ignoreWhitespace bool
+	// DIFF_FORMAT_UNIFIED or DIFF_FORMAT_GIT
+	format string
+	// prefix of the temp file names passed to the external diff tool
+	tempPrefix string
+	// extension of the temp file names passed to the external diff tool, e.g. ".yaml"
+	tempExt string
+	// unchanged lines shown around each change (like diff -U<n>)
+	contextLines int
+	// creates the before/after temp files passed to the external diff tool
+	createTemp fileutil.CreateTempFunc
+}
+
+// DifferOptions configures a Differ
+type DifferOptions struct {
+	// External diff tool command line, e.g. "dyff between --omit-header --output github", called with the before
+	// and after files appended as arguments. Its lines starting with "+" or "-" are counted as changes, except the
+	// "+++"/"---" headers. The built-in Go unified diff is used if empty
+	Tool string
+	// Executor running the external diff tool, a real one if nil
+	Executor command.CommandExecutor
+	// Ignore changes in the amount of whitespace, like reindented YAML or trailing spaces,
+	// so whitespace-only changes report as no change. Only applies to the built-in unified diff
+	IgnoreWhitespace bool
+	// Output format of the built-in unified diff: DIFF_FORMAT_UNIFIED (default) or DIFF_FORMAT_GIT, a git patch with one file per resource
+	Format string
+	// Prefix of the before/after temp file names of the external diff tool, e.g. "gitops-kustomz-my-app-1234-", to attribute stray files of a run
+	TempPrefix string
+	// Extension of the before/after temp files of the external diff tool, e.g. ".yml" or "json", for tools keying
+	// their behavior off it. DEFAULT_TEMP_EXT if empty
+	TempExt string
+	// Number of unchanged lines shown around each change of the built-in unified diff, like diff -U<n>, e.g. 0 for the changed
+	// lines only on large manifests. DEFAULT_CONTEXT_LINES if nil, a negative value fails the diff
+	ContextLines *int
+	// Creates the before/after temp files passed to the external diff tool, os.CreateTemp if nil.
+	// Mainly for tests, to predict the file names replaced in the tool output
+	CreateTemp fileutil.CreateTempFunc
+}
 
 // Ensure Differ implements ManifestDiffer
 var _ ManifestDiffer = (*Differ)(nil)
 
 // NewDiffer creates a new differ
 func NewDiffer() *Differ {
-	return &Differ{}
+	return NewDifferWithOptions(DifferOptions{})
+}
+
+// NewDifferWithOptions creates a new differ with the given options
+func NewDifferWithOptions(opts DifferOptions) *Differ {
+	executor := opts.Executor
+	if executor == nil {
+		executor = command.NewExecutor()
+	}
+	contextLines := DEFAULT_CONTEXT_LINES
+	if opts.ContextLines != nil {
+		contextLines = *opts.ContextLines
+	}
+	return &Differ{
+		executor:         executor,
+		tool:             strings.Fields(opts.Tool),
+		ignoreWhitespace: opts.IgnoreWhitespace,
+		format:           opts.Format,
+		tempPrefix:       opts.TempPrefix,
+		tempExt:          normalizeTempExt(opts.TempExt),
+		contextLines:     contextLines,
+		createTemp:       opts.CreateTemp,
+	}
+}
+
+// ValidateContextLines checks that the number of diff context lines is not negative
+func ValidateContextLines(n int) error {
+	if n < 0 {
+		return fmt.Errorf("diff context lines must not be negative, got: %d", n)
+	}
+	return nil
+}
+
+// ValidateTempExt checks that ext is a file extension with or without its leading dot, e.g. ".yaml" or "json"
+func ValidateTempExt(ext string) error {
+	if ext != "" && !tempExtPattern.MatchString(ext) {
+		return fmt.Errorf("invalid temp file extension %q, must be letters and digits with an optional leading dot, e.g. .yaml", ext)
+	}
+	return nil
+}
+
+// normalizeTempExt returns ext with its leading dot, DEFAULT_TEMP_EXT if empty
+func normalizeTempExt(ext string) string {
+	if ext == "" {
+		return DEFAULT_TEMP_EXT
+	}
+	return "." + strings.TrimPrefix(ext, ".")
+}
+
+// ValidateTool checks that the configured external diff tool is installed, nothing to check without tool
+func (d *Differ) ValidateTool() error {
+	if len(d.tool) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath(d.tool[0]); err != nil {
+		return fmt.Errorf("diff tool %s not found: %w", d.tool[0], err)
+	}
+	return nil
 }
 
 // Convert text to bytes and call Diff
@@ -31,69 +149,151 @@ func (d *Differ) DiffText(before, after string) (string, error) {
 	return d.Diff([]byte(before), []byte(after))
 }
 
-// Diff compares two manifests and returns a unified diff
+// Diff compares two manifests and returns a unified diff, or the output of the external diff tool if configured.
+// JSON manifests are converted to YAML first, so the diff shows one line per field
 func (d *Differ) Diff(before, after []byte) (string, error) {
-	// Use system diff -u for unified diff with context
+	before, err := manifest.ToYAML(before)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert base manifest: %w", err)
+	}
+	after, err = manifest.ToYAML(after)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert head manifest: %w", err)
+	}
+	if len(d.tool) > 0 {
+		return d.toolDiff(before, after)
+	}
+	if d.format == DIFF_FORMAT_GIT {
+		return d.gitPatch(before, after)
+	}
 	return d.unifiedDiff(before, after)
 }
 
-// unifiedDiff uses system diff -u command for proper unified diff with context
-func (d *Differ) unifiedDiff(before, after []byte) (string, error) {
+// toolDiff runs the external diff tool on the manifests written to temp files
+func (d *Differ) toolDiff(before, after []byte) (string, error) {
 	if bytes.Equal(before, after) {
 		return "", nil
 	}
 
-	// Create temp files for diff
-	beforeFile, err := os.CreateTemp("", "before-*.yaml")
+	tempFiles := fileutil.NewTempFilesWithCreate(d.tempPrefix, d.createTemp)
+	defer tempFiles.Cleanup()
+	beforePath, err := tempFiles.Write("before-*"+d.tempExt, before)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", err
 	}
-	defer func() {
-		_ = beforeFile.Close()
-		_ = os.Remove(beforeFile.Name())
-	}()
-
-	afterFile, err := os.CreateTemp("", "after-*.yaml")
+	afterPath, err := tempFiles.Write("after-*"+d.tempExt, after)
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return "", err
 	}
-	defer func() {
-		_ = afterFile.Close()
-		_ = os.Remove(afterFile.Name())
-	}()
 
-	// Write manifests to temp files
-	if _, err := beforeFile.Write(before); err != nil {
-		return "", fmt.Errorf("failed to write base manifest: %w", err)
+	args := append(append([]string{}, d.tool[1:]...), beforePath, afterPath)
+	result, err := d.executor.Run(context.Background(), "", d.tool[0], args...)
+	// like diff, tools may exit with code 1 when the files differ (e.g. dyff --set-exit-code)
+	if err != nil && (result == nil || result.ExitCode != 1) {
+		stderr := ""
+		if result != nil {
+			stderr = strings.TrimSpace(string(result.Stderr))
+		}
+		return "", fmt.Errorf("diff tool %s failed: %w: %s", d.tool[0], err, stderr)
 	}
-	if err := beforeFile.Close(); err != nil {
-		return "", fmt.Errorf("failed to close base file: %w", err)
+
+	// Replace temp file names with "before" and "after"
+	diffOutput := string(result.Stdout)
+	diffOutput = strings.ReplaceAll(diffOutput, beforePath, "before")
+	diffOutput = strings.ReplaceAll(diffOutput, afterPath, "after")
+	return diffOutput, nil
+}
+
+// unifiedDiff returns the unified diff of the manifests like "diff -u", with the before and after file headers
+func (d *Differ) unifiedDiff(before, after []byte) (string, error) {
+	if bytes.Equal(before, after) {
+		return "", nil
+	}
+	if err := ValidateContextLines(d.contextLines); err != nil {
+		return "", err
 	}
 
-	if _, err := afterFile.Write(after); err != nil {
-		return "", fmt.Errorf("failed to write after manifest: %w", err)
+	beforeLines, afterLines := splitLines(string(before)), splitLines(string(after))
+	matcher := difflib.NewMatcherWithJunk(d.compareKeys(beforeLines), d.compareKeys(afterLines), false, nil)
+	groups := matcher.GetGroupedOpCodes(d.contextLines)
+	if len(groups) == 0 {
+		// only whitespace changes with ignoreWhitespace
+		return "", nil
 	}
-	if err := afterFile.Close(); err != nil {
-		return "", fmt.Errorf("failed to close after file: %w", err)
+
+	var out strings.Builder
+	// the header times of "diff -u", the diffed content is not read from files
+	now := time.Now().Format(time.DateTime)
+	fmt.Fprintf(&out, "--- before\t%s\n+++ after\t%s\n", now, now)
+	for _, group := range groups {
+		first, last := group[0], group[len(group)-1]
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", formatHunkRange(first.I1, last.I2), formatHunkRange(first.J1, last.J2))
+		for _, op := range group {
+			if op.Tag == 'e' {
+				writeHunkLines(&out, " ", beforeLines[op.I1:op.I2])
+				continue
+			}
+			if op.Tag == 'r' || op.Tag == 'd' {
+				writeHunkLines(&out, "-", beforeLines[op.I1:op.I2])
+			}
+			if op.Tag == 'r' || op.Tag == 'i' {
+				writeHunkLines(&out, "+", afterLines[op.J1:op.J2])
+			}
+		}
 	}
+	return out.String(), nil
+}
 
-	// Run diff -u
-	cmd := exec.Command("diff", "-u", beforeFile.Name(), afterFile.Name())
-	output, err := cmd.CombinedOutput()
+// splitLines splits content into lines keeping their line break, the last line has none if the content does not end
+// with one
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
 
-	// diff returns exit code 1 when files differ (not an error)
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			// This is expected when files differ
-		} else {
-			return "", fmt.Errorf("diff command failed: %w", err)
+// compareKeys returns the lines as compared by the diff: as is, or with ignoreWhitespace every whitespace run made
+// a single space and the trailing whitespace removed like "diff -b". A last line without line break differs from
+// the same line with one
+func (d *Differ) compareKeys(lines []string) []string {
+	if !d.ignoreWhitespace {
+		return lines
+	}
+	keys := make([]string, len(lines))
+	for i, line := range lines {
+		text, hasBreak := strings.CutSuffix(line, "\n")
+		key := strings.TrimRight(whitespacePattern.ReplaceAllString(text, " "), " ")
+		if hasBreak {
+			key += "\n"
 		}
+		keys[i] = key
 	}
+	return keys
+}
 
-	// Replace temp file names with "before" and "after"
-	diffOutput := string(output)
-	diffOutput = strings.ReplaceAll(diffOutput, beforeFile.Name(), "before")
-	diffOutput = strings.ReplaceAll(diffOutput, afterFile.Name(), "after")
+// formatHunkRange formats the line range [start, stop) of a hunk header like "diff -u": "3", "3,2", or "2,0"
+// for an empty range after line 2
+func formatHunkRange(start, stop int) string {
+	length := stop - start
+	switch length {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
 
-	return diffOutput, nil
+// writeHunkLines writes the lines of a hunk with their prefix, a line without line break is followed by the
+// "no newline" marker of "diff -u"
+func writeHunkLines(out *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		out.WriteString(prefix)
+		out.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
 }
@@ -1,9 +1,17 @@
 package diff
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
 )
 
 // normalizeTimestamps replaces timestamps in diff output with a placeholder
@@ -279,6 +287,275 @@ func TestDiffer_ConcurrentAccess(t *testing.T) {
 	}
 }
 
+// dyffOutput is a sample output of dyff between --output github, with 3 added and 2 removed lines
+const dyffOutput = `@@ spec.replicas (apps/v1/Deployment/my-app) @@
+! ± value change
+- 2
++ 3
+
+@@ spec.template.spec.containers.my-app.env (apps/v1/Deployment/my-app) @@
+! - one list entry removed:
+- name: LOG_LEVEL
+! + one list entry added:
++ name: LOG_LEVEL
++ value: debug
+`
+
+// TestDiffer_Diff_Tool tests that the configured external diff tool output is used
+func TestDiffer_Diff_Tool(t *testing.T) {
+	tests := []struct {
+		name      string
+		before    string
+		after     string
+		exitCode  int
+		runErr    error
+		wantDiff  string
+		wantCalls int
+		wantErr   string
+	}{
+		{
+			name:      "tool output is used",
+			before:    "replicas: 2",
+			after:     "replicas: 3",
+			wantDiff:  dyffOutput,
+			wantCalls: 1,
+		},
+		{
+			name:      "exit code 1 when files differ",
+			before:    "replicas: 2",
+			after:     "replicas: 3",
+			exitCode:  1,
+			runErr:    fmt.Errorf("exit status 1"),
+			wantDiff:  dyffOutput,
+			wantCalls: 1,
+		},
+		{
+			name:      "identical content does not run the tool",
+			before:    "replicas: 2",
+			after:     "replicas: 2",
+			wantDiff:  "",
+			wantCalls: 0,
+		},
+		{
+			name:      "tool failure",
+			before:    "replicas: 2",
+			after:     "replicas: 3",
+			exitCode:  255,
+			runErr:    fmt.Errorf("exit status 255"),
+			wantCalls: 1,
+			wantErr:   "diff tool dyff failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBefore, gotAfter string
+			fake := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					before, _ := os.ReadFile(args[len(args)-2])
+					after, _ := os.ReadFile(args[len(args)-1])
+					gotBefore, gotAfter = string(before), string(after)
+					return &command.Result{Stdout: []byte(dyffOutput), Stderr: []byte("boom"), ExitCode: tt.exitCode}, tt.runErr
+				},
+			}
+			d := NewDifferWithOptions(DifferOptions{Tool: "dyff between --omit-header --output github", Executor: fake})
+
+			got, err := d.Diff([]byte(tt.before), []byte(tt.after))
+			calls := fake.Calls()
+			if len(calls) != tt.wantCalls {
+				t.Fatalf("Diff() ran the tool %d times, want %d", len(calls), tt.wantCalls)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Diff() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if got != tt.wantDiff {
+				t.Errorf("Diff() = %q, want %q", got, tt.wantDiff)
+			}
+			if tt.wantCalls == 0 {
+				return
+			}
+			if name, args := calls[0].Name, calls[0].Args; name != "dyff" || len(args) != 6 || args[0] != "between" || args[3] != "github" {
+				t.Errorf("Diff() ran %q, want dyff between --omit-header --output github <before> <after>", calls[0].String())
+			}
+			if gotBefore != tt.before || gotAfter != tt.after {
+				t.Errorf("Diff() passed files with %q and %q, want %q and %q", gotBefore, gotAfter, tt.before, tt.after)
+			}
+			if added, deleted, total := CalcLineChangesFromDiffContent(got); added != 3 || deleted != 2 || total != 5 {
+				t.Errorf("CalcLineChangesFromDiffContent() = %d, %d, %d, want 3, 2, 5", added, deleted, total)
+			}
+		})
+	}
+}
+
+// TestDiffer_Diff_ContextLines tests the number of unchanged lines shown around a change and the rejection of negative values
+func TestDiffer_Diff_ContextLines(t *testing.T) {
+	const before = "a: 1\nb: 2\nc: 3\nd: 4\ne: 5\nf: 6\ng: 7\nh: 8\ni: 9\n"
+	after := strings.Replace(before, "e: 5", "e: 50", 1)
+	intPtr := func(n int) *int { return &n }
+
+	tests := []struct {
+		name         string
+		contextLines *int
+		wantContext  []string
+		wantOmitted  []string
+		wantErr      string
+	}{
+		{
+			name:        "default",
+			wantContext: []string{" b: 2", " d: 4", " f: 6", " h: 8"},
+			wantOmitted: []string{" a: 1", " i: 9"},
+		},
+		{
+			name:         "no context",
+			contextLines: intPtr(0),
+			wantOmitted:  []string{" d: 4", " f: 6"},
+		},
+		{
+			name:         "one line",
+			contextLines: intPtr(1),
+			wantContext:  []string{" d: 4", " f: 6"},
+			wantOmitted:  []string{" c: 3", " g: 7"},
+		},
+		{
+			name:         "negative",
+			contextLines: intPtr(-1),
+			wantErr:      "diff context lines must not be negative, got: -1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewDifferWithOptions(DifferOptions{ContextLines: tt.contextLines}).Diff([]byte(before), []byte(after))
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Diff() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if !strings.Contains(got, "-e: 5\n+e: 50\n") {
+				t.Errorf("Diff() = %q, want the change", got)
+			}
+			lines := strings.Split(got, "\n")
+			for _, want := range tt.wantContext {
+				if !slices.Contains(lines, want) {
+					t.Errorf("Diff() = %q, want the context line %q", got, want)
+				}
+			}
+			for _, omitted := range tt.wantOmitted {
+				if slices.Contains(lines, omitted) {
+					t.Errorf("Diff() = %q, want no context line %q", got, omitted)
+				}
+			}
+		})
+	}
+}
+
+// TestDiffer_Diff_IgnoreWhitespace tests that whitespace-only changes are ignored while real changes are still shown
+func TestDiffer_Diff_IgnoreWhitespace(t *testing.T) {
+	const before = "spec:\n  replicas: 2\n  template:\n    spec: {}\n"
+	tests := []struct {
+		name             string
+		ignoreWhitespace bool
+		after            string
+		wantEmpty        bool
+		wantContains     string
+	}{
+		{
+			name:             "reindented",
+			ignoreWhitespace: true,
+			after:            "spec:\n    replicas: 2\n    template:\n        spec: {}\n",
+			wantEmpty:        true,
+		},
+		{
+			name:             "trailing spaces",
+			ignoreWhitespace: true,
+			after:            "spec:  \n  replicas: 2 \n  template:\n    spec: {}\t\n",
+			wantEmpty:        true,
+		},
+		{
+			name:             "real change with whitespace changes",
+			ignoreWhitespace: true,
+			after:            "spec:  \n    replicas: 3\n  template:\n    spec: {}\n",
+			wantContains:     "+    replicas: 3",
+		},
+		{
+			name:         "whitespace change without the option",
+			after:        "spec:  \n  replicas: 2\n  template:\n    spec: {}\n",
+			wantContains: "+spec:  ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDifferWithOptions(DifferOptions{IgnoreWhitespace: tt.ignoreWhitespace})
+			got, err := d.Diff([]byte(before), []byte(tt.after))
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if tt.wantEmpty && got != "" {
+				t.Errorf("Diff() = %q, want empty diff", got)
+			}
+			if tt.wantContains != "" && !strings.Contains(got, tt.wantContains) {
+				t.Errorf("Diff() = %q, want it to contain %q", got, tt.wantContains)
+			}
+			gotText, err := d.DiffText(before, tt.after)
+			if err != nil {
+				t.Fatalf("DiffText() error = %v", err)
+			}
+			// the file headers hold the diff times, only the hunks are compared
+			if hunks := func(d string) string { return d[strings.Index(d, "@@")+1:] }; hunks(gotText) != hunks(got) {
+				t.Errorf("DiffText() = %q, want the same hunks as Diff() %q", gotText, got)
+			}
+			if added, deleted, total := CalcLineChangesFromDiffContent(got); tt.wantEmpty && (added != 0 || deleted != 0 || total != 0) {
+				t.Errorf("CalcLineChangesFromDiffContent() = %d, %d, %d, want no change", added, deleted, total)
+			}
+		})
+	}
+}
+
+// TestDiffer_Diff_WithoutSystemDiff tests that the unified diff does not depend on a diff binary
+func TestDiffer_Diff_WithoutSystemDiff(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	got, err := NewDiffer().Diff([]byte("replicas: 2\n"), []byte("replicas: 3\n"))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if want := "@@ -1 +1 @@\n-replicas: 2\n+replicas: 3\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("Diff() = %q, want it to end with %q", got, want)
+	}
+}
+
+// TestDiffer_ValidateTool tests the preflight check of the external diff tool
+func TestDiffer_ValidateTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		tool    string
+		wantErr bool
+	}{
+		{name: "no tool", tool: ""},
+		{name: "installed tool", tool: "sh -c"},
+		{name: "missing tool", tool: "not-an-installed-diff-tool between", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewDifferWithOptions(DifferOptions{Tool: tt.tool}).ValidateTool()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTool() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // Benchmark tests
 func BenchmarkDiffer_Diff(b *testing.B) {
 	d := NewDiffer()
@@ -293,3 +570,159 @@ func BenchmarkDiffer_Diff(b *testing.B) {
 		}
 	}
 }
+
+// TestDiffer_Diff_TempPrefix tests that the temp files passed to the diff tool carry the prefix and are removed,
+// also when the tool panics
+func TestDiffer_Diff_TempPrefix(t *testing.T) {
+	for _, panics := range []bool{false, true} {
+		var paths []string
+		fake := &testutil.FakeExecutor{
+			Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+				paths = args[len(args)-2:]
+				if panics {
+					panic("diff tool crashed")
+				}
+				return &command.Result{Stdout: []byte("+ replicas: 3")}, nil
+			},
+		}
+		d := NewDifferWithOptions(DifferOptions{Tool: "dyff between", Executor: fake, TempPrefix: "gitops-kustomz-my-app-42-"})
+
+		func() {
+			defer func() { _ = recover() }()
+			if _, err := d.Diff([]byte("replicas: 2"), []byte("replicas: 3")); err != nil {
+				t.Errorf("Diff() error = %v", err)
+			}
+		}()
+
+		if len(paths) != 2 {
+			t.Fatalf("diff tool called with %v, want the before and after files", paths)
+		}
+		for i, want := range []string{"gitops-kustomz-my-app-42-before-", "gitops-kustomz-my-app-42-after-"} {
+			if name := filepath.Base(paths[i]); !strings.HasPrefix(name, want) {
+				t.Errorf("temp file %s does not start with %s (panics: %v)", name, want, panics)
+			}
+			if _, err := os.Stat(paths[i]); !os.IsNotExist(err) {
+				t.Errorf("temp file %s was not removed (panics: %v), stat error = %v", paths[i], panics, err)
+			}
+		}
+	}
+}
+
+// TestDiffer_Diff_ToolHeaders tests that the temp file names in the diff tool output are replaced by before and after,
+// with predictable temp file names
+func TestDiffer_Diff_ToolHeaders(t *testing.T) {
+	dir := t.TempDir()
+	createTemp := func(_, pattern string) (*os.File, error) {
+		return os.Create(filepath.Join(dir, strings.Replace(pattern, "*", "0", 1)))
+	}
+	beforePath := filepath.Join(dir, "gitops-kustomz-my-app-42-before-0.yaml")
+	afterPath := filepath.Join(dir, "gitops-kustomz-my-app-42-after-0.yaml")
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			stdout := fmt.Sprintf("--- %s\t2025-10-01 12:00:00\n+++ %s\t2025-10-01 12:00:00\n@@ -1 +1 @@\n-replicas: 2\n+replicas: 3\n",
+				args[len(args)-2], args[len(args)-1])
+			return &command.Result{Stdout: []byte(stdout), ExitCode: 1}, fmt.Errorf("exit status 1")
+		},
+	}
+	d := NewDifferWithOptions(DifferOptions{
+		Tool:       "diff -u",
+		Executor:   fake,
+		TempPrefix: "gitops-kustomz-my-app-42-",
+		CreateTemp: createTemp,
+	})
+
+	got, err := d.Diff([]byte("replicas: 2\n"), []byte("replicas: 3\n"))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if calls := fake.Calls(); len(calls) != 1 || calls[0].String() != "diff -u "+beforePath+" "+afterPath {
+		t.Errorf("Diff() ran %v, want diff -u %s %s", calls, beforePath, afterPath)
+	}
+	want := "--- before\t2025-10-01 12:00:00\n+++ after\t2025-10-01 12:00:00\n@@ -1 +1 @@\n-replicas: 2\n+replicas: 3\n"
+	if got != want {
+		t.Errorf("Diff() = %q, want %q", got, want)
+	}
+	for _, path := range []string{beforePath, afterPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("temp file %s was not removed, stat error = %v", path, err)
+		}
+	}
+}
+
+// TestDiffer_Diff_TempExt tests the extension of the temp files passed to the diff tool
+func TestDiffer_Diff_TempExt(t *testing.T) {
+	tests := []struct {
+		name    string
+		tempExt string
+		wantExt string
+	}{
+		{name: "default", tempExt: "", wantExt: ".yaml"},
+		{name: "with dot", tempExt: ".yml", wantExt: ".yml"},
+		{name: "without dot", tempExt: "json", wantExt: ".json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths []string
+			fake := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					paths = args[len(args)-2:]
+					return &command.Result{Stdout: []byte("+ replicas: 3")}, nil
+				},
+			}
+			d := NewDifferWithOptions(DifferOptions{Tool: "dyff between", Executor: fake, TempExt: tt.tempExt})
+
+			if _, err := d.Diff([]byte("replicas: 2"), []byte("replicas: 3")); err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if len(paths) != 2 {
+				t.Fatalf("diff tool called with %v, want the before and after files", paths)
+			}
+			for _, path := range paths {
+				if ext := filepath.Ext(path); ext != tt.wantExt {
+					t.Errorf("temp file %s has extension %q, want %q", filepath.Base(path), ext, tt.wantExt)
+				}
+			}
+		})
+	}
+}
+
+// TestValidateTempExt tests the accepted temp file extensions
+func TestValidateTempExt(t *testing.T) {
+	for _, ext := range []string{"", ".yaml", "yml", ".json"} {
+		if err := ValidateTempExt(ext); err != nil {
+			t.Errorf("ValidateTempExt(%q) error = %v, want nil", ext, err)
+		}
+	}
+	for _, ext := range []string{".", "../x", ".tar.gz", "y ml"} {
+		if err := ValidateTempExt(ext); err == nil {
+			t.Errorf("ValidateTempExt(%q) error = nil, want an error", ext)
+		}
+	}
+}
+
+// TestDiffer_Diff_JSON tests that JSON manifests are diffed as YAML, one line per field
+func TestDiffer_Diff_JSON(t *testing.T) {
+	var contents []string
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			for _, path := range args[len(args)-2:] {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return nil, err
+				}
+				contents = append(contents, string(content))
+			}
+			return &command.Result{Stdout: []byte("- replicas: 2\n+ replicas: 3\n")}, nil
+		},
+	}
+	d := NewDifferWithOptions(DifferOptions{Tool: "dyff between", Executor: fake})
+
+	if _, err := d.Diff([]byte(`{"kind":"Deployment","spec":{"replicas":2}}`), []byte(`{"kind":"Deployment","spec":{"replicas":3}}`)); err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	want := []string{"kind: Deployment\nspec:\n  replicas: 2\n", "kind: Deployment\nspec:\n  replicas: 3\n"}
+	if len(contents) != 2 || contents[0] != want[0] || contents[1] != want[1] {
+		t.Errorf("diff tool called with manifests %q, want %q", contents, want)
+	}
+}
@@ -0,0 +1,181 @@
+package diff
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
+)
+
+const (
+	DIFF_FORMAT_UNIFIED = "unified" // single unified diff of the whole manifests
+	DIFF_FORMAT_GIT     = "git"     // git patch with one file per resource, applicable with git apply
+)
+
+// gitPatch returns a git patch of the manifests with one file per resource, e.g. a/Deployment/my-ns/my-app.yaml,
+// added and removed resources are new and deleted files. Resources without changes are omitted
+func (d *Differ) gitPatch(before, after []byte) (string, error) {
+	return d.patchFiles(resourceFiles(before), resourceFiles(after))
+}
+
+// DiffResources diffs the files of split builds (kustomize build -o), one resource per file, file by file.
+// The result is a git patch like DIFF_FORMAT_GIT, files being named after the resource they hold
+// instead of the kustomize file name, so renaming does not depend on the kustomize version
+func (d *Differ) DiffResources(before, after map[string][]byte) (string, error) {
+	beforeFiles, err := resourceFilesOf(before)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert base resources: %w", err)
+	}
+	afterFiles, err := resourceFilesOf(after)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert head resources: %w", err)
+	}
+	return d.patchFiles(beforeFiles, afterFiles)
+}
+
+// patchFiles returns a git patch of the before and after file contents by path
+func (d *Differ) patchFiles(beforeFiles, afterFiles map[string]string) (string, error) {
+	paths := []string{}
+	for p := range beforeFiles {
+		paths = append(paths, p)
+	}
+	for p := range afterFiles {
+		if _, ok := beforeFiles[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var patch strings.Builder
+	for _, p := range paths {
+		beforeContent, afterContent := beforeFiles[p], afterFiles[p]
+		hunks, err := d.unifiedDiff([]byte(beforeContent), []byte(afterContent))
+		if err != nil {
+			return "", fmt.Errorf("failed to diff %s: %w", p, err)
+		}
+		hunks = stripUnifiedHeader(hunks)
+		if hunks == "" {
+			continue
+		}
+
+		fromFile, toFile := "a/"+p, "b/"+p
+		fmt.Fprintf(&patch, "diff --git a/%s b/%s\n", p, p)
+		switch {
+		case beforeContent == "":
+			patch.WriteString("new file mode 100644\n")
+			fromFile = "/dev/null"
+		case afterContent == "":
+			patch.WriteString("deleted file mode 100644\n")
+			toFile = "/dev/null"
+		}
+		fmt.Fprintf(&patch, "--- %s\n+++ %s\n", fromFile, toFile)
+		patch.WriteString(hunks)
+	}
+	return patch.String(), nil
+}
+
+// stripUnifiedHeader removes the "---"/"+++" file header lines of a unified diff, keeping its hunks
+func stripUnifiedHeader(unified string) string {
+	for _, prefix := range []string{"--- ", "+++ "} {
+		if !strings.HasPrefix(unified, prefix) {
+			break
+		}
+		if i := strings.Index(unified, "\n"); i >= 0 {
+			unified = unified[i+1:]
+		} else {
+			unified = ""
+		}
+	}
+	return unified
+}
+
+// resourceFiles splits a manifest into one file content per resource, keyed by resourceFilePath.
+// Documents of the same resource, or without identity, are joined in the same file
+func resourceFiles(content []byte) map[string]string {
+	files := make(map[string]string)
+	for _, doc := range manifest.SplitDocuments(content) {
+		if !strings.HasSuffix(doc, "\n") {
+			doc += "\n"
+		}
+		p := resourceFilePath(documentKey(doc))
+		if existing, ok := files[p]; ok {
+			doc = existing + "---\n" + doc
+		}
+		files[p] = doc
+	}
+	return files
+}
+
+// resourceFilesOf keys the files of a split build by resourceFilePath, in file name order so files of the same
+// resource are joined deterministically. JSON files are converted to YAML like Diff does
+func resourceFilesOf(resources map[string][]byte) (map[string]string, error) {
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := make(map[string]string, len(resources))
+	for _, name := range names {
+		content, err := manifest.ToYAML(resources[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		doc := string(content)
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		if !strings.HasSuffix(doc, "\n") {
+			doc += "\n"
+		}
+		p := resourceFilePath(documentKey(doc))
+		if existing, ok := files[p]; ok {
+			doc = existing + "---\n" + doc
+		}
+		files[p] = doc
+	}
+	return files, nil
+}
+
+// resourceFilePath returns the patch file path of a resource: <kind>/<namespace>/<name>.yaml,
+// or <kind>/<name>.yaml without namespace. The kind of a custom resource is qualified by its group like kubectl does,
+// e.g. ScaledObject.keda.sh/my-ns/my-app.yaml
+func resourceFilePath(key resourceKey) string {
+	kind, name := key.Kind, key.Name
+	if kind == "" {
+		kind = "_"
+	}
+	if key.Group != "" {
+		kind += "." + key.Group
+	}
+	if name == "" {
+		name = "_"
+	}
+	if key.Namespace == "" {
+		return path.Join(kind, name+".yaml")
+	}
+	return path.Join(kind, key.Namespace, name+".yaml")
+}
+
+// resourceKeyOfFilePath parses a patch file path back to the resource identity, see resourceFilePath
+func resourceKeyOfFilePath(p string) resourceKey {
+	parts := strings.Split(strings.TrimSuffix(p, ".yaml"), "/")
+	unset := func(s string) string {
+		if s == "_" {
+			return ""
+		}
+		return s
+	}
+	// kinds have no dot, the qualifying group follows the first one
+	kind, group, _ := strings.Cut(parts[0], ".")
+	switch len(parts) {
+	case 2:
+		return resourceKey{Group: group, Kind: unset(kind), Name: unset(parts[1])}
+	case 3:
+		return resourceKey{Group: group, Kind: unset(kind), Namespace: parts[1], Name: unset(parts[2])}
+	default:
+		return resourceKey{}
+	}
+}
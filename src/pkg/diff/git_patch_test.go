@@ -0,0 +1,214 @@
+package diff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	gitPatchBefore = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+  namespace: my-ns
+data:
+  key: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: my-ns
+`
+	gitPatchAfter = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  replicas: 3
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-svc
+  namespace: my-ns
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: my-ns
+`
+)
+
+// TestDiffer_Diff_GitFormat tests the per resource git file headers of the git patch format
+func TestDiffer_Diff_GitFormat(t *testing.T) {
+	d := NewDifferWithOptions(DifferOptions{Format: DIFF_FORMAT_GIT})
+	got, err := d.Diff([]byte(gitPatchBefore), []byte(gitPatchAfter))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	wantHeaders := []string{
+		"diff --git a/ConfigMap/my-ns/my-config.yaml b/ConfigMap/my-ns/my-config.yaml\ndeleted file mode 100644\n--- a/ConfigMap/my-ns/my-config.yaml\n+++ /dev/null\n@@ -1,7 +0,0 @@\n",
+		"diff --git a/Deployment/my-ns/my-app.yaml b/Deployment/my-ns/my-app.yaml\n--- a/Deployment/my-ns/my-app.yaml\n+++ b/Deployment/my-ns/my-app.yaml\n@@ ",
+		"diff --git a/Service/my-ns/my-svc.yaml b/Service/my-ns/my-svc.yaml\nnew file mode 100644\n--- /dev/null\n+++ b/Service/my-ns/my-svc.yaml\n@@ -0,0 +1,8 @@\n",
+	}
+	last := -1
+	for _, header := range wantHeaders {
+		i := strings.Index(got, header)
+		if i < 0 {
+			t.Fatalf("Diff() = %q, want it to contain %q", got, header)
+		}
+		if i < last {
+			t.Errorf("Diff() file %q is out of order, want files sorted by path", header)
+		}
+		last = i
+	}
+	if strings.Contains(got, "Namespace/my-ns.yaml") {
+		t.Errorf("Diff() = %q, want unchanged resources omitted", got)
+	}
+	if n := strings.Count(got, "diff --git "); n != len(wantHeaders) {
+		t.Errorf("Diff() has %d files, want %d", n, len(wantHeaders))
+	}
+
+	same, err := d.Diff([]byte(gitPatchBefore), []byte(gitPatchBefore))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+	if same != "" {
+		t.Errorf("Diff() of identical manifests = %q, want empty", same)
+	}
+}
+
+// TestDiffer_Diff_GitFormat_Apply tests that the git patch applies cleanly to the before resources and yields the after ones
+func TestDiffer_Diff_GitFormat_Apply(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	d := NewDifferWithOptions(DifferOptions{Format: DIFF_FORMAT_GIT})
+	patch, err := d.Diff([]byte(gitPatchBefore), []byte(gitPatchAfter))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	dir := t.TempDir()
+	for p, content := range resourceFiles([]byte(gitPatchBefore)) {
+		path := filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	patchFile := filepath.Join(t.TempDir(), "manifests.patch")
+	if err := os.WriteFile(patchFile, []byte(patch), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{{"apply", "--check", patchFile}, {"apply", patchFile}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s error = %v: %s\npatch:\n%s", strings.Join(args, " "), err, out, patch)
+		}
+	}
+
+	for p, want := range resourceFiles([]byte(gitPatchAfter)) {
+		got, err := os.ReadFile(filepath.Join(dir, p))
+		if err != nil {
+			t.Errorf("resource %s missing after git apply: %v", p, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("resource %s after git apply = %q, want %q", p, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ConfigMap/my-ns/my-config.yaml")); !os.IsNotExist(err) {
+		t.Errorf("removed resource still exists after git apply, stat error = %v", err)
+	}
+}
+
+// TestCalcResourceStats_GitFormat tests that changes of a git patch are attributed to the resource of their file
+func TestCalcResourceStats_GitFormat(t *testing.T) {
+	d := NewDifferWithOptions(DifferOptions{Format: DIFF_FORMAT_GIT})
+	patch, err := d.Diff([]byte(gitPatchBefore), []byte(gitPatchAfter))
+	if err != nil {
+		t.Fatalf("Diff() error = %v", err)
+	}
+
+	stats := CalcResourceStats([]byte(gitPatchBefore), []byte(gitPatchAfter), patch)
+	// all the lines of a removed or added resource file are counted
+	want := map[string][2]int{
+		"ConfigMap/my-ns/my-config": {0, 7},
+		"Deployment/my-ns/my-app":   {1, 1},
+		"Service/my-ns/my-svc":      {8, 0},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("CalcResourceStats() = %+v, want %d resources", stats, len(want))
+	}
+	for _, stat := range stats {
+		key := stat.Kind + "/" + stat.Namespace + "/" + stat.Name
+		wantStat, ok := want[key]
+		if !ok {
+			t.Errorf("CalcResourceStats() unexpected resource %s", key)
+			continue
+		}
+		if stat.Added != wantStat[0] || stat.Deleted != wantStat[1] {
+			t.Errorf("CalcResourceStats() %s = +%d -%d, want +%d -%d", key, stat.Added, stat.Deleted, wantStat[0], wantStat[1])
+		}
+	}
+}
+
+// TestDiffer_DiffResources tests that the files of split builds are diffed file by file, named after their resource
+func TestDiffer_DiffResources(t *testing.T) {
+	before := map[string][]byte{
+		"v1_configmap_my-config.yaml":    []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: my-config\n  namespace: my-ns\ndata:\n  key: value\n"),
+		"apps_v1_deployment_my-app.yaml": []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: my-app\n  namespace: my-ns\nspec:\n  replicas: 2\n"),
+		"v1_namespace_my-ns.yaml":        []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: my-ns\n"),
+	}
+	after := map[string][]byte{
+		"apps_v1_deployment_my-app.yaml": []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: my-app\n  namespace: my-ns\nspec:\n  replicas: 3\n"),
+		"v1_namespace_my-ns.yaml":        []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: my-ns\n"),
+	}
+
+	d := NewDifferWithOptions(DifferOptions{})
+	got, err := d.DiffResources(before, after)
+	if err != nil {
+		t.Fatalf("DiffResources() error = %v", err)
+	}
+	wantHeaders := []string{
+		"diff --git a/ConfigMap/my-ns/my-config.yaml b/ConfigMap/my-ns/my-config.yaml\ndeleted file mode 100644\n",
+		"diff --git a/Deployment/my-ns/my-app.yaml b/Deployment/my-ns/my-app.yaml\n--- a/Deployment/my-ns/my-app.yaml\n+++ b/Deployment/my-ns/my-app.yaml\n@@ -4,4 +4,4 @@\n",
+	}
+	for _, header := range wantHeaders {
+		if !strings.Contains(got, header) {
+			t.Errorf("DiffResources() = %q, want it to contain %q", got, header)
+		}
+	}
+	if n := strings.Count(got, "diff --git "); n != len(wantHeaders) {
+		t.Errorf("DiffResources() has %d files, want %d", n, len(wantHeaders))
+	}
+
+	same, err := d.DiffResources(before, before)
+	if err != nil {
+		t.Fatalf("DiffResources() error = %v", err)
+	}
+	if same != "" {
+		t.Errorf("DiffResources() of identical resources = %q, want empty", same)
+	}
+}
@@ -0,0 +1,58 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	DIFF_MASK_REPLACEMENT = "***" // replaces the masked substrings of the diff content
+)
+
+// CompileMaskPatterns compiles the regular expressions of the values to mask in diffs
+func CompileMaskPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid diff mask pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// MaskContent replaces the substrings matching any pattern by DIFF_MASK_REPLACEMENT, line by line
+// so a pattern never spans lines and the diff keeps its lines and their +/- markers
+func MaskContent(content string, patterns []*regexp.Regexp) string {
+	return maskLines(content, patterns, true)
+}
+
+// MaskManifest is MaskContent for a manifest without diff markers, e.g. the full manifest of an environment
+func MaskManifest(content string, patterns []*regexp.Regexp) string {
+	return maskLines(content, patterns, false)
+}
+
+// maskLines masks content line by line, keeping the first character of each line if hasMarkers
+func maskLines(content string, patterns []*regexp.Regexp, hasMarkers bool) string {
+	if len(patterns) == 0 || content == "" {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		marker, body := "", line
+		if hasMarkers {
+			// keep the diff marker of the line, e.g. "+", "-" or " "
+			marker, body = line[:1], line[1:]
+		}
+		for _, re := range patterns {
+			body = re.ReplaceAllString(body, DIFF_MASK_REPLACEMENT)
+		}
+		lines[i] = marker + body
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMaskContent tests that matching values are redacted line by line and the rest is unchanged
+func TestMaskContent(t *testing.T) {
+	const content = `--- before
++++ after
+@@ -1,4 +1,4 @@
+ kind: Secret
+-  DATABASE_URL: postgres://app:old-secret@db:5432/app
++  DATABASE_URL: postgres://app:new-secret@db:5432/app
+   token: ghp_abcdef123456
+ replicas: 2`
+
+	tests := []struct {
+		name     string
+		patterns []string
+		want     string
+	}{
+		{
+			name: "no pattern",
+			want: content,
+		},
+		{
+			name:     "connection string password and token",
+			patterns: []string{`://[^:@/]+:[^@]+@`, `ghp_[A-Za-z0-9]+`},
+			want: `--- before
++++ after
+@@ -1,4 +1,4 @@
+ kind: Secret
+-  DATABASE_URL: postgres***db:5432/app
++  DATABASE_URL: postgres***db:5432/app
+   token: ***
+ replicas: 2`,
+		},
+		{
+			name:     "pattern does not eat the diff markers",
+			patterns: []string{`DATABASE_URL: .*$`},
+			want: `--- before
++++ after
+@@ -1,4 +1,4 @@
+ kind: Secret
+-  ***
++  ***
+   token: ghp_abcdef123456
+ replicas: 2`,
+		},
+		{
+			name:     "unmatched pattern",
+			patterns: []string{`AKIA[0-9A-Z]{16}`},
+			want:     content,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patterns, err := CompileMaskPatterns(tt.patterns)
+			if err != nil {
+				t.Fatalf("CompileMaskPatterns() error = %v", err)
+			}
+			got := MaskContent(content, patterns)
+			if got != tt.want {
+				t.Errorf("MaskContent() = %q, want %q", got, tt.want)
+			}
+			if strings.Count(got, "\n") != strings.Count(content, "\n") {
+				t.Errorf("MaskContent() changed the number of lines")
+			}
+			added, deleted, total := CalcLineChangesFromDiffContent(got)
+			wantAdded, wantDeleted, wantTotal := CalcLineChangesFromDiffContent(content)
+			if added != wantAdded || deleted != wantDeleted || total != wantTotal {
+				t.Errorf("MaskContent() line changes = +%d -%d (%d), want +%d -%d (%d)", added, deleted, total, wantAdded, wantDeleted, wantTotal)
+			}
+		})
+	}
+}
+
+// TestMaskManifest tests that values are masked from the first column of a manifest, which has no diff markers
+func TestMaskManifest(t *testing.T) {
+	patterns, err := CompileMaskPatterns([]string{`password: .*`, `tok_[a-z0-9]+`})
+	if err != nil {
+		t.Fatalf("CompileMaskPatterns() error = %v", err)
+	}
+	content := "password: top-level\nkind: Secret\nstringData:\n  token: tok_abc123\n"
+	want := "***\nkind: Secret\nstringData:\n  token: ***\n"
+	if got := MaskManifest(content, patterns); got != want {
+		t.Errorf("MaskManifest() = %q, want %q", got, want)
+	}
+}
+
+// TestCompileMaskPatterns_Invalid tests that an invalid pattern is reported
+func TestCompileMaskPatterns_Invalid(t *testing.T) {
+	_, err := CompileMaskPatterns([]string{`token=\S+`, `(unclosed`})
+	if err == nil || !strings.Contains(err.Error(), `invalid diff mask pattern "(unclosed"`) {
+		t.Errorf("CompileMaskPatterns() error = %v, want invalid pattern error", err)
+	}
+}
@@ -0,0 +1,46 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
+)
+
+// DiffPerResource diffs the manifests resource by resource, documents being matched by kind, namespace and name.
+// Returns the diff of each changed resource keyed by its identifier, e.g. Deployment/my-ns/my-app, or
+// Namespace/my-ns without namespace. An added or removed resource is diffed against an empty counterpart
+func (d *Differ) DiffPerResource(before, after []byte) (map[string]string, error) {
+	before, err := manifest.ToYAML(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert base manifest: %w", err)
+	}
+	after, err = manifest.ToYAML(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert head manifest: %w", err)
+	}
+
+	beforeFiles, afterFiles := resourceFiles(before), resourceFiles(after)
+	for p := range afterFiles {
+		if _, ok := beforeFiles[p]; !ok {
+			beforeFiles[p] = ""
+		}
+	}
+	diffs := make(map[string]string)
+	for p, beforeContent := range beforeFiles {
+		afterContent := afterFiles[p]
+		var resourceDiff string
+		if len(d.tool) > 0 {
+			resourceDiff, err = d.toolDiff([]byte(beforeContent), []byte(afterContent))
+		} else {
+			resourceDiff, err = d.unifiedDiff([]byte(beforeContent), []byte(afterContent))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", p, err)
+		}
+		if resourceDiff != "" {
+			diffs[strings.TrimSuffix(p, ".yaml")] = resourceDiff
+		}
+	}
+	return diffs, nil
+}
@@ -0,0 +1,51 @@
+package diff
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestDiffer_DiffPerResource tests that resources are diffed apart, added and removed ones against an empty counterpart
+func TestDiffer_DiffPerResource(t *testing.T) {
+	got, err := NewDiffer().DiffPerResource([]byte(gitPatchBefore), []byte(gitPatchAfter))
+	if err != nil {
+		t.Fatalf("DiffPerResource() error = %v", err)
+	}
+
+	wantIds := []string{"ConfigMap/my-ns/my-config", "Deployment/my-ns/my-app", "Service/my-ns/my-svc"}
+	ids := make([]string, 0, len(got))
+	for id := range got {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, wantIds) {
+		t.Fatalf("DiffPerResource() resources = %v, want %v", ids, wantIds)
+	}
+
+	tests := []struct {
+		id          string
+		wantHunk    string
+		wantMissing string
+	}{
+		{id: "ConfigMap/my-ns/my-config", wantHunk: "@@ -1,7 +0,0 @@\n-apiVersion: v1\n-kind: ConfigMap\n"},
+		{id: "Deployment/my-ns/my-app", wantHunk: "-  replicas: 2\n+  replicas: 3\n", wantMissing: "kind: Service"},
+		{id: "Service/my-ns/my-svc", wantHunk: "@@ -0,0 +1,8 @@\n+apiVersion: v1\n+kind: Service\n"},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(got[tt.id], tt.wantHunk) {
+			t.Errorf("DiffPerResource()[%s] = %q, want it to contain %q", tt.id, got[tt.id], tt.wantHunk)
+		}
+		if tt.wantMissing != "" && strings.Contains(got[tt.id], tt.wantMissing) {
+			t.Errorf("DiffPerResource()[%s] = %q, want only its own resource", tt.id, got[tt.id])
+		}
+	}
+
+	same, err := NewDiffer().DiffPerResource([]byte(gitPatchBefore), []byte(gitPatchBefore))
+	if err != nil {
+		t.Fatalf("DiffPerResource() error = %v", err)
+	}
+	if len(same) != 0 {
+		t.Errorf("DiffPerResource() of identical manifests = %v, want no resource", same)
+	}
+}
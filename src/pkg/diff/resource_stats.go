@@ -0,0 +1,212 @@
+package diff
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// hunkHeaderPattern matches a unified diff hunk header, e.g. "@@ -48,7 +48,9 @@"
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// HunkHeader is the position of a unified diff hunk in the old and new files
+// A range of 0 lines starts at the line before it, e.g. "@@ -3,0 +4,2 @@" inserts 2 lines after old line 3
+type HunkHeader struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+}
+
+// ParseHunkHeader parses a "@@ -a,b +c,d @@" hunk header, an omitted line count is 1
+func ParseHunkHeader(line string) (HunkHeader, bool) {
+	match := hunkHeaderPattern.FindStringSubmatch(line)
+	if match == nil {
+		return HunkHeader{}, false
+	}
+	atoi := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return HunkHeader{
+		OldStart: atoi(match[1]),
+		OldLines: atoi(match[2]),
+		NewStart: atoi(match[3]),
+		NewLines: atoi(match[4]),
+	}, true
+}
+
+// resourceKey identifies a resource of a manifest, the API group tells apart custom resources of the same kind
+// defined by different CRDs. The version is left out so an apiVersion bump does not change the identity
+type resourceKey struct {
+	Group     string // API group of a custom resource, e.g. "keda.sh" for keda.sh/v1alpha1, empty for built-in kinds
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// CalcResourceStats attributes the added and deleted lines of diffContent, a unified diff of before and after,
+// to the resources owning them, lines are counted like CalcLineChangesFromDiffContent so the stats sum to its totals
+// The changed lines of each resource are also reported as ranges of after lines (of the resource file in a git patch),
+// deleted lines being located at the after line following them
+// Resources without changes are omitted, the result is sorted by kind, group, namespace then name
+func CalcResourceStats(before, after []byte, diffContent string) []models.ResourceStat {
+	beforeOwners := lineOwners(before)
+	afterOwners := lineOwners(after)
+
+	stats := make(map[resourceKey]*models.ResourceStat)
+	statOfKey := func(key resourceKey) *models.ResourceStat {
+		if _, ok := stats[key]; !ok {
+			stats[key] = &models.ResourceStat{Group: key.Group, Kind: key.Kind, Namespace: key.Namespace, Name: key.Name}
+		}
+		return stats[key]
+	}
+	// in a git patch, lines belong to the resource of the current file
+	var fileKey *resourceKey
+	statOf := func(owners []resourceKey, line int) *models.ResourceStat {
+		if fileKey != nil {
+			return statOfKey(*fileKey)
+		}
+		key := resourceKey{}
+		if line >= 1 && line <= len(owners) {
+			key = owners[line-1]
+		}
+		return statOfKey(key)
+	}
+
+	inHunk := false
+	oldLine, newLine := 0, 0
+	for _, line := range strings.Split(diffContent, "\n") {
+		if p, ok := strings.CutPrefix(line, "diff --git a/"); ok {
+			key := resourceKeyOfFilePath(strings.SplitN(p, " b/", 2)[0])
+			fileKey = &key
+			inHunk = false
+			continue
+		}
+		if hunk, ok := ParseHunkHeader(line); ok {
+			inHunk = true
+			oldLine, newLine = hunk.OldStart, hunk.NewStart
+			// an empty range starts at the line before it
+			if hunk.OldLines == 0 {
+				oldLine++
+			}
+			if hunk.NewLines == 0 {
+				newLine++
+			}
+			continue
+		}
+		if !inHunk || line == "" {
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			oldLine++
+			newLine++
+		case '-':
+			stat := statOf(beforeOwners, oldLine)
+			stat.Deleted++
+			addChangedLine(stat, newLine)
+			oldLine++
+		case '+':
+			stat := statOf(afterOwners, newLine)
+			stat.Added++
+			addChangedLine(stat, newLine)
+			newLine++
+		}
+	}
+
+	results := []models.ResourceStat{}
+	for _, stat := range stats {
+		if stat.Added+stat.Deleted > 0 {
+			results = append(results, *stat)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Kind != results[j].Kind {
+			return results[i].Kind < results[j].Kind
+		}
+		if results[i].Group != results[j].Group {
+			return results[i].Group < results[j].Group
+		}
+		if results[i].Namespace != results[j].Namespace {
+			return results[i].Namespace < results[j].Namespace
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results
+}
+
+// addChangedLine extends the last line range of stat with line if adjacent, appends a new range otherwise
+// Lines are visited in increasing order, a deletion followed by additions shares their first line
+func addChangedLine(stat *models.ResourceStat, line int) {
+	if n := len(stat.LineRanges); n > 0 && line <= stat.LineRanges[n-1].End+1 {
+		stat.LineRanges[n-1].End = max(stat.LineRanges[n-1].End, line)
+		return
+	}
+	stat.LineRanges = append(stat.LineRanges, models.LineRange{Start: line, End: line})
+}
+
+// lineOwners returns the resource owning each line of a multi-document manifest,
+// document separators belong to the following document
+func lineOwners(manifest []byte) []resourceKey {
+	lines := strings.SplitAfter(string(manifest), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	owners := make([]resourceKey, len(lines))
+	start := 0
+	flush := func(end int) {
+		key := documentKey(strings.Join(lines[start:end], ""))
+		for i := start; i < end; i++ {
+			owners[i] = key
+		}
+		start = end
+	}
+	for i, line := range lines {
+		if i > start && strings.TrimRight(line, " \t\r\n") == "---" {
+			flush(i)
+		}
+	}
+	flush(len(lines))
+	return owners
+}
+
+// documentKey returns the identity of a single YAML document of any apiVersion and kind, empty if it cannot be parsed
+func documentKey(document string) resourceKey {
+	var header struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+		Metadata   struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(document), &header); err != nil {
+		return resourceKey{}
+	}
+	return resourceKey{
+		Group:     customResourceGroup(header.APIVersion),
+		Kind:      header.Kind,
+		Namespace: header.Metadata.Namespace,
+		Name:      header.Metadata.Name,
+	}
+}
+
+// customResourceGroup returns the group of a custom resource apiVersion, e.g. "keda.sh" for keda.sh/v1alpha1
+// CRD groups always contain a dot, built-in groups are the core one (v1), single words (apps/v1) or *.k8s.io ones,
+// for which it is empty as built-in kinds are unique, e.g. an Ingress keeps its identity across extensions and networking.k8s.io
+func customResourceGroup(apiVersion string) string {
+	group, _, found := strings.Cut(apiVersion, "/")
+	if !found || !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io") {
+		return ""
+	}
+	return group
+}
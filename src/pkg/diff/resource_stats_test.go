@@ -0,0 +1,271 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+const resourceStatsBefore = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-app-config
+  namespace: my-app
+data:
+  LOG_LEVEL: info
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: my-app
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - image: nginx:1.21
+        name: my-app
+`
+
+const resourceStatsAfter = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-app-config
+  namespace: my-app
+data:
+  LOG_LEVEL: debug
+  FEATURE_X: "true"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: my-app
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - image: nginx:1.21
+        name: my-app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-app
+  namespace: my-app
+spec:
+  ports:
+  - port: 80
+`
+
+// TestCalcResourceStats tests that diff lines are attributed to their resources and sum to the totals
+func TestCalcResourceStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []models.ResourceStat
+	}{
+		{
+			name:   "no changes",
+			before: resourceStatsBefore,
+			after:  resourceStatsBefore,
+			want:   []models.ResourceStat{},
+		},
+		{
+			name:   "modified and added resources",
+			before: resourceStatsBefore,
+			after:  resourceStatsAfter,
+			want: []models.ResourceStat{
+				{Kind: "ConfigMap", Namespace: "my-app", Name: "my-app-config", Added: 2, Deleted: 1,
+					LineRanges: []models.LineRange{{Start: 7, End: 8}}},
+				{Kind: "Deployment", Namespace: "my-app", Name: "my-app", Added: 1, Deleted: 1,
+					LineRanges: []models.LineRange{{Start: 16, End: 16}}},
+				{Kind: "Service", Namespace: "my-app", Name: "my-app", Added: 9, Deleted: 0,
+					LineRanges: []models.LineRange{{Start: 22, End: 30}}},
+			},
+		},
+		{
+			name:   "deleted resource",
+			before: resourceStatsAfter,
+			after:  resourceStatsBefore,
+			want: []models.ResourceStat{
+				{Kind: "ConfigMap", Namespace: "my-app", Name: "my-app-config", Added: 1, Deleted: 2,
+					LineRanges: []models.LineRange{{Start: 7, End: 7}}},
+				{Kind: "Deployment", Namespace: "my-app", Name: "my-app", Added: 1, Deleted: 1,
+					LineRanges: []models.LineRange{{Start: 15, End: 15}}},
+				{Kind: "Service", Namespace: "my-app", Name: "my-app", Added: 0, Deleted: 9,
+					LineRanges: []models.LineRange{{Start: 21, End: 21}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffContent, err := NewDiffer().DiffText(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("DiffText() error = %v", err)
+			}
+
+			got := CalcResourceStats([]byte(tt.before), []byte(tt.after), diffContent)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CalcResourceStats() = %+v, want %+v", got, tt.want)
+			}
+
+			added, deleted := 0, 0
+			for _, stat := range got {
+				added += stat.Added
+				deleted += stat.Deleted
+			}
+			wantAdded, wantDeleted, _ := CalcLineChangesFromDiffContent(diffContent)
+			if added != wantAdded || deleted != wantDeleted {
+				t.Errorf("CalcResourceStats() sums to %d➕/%d➖, want the environment totals %d➕/%d➖", added, deleted, wantAdded, wantDeleted)
+			}
+		})
+	}
+}
+
+// TestParseHunkHeader tests the parsing of unified diff hunk headers
+func TestParseHunkHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   HunkHeader
+		wantOk bool
+	}{
+		{
+			name:   "old and new ranges",
+			line:   "@@ -48,7 +50,8 @@",
+			want:   HunkHeader{OldStart: 48, OldLines: 7, NewStart: 50, NewLines: 8},
+			wantOk: true,
+		},
+		{
+			name:   "omitted line counts are 1",
+			line:   "@@ -3 +3 @@",
+			want:   HunkHeader{OldStart: 3, OldLines: 1, NewStart: 3, NewLines: 1},
+			wantOk: true,
+		},
+		{
+			name:   "empty range and section heading",
+			line:   "@@ -0,0 +1,4 @@ kind: Service",
+			want:   HunkHeader{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 4},
+			wantOk: true,
+		},
+		{
+			name: "not a hunk header",
+			line: "--- before",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseHunkHeader(tt.line)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("ParseHunkHeader(%q) = %+v, %v, want %+v, %v", tt.line, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+// TestCalcResourceStats_MultiHunkLineRanges tests the changed line ranges extracted from a multi-hunk diff
+func TestCalcResourceStats_MultiHunkLineRanges(t *testing.T) {
+	before := resourceStatsBefore
+	after := strings.Replace(resourceStatsBefore, "LOG_LEVEL: info", "LOG_LEVEL: debug", 1)
+	after = strings.Replace(after, "        name: my-app\n", "        name: my-app\n        ports:\n        - containerPort: 80\n", 1)
+	// enough unchanged lines between the changes to split them into two hunks
+	filler := "  annotations:\n" + strings.Repeat("    unchanged: \"true\"\n", 8)
+	before = strings.Replace(before, "  namespace: my-app\nspec:", "  namespace: my-app\n"+filler+"spec:", 1)
+	after = strings.Replace(after, "  namespace: my-app\nspec:", "  namespace: my-app\n"+filler+"spec:", 1)
+
+	diffContent, err := NewDiffer().DiffText(before, after)
+	if err != nil {
+		t.Fatalf("DiffText() error = %v", err)
+	}
+	if hunks := strings.Count(diffContent, "\n@@ "); hunks != 2 {
+		t.Fatalf("diff has %d hunks, want 2:\n%s", hunks, diffContent)
+	}
+
+	got := CalcResourceStats([]byte(before), []byte(after), diffContent)
+	want := []models.ResourceStat{
+		{Kind: "ConfigMap", Namespace: "my-app", Name: "my-app-config", Added: 1, Deleted: 1,
+			LineRanges: []models.LineRange{{Start: 7, End: 7}}},
+		{Kind: "Deployment", Namespace: "my-app", Name: "my-app", Added: 2, Deleted: 0,
+			LineRanges: []models.LineRange{{Start: 30, End: 31}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CalcResourceStats() = %+v, want %+v", got, want)
+	}
+	if s := got[1].LineRanges[0].String(); s != "30-31" {
+		t.Errorf("LineRange.String() = %q, want %q", s, "30-31")
+	}
+}
+
+// TestCalcResourceStats_CustomResources tests the identity of custom resources, qualified by their API group
+// so resources of the same kind and name from different CRDs are told apart, in both diff formats
+func TestCalcResourceStats_CustomResources(t *testing.T) {
+	const before = `apiVersion: keda.sh/v1alpha1
+kind: ScaledObject
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  maxReplicaCount: 10
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  secretName: my-app-tls
+---
+apiVersion: example.com/v1
+kind: Certificate
+metadata:
+  name: my-app
+  namespace: my-ns
+spec:
+  issuer: internal
+`
+	after := strings.Replace(strings.Replace(before, "maxReplicaCount: 10", "maxReplicaCount: 20", 1),
+		"issuer: internal", "issuer: external", 1)
+	// the unchanged cert-manager.io certificate is omitted
+	want := []models.ResourceStat{
+		{Group: "example.com", Kind: "Certificate", Namespace: "my-ns", Name: "my-app", Added: 1, Deleted: 1},
+		{Group: "keda.sh", Kind: "ScaledObject", Namespace: "my-ns", Name: "my-app", Added: 1, Deleted: 1},
+	}
+
+	tests := []struct {
+		name     string
+		format   string
+		wantFile string
+	}{
+		{name: "unified", format: DIFF_FORMAT_UNIFIED},
+		{name: "git", format: DIFF_FORMAT_GIT, wantFile: "diff --git a/ScaledObject.keda.sh/my-ns/my-app.yaml b/ScaledObject.keda.sh/my-ns/my-app.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffContent, err := NewDifferWithOptions(DifferOptions{Format: tt.format}).Diff([]byte(before), []byte(after))
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if tt.wantFile != "" && !strings.Contains(diffContent, tt.wantFile) {
+				t.Errorf("Diff() = %s, want the file header %q", diffContent, tt.wantFile)
+			}
+
+			got := []models.ResourceStat{}
+			for _, stat := range CalcResourceStats([]byte(before), []byte(after), diffContent) {
+				stat.LineRanges = nil
+				got = append(got, stat)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("CalcResourceStats() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
@@ -4,17 +4,50 @@ import "strings"
 
 // CalcLineChangesFromDiffContent calculates the number of added and deleted lines from a diff content
 // returns: addedLines, deletedLines, totalLines
-// operate on `diff -u` output, so other options may not work
+// operate on `diff -u` output: the lines of a hunk starting with "+" or "-" are counted, the "---"/"+++" file headers
+// and "@@" hunk markers are not. Outside hunks, e.g. the output of an external diff tool, the lines starting with
+// "+" or "-" are counted except the file headers
 func CalcLineChangesFromDiffContent(diffContent string) (int, int, int) {
 	addedLines := 0
 	deletedLines := 0
+	// old and new lines left in the current hunk, its lines are content even if they look like file headers,
+	// e.g. "----" for a removed "---" document separator
+	oldLeft, newLeft := 0, 0
 	for _, line := range strings.Split(diffContent, "\n") {
-		if strings.HasPrefix(line, "+ ") {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				addedLines++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				deletedLines++
+				oldLeft--
+			case strings.HasPrefix(line, " "), line == "":
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+		if hunk, ok := ParseHunkHeader(line); ok {
+			oldLeft, newLeft = hunk.OldLines, hunk.NewLines
+			continue
+		}
+		if isCountedAddedLine(line) {
 			addedLines++
 		}
-		if strings.HasPrefix(line, "- ") {
+		if isCountedDeletedLine(line) {
 			deletedLines++
 		}
 	}
 	return addedLines, deletedLines, addedLines + deletedLines
 }
+
+// isCountedAddedLine reports whether a diff line outside hunks counts as an added line
+func isCountedAddedLine(line string) bool {
+	return strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++")
+}
+
+// isCountedDeletedLine reports whether a diff line outside hunks counts as a deleted line
+func isCountedDeletedLine(line string) bool {
+	return strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "---")
+}
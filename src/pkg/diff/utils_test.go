@@ -167,6 +167,68 @@ func TestCalcLineChangesFromDiffContent_EdgeCases(t *testing.T) {
 	})
 }
 
+// TestCalcLineChangesFromDiffContent_DiffGrammar tests the counting of the lines emitted by "diff -u": content lines
+// without space after the marker are counted, file headers and hunk markers are not, even inside hunks
+func TestCalcLineChangesFromDiffContent_DiffGrammar(t *testing.T) {
+	tests := []struct {
+		name        string
+		diffContent string
+		wantAdded   int
+		wantDeleted int
+	}{
+		{
+			name:        "headers only",
+			diffContent: "--- before\t2025-10-23 00:52:21\n+++ after\t2025-10-23 00:52:21\n",
+		},
+		{
+			name:        "content lines without space",
+			diffContent: "--- before\n+++ after\n@@ -1,2 +1,2 @@\n kind: Deployment\n-replicas: 2\n+replicas: 3\n",
+			wantAdded:   1,
+			wantDeleted: 1,
+		},
+		{
+			name:        "removed document separator and added list item",
+			diffContent: "--- before\n+++ after\n@@ -1,3 +1,2 @@\n a: 1\n----\n-b: 2\n+- c\n",
+			wantAdded:   1,
+			wantDeleted: 2,
+		},
+		{
+			name:        "removed line looking like a header",
+			diffContent: "--- before\n+++ after\n@@ -1 +1 @@\n--- x\n+++ y\n",
+			wantAdded:   1,
+			wantDeleted: 1,
+		},
+		{
+			name:        "several hunks",
+			diffContent: "--- before\n+++ after\n@@ -1,2 +1,2 @@\n a: 1\n-b: 2\n+b: 3\n@@ -9 +9,2 @@\n-i: 9\n+i: 10\n+j: 11\n\\ No newline at end of file\n",
+			wantAdded:   3,
+			wantDeleted: 2,
+		},
+		{
+			name:        "git patch with new and removed files",
+			diffContent: "diff --git a/x.yaml b/x.yaml\nnew file mode 100644\n--- /dev/null\n+++ b/x.yaml\n@@ -0,0 +1,2 @@\n+a: 1\n+---\ndiff --git a/y.yaml b/y.yaml\ndeleted file mode 100644\n--- a/y.yaml\n+++ /dev/null\n@@ -1 +0,0 @@\n-b: 2\n",
+			wantAdded:   2,
+			wantDeleted: 1,
+		},
+		{
+			name:        "diff tool output without hunks",
+			diffContent: "spec.replicas\n- 2\n+ 3\n+++ header\n-removed\n",
+			wantAdded:   1,
+			wantDeleted: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, deleted, total := CalcLineChangesFromDiffContent(tt.diffContent)
+			if added != tt.wantAdded || deleted != tt.wantDeleted || total != tt.wantAdded+tt.wantDeleted {
+				t.Errorf("CalcLineChangesFromDiffContent() = %d, %d, %d, want %d, %d, %d",
+					added, deleted, total, tt.wantAdded, tt.wantDeleted, tt.wantAdded+tt.wantDeleted)
+			}
+		})
+	}
+}
+
 // BenchmarkCalcLineChangesFromDiffContent benchmarks the utility function
 func BenchmarkCalcLineChangesFromDiffContent(b *testing.B) {
 	// Create a large diff content for benchmarking
@@ -0,0 +1,96 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteFileAtomic tests that the file is written completely with the requested permissions
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte(`{"old":true}`), 0600); err != nil {
+		t.Fatalf("failed to write previous file: %v", err)
+	}
+
+	if err := WriteFileAtomic(path, []byte(`{"new":true}`), 0644); err != nil {
+		t.Fatalf("WriteFileAtomic() error = %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(got) != `{"new":true}` {
+		t.Errorf("file content = %q, want %q", got, `{"new":true}`)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("file permissions = %v, want %v", info.Mode().Perm(), os.FileMode(0644))
+	}
+	assertNoTempFiles(t, filepath.Dir(path))
+}
+
+// TestWriteFileAtomic_PartialWrite tests that a write failing halfway never leaves a truncated file
+func TestWriteFileAtomic_PartialWrite(t *testing.T) {
+	data := []byte(`{"policies":["ha","tls"]}`)
+	partialWrite := func(f *os.File, data []byte) error {
+		if _, err := f.Write(data[:len(data)/2]); err != nil {
+			return err
+		}
+		return errors.New("disk full")
+	}
+
+	tests := []struct {
+		name     string
+		previous string // content of the file before the write, absent if empty
+	}{
+		{
+			name: "no previous file stays absent",
+		},
+		{
+			name:     "previous file is kept",
+			previous: `{"policies":[]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "report.json")
+			if tt.previous != "" {
+				if err := os.WriteFile(path, []byte(tt.previous), 0644); err != nil {
+					t.Fatalf("failed to write previous file: %v", err)
+				}
+			}
+
+			if err := writeFileAtomic(path, data, 0644, partialWrite); err == nil {
+				t.Fatal("writeFileAtomic() error = nil, want the write error")
+			}
+
+			got, err := os.ReadFile(path)
+			switch {
+			case tt.previous == "" && !os.IsNotExist(err):
+				t.Errorf("file exists after a failed write (content %q, error %v), want absent", got, err)
+			case tt.previous != "" && string(got) != tt.previous:
+				t.Errorf("file content = %q, want the previous content %q", got, tt.previous)
+			}
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+// assertNoTempFiles fails if temp files are left in dir
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".*.tmp"))
+	if err != nil {
+		t.Fatalf("failed to list temp files: %v", err)
+	}
+	if len(matches) > 0 {
+		t.Errorf("temp files left behind: %v", matches)
+	}
+}
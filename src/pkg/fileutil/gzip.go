@@ -0,0 +1,53 @@
+package fileutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Extension appended to the name of the files written gzip-compressed
+const GZIP_EXT = ".gz"
+
+// WriteOutputFile writes data to path with WriteFileAtomic, gzip-compressed if path ends with GZIP_EXT
+func WriteOutputFile(path string, data []byte, perm os.FileMode) error {
+	if !strings.HasSuffix(path, GZIP_EXT) {
+		return WriteFileAtomic(path, data, perm)
+	}
+	return writeFileAtomic(path, data, perm, func(f *os.File, data []byte) error {
+		gz := gzip.NewWriter(f)
+		if _, err := gz.Write(data); err != nil {
+			return err
+		}
+		return gz.Close()
+	})
+}
+
+// ReadOutputFile reads the file at path, decompressed if path ends with GZIP_EXT
+func ReadOutputFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasSuffix(path, GZIP_EXT) {
+		return data, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	defer gz.Close()
+	decompressed, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return decompressed, nil
+}
+
+// OutputExt returns ext, with GZIP_EXT appended if compress, e.g. ".json.gz"
+func OutputExt(ext string, compress bool) string {
+	if compress {
+		return ext + GZIP_EXT
+	}
+	return ext
+}
@@ -0,0 +1,70 @@
+package fileutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteOutputFile tests that a .gz path is written gzip-compressed and read back as the plain content
+func TestWriteOutputFile(t *testing.T) {
+	data := []byte(`{"service":"my-app","policies":["ha","tls"]}`)
+	tests := []struct {
+		name       string
+		file       string
+		compressed bool
+	}{
+		{name: "plain", file: "report.json"},
+		{name: "gzip", file: "report.json.gz", compressed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			if err := WriteOutputFile(path, data, 0644); err != nil {
+				t.Fatalf("WriteOutputFile() error = %v", err)
+			}
+
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if tt.compressed {
+				gz, err := gzip.NewReader(bytes.NewReader(raw))
+				if err != nil {
+					t.Fatalf("file is not gzip-compressed: %v", err)
+				}
+				if raw, err = io.ReadAll(gz); err != nil {
+					t.Fatalf("failed to decompress file: %v", err)
+				}
+			}
+			if !bytes.Equal(raw, data) {
+				t.Errorf("file content = %q, want %q", raw, data)
+			}
+
+			got, err := ReadOutputFile(path)
+			if err != nil {
+				t.Fatalf("ReadOutputFile() error = %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("ReadOutputFile() = %q, want %q", got, data)
+			}
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+// TestReadOutputFile_NotGzip tests that a .gz file which is not gzip-compressed is an error
+func TestReadOutputFile_NotGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json.gz")
+	if err := os.WriteFile(path, []byte(`{}`), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if _, err := ReadOutputFile(path); err == nil {
+		t.Error("ReadOutputFile() error = nil, want a decompression error")
+	}
+}
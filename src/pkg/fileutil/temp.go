@@ -0,0 +1,69 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// unsafePrefixChars are the characters replaced in a temp file prefix, e.g. the "/" of a nested service name
+var unsafePrefixChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// TempFiles creates temp files named after a common prefix, so stray files of a run can be attributed and cleaned up
+// Defer Cleanup right after NewTempFiles, before any file is created, so the files are removed on every return and panic
+type TempFiles struct {
+	prefix     string
+	createTemp CreateTempFunc
+	paths      []string
+}
+
+// CreateTempFunc creates a new temp file in dir named after pattern, see os.CreateTemp
+type CreateTempFunc func(dir, pattern string) (*os.File, error)
+
+// NewTempFiles creates a set of temp files whose names start with prefix, characters unsafe in file names are replaced by "_"
+func NewTempFiles(prefix string) *TempFiles {
+	return NewTempFilesWithCreate(prefix, nil)
+}
+
+// NewTempFilesWithCreate is NewTempFiles with the file creation injectable, e.g. for predictable names in tests.
+// os.CreateTemp if nil
+func NewTempFilesWithCreate(prefix string, createTemp CreateTempFunc) *TempFiles {
+	if createTemp == nil {
+		createTemp = os.CreateTemp
+	}
+	return &TempFiles{prefix: SanitizeTempPrefix(prefix), createTemp: createTemp}
+}
+
+// SanitizeTempPrefix replaces the characters of prefix unsafe in file names by "_"
+func SanitizeTempPrefix(prefix string) string {
+	return unsafePrefixChars.ReplaceAllString(prefix, "_")
+}
+
+// Write writes content to a new temp file named prefix + pattern, see os.CreateTemp, and returns its path
+func (t *TempFiles) Write(pattern string, content []byte) (string, error) {
+	file, err := t.createTemp("", t.prefix+pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	t.paths = append(t.paths, file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		_ = file.Close()
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// Cleanup removes all the temp files created so far, it can be called several times
+func (t *TempFiles) Cleanup() {
+	for _, path := range t.paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			// Log error but don't fail the operation
+			fmt.Printf("Warning: failed to remove temp file %s: %v\n", path, err)
+		}
+	}
+	t.paths = nil
+}
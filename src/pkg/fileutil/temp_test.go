@@ -0,0 +1,76 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestTempFiles tests that temp files carry the sanitized prefix and are removed by Cleanup
+func TestTempFiles(t *testing.T) {
+	tempFiles := NewTempFiles("gitops-kustomz-team/my-app-42-")
+	path, err := tempFiles.Write("manifest-*.yaml", []byte("kind: Deployment"))
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	if name := filepath.Base(path); !strings.HasPrefix(name, "gitops-kustomz-team_my-app-42-manifest-") || !strings.HasSuffix(name, ".yaml") {
+		t.Errorf("Write() created %s, want gitops-kustomz-team_my-app-42-manifest-*.yaml", name)
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "kind: Deployment" {
+		t.Errorf("temp file content = %q, %v, want %q", content, err, "kind: Deployment")
+	}
+
+	tempFiles.Cleanup()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("temp file %s still exists after Cleanup(), stat error = %v", path, err)
+	}
+	// a second cleanup is a no-op
+	tempFiles.Cleanup()
+}
+
+// TestTempFiles_CleanupOnPanic tests that a deferred Cleanup removes the temp files when the caller panics
+func TestTempFiles_CleanupOnPanic(t *testing.T) {
+	var path string
+	func() {
+		defer func() { _ = recover() }()
+		tempFiles := NewTempFiles("gitops-kustomz-my-app-")
+		defer tempFiles.Cleanup()
+
+		var err error
+		if path, err = tempFiles.Write("before-*.yaml", []byte("replicas: 2")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		panic("tool crashed")
+	}()
+
+	if path == "" {
+		t.Fatal("no temp file was written")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("temp file %s still exists after a panic, stat error = %v", path, err)
+	}
+}
+
+// TestNewTempFilesWithCreate tests that temp files are created through the injected function with the prefixed pattern
+func TestNewTempFilesWithCreate(t *testing.T) {
+	dir := t.TempDir()
+	var patterns []string
+	tempFiles := NewTempFilesWithCreate("gitops-kustomz-my-app-", func(_, pattern string) (*os.File, error) {
+		patterns = append(patterns, pattern)
+		return os.Create(filepath.Join(dir, strings.Replace(pattern, "*", "0", 1)))
+	})
+	defer tempFiles.Cleanup()
+
+	path, err := tempFiles.Write("before-*.yaml", []byte("kind: Deployment"))
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if want := filepath.Join(dir, "gitops-kustomz-my-app-before-0.yaml"); path != want {
+		t.Errorf("Write() created %s, want %s", path, want)
+	}
+	if len(patterns) != 1 || patterns[0] != "gitops-kustomz-my-app-before-*.yaml" {
+		t.Errorf("create called with patterns %v, want [gitops-kustomz-my-app-before-*.yaml]", patterns)
+	}
+}
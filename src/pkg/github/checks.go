@@ -0,0 +1,113 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+	"github.com/google/go-github/v66/github"
+)
+
+const (
+	// Maximum number of annotations of a single check run create or update request, the API rejects more
+	MAX_CHECK_RUN_ANNOTATIONS = 50
+
+	ANNOTATION_LEVEL_FAILURE = "failure"
+	ANNOTATION_LEVEL_WARNING = "warning"
+	ANNOTATION_LEVEL_NOTICE  = "notice"
+)
+
+// annotationLevel maps the enforcement level of a violated policy to the check run annotation level
+func annotationLevel(level string) string {
+	switch level {
+	case policy.POLICY_LEVEL_BLOCK:
+		return ANNOTATION_LEVEL_FAILURE
+	case policy.POLICY_LEVEL_WARNING:
+		return ANNOTATION_LEVEL_WARNING
+	default:
+		return ANNOTATION_LEVEL_NOTICE
+	}
+}
+
+// BuildCheckRunAnnotations builds one check run annotation per violation, pointing at the line of its manifest file,
+// sorted by path, line then policy id. Violations without a file cannot be shown inline and are left out
+func BuildCheckRunAnnotations(violations []models.FileViolation) []*github.CheckRunAnnotation {
+	sorted := make([]models.FileViolation, 0, len(violations))
+	for _, v := range violations {
+		if v.Path == "" {
+			logger.WithField("policyId", v.PolicyId).Debug("Violation without manifest file, not annotated")
+			continue
+		}
+		sorted = append(sorted, v)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		if sorted[i].Line != sorted[j].Line {
+			return sorted[i].Line < sorted[j].Line
+		}
+		return sorted[i].PolicyId < sorted[j].PolicyId
+	})
+
+	annotations := make([]*github.CheckRunAnnotation, 0, len(sorted))
+	for _, v := range sorted {
+		line := max(v.Line, 1)
+		annotations = append(annotations, &github.CheckRunAnnotation{
+			Path:            github.String(v.Path),
+			StartLine:       github.Int(line),
+			EndLine:         github.Int(line),
+			AnnotationLevel: github.String(annotationLevel(v.Level)),
+			Title:           github.String(v.PolicyName),
+			Message:         github.String(v.Message),
+		})
+	}
+	return annotations
+}
+
+// CreateCheckRun creates a completed check run on the head commit with the annotations, sent in chunks of
+// MAX_CHECK_RUN_ANNOTATIONS: the first one on creation, the others by updating the check run
+func (c *Client) CreateCheckRun(
+	ctx context.Context,
+	repo, headSHA, name, conclusion, title, summary string,
+	annotations []*github.CheckRunAnnotation,
+) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+
+	chunk := func(start int) []*github.CheckRunAnnotation {
+		return annotations[start:min(start+MAX_CHECK_RUN_ANNOTATIONS, len(annotations))]
+	}
+	output := func(start int) *github.CheckRunOutput {
+		return &github.CheckRunOutput{
+			Title:       github.String(title),
+			Summary:     github.String(summary),
+			Annotations: chunk(start),
+		}
+	}
+
+	checkRun, _, err := c.client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    headSHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+		Output:     output(0),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create check run: %w", err)
+	}
+	for start := MAX_CHECK_RUN_ANNOTATIONS; start < len(annotations); start += MAX_CHECK_RUN_ANNOTATIONS {
+		_, _, err := c.client.Checks.UpdateCheckRun(ctx, owner, repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+			Name:   name,
+			Output: output(start),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add annotations to check run: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/google/go-github/v66/github"
+)
+
+// TestBuildCheckRunAnnotations tests the annotations built from violations mapped to manifest files
+func TestBuildCheckRunAnnotations(t *testing.T) {
+	violations := []models.FileViolation{
+		{Path: "services/my-app/environments/prod/hpa.yaml", Line: 3, PolicyId: "hpa", PolicyName: "HPA", Level: "RECOMMEND", Message: "maxReplicas too low"},
+		{Path: "services/my-app/base/deployment.yaml", Line: 12, PolicyId: "ha", PolicyName: "Service High Availability", Level: "BLOCK", Message: "replicas too low"},
+		{Path: "services/my-app/base/deployment.yaml", PolicyId: "labels", PolicyName: "Labels", Level: "WARNING", Message: `missing "team" label`},
+		{PolicyId: "pdb", PolicyName: "PDB", Level: "BLOCK", Message: "no PodDisruptionBudget"},
+	}
+	type annotation struct {
+		path    string
+		line    int
+		level   string
+		title   string
+		message string
+	}
+	want := []annotation{
+		{"services/my-app/base/deployment.yaml", 1, "warning", "Labels", `missing "team" label`},
+		{"services/my-app/base/deployment.yaml", 12, "failure", "Service High Availability", "replicas too low"},
+		{"services/my-app/environments/prod/hpa.yaml", 3, "notice", "HPA", "maxReplicas too low"},
+	}
+
+	got := []annotation{}
+	for _, a := range BuildCheckRunAnnotations(violations) {
+		if a.GetStartLine() != a.GetEndLine() {
+			t.Errorf("BuildCheckRunAnnotations() lines %d-%d, want a single line", a.GetStartLine(), a.GetEndLine())
+		}
+		got = append(got, annotation{a.GetPath(), a.GetStartLine(), a.GetAnnotationLevel(), a.GetTitle(), a.GetMessage()})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildCheckRunAnnotations() = %+v, want %+v", got, want)
+	}
+}
+
+// TestClient_CreateCheckRun tests that annotations over the per-request limit are added by updating the check run
+func TestClient_CreateCheckRun(t *testing.T) {
+	var requests []string
+	var annotationCounts []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Output struct {
+				Annotations []json.RawMessage `json:"annotations"`
+			} `json:"output"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		annotationCounts = append(annotationCounts, len(body.Output.Annotations))
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": 5})
+	}))
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	c := &Client{client: gh}
+
+	violations := make([]models.FileViolation, 120)
+	for i := range violations {
+		violations[i] = models.FileViolation{Path: "services/my-app/base/deployment.yaml", Line: i + 1, Message: fmt.Sprintf("violation %d", i)}
+	}
+	err := c.CreateCheckRun(context.Background(), "owner/repo", "abc123", "gitops-kustomz", "failure",
+		"Policy violations", "120 violations", BuildCheckRunAnnotations(violations))
+	if err != nil {
+		t.Fatalf("CreateCheckRun() error = %v", err)
+	}
+
+	wantRequests := []string{"POST /repos/owner/repo/check-runs", "PATCH /repos/owner/repo/check-runs/5", "PATCH /repos/owner/repo/check-runs/5"}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("CreateCheckRun() requests = %v, want %v", requests, wantRequests)
+	}
+	if want := []int{50, 50, 20}; !reflect.DeepEqual(annotationCounts, want) {
+		t.Errorf("CreateCheckRun() annotations per request = %v, want %v", annotationCounts, want)
+	}
+}
@@ -1,15 +1,15 @@
 package github
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/template"
 	"github.com/google/go-github/v66/github"
@@ -19,8 +19,27 @@ import (
 
 var logger = log.WithField("package", "github")
 
+const (
+	// Safety cap of PR changed files to fetch, PRs can have thousands of files
+	MAX_PR_CHANGED_FILES = 3000
+	// Client-side deadline of GitHub API requests, unless a configured HTTP client is injected
+	DEFAULT_HTTP_TIMEOUT = 30 * time.Second
+)
+
 const GH_COMMENT_MARKER = template.ToolCommentSignature
 
+// CommentMarker returns the marker of the tool comment of an environment, the combined comment marker if env is empty.
+// Markers do not contain one another, a comment is never found under another's marker
+func CommentMarker(env string) string {
+	if env == "" {
+		return GH_COMMENT_MARKER
+	}
+	return fmt.Sprintf(template.ToolCommentEnvSignature, env)
+}
+
+// State of an approving PR review
+const REVIEW_STATE_APPROVED = "APPROVED"
+
 // GitHubClient defines the interface for GitHub API operations
 type GitHubClient interface {
 	// GetPR retrieves pull request information
@@ -28,25 +47,45 @@ type GitHubClient interface {
 	// CreateComment creates a new comment on a pull request
 	CreateComment(ctx context.Context, repo string, number int, body string) (*models.Comment, error)
 	// UpdateComment updates an existing comment
-	UpdateComment(ctx context.Context, repo string, commentID int64, body string) error
+	UpdateComment(ctx context.Context, repo string, commentID int64, body string) (*models.Comment, error)
+	// DeleteComment deletes an existing comment
+	DeleteComment(ctx context.Context, repo string, commentID int64) error
 	// GetComments retrieves all comments for a pull request
 	GetComments(ctx context.Context, repo string, number int) ([]*models.Comment, error)
-	// FindToolComment finds an existing tool-generated comment
-	FindToolComment(ctx context.Context, repo string, prNumber int) (*models.Comment, error)
+	// GetReviews retrieves the bodies of the reviews of a pull request as comments with their review state
+	GetReviews(ctx context.Context, repo string, number int) ([]*models.Comment, error)
+	// ListChangedFiles retrieves the paths of all files changed in a pull request
+	ListChangedFiles(ctx context.Context, repo string, prNumber int) ([]string, error)
+	// FindToolComment finds an existing tool-generated comment with the marker
+	FindToolComment(ctx context.Context, repo string, prNumber int, marker string) (*models.Comment, error)
 	// SparseCheckoutAtPath clones with treeless and sparse checks out specific ref at path
 	SparseCheckoutAtPath(ctx context.Context, cloneURL, ref, path string) (string, error)
+	// SparseCheckoutAtMergeBase sparse checks out the merge-base of baseRef and headSHA at path
+	SparseCheckoutAtMergeBase(ctx context.Context, repo, baseRef, headSHA, path string) (string, string, error)
+	// SparseCheckoutAtCommit sparse checks out the commit sha at path
+	SparseCheckoutAtCommit(ctx context.Context, repo, sha, path string) (string, error)
 }
 
 // Client handles GitHub API interactions using go-github
 type Client struct {
-	client *github.Client
+	client   *github.Client
+	executor command.CommandExecutor
+
+	// directory where repositories are checked out, defaults to <pwd>/tmp
+	checkoutRoot string
 }
 
 // Ensure Client implements GitHubClient
 var _ GitHubClient = (*Client)(nil)
 
-// NewClient creates a new GitHub client
+// NewClient creates a new GitHub client with the default HTTP timeout
 func NewClient() (*Client, error) {
+	return NewClientWithHTTPClient(&http.Client{Timeout: DEFAULT_HTTP_TIMEOUT})
+}
+
+// NewClientWithHTTPClient creates a new GitHub client sending its API requests through httpClient,
+// e.g. to configure the timeout, a proxy or a retrying transport. The token is added on top of its transport
+func NewClientWithHTTPClient(httpClient *http.Client) (*Client, error) {
 	token := os.Getenv("GH_TOKEN")
 	if token == "" {
 		token = os.Getenv("GITHUB_TOKEN")
@@ -55,12 +94,17 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("GitHub token not found. Set GH_TOKEN or GITHUB_TOKEN environment variable")
 	}
 
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DEFAULT_HTTP_TIMEOUT}
+	}
+
 	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-	tc := oauth2.NewClient(context.Background(), ts)
+	tc := oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, httpClient), ts)
 	client := github.NewClient(tc)
 
 	return &Client{
-		client: client,
+		client:   client,
+		executor: command.NewExecutor(),
 	}, nil
 }
 
@@ -99,17 +143,14 @@ func (c *Client) CreateComment(ctx context.Context, repo string, number int, bod
 		return nil, fmt.Errorf("failed to create comment: %w", err)
 	}
 
-	return &models.Comment{
-		ID:   created.GetID(),
-		Body: created.GetBody(),
-	}, nil
+	return toComment(created), nil
 }
 
 // UpdateComment updates an existing comment
-func (c *Client) UpdateComment(ctx context.Context, repo string, commentID int64, body string) error {
+func (c *Client) UpdateComment(ctx context.Context, repo string, commentID int64, body string) (*models.Comment, error) {
 	owner, repo, err := ParseOwnerRepo(repo)
 	if err != nil {
-		return fmt.Errorf("failed to parse repository: %w", err)
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
 	}
 	comment := &github.IssueComment{
 		Body: github.String(body),
@@ -118,7 +159,33 @@ func (c *Client) UpdateComment(ctx context.Context, repo string, commentID int64
 	commentRes, res, err := c.client.Issues.EditComment(ctx, owner, repo, commentID, comment)
 	log.WithField("comment", commentRes).WithField("response", res).Debug("Updated comment")
 	if err != nil {
-		return fmt.Errorf("failed to update comment: %w", err)
+		return nil, fmt.Errorf("failed to update comment: %w", err)
+	}
+
+	return toComment(commentRes), nil
+}
+
+// toComment converts a go-github issue comment
+func toComment(c *github.IssueComment) *models.Comment {
+	return &models.Comment{
+		ID:        c.GetID(),
+		Body:      c.GetBody(),
+		User:      c.GetUser().GetLogin(),
+		HTMLURL:   c.GetHTMLURL(),
+		CreatedAt: c.GetCreatedAt().Time,
+		UpdatedAt: c.GetUpdatedAt().Time,
+	}
+}
+
+// DeleteComment deletes an existing comment
+func (c *Client) DeleteComment(ctx context.Context, repo string, commentID int64) error {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository: %w", err)
+	}
+
+	if _, err := c.client.Issues.DeleteComment(ctx, owner, repo, commentID); err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
 	}
 
 	return nil
@@ -143,10 +210,7 @@ func (c *Client) GetComments(ctx context.Context, repo string, prNumber int) ([]
 		}
 
 		for _, c := range comments {
-			allComments = append(allComments, &models.Comment{
-				ID:   c.GetID(),
-				Body: c.GetBody(),
-			})
+			allComments = append(allComments, toComment(c))
 		}
 
 		if resp.NextPage == 0 {
@@ -158,9 +222,85 @@ func (c *Client) GetComments(ctx context.Context, repo string, prNumber int) ([]
 	return allComments, nil
 }
 
-// FindToolComment finds an existing tool-generated comment
+// GetReviews retrieves the reviews of a pull request with a body, e.g. submitted with Approve, as comments
+// with their review state, reviews without a body are left out
+func (c *Client) GetReviews(ctx context.Context, repo string, prNumber int) ([]*models.Comment, error) {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+	opts := &github.ListOptions{PerPage: 100}
+
+	var allReviews []*models.Comment
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get reviews: %w", err)
+		}
+
+		for _, review := range reviews {
+			if review.GetBody() == "" {
+				continue
+			}
+			allReviews = append(allReviews, &models.Comment{
+				ID:          review.GetID(),
+				Body:        review.GetBody(),
+				User:        review.GetUser().GetLogin(),
+				HTMLURL:     review.GetHTMLURL(),
+				CreatedAt:   review.GetSubmittedAt().Time,
+				UpdatedAt:   review.GetSubmittedAt().Time,
+				ReviewState: review.GetState(),
+			})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allReviews, nil
+}
+
+// ListChangedFiles retrieves the paths of all files changed in a pull request
+// At most MAX_PR_CHANGED_FILES files are returned, a warning is logged if the list was truncated
+func (c *Client) ListChangedFiles(ctx context.Context, repo string, prNumber int) ([]string, error) {
+	owner, repo, err := ParseOwnerRepo(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse repository: %w", err)
+	}
+	opts := &github.ListOptions{PerPage: 100}
+
+	var allFiles []string
+	for {
+		files, resp, err := c.client.PullRequests.ListFiles(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PR files: %w", err)
+		}
+
+		for _, f := range files {
+			allFiles = append(allFiles, f.GetFilename())
+		}
+
+		if len(allFiles) >= MAX_PR_CHANGED_FILES {
+			if len(allFiles) > MAX_PR_CHANGED_FILES || resp.NextPage != 0 {
+				logger.WithField("prNumber", prNumber).WithField("maxFiles", MAX_PR_CHANGED_FILES).Warn("PR has too many changed files, the list is truncated")
+			}
+			allFiles = allFiles[:MAX_PR_CHANGED_FILES]
+			break
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return allFiles, nil
+}
+
+// FindToolComment finds an existing tool-generated comment with the marker, see CommentMarker
 // If multiple comments with the same marker exist, returns the latest one (highest ID)
-func (c *Client) FindToolComment(ctx context.Context, repo string, prNumber int) (*models.Comment, error) {
+func (c *Client) FindToolComment(ctx context.Context, repo string, prNumber int, marker string) (*models.Comment, error) {
 	comments, err := c.GetComments(ctx, repo, prNumber)
 	if err != nil {
 		return nil, err
@@ -168,7 +308,7 @@ func (c *Client) FindToolComment(ctx context.Context, repo string, prNumber int)
 
 	var latestComment *models.Comment
 	for _, comment := range comments {
-		if strings.Contains(comment.Body, GH_COMMENT_MARKER) {
+		if strings.Contains(comment.Body, marker) {
 			// If multiple comments exist, for optmization reason, get the first one
 			latestComment = comment
 			break
@@ -188,21 +328,152 @@ func (c *Client) FindToolComment(ctx context.Context, repo string, prNumber int)
 func (c *Client) SparseCheckoutAtPath(ctx context.Context, repo, branch, path string) (string, error) {
 	logger.WithField("repo", repo).WithField("branch", branch).WithField("path", path).Info("SparseCheckoutAtPath()")
 
-	// create /tmp at pwd if not exists
-	pwd, err := os.Getwd()
+	tmpdir, checkoutDir, cloneURL, err := c.prepareCheckout(repo, branch)
+	if err != nil {
+		return "", err
+	}
+	repoDir := filepath.Join(tmpdir, checkoutDir)
+
+	// 1. git clone --filter=blob:none --depth 1 --no-checkout --single-branch -b branch cloneURL directory
+	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Cloning...")
+	if _, err := c.runGit(ctx, tmpdir, "clone", "--filter=blob:none", "--depth", "1", "--no-checkout", "--single-branch", "-b", branch, cloneURL, checkoutDir); err != nil {
+		return "", fmt.Errorf("failed to clone: %w", err)
+	}
+
+	// 2. git sparse-checkout set --no-cone path
+	// 3. git checkout branch
+	if err := c.sparseCheckout(ctx, repoDir, branch, path); err != nil {
+		_ = os.RemoveAll(repoDir)
+		return "", err
+	}
+
+	// 4. return directory
+	return c.absCheckoutPath(repoDir)
+}
+
+// SparseCheckoutAtMergeBase clones the base branch history (treeless), fetches the head commit,
+// computes their merge-base and sparse checks out the merge-base commit at path.
+// This matches what GitHub's "Files changed" tab compares against, even if the base branch advanced.
+// returns the directory containing the checked out files and the merge-base SHA
+// It does the following commands:
+// 1. git clone --filter=blob:none --no-checkout --single-branch -b baseRef cloneURL directory
+// 2. git fetch --filter=blob:none origin headSHA
+// 3. git merge-base HEAD FETCH_HEAD
+// 4. git sparse-checkout set --no-cone path
+// 5. git checkout mergeBaseSHA
+// 6. return directory
+func (c *Client) SparseCheckoutAtMergeBase(ctx context.Context, repo, baseRef, headSHA, path string) (string, string, error) {
+	logger.WithField("repo", repo).WithField("baseRef", baseRef).WithField("headSHA", headSHA).WithField("path", path).Info("SparseCheckoutAtMergeBase()")
+
+	tmpdir, checkoutDir, cloneURL, err := c.prepareCheckout(repo, "mergebase-"+baseRef)
 	if err != nil {
-		return "", fmt.Errorf("failed to get pwd: %w", err)
+		return "", "", err
+	}
+	repoDir := filepath.Join(tmpdir, checkoutDir)
+
+	// 1. git clone --filter=blob:none --no-checkout --single-branch -b baseRef cloneURL directory
+	// history is needed to compute the merge-base, so no --depth here
+	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Cloning...")
+	if _, err := c.runGit(ctx, tmpdir, "clone", "--filter=blob:none", "--no-checkout", "--single-branch", "-b", baseRef, cloneURL, checkoutDir); err != nil {
+		return "", "", fmt.Errorf("failed to clone: %w", err)
+	}
+
+	// 2. git fetch --filter=blob:none origin headSHA
+	if _, err := c.runGit(ctx, repoDir, "fetch", "--filter=blob:none", "origin", headSHA); err != nil {
+		_ = os.RemoveAll(repoDir)
+		return "", "", fmt.Errorf("failed to fetch head commit: %w", err)
+	}
+
+	// 3. git merge-base HEAD FETCH_HEAD
+	out, err := c.runGit(ctx, repoDir, "merge-base", "HEAD", "FETCH_HEAD")
+	if err != nil {
+		_ = os.RemoveAll(repoDir)
+		return "", "", fmt.Errorf("failed to compute merge-base: %w", err)
+	}
+	mergeBase := strings.TrimSpace(out)
+	if mergeBase == "" {
+		_ = os.RemoveAll(repoDir)
+		return "", "", fmt.Errorf("no merge-base found between %s and %s", baseRef, headSHA)
+	}
+	logger.WithField("mergeBase", mergeBase).Info("Computed merge-base")
+
+	// 4. git sparse-checkout set --no-cone path
+	// 5. git checkout mergeBaseSHA
+	if err := c.sparseCheckout(ctx, repoDir, mergeBase, path); err != nil {
+		_ = os.RemoveAll(repoDir)
+		return "", "", err
+	}
+
+	// 6. return directory
+	absPath, err := c.absCheckoutPath(repoDir)
+	if err != nil {
+		return "", "", err
+	}
+	return absPath, mergeBase, nil
+}
+
+// SparseCheckoutAtCommit clones with treeless, fetches the commit sha and sparse checks it out at path,
+// for commits that are not a branch tip, e.g. an audit of a past commit of main
+// returns the directory containing the checked out files
+// It does the following commands:
+// 1. git clone --filter=blob:none --depth 1 --no-checkout cloneURL directory
+// 2. git fetch --filter=blob:none --depth 1 origin sha
+// 3. git sparse-checkout set --no-cone path
+// 4. git checkout sha
+// 5. return directory
+func (c *Client) SparseCheckoutAtCommit(ctx context.Context, repo, sha, path string) (string, error) {
+	logger.WithField("repo", repo).WithField("sha", sha).WithField("path", path).Info("SparseCheckoutAtCommit()")
+
+	tmpdir, checkoutDir, cloneURL, err := c.prepareCheckout(repo, "commit-"+sha)
+	if err != nil {
+		return "", err
+	}
+	repoDir := filepath.Join(tmpdir, checkoutDir)
+
+	// 1. git clone --filter=blob:none --depth 1 --no-checkout cloneURL directory
+	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Cloning...")
+	if _, err := c.runGit(ctx, tmpdir, "clone", "--filter=blob:none", "--depth", "1", "--no-checkout", cloneURL, checkoutDir); err != nil {
+		return "", fmt.Errorf("failed to clone: %w", err)
+	}
+
+	// 2. git fetch --filter=blob:none --depth 1 origin sha
+	if _, err := c.runGit(ctx, repoDir, "fetch", "--filter=blob:none", "--depth", "1", "origin", sha); err != nil {
+		_ = os.RemoveAll(repoDir)
+		return "", fmt.Errorf("failed to fetch commit %s: %w", sha, err)
+	}
+
+	// 3. git sparse-checkout set --no-cone path
+	// 4. git checkout sha
+	if err := c.sparseCheckout(ctx, repoDir, sha, path); err != nil {
+		_ = os.RemoveAll(repoDir)
+		return "", err
+	}
+
+	// 5. return directory
+	return c.absCheckoutPath(repoDir)
+}
+
+// prepareCheckout creates the checkout root and returns the root dir, a unique checkout dir name
+// and the clone URL (authenticated with the GitHub token if available)
+func (c *Client) prepareCheckout(repo, name string) (tmpdir, checkoutDir, cloneURL string, err error) {
+	// create /tmp at pwd if not exists
+	tmpdir = c.checkoutRoot
+	if tmpdir == "" {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to get pwd: %w", err)
+		}
+		tmpdir = filepath.Join(pwd, "tmp")
 	}
-	tmpdir := filepath.Join(pwd, "tmp")
 	if err := os.MkdirAll(tmpdir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create tmpdir at %s: %w", tmpdir, err)
+		return "", "", "", fmt.Errorf("failed to create tmpdir at %s: %w", tmpdir, err)
 	}
 
-	chkoutName := strings.ReplaceAll(branch, "/", "_")
-	checkoutDir := fmt.Sprintf("chk-%s-%d", chkoutName, time.Now().Unix())
-	cloneURL, err := GetHTTPSCloneURLForRepo(repo)
+	chkoutName := strings.ReplaceAll(name, "/", "_")
+	checkoutDir = fmt.Sprintf("chk-%s-%d", chkoutName, time.Now().Unix())
+	cloneURL, err = GetHTTPSCloneURLForRepo(repo)
 	if err != nil {
-		return "", fmt.Errorf("failed to get clone URL: %w", err)
+		return "", "", "", fmt.Errorf("failed to get clone URL: %w", err)
 	}
 
 	// Use GitHub token for authentication
@@ -214,72 +485,45 @@ func (c *Client) SparseCheckoutAtPath(ctx context.Context, repo, branch, path st
 		// Use x-access-token as username with token as password
 		cloneURL = strings.Replace(cloneURL, "https://", fmt.Sprintf("https://x-access-token:%s@", token), 1)
 	}
+	return tmpdir, checkoutDir, cloneURL, nil
+}
 
-	// 1. git clone --filter=blob:none --depth 1 --no-checkout --single-branch -b branch cloneURL directory
-	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Cloning...")
-	cloneCmd := exec.CommandContext(ctx, "git", "clone", "--filter=blob:none", "--depth", "1", "--no-checkout", "--single-branch", "-b", branch, cloneURL, checkoutDir)
-	logger.WithField("cloneCmd", cloneCmd.String()).Debug("Showing clone command")
-	cloneCmd.Dir = tmpdir
-	var cloneStdout, cloneStderr bytes.Buffer
-	cloneCmd.Stdout = &cloneStdout
-	cloneCmd.Stderr = &cloneStderr
-	if err := cloneCmd.Run(); err != nil {
-		logger.WithField("stdout", cloneStdout.String()).WithField("stderr", cloneStderr.String()).Error("Clone failed")
-		return "", fmt.Errorf("failed to clone: %w\nStdout: %s\nStderr: %s", err, cloneStdout.String(), cloneStderr.String())
-	}
-	logger.WithField("stdout", cloneStdout.String()).WithField("stderr", cloneStderr.String()).Debug("Clone succeeded")
-
-	// 2. git sparse-checkout set --no-cone path
-	logger.WithField("tmpdir", tmpdir).WithField("checkoutDir", checkoutDir).Debug("Set path sparse-checkout...")
-	sparseCmd := exec.CommandContext(ctx, "git", "sparse-checkout", "set", "--no-cone", path)
-	sparseCmd.Dir = filepath.Join(tmpdir, checkoutDir)
-	logger.WithField("sparseCmd", sparseCmd.String()).Debug("Showing sparse-checkout command")
-	var sparseStdout, sparseStderr bytes.Buffer
-	sparseCmd.Stdout = &sparseStdout
-	sparseCmd.Stderr = &sparseStderr
-	if err := sparseCmd.Run(); err != nil {
-		logger.WithField("stdout", sparseStdout.String()).WithField("stderr", sparseStderr.String()).Error("Sparse checkout set failed")
-		_ = os.RemoveAll(checkoutDir)
-		return "", fmt.Errorf("failed to set sparse checkout: %w\nStdout: %s\nStderr: %s", err, sparseStdout.String(), sparseStderr.String())
-	}
-	logger.WithField("stdout", sparseStdout.String()).WithField("stderr", sparseStderr.String()).Debug("Sparse checkout set succeeded")
+// sparseCheckout restricts the working tree of repoDir to path and checks out ref
+func (c *Client) sparseCheckout(ctx context.Context, repoDir, ref, path string) error {
+	logger.WithField("repoDir", repoDir).Debug("Set path sparse-checkout...")
+	if _, err := c.runGit(ctx, repoDir, "sparse-checkout", "set", "--no-cone", path); err != nil {
+		return fmt.Errorf("failed to set sparse checkout: %w", err)
+	}
 
-	// 3. git checkout branch
-	logger.WithField("tmpdir", tmpdir).WithField("branch", branch).WithField("checkoutDir", checkoutDir).Debug("Check out branch...")
-	checkoutCmd := exec.CommandContext(ctx, "git", "checkout", branch)
-	checkoutCmd.Dir = filepath.Join(tmpdir, checkoutDir)
-	logger.WithField("checkoutCmd", checkoutCmd.String()).Debug("Showing checkout command")
-	var checkoutStdout, checkoutStderr bytes.Buffer
-	checkoutCmd.Stdout = &checkoutStdout
-	checkoutCmd.Stderr = &checkoutStderr
-	if err := checkoutCmd.Run(); err != nil {
-		logger.WithField("stdout", checkoutStdout.String()).WithField("stderr", checkoutStderr.String()).Error("Checkout failed")
-		_ = os.RemoveAll(checkoutDir)
-		return "", fmt.Errorf("failed to checkout: %w\nStdout: %s\nStderr: %s", err, checkoutStdout.String(), checkoutStderr.String())
-	}
-	logger.WithField("stdout", checkoutStdout.String()).WithField("stderr", checkoutStderr.String()).Debug("Checkout succeeded")
+	logger.WithField("repoDir", repoDir).WithField("ref", ref).Debug("Check out ref...")
+	if _, err := c.runGit(ctx, repoDir, "checkout", ref); err != nil {
+		return fmt.Errorf("failed to checkout: %w", err)
+	}
+	return nil
+}
 
-	// 4. return directory
-	absPath, err := filepath.Abs(filepath.Join(tmpdir, checkoutDir))
-	logger.WithField("checkoutDir", checkoutDir).WithField("absPath", absPath).Debug("Absolute path...")
+func (c *Client) absCheckoutPath(repoDir string) (string, error) {
+	absPath, err := filepath.Abs(repoDir)
+	logger.WithField("repoDir", repoDir).WithField("absPath", absPath).Debug("Absolute path...")
 	if err != nil {
-		_ = os.RemoveAll(checkoutDir)
+		_ = os.RemoveAll(repoDir)
 		return "", fmt.Errorf("failed to get absolute path: %w", err)
 	}
-
-	// // this spots the case that --manfifest-path has a ./ prefix didn't work
-	// list files with permissions in the following directory [pwd, tmpdir, checkoutDir]
-	// logger.Info("DEBUGGING: LISTING FILES IN THE FOLLOWING DIRECTORIES [pwd, tmpdir, absPath]")
-	// dirs := []string{pwd, tmpdir, absPath}
-	// for _, dir := range dirs {
-	// 	logger.WithField("dir", dir).Debug("Started list ls -la...")
-	// 	lsCmd := exec.CommandContext(ctx, "ls", "-la", dir)
-	// 	output, err := lsCmd.CombinedOutput()
-	// 	if err != nil {
-	// 		return "", fmt.Errorf("failed to list directory %s: %w\nOutput: %s", dir, err, string(output))
-	// 	}
-	// 	logger.WithField("dir", dir).WithField("output", string(output)).Debug("Listed directory...")
-	// }
-
 	return absPath, nil
 }
+
+// runGit runs a git command in dir and returns its stdout
+func (c *Client) runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	lg := logger.WithField("dir", dir).WithField("args", args[0])
+	res, err := c.executor.Run(ctx, dir, "git", args...)
+	if err != nil {
+		var stdout, stderr string
+		if res != nil {
+			stdout, stderr = string(res.Stdout), string(res.Stderr)
+		}
+		lg.WithField("stdout", stdout).WithField("stderr", stderr).Error("git command failed")
+		return "", fmt.Errorf("git %s: %w\nStdout: %s\nStderr: %s", args[0], err, stdout, stderr)
+	}
+	lg.WithField("stdout", string(res.Stdout)).WithField("stderr", string(res.Stderr)).Debug("git command succeeded")
+	return string(res.Stdout), nil
+}
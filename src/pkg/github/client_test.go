@@ -0,0 +1,455 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"github.com/google/go-github/v66/github"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// newTestClient creates a client using the given fake executor and a temp checkout root
+func newTestClient(t *testing.T, executor command.CommandExecutor) *Client {
+	t.Helper()
+	t.Setenv("GH_TOKEN", "")
+	t.Setenv("GITHUB_TOKEN", "")
+	return &Client{
+		executor:     executor,
+		checkoutRoot: t.TempDir(),
+	}
+}
+
+// TestClient_SparseCheckoutAtMergeBase tests that the merge-base reported by git is checked out
+func TestClient_SparseCheckoutAtMergeBase(t *testing.T) {
+	const mergeBaseSHA = "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if args[0] == "merge-base" {
+				return &command.Result{Stdout: []byte(mergeBaseSHA + "\n")}, nil
+			}
+			return &command.Result{}, nil
+		},
+	}
+	c := newTestClient(t, fake)
+
+	dir, mergeBase, err := c.SparseCheckoutAtMergeBase(context.Background(), "owner/repo", "main", "headsha", "services/my-app")
+	if err != nil {
+		t.Fatalf("SparseCheckoutAtMergeBase() error = %v", err)
+	}
+	if mergeBase != mergeBaseSHA {
+		t.Errorf("SparseCheckoutAtMergeBase() mergeBase = %q, want %q", mergeBase, mergeBaseSHA)
+	}
+	if !strings.HasPrefix(dir, c.checkoutRoot) {
+		t.Errorf("SparseCheckoutAtMergeBase() dir = %q, want under %q", dir, c.checkoutRoot)
+	}
+
+	calls := fake.Calls()
+	want := []string{
+		"git clone --filter=blob:none --no-checkout --single-branch -b main https://github.com/owner/repo.git",
+		"git fetch --filter=blob:none origin headsha",
+		"git merge-base HEAD FETCH_HEAD",
+		"git sparse-checkout set --no-cone services/my-app",
+		"git checkout " + mergeBaseSHA,
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("SparseCheckoutAtMergeBase() ran %d commands, want %d: %v", len(calls), len(want), calls)
+	}
+	for i, call := range calls {
+		if !strings.HasPrefix(call.String(), want[i]) {
+			t.Errorf("command %d = %q, want prefix %q", i, call.String(), want[i])
+		}
+	}
+}
+
+// TestClient_SparseCheckoutAtCommit tests that the commit is fetched then checked out
+func TestClient_SparseCheckoutAtCommit(t *testing.T) {
+	const sha = "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{}, nil
+		},
+	}
+	c := newTestClient(t, fake)
+
+	dir, err := c.SparseCheckoutAtCommit(context.Background(), "owner/repo", sha, "services/my-app")
+	if err != nil {
+		t.Fatalf("SparseCheckoutAtCommit() error = %v", err)
+	}
+	if !strings.HasPrefix(dir, c.checkoutRoot) {
+		t.Errorf("SparseCheckoutAtCommit() dir = %q, want under %q", dir, c.checkoutRoot)
+	}
+
+	calls := fake.Calls()
+	want := []string{
+		"git clone --filter=blob:none --depth 1 --no-checkout https://github.com/owner/repo.git",
+		"git fetch --filter=blob:none --depth 1 origin " + sha,
+		"git sparse-checkout set --no-cone services/my-app",
+		"git checkout " + sha,
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("SparseCheckoutAtCommit() ran %d commands, want %d: %v", len(calls), len(want), calls)
+	}
+	for i, call := range calls {
+		if !strings.HasPrefix(call.String(), want[i]) {
+			t.Errorf("command %d = %q, want prefix %q", i, call.String(), want[i])
+		}
+	}
+}
+
+// TestClient_SparseCheckoutAtMergeBase_Errors tests failures while computing the merge-base
+func TestClient_SparseCheckoutAtMergeBase_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		stdout  string
+		err     error
+		wantErr string
+	}{
+		{
+			name:    "merge-base command fails",
+			err:     fmt.Errorf("exit status 1"),
+			wantErr: "failed to compute merge-base",
+		},
+		{
+			name:    "no merge-base",
+			stdout:  "\n",
+			wantErr: "no merge-base found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					if args[0] == "merge-base" {
+						return &command.Result{Stdout: []byte(tt.stdout)}, tt.err
+					}
+					return &command.Result{}, nil
+				},
+			}
+			c := newTestClient(t, fake)
+
+			_, _, err := c.SparseCheckoutAtMergeBase(context.Background(), "owner/repo", "main", "headsha", "services/my-app")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("SparseCheckoutAtMergeBase() error = %v, want error containing %q", err, tt.wantErr)
+			}
+			for _, call := range fake.Calls() {
+				if len(call.Args) > 0 && call.Args[0] == "checkout" {
+					t.Errorf("SparseCheckoutAtMergeBase() should not checkout on error, got %q", call.String())
+				}
+			}
+		})
+	}
+}
+
+// newPaginatedFilesServer serves totalFiles PR files, perPage files per page with GitHub Link headers
+func newPaginatedFilesServer(t *testing.T, totalFiles int) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		start := (page - 1) * perPage
+		end := min(start+perPage, totalFiles)
+		files := []map[string]string{}
+		for i := start; i < end; i++ {
+			files = append(files, map[string]string{"filename": fmt.Sprintf("services/my-app/file-%d.yaml", i)})
+		}
+		if end < totalFiles {
+			next := *r.URL
+			q := next.Query()
+			q.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+		}
+		_ = json.NewEncoder(w).Encode(files)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// TestClient_ListChangedFiles tests that all pages are collected up to the safety cap
+func TestClient_ListChangedFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		totalFiles   int
+		wantFiles    int
+		wantRequests int
+		wantWarning  bool
+	}{
+		{
+			name:         "single page",
+			totalFiles:   42,
+			wantFiles:    42,
+			wantRequests: 1,
+		},
+		{
+			name:         "multiple pages",
+			totalFiles:   250,
+			wantFiles:    250,
+			wantRequests: 3,
+		},
+		{
+			name:         "exactly the cap",
+			totalFiles:   MAX_PR_CHANGED_FILES,
+			wantFiles:    MAX_PR_CHANGED_FILES,
+			wantRequests: MAX_PR_CHANGED_FILES / 100,
+		},
+		{
+			name:         "truncated at the cap",
+			totalFiles:   MAX_PR_CHANGED_FILES + 500,
+			wantFiles:    MAX_PR_CHANGED_FILES,
+			wantRequests: MAX_PR_CHANGED_FILES / 100,
+			wantWarning:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newPaginatedFilesServer(t, tt.totalFiles)
+			gh := github.NewClient(nil)
+			gh.BaseURL, _ = url.Parse(server.URL + "/")
+			c := &Client{client: gh}
+			hook := logtest.NewGlobal()
+			defer hook.Reset()
+
+			files, err := c.ListChangedFiles(context.Background(), "owner/repo", 1)
+			if err != nil {
+				t.Fatalf("ListChangedFiles() error = %v", err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("ListChangedFiles() returned %d files, want %d", len(files), tt.wantFiles)
+			}
+			if *requests != tt.wantRequests {
+				t.Errorf("ListChangedFiles() made %d requests, want %d", *requests, tt.wantRequests)
+			}
+
+			warned := false
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == log.WarnLevel && strings.Contains(entry.Message, "truncated") {
+					warned = true
+				}
+			}
+			if warned != tt.wantWarning {
+				t.Errorf("ListChangedFiles() truncation warning = %v, want %v", warned, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// TestClient_DeleteComment tests that the comment is deleted through the issues API
+func TestClient_DeleteComment(t *testing.T) {
+	var gotMethod, gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	c := &Client{client: gh}
+
+	if err := c.DeleteComment(context.Background(), "owner/repo", 42); err != nil {
+		t.Fatalf("DeleteComment() error = %v", err)
+	}
+	if gotMethod != http.MethodDelete || gotPath != "/repos/owner/repo/issues/comments/42" {
+		t.Errorf("DeleteComment() requested %s %s, want DELETE /repos/owner/repo/issues/comments/42", gotMethod, gotPath)
+	}
+}
+
+// TestNewClientWithHTTPClient tests that the injected HTTP client is used, with its timeout, and still authenticated
+func TestNewClientWithHTTPClient(t *testing.T) {
+	t.Setenv("GH_TOKEN", "test-token")
+
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		timeout  time.Duration
+		wantErr  bool
+		wantAuth string
+	}{
+		{
+			name:     "fast server",
+			timeout:  time.Second,
+			wantAuth: "Bearer test-token",
+		},
+		{
+			name:    "slow server exceeds the client timeout",
+			delay:   500 * time.Millisecond,
+			timeout: 50 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotAuth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotAuth = r.Header.Get("Authorization")
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"number": 1})
+			}))
+			t.Cleanup(server.Close)
+
+			c, err := NewClientWithHTTPClient(&http.Client{Timeout: tt.timeout})
+			if err != nil {
+				t.Fatalf("NewClientWithHTTPClient() error = %v", err)
+			}
+			c.client.BaseURL, _ = url.Parse(server.URL + "/")
+
+			start := time.Now()
+			_, err = c.GetPR(context.Background(), "owner/repo", 1)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "Client.Timeout exceeded") {
+					t.Errorf("GetPR() error = %v, want client timeout", err)
+				}
+				if elapsed := time.Since(start); elapsed >= tt.delay {
+					t.Errorf("GetPR() returned after %s, want before the server delay %s", elapsed, tt.delay)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetPR() error = %v", err)
+			}
+			if gotAuth != tt.wantAuth {
+				t.Errorf("GetPR() Authorization = %q, want %q", gotAuth, tt.wantAuth)
+			}
+		})
+	}
+}
+
+// TestNewClient_DefaultTimeout tests that the default client has a client-side deadline
+func TestNewClient_DefaultTimeout(t *testing.T) {
+	t.Setenv("GH_TOKEN", "test-token")
+	c, err := NewClient()
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if got := c.client.Client().Timeout; got != DEFAULT_HTTP_TIMEOUT {
+		t.Errorf("NewClient() HTTP timeout = %s, want %s", got, DEFAULT_HTTP_TIMEOUT)
+	}
+}
+
+// TestClient_CreateUpdateComment tests that the posted comment is returned with its HTML URL and author
+func TestClient_CreateUpdateComment(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := 11
+		if r.Method == http.MethodPatch {
+			id = 42
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"id":       id,
+			"body":     "report",
+			"html_url": fmt.Sprintf("https://github.com/owner/repo/pull/7#issuecomment-%d", id),
+			"user":     map[string]any{"login": "gitops-bot"},
+		})
+	}))
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	c := &Client{client: gh}
+
+	created, err := c.CreateComment(context.Background(), "owner/repo", 7, "report")
+	if err != nil {
+		t.Fatalf("CreateComment() error = %v", err)
+	}
+	updated, err := c.UpdateComment(context.Background(), "owner/repo", 42, "report")
+	if err != nil {
+		t.Fatalf("UpdateComment() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		comment *models.Comment
+		wantID  int64
+		wantURL string
+	}{
+		{name: "create", comment: created, wantID: 11, wantURL: "https://github.com/owner/repo/pull/7#issuecomment-11"},
+		{name: "update", comment: updated, wantID: 42, wantURL: "https://github.com/owner/repo/pull/7#issuecomment-42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.comment.ID != tt.wantID || tt.comment.HTMLURL != tt.wantURL {
+				t.Errorf("comment = {ID: %d, HTMLURL: %q}, want {ID: %d, HTMLURL: %q}", tt.comment.ID, tt.comment.HTMLURL, tt.wantID, tt.wantURL)
+			}
+			if tt.comment.User != "gitops-bot" {
+				t.Errorf("comment User = %q, want gitops-bot", tt.comment.User)
+			}
+		})
+	}
+}
+
+// TestClient_GetReviews tests that the review bodies are returned with their state, reviews without body left out
+func TestClient_GetReviews(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		_ = json.NewEncoder(w).Encode([]map[string]any{
+			{"id": 1, "body": "/sp-override-ha", "state": "APPROVED", "user": map[string]any{"login": "alice"}},
+			{"id": 2, "body": "", "state": "APPROVED", "user": map[string]any{"login": "bob"}},
+			{"id": 3, "body": "needs a PDB", "state": "CHANGES_REQUESTED", "user": map[string]any{"login": "carol"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(server.URL + "/")
+	c := &Client{client: gh}
+
+	reviews, err := c.GetReviews(context.Background(), "owner/repo", 7)
+	if err != nil {
+		t.Fatalf("GetReviews() error = %v", err)
+	}
+	if gotPath != "/repos/owner/repo/pulls/7/reviews" {
+		t.Errorf("GetReviews() requested %s, want /repos/owner/repo/pulls/7/reviews", gotPath)
+	}
+	got := []models.Comment{}
+	for _, review := range reviews {
+		got = append(got, models.Comment{ID: review.ID, Body: review.Body, User: review.User, ReviewState: review.ReviewState})
+	}
+	want := []models.Comment{
+		{ID: 1, Body: "/sp-override-ha", User: "alice", ReviewState: REVIEW_STATE_APPROVED},
+		{ID: 3, Body: "needs a PDB", User: "carol", ReviewState: "CHANGES_REQUESTED"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetReviews() = %+v, want %+v", got, want)
+	}
+}
+
+// TestCommentMarker tests that the markers of the combined and environment comments do not contain one another
+func TestCommentMarker(t *testing.T) {
+	envs := []string{"", "stg", "prod", "prod-eu"}
+	for _, env := range envs {
+		for _, other := range envs {
+			if env == other {
+				continue
+			}
+			if strings.Contains(CommentMarker(env), CommentMarker(other)) {
+				t.Errorf("CommentMarker(%q) = %q contains CommentMarker(%q) = %q", env, CommentMarker(env), other, CommentMarker(other))
+			}
+		}
+	}
+	if got := CommentMarker(""); got != GH_COMMENT_MARKER {
+		t.Errorf("CommentMarker(\"\") = %q, want %q", got, GH_COMMENT_MARKER)
+	}
+}
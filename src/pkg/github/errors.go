@@ -0,0 +1,63 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// transientGitMessages are git stderr messages of network failures, a clone or fetch failing with them may succeed on retry
+var transientGitMessages = []string{
+	"could not resolve host",
+	"connection timed out",
+	"connection reset",
+	"connection refused",
+	"failed to connect",
+	"operation timed out",
+	"early eof",
+	"rpc failed",
+	"the remote end hung up unexpectedly",
+	"tls handshake timeout",
+	"the requested url returned error: 429",
+	"the requested url returned error: 500",
+	"the requested url returned error: 502",
+	"the requested url returned error: 503",
+	"the requested url returned error: 504",
+}
+
+// IsTransientError reports whether err is caused by the infrastructure and may not happen again on retry:
+// a network failure, a GitHub rate limit or server error, or a git clone/fetch losing its connection.
+// Other errors, e.g. an invalid config, a kustomize build failure or a missing PR, are terminal.
+// A cancelled or exceeded context is terminal, the run is over
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var rateLimitErr *github.RateLimitError
+	var abuseRateLimitErr *github.AbuseRateLimitError
+	if errors.As(err, &rateLimitErr) || errors.As(err, &abuseRateLimitErr) {
+		return true
+	}
+	var responseErr *github.ErrorResponse
+	if errors.As(err, &responseErr) && responseErr.Response != nil {
+		status := responseErr.Response.StatusCode
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, transient := range transientGitMessages {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v66/github"
+)
+
+// TestIsTransientError tests the classification of infrastructure errors worth a retry against deterministic ones
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{
+			name: "rate limit",
+			err:  fmt.Errorf("failed to get PR: %w", &github.RateLimitError{Message: "API rate limit exceeded"}),
+			want: true,
+		},
+		{
+			name: "secondary rate limit",
+			err:  fmt.Errorf("failed to create comment: %w", &github.AbuseRateLimitError{Message: "secondary rate limit"}),
+			want: true,
+		},
+		{
+			name: "server error",
+			err:  fmt.Errorf("failed to get PR: %w", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}),
+			want: true,
+		},
+		{
+			name: "not found",
+			err:  fmt.Errorf("failed to get PR: %w", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}}),
+		},
+		{
+			name: "network error",
+			err:  fmt.Errorf("failed to get PR: %w", &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}),
+			want: true,
+		},
+		{
+			name: "git clone losing its connection",
+			err:  fmt.Errorf("failed to clone: git clone: exit status 128\nStdout: \nStderr: fatal: unable to access 'https://github.com/owner/repo.git/': Could not resolve host: github.com"),
+			want: true,
+		},
+		{
+			name: "git fetch of a missing commit",
+			err:  fmt.Errorf("failed to fetch commit abc: git fetch: exit status 128\nStdout: \nStderr: fatal: couldn't find remote ref abc"),
+		},
+		{
+			name: "config error",
+			err:  fmt.Errorf("failed to initialize: policy ha: name is required"),
+		},
+		{
+			name: "exceeded deadline",
+			err:  fmt.Errorf("failed to clone: %w", context.DeadlineExceeded),
+		},
+		{
+			name: "nil",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientError(tt.err); got != tt.want {
+				t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
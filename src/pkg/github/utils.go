@@ -2,6 +2,7 @@ package github
 
 import (
 	"fmt"
+	"path"
 	"strings"
 )
 
@@ -52,3 +53,16 @@ func GetWorkflowRunUrl(repo string, runId int) (string, error) {
 	}
 	return fmt.Sprintf("https://github.com/%s/%s/actions/runs/%d", owner, repo, runId), nil
 }
+
+// FilterFilesUnderPath returns the files (repository-relative, slash separated) located under dir
+// Example: FilterFilesUnderPath(["services/a/base/x.yaml", "services/b/y.yaml"], "./services/a") -> ["services/a/base/x.yaml"]
+func FilterFilesUnderPath(files []string, dir string) []string {
+	prefix := strings.TrimSuffix(path.Clean(strings.ReplaceAll(dir, "\\", "/")), "/") + "/"
+	matched := []string{}
+	for _, file := range files {
+		if strings.HasPrefix(path.Clean(file), prefix) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
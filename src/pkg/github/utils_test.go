@@ -0,0 +1,54 @@
+package github
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestFilterFilesUnderPath tests mapping PR changed files to a service path
+func TestFilterFilesUnderPath(t *testing.T) {
+	files := []string{
+		"services/my-app/base/deployment.yaml",
+		"services/my-app/environments/prod/kustomization.yaml",
+		"services/my-app-2/base/deployment.yaml",
+		"services/other/base/deployment.yaml",
+		"README.md",
+	}
+
+	tests := []struct {
+		name string
+		dir  string
+		want []string
+	}{
+		{
+			name: "service path",
+			dir:  "services/my-app",
+			want: []string{
+				"services/my-app/base/deployment.yaml",
+				"services/my-app/environments/prod/kustomization.yaml",
+			},
+		},
+		{
+			name: "dot-slash prefix and trailing slash",
+			dir:  "./services/my-app/",
+			want: []string{
+				"services/my-app/base/deployment.yaml",
+				"services/my-app/environments/prod/kustomization.yaml",
+			},
+		},
+		{
+			name: "no files under path",
+			dir:  "services/unknown",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterFilesUnderPath(files, tt.dir)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilterFilesUnderPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
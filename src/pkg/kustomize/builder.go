@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/logctx"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -38,28 +40,87 @@ type KustomizeBuilder interface {
 	// Build runs kustomize build on the specified path
 	// Path here is a full path to service (manifestRoot + service), kustomize will be built at path+overlay
 	Build(ctx context.Context, path string, overlayName string) ([]byte, error)
+	// BuildWithWarnings is like Build, but also returns the warnings kustomize printed to stderr on success
+	BuildWithWarnings(ctx context.Context, path string, overlayName string) ([]byte, []string, error)
 	BuildToText(ctx context.Context, path string, overlayName string) (string, error)
 }
 
 // Builder handles kustomize builds
-type Builder struct{}
+type Builder struct {
+	executor command.CommandExecutor
+}
 
 // Ensure Builder implements KustomizeBuilder
 var _ KustomizeBuilder = (*Builder)(nil)
 
 // NewBuilder creates a new kustomize builder
 func NewBuilder() *Builder {
-	return &Builder{}
+	return &Builder{
+		executor: command.NewExecutor(),
+	}
+}
+
+// NewBuilderWithExecutor creates a new kustomize builder running commands with the given executor
+func NewBuilderWithExecutor(executor command.CommandExecutor) *Builder {
+	return &Builder{
+		executor: executor,
+	}
 }
 
 func (b *Builder) Build(ctx context.Context, path string, overlayName string) ([]byte, error) {
+	manifest, _, err := b.BuildWithWarnings(ctx, path, overlayName)
+	return manifest, err
+}
+
+func (b *Builder) BuildWithWarnings(ctx context.Context, path string, overlayName string) ([]byte, []string, error) {
 	buildPath, err := b.getBuildPath(path, overlayName)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	return b.buildAtPath(ctx, buildPath)
 }
 
+// BuildResources is like BuildWithWarnings, but builds with "kustomize build -o <dir>", writing one file per resource
+// with a deterministic name, e.g. apps_v1_deployment_my-app.yaml. The resources are returned by file name, they need
+// no splitting of the YAML stream
+func (b *Builder) BuildResources(ctx context.Context, path string, overlayName string) (map[string][]byte, []string, error) {
+	buildPath, err := b.getBuildPath(path, overlayName)
+	if err != nil {
+		return nil, nil, err
+	}
+	outputDir, err := os.MkdirTemp("", "gitops-kustomz-build-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create the build output directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(outputDir); err != nil {
+			logger.WithField("dir", outputDir).WithError(err).Warn("Failed to remove the build output directory")
+		}
+	}()
+
+	// the path stays the last argument, like a build to stdout
+	_, warnings, err := b.runBuild(ctx, buildPath, "-o", outputDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the build output directory: %w", err)
+	}
+	resources := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(outputDir, entry.Name()))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read built resource %s: %w", entry.Name(), err)
+		}
+		resources[entry.Name()] = content
+	}
+	return resources, warnings, nil
+}
+
 func (b *Builder) BuildToText(ctx context.Context, path string, overlayName string) (string, error) {
 	bytes, err := b.Build(ctx, path, overlayName)
 	if err != nil {
@@ -70,21 +131,75 @@ func (b *Builder) BuildToText(ctx context.Context, path string, overlayName stri
 
 // Build runs kustomize build on the specified path
 // path here is fullpath to a service (manifestRoot + service)
-func (b *Builder) buildAtPath(ctx context.Context, path string) ([]byte, error) {
-	logger.WithField("path", path).Info("Building at path...")
-	cmd := exec.CommandContext(ctx, "kustomize", "build", path)
+// Only stdout is the manifest, stderr (e.g. deprecation warnings) is returned separately as warnings
+func (b *Builder) buildAtPath(ctx context.Context, path string) ([]byte, []string, error) {
+	return b.runBuild(ctx, path)
+}
 
-	// Use Output() instead of CombinedOutput() to avoid stderr warnings in the output
-	output, err := cmd.Output()
+// runBuild runs kustomize build on path with the flags, returns its stdout and the warnings of its stderr
+func (b *Builder) runBuild(ctx context.Context, path string, flags ...string) ([]byte, []string, error) {
+	logctx.Entry(ctx, logger).WithField("path", path).Info("Building at path...")
+	args := append(append([]string{"build"}, flags...), path)
+	result, err := b.executor.Run(ctx, "", "kustomize", args...)
 	if err != nil {
-		// On error, get stderr for debugging
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, fmt.Errorf("kustomize build failed: %w\nStderr: %s", err, string(exitErr.Stderr))
+		if result != nil && len(result.Stderr) > 0 {
+			return nil, nil, fmt.Errorf("kustomize build failed: %w\nStderr: %s", err, string(result.Stderr))
 		}
-		return nil, fmt.Errorf("kustomize build failed: %w", err)
+		return nil, nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	warnings := parseWarnings(result.Stderr)
+	if len(warnings) > 0 {
+		logctx.Entry(ctx, logger).WithField("path", path).WithField("warnings", warnings).Warn("kustomize build succeeded with warnings")
 	}
+	return result.Stdout, warnings, nil
+}
+
+// Version returns the installed kustomize version, e.g. "5.4.3"
+func (b *Builder) Version(ctx context.Context) (string, error) {
+	result, err := b.executor.Run(ctx, "", "kustomize", "version")
+	if err != nil {
+		return "", fmt.Errorf("failed to get kustomize version: %w", err)
+	}
+	return command.ParseVersion(string(result.Stdout))
+}
+
+// parseWarnings splits kustomize stderr output into non-empty lines
+func parseWarnings(stderr []byte) []string {
+	var warnings []string
+	for _, line := range strings.Split(string(stderr), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			warnings = append(warnings, line)
+		}
+	}
+	return warnings
+}
+
+// OverlayExists reports whether the overlay of the environment exists in the service at path,
+// the service is not deployed to the environment otherwise
+func (b *Builder) OverlayExists(path string, overlayName string) bool {
+	_, err := os.Stat(filepath.Join(path, KUSTOMIZE_OVERLAY_DIR_NAME, overlayName))
+	return err == nil
+}
+
+// ListOverlays returns the sorted names of the overlay directories of the service at path, other entries are ignored
+func (b *Builder) ListOverlays(path string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(path, KUSTOMIZE_OVERLAY_DIR_NAME))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list overlays of '%s': %w", path, err)
+	}
+	var overlays []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			overlays = append(overlays, entry.Name())
+		}
+	}
+	return overlays, nil
+}
 
-	return output, nil
+// OverlayPath returns the path built for the overlay of the service at path, e.g. services/my-app/environments/prod
+func (b *Builder) OverlayPath(path string, overlayName string) (string, error) {
+	return b.getBuildPath(path, overlayName)
 }
 
 // GetServiceEnvironmentPath returns the path to build for a service/environment
@@ -0,0 +1,165 @@
+package kustomize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// newTestServiceDir creates a service directory with a base and the given overlays, returns its path
+func newTestServiceDir(t *testing.T, overlays ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, sub := range append([]string{KUSTOMIZE_BASE_DIR}, prefixOverlays(overlays)...) {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", sub, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, sub, "kustomization.yaml"), []byte("resources: []\n"), 0644); err != nil {
+			t.Fatalf("failed to write kustomization in %s: %v", sub, err)
+		}
+	}
+	return dir
+}
+
+func prefixOverlays(overlays []string) []string {
+	paths := make([]string, len(overlays))
+	for i, overlay := range overlays {
+		paths[i] = filepath.Join(KUSTOMIZE_OVERLAY_DIR_NAME, overlay)
+	}
+	return paths
+}
+
+// TestBuilder_BuildWithWarnings tests that stderr warnings are kept out of the built manifest
+func TestBuilder_BuildWithWarnings(t *testing.T) {
+	const manifest = "apiVersion: v1\nkind: Service\nmetadata:\n  name: my-app\n"
+	tests := []struct {
+		name         string
+		stderr       string
+		err          error
+		wantWarnings []string
+		wantErr      string
+	}{
+		{
+			name: "no warnings",
+		},
+		{
+			name:         "warnings on stderr",
+			stderr:       "# Warning: 'commonLabels' is deprecated. Please use 'labels' instead.\n\n# Warning: 'patchesStrategicMerge' is deprecated.\n",
+			wantWarnings: []string{"# Warning: 'commonLabels' is deprecated. Please use 'labels' instead.", "# Warning: 'patchesStrategicMerge' is deprecated."},
+		},
+		{
+			name:    "build failure includes stderr",
+			stderr:  "Error: accumulating resources",
+			err:     fmt.Errorf("exit status 1"),
+			wantErr: "Stderr: Error: accumulating resources",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					return &command.Result{Stdout: []byte(manifest), Stderr: []byte(tt.stderr)}, tt.err
+				},
+			}
+			b := &Builder{executor: fake}
+			serviceDir := newTestServiceDir(t, "stg")
+
+			got, warnings, err := b.BuildWithWarnings(context.Background(), serviceDir, "stg")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("BuildWithWarnings() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildWithWarnings() error = %v", err)
+			}
+			if string(got) != manifest {
+				t.Errorf("BuildWithWarnings() manifest = %q, want %q", got, manifest)
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("BuildWithWarnings() warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+
+			calls := fake.Calls()
+			wantCall := "kustomize build " + filepath.Join(serviceDir, KUSTOMIZE_OVERLAY_DIR_NAME, "stg")
+			if len(calls) != 1 || calls[0].String() != wantCall {
+				t.Errorf("BuildWithWarnings() calls = %v, want [%s]", calls, wantCall)
+			}
+		})
+	}
+}
+
+// TestBuilder_BuildResources tests that the files kustomize writes to the -o directory are returned by name
+func TestBuilder_BuildResources(t *testing.T) {
+	files := map[string]string{
+		"apps_v1_deployment_my-app.yaml": "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: my-app\n",
+		"v1_service_my-app.yaml":         "apiVersion: v1\nkind: Service\nmetadata:\n  name: my-app\n",
+	}
+	var outputDir string
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			outputDir = args[2]
+			for file, content := range files {
+				if err := os.WriteFile(filepath.Join(outputDir, file), []byte(content), 0644); err != nil {
+					return nil, err
+				}
+			}
+			return &command.Result{Stderr: []byte("# Warning: 'commonLabels' is deprecated.\n")}, nil
+		},
+	}
+	b := &Builder{executor: fake}
+	serviceDir := newTestServiceDir(t, "stg")
+
+	got, warnings, err := b.BuildResources(context.Background(), serviceDir, "stg")
+	if err != nil {
+		t.Fatalf("BuildResources() error = %v", err)
+	}
+	want := map[string][]byte{}
+	for file, content := range files {
+		want[file] = []byte(content)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("BuildResources() = %q, want %q", got, want)
+	}
+	if wantWarnings := []string{"# Warning: 'commonLabels' is deprecated."}; !reflect.DeepEqual(warnings, wantWarnings) {
+		t.Errorf("BuildResources() warnings = %q, want %q", warnings, wantWarnings)
+	}
+
+	calls := fake.Calls()
+	wantCall := "kustomize build -o " + outputDir + " " + filepath.Join(serviceDir, KUSTOMIZE_OVERLAY_DIR_NAME, "stg")
+	if len(calls) != 1 || calls[0].String() != wantCall {
+		t.Errorf("BuildResources() calls = %v, want [%s]", calls, wantCall)
+	}
+	if _, err := os.Stat(outputDir); !os.IsNotExist(err) {
+		t.Errorf("BuildResources() left the output directory %s, want it removed", outputDir)
+	}
+}
+
+// TestBuilder_ListOverlays tests that the overlay directories are listed sorted, other entries ignored
+func TestBuilder_ListOverlays(t *testing.T) {
+	dir := newTestServiceDir(t, "stg", "prod", "sandbox")
+	if err := os.WriteFile(filepath.Join(dir, KUSTOMIZE_OVERLAY_DIR_NAME, "README.md"), []byte("# overlays\n"), 0644); err != nil {
+		t.Fatalf("failed to write README.md: %v", err)
+	}
+
+	got, err := NewBuilder().ListOverlays(dir)
+	if err != nil {
+		t.Fatalf("ListOverlays() error = %v", err)
+	}
+	if want := []string{"prod", "sandbox", "stg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListOverlays() = %v, want %v", got, want)
+	}
+
+	if _, err := NewBuilder().ListOverlays(t.TempDir()); err == nil {
+		t.Error("ListOverlays() error = nil, want an error without overlay directory")
+	}
+}
@@ -0,0 +1,38 @@
+package logctx
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fieldsKey is the context key of the log fields
+type fieldsKey struct{}
+
+// WithFields returns a copy of ctx carrying the given log fields on top of those already in ctx,
+// e.g. the environment a goroutine works on, so the packages it calls log lines attributable to it
+func WithFields(ctx context.Context, fields log.Fields) context.Context {
+	merged := make(log.Fields, len(fields))
+	for k, v := range Fields(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// Fields returns the log fields carried by ctx, nil if none
+func Fields(ctx context.Context) log.Fields {
+	fields, _ := ctx.Value(fieldsKey{}).(log.Fields)
+	return fields
+}
+
+// Entry returns entry, usually a package logger, with the log fields carried by ctx added
+func Entry(ctx context.Context, entry *log.Entry) *log.Entry {
+	fields := Fields(ctx)
+	if len(fields) == 0 {
+		return entry
+	}
+	return entry.WithFields(fields)
+}
@@ -0,0 +1,46 @@
+package logctx
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// TestWithFields tests that the fields carried by a context add up and end up on the entries derived from it
+func TestWithFields(t *testing.T) {
+	base := log.New().WithField("package", "test")
+
+	if got := Entry(context.Background(), base); got != base {
+		t.Errorf("Entry() without fields = %v, want the entry unchanged", got.Data)
+	}
+
+	parent := WithFields(context.Background(), log.Fields{"service": "my-app", "environment": "stg"})
+	child := WithFields(parent, log.Fields{"environment": "prod"})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want log.Fields
+	}{
+		{
+			name: "parent",
+			ctx:  parent,
+			want: log.Fields{"package": "test", "service": "my-app", "environment": "stg"},
+		},
+		{
+			name: "child overrides a field of its parent",
+			ctx:  child,
+			want: log.Fields{"package": "test", "service": "my-app", "environment": "prod"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Entry(tt.ctx, base).Data; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Entry() fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const documentSeparator = "---"
+
+// SplitDocuments splits a multi-document YAML manifest into the raw text of each non-empty document,
+// keeping the original formatting so filtered manifests diff identically
+func SplitDocuments(manifest []byte) []string {
+	documents := []string{}
+	var current strings.Builder
+	flush := func() {
+		if doc := current.String(); strings.TrimSpace(doc) != "" {
+			documents = append(documents, doc)
+		}
+		current.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(string(manifest), "\n") {
+		if strings.TrimRight(line, " \t\r\n") == documentSeparator {
+			flush()
+			continue
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return documents
+}
+
+// JoinDocuments joins raw documents back into a multi-document YAML manifest
+func JoinDocuments(documents []string) []byte {
+	var buf strings.Builder
+	for i, doc := range documents {
+		if i > 0 {
+			buf.WriteString(documentSeparator + "\n")
+		}
+		buf.WriteString(doc)
+		if !strings.HasSuffix(doc, "\n") {
+			buf.WriteString("\n")
+		}
+	}
+	return []byte(buf.String())
+}
+
+// DocumentKind returns the kind of a single YAML document, empty if not set
+func DocumentKind(document string) (string, error) {
+	var header struct {
+		Kind string `yaml:"kind"`
+	}
+	if err := yaml.Unmarshal([]byte(document), &header); err != nil {
+		return "", fmt.Errorf("failed to parse manifest document: %w", err)
+	}
+	return header.Kind, nil
+}
+
+// FilterKinds keeps only the documents whose kind is in includeKinds (all kinds if empty)
+// and not in excludeKinds. Kinds are matched case-insensitively.
+// The manifest is returned unchanged if no filter is set.
+func FilterKinds(manifest []byte, includeKinds, excludeKinds []string) ([]byte, error) {
+	if len(includeKinds) == 0 && len(excludeKinds) == 0 {
+		return manifest, nil
+	}
+
+	kept := []string{}
+	for _, doc := range SplitDocuments(manifest) {
+		kind, err := DocumentKind(doc)
+		if err != nil {
+			return nil, err
+		}
+		if len(includeKinds) > 0 && !containsFold(includeKinds, kind) {
+			continue
+		}
+		if containsFold(excludeKinds, kind) {
+			continue
+		}
+		kept = append(kept, doc)
+	}
+	return JoinDocuments(kept), nil
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package manifest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const multiKindManifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-app-config
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - name: my-app
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: my-app
+`
+
+// TestSplitDocuments tests splitting multi-document manifests while keeping formatting
+func TestSplitDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+	}{
+		{
+			name:     "empty manifest",
+			manifest: "",
+			want:     []string{},
+		},
+		{
+			name:     "single document",
+			manifest: "kind: Service\n",
+			want:     []string{"kind: Service\n"},
+		},
+		{
+			name:     "leading separator and empty documents are dropped",
+			manifest: "---\nkind: Service\n---\n\n---\nkind: Deployment\n",
+			want:     []string{"kind: Service\n", "kind: Deployment\n"},
+		},
+		{
+			name:     "indentation is kept",
+			manifest: "kind: Deployment\nspec:\n  containers:\n  - name: app\n",
+			want:     []string{"kind: Deployment\nspec:\n  containers:\n  - name: app\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitDocuments([]byte(tt.manifest))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitDocuments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterKinds tests include/exclude filtering of manifest documents by kind
+func TestFilterKinds(t *testing.T) {
+	tests := []struct {
+		name         string
+		includeKinds []string
+		excludeKinds []string
+		wantKinds    []string
+	}{
+		{
+			name:      "no filter keeps everything",
+			wantKinds: []string{"ConfigMap", "Deployment", "HorizontalPodAutoscaler"},
+		},
+		{
+			name:         "include kinds",
+			includeKinds: []string{"Deployment", "HorizontalPodAutoscaler"},
+			wantKinds:    []string{"Deployment", "HorizontalPodAutoscaler"},
+		},
+		{
+			name:         "exclude kinds",
+			excludeKinds: []string{"ConfigMap"},
+			wantKinds:    []string{"Deployment", "HorizontalPodAutoscaler"},
+		},
+		{
+			name:         "case-insensitive",
+			includeKinds: []string{"deployment"},
+			wantKinds:    []string{"Deployment"},
+		},
+		{
+			name:         "exclude wins over include",
+			includeKinds: []string{"Deployment", "ConfigMap"},
+			excludeKinds: []string{"ConfigMap"},
+			wantKinds:    []string{"Deployment"},
+		},
+		{
+			name:         "nothing matches",
+			includeKinds: []string{"Ingress"},
+			wantKinds:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FilterKinds([]byte(multiKindManifest), tt.includeKinds, tt.excludeKinds)
+			if err != nil {
+				t.Fatalf("FilterKinds() error = %v", err)
+			}
+			gotKinds := []string{}
+			for _, doc := range SplitDocuments(got) {
+				kind, err := DocumentKind(doc)
+				if err != nil {
+					t.Fatalf("DocumentKind() error = %v", err)
+				}
+				gotKinds = append(gotKinds, kind)
+			}
+			if !reflect.DeepEqual(gotKinds, tt.wantKinds) {
+				t.Errorf("FilterKinds() kinds = %v, want %v", gotKinds, tt.wantKinds)
+			}
+			if len(tt.includeKinds) == 0 && len(tt.excludeKinds) == 0 && string(got) != multiKindManifest {
+				t.Errorf("FilterKinds() without filters should return the manifest unchanged")
+			}
+		})
+	}
+
+	t.Run("kept documents are unchanged", func(t *testing.T) {
+		got, err := FilterKinds([]byte(multiKindManifest), []string{"Deployment"}, nil)
+		if err != nil {
+			t.Fatalf("FilterKinds() error = %v", err)
+		}
+		if !strings.Contains(string(got), "      containers:\n      - name: my-app\n") {
+			t.Errorf("FilterKinds() changed the document formatting:\n%s", got)
+		}
+	})
+
+	t.Run("invalid document", func(t *testing.T) {
+		if _, err := FilterKinds([]byte("kind: [unclosed\n"), []string{"Deployment"}, nil); err == nil {
+			t.Error("FilterKinds() error = nil, want parse error")
+		}
+	})
+}
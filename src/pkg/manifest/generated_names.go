@@ -0,0 +1,104 @@
+package manifest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GeneratedNameHashPlaceholder replaces the hash suffix of generated names once normalized
+const GeneratedNameHashPlaceholder = "<hash>"
+
+// generatedNamePattern matches the content hash suffix kustomize appends to the names of
+// configMapGenerator/secretGenerator resources: 10 characters of its hex-like hash alphabet
+var generatedNamePattern = regexp.MustCompile(`^(.+)-[bcdfghkmt2456789]{10}$`)
+
+// generatedKinds are the kinds whose names kustomize generators suffix with a content hash
+var generatedKinds = map[string]bool{
+	"ConfigMap": true,
+	"Secret":    true,
+}
+
+// NormalizeGeneratedNames replaces the hash suffix of generated ConfigMap and Secret names by a placeholder,
+// in their definition and in every reference, e.g. my-config-5t8f9k2h6m becomes my-config-<hash>.
+// A generator change then only diffs on the changed data, not on every resource referencing the new name
+func NormalizeGeneratedNames(manifest []byte) ([]byte, error) {
+	replacements := map[string]string{}
+	if err := addGeneratedNameReplacements(replacements, manifest); err != nil {
+		return nil, err
+	}
+	return []byte(replaceNames(string(manifest), replacements)), nil
+}
+
+// NormalizeGeneratedNamesOfResources is like NormalizeGeneratedNames for the files of a split build, one resource
+// per file: a generated name is replaced in the files of all the resources referencing it
+func NormalizeGeneratedNamesOfResources(resources map[string][]byte) (map[string][]byte, error) {
+	replacements := map[string]string{}
+	for name, content := range resources {
+		if err := addGeneratedNameReplacements(replacements, content); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	normalized := make(map[string][]byte, len(resources))
+	for name, content := range resources {
+		normalized[name] = []byte(replaceNames(string(content), replacements))
+	}
+	return normalized, nil
+}
+
+// addGeneratedNameReplacements adds the placeholder name of each generated resource of manifest to replacements
+func addGeneratedNameReplacements(replacements map[string]string, manifest []byte) error {
+	for _, doc := range SplitDocuments(manifest) {
+		var header struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &header); err != nil {
+			return fmt.Errorf("failed to parse manifest document: %w", err)
+		}
+		if !generatedKinds[header.Kind] {
+			continue
+		}
+		if match := generatedNamePattern.FindStringSubmatch(header.Metadata.Name); match != nil {
+			replacements[header.Metadata.Name] = match[1] + "-" + GeneratedNameHashPlaceholder
+		}
+	}
+	return nil
+}
+
+// replaceNames replaces the generated names of replacements in s, see replaceName
+func replaceNames(s string, replacements map[string]string) string {
+	for name, replacement := range replacements {
+		s = replaceName(s, name, replacement)
+	}
+	return s
+}
+
+// replaceName replaces the occurrences of name that are not part of a longer name
+func replaceName(s, name, replacement string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, name)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(name)
+		if (i == 0 || !isNameChar(s[i-1])) && (end == len(s) || !isNameChar(s[end])) {
+			b.WriteString(s[:i])
+			b.WriteString(replacement)
+		} else {
+			b.WriteString(s[:end])
+		}
+		s = s[end:]
+	}
+}
+
+// isNameChar reports whether c may be part of a kubernetes resource name
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '.' || c == '_'
+}
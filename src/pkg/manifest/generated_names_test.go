@@ -0,0 +1,147 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+// generatedManifest returns a manifest with a generated ConfigMap referenced by a Deployment
+func generatedManifest(hash, logLevel string) string {
+	return `apiVersion: v1
+data:
+  LOG_LEVEL: ` + logLevel + `
+kind: ConfigMap
+metadata:
+  name: my-app-config-` + hash + `
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - envFrom:
+        - configMapRef:
+            name: my-app-config-` + hash + `
+        name: my-app
+      volumes:
+      - configMap:
+          name: my-app-config-` + hash + `
+        name: config
+`
+}
+
+// TestNormalizeGeneratedNames tests that only the generated hash suffixes are neutralized
+func TestNormalizeGeneratedNames(t *testing.T) {
+	tests := []struct {
+		name        string
+		manifest    string
+		wantContain []string
+		wantAbsent  []string
+	}{
+		{
+			name:        "generated configmap and its references",
+			manifest:    generatedManifest("5t8f9k2h6m", "info"),
+			wantContain: []string{"  name: my-app-config-<hash>\n", "            name: my-app-config-<hash>\n", "          name: my-app-config-<hash>\n"},
+			wantAbsent:  []string{"5t8f9k2h6m"},
+		},
+		{
+			name: "generated secret",
+			manifest: `apiVersion: v1
+kind: Secret
+metadata:
+  name: db-credentials-g7h8m9dbt4
+`,
+			wantContain: []string{"name: db-credentials-<hash>"},
+		},
+		{
+			name: "configmap without hash suffix",
+			manifest: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-app-config
+`,
+			wantContain: []string{"name: my-app-config\n"},
+			wantAbsent:  []string{"<hash>"},
+		},
+		{
+			name: "hash-like suffix of another kind",
+			manifest: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app-5t8f9k2h6m
+`,
+			wantContain: []string{"name: my-app-5t8f9k2h6m"},
+		},
+		{
+			name: "longer names are kept",
+			manifest: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg-5t8f9k2h6m
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: cfg-5t8f9k2h6m-svc
+`,
+			wantContain: []string{"name: cfg-<hash>\n", "name: cfg-5t8f9k2h6m-svc\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeGeneratedNames([]byte(tt.manifest))
+			if err != nil {
+				t.Fatalf("NormalizeGeneratedNames() error = %v", err)
+			}
+			for _, want := range tt.wantContain {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("NormalizeGeneratedNames() missing %q in:\n%s", want, got)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(string(got), absent) {
+					t.Errorf("NormalizeGeneratedNames() should not contain %q in:\n%s", absent, got)
+				}
+			}
+		})
+	}
+}
+
+// TestNormalizeGeneratedNames_HashOnlyChange tests that a hash-only change normalizes to identical manifests,
+// while a data change only differs on the data
+func TestNormalizeGeneratedNames_HashOnlyChange(t *testing.T) {
+	before, err := NormalizeGeneratedNames([]byte(generatedManifest("5t8f9k2h6m", "info")))
+	if err != nil {
+		t.Fatalf("NormalizeGeneratedNames() error = %v", err)
+	}
+
+	hashOnly, err := NormalizeGeneratedNames([]byte(generatedManifest("b2c4d6f8gk", "info")))
+	if err != nil {
+		t.Fatalf("NormalizeGeneratedNames() error = %v", err)
+	}
+	if string(before) != string(hashOnly) {
+		t.Errorf("NormalizeGeneratedNames() hash-only change should be identical:\n%s\nvs\n%s", before, hashOnly)
+	}
+
+	dataChange, err := NormalizeGeneratedNames([]byte(generatedManifest("b2c4d6f8gk", "debug")))
+	if err != nil {
+		t.Fatalf("NormalizeGeneratedNames() error = %v", err)
+	}
+	beforeLines, afterLines := strings.Split(string(before), "\n"), strings.Split(string(dataChange), "\n")
+	if len(beforeLines) != len(afterLines) {
+		t.Fatalf("NormalizeGeneratedNames() data change has %d lines, want %d", len(afterLines), len(beforeLines))
+	}
+	changed := []string{}
+	for i := range beforeLines {
+		if beforeLines[i] != afterLines[i] {
+			changed = append(changed, afterLines[i])
+		}
+	}
+	if len(changed) != 1 || changed[0] != "  LOG_LEVEL: debug" {
+		t.Errorf("NormalizeGeneratedNames() data change differs on %v, want only [  LOG_LEVEL: debug]", changed)
+	}
+}
@@ -0,0 +1,149 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlPath is a parsed path pattern, one glob per segment
+type yamlPath []*regexp.Regexp
+
+// ParseIgnorePath parses a YAML path pattern like metadata.annotations."checksum/*": segments are separated by dots,
+// a segment with dots is double quoted. A segment is a glob matched against a mapping key, or against the index of
+// a list item, where "*" matches any characters and "?" a single one
+func ParseIgnorePath(pattern string) (yamlPath, error) {
+	var segments []string
+	var current strings.Builder
+	quoted := false
+	for _, char := range pattern {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == '.' && !quoted:
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(char)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("invalid path %q: unterminated quote", pattern)
+	}
+	segments = append(segments, current.String())
+
+	path := make(yamlPath, 0, len(segments))
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("invalid path %q: empty segment", pattern)
+		}
+		glob := regexp.QuoteMeta(segment)
+		glob = strings.ReplaceAll(glob, `\*`, ".*")
+		glob = strings.ReplaceAll(glob, `\?`, ".")
+		path = append(path, regexp.MustCompile("^"+glob+"$"))
+	}
+	return path, nil
+}
+
+// ValidateIgnorePaths checks that the YAML path patterns parse, see ParseIgnorePath
+func ValidateIgnorePaths(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := ParseIgnorePath(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StripPaths removes the fields matching any of the YAML path patterns from every document of the manifest, e.g.
+// metadata.annotations."kubectl.kubernetes.io/last-applied-configuration", so their changes do not diff.
+// A mapping left empty by the removal is removed too, list items are never removed.
+// The documents are re-serialized, so both sides of a diff must be stripped with the same patterns
+func StripPaths(manifest []byte, patterns []string) ([]byte, error) {
+	if len(patterns) == 0 {
+		return manifest, nil
+	}
+	paths := make([]yamlPath, 0, len(patterns))
+	for _, pattern := range patterns {
+		path, err := ParseIgnorePath(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+
+	documents := SplitDocuments(manifest)
+	for i, doc := range documents {
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(doc), &node); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest document: %w", err)
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+		stripNode(node.Content[0], paths)
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, fmt.Errorf("failed to serialize manifest document: %w", err)
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, fmt.Errorf("failed to serialize manifest document: %w", err)
+		}
+		documents[i] = buf.String()
+	}
+	return JoinDocuments(documents), nil
+}
+
+// stripNode removes the fields of node matching the paths, relative to node, and reports whether node is a mapping
+// left empty by the removal
+func stripNode(node *yaml.Node, paths []yamlPath) bool {
+	switch node.Kind {
+	case yaml.MappingNode:
+		if len(node.Content) == 0 {
+			return false
+		}
+		kept := node.Content[:0]
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			matched, rest := matchSegment(paths, key.Value)
+			if matched || (len(rest) > 0 && stripNode(value, rest)) {
+				continue
+			}
+			kept = append(kept, key, value)
+		}
+		node.Content = kept
+		return len(kept) == 0
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			if _, rest := matchSegment(paths, strconv.Itoa(i)); len(rest) > 0 {
+				stripNode(item, rest)
+			}
+		}
+	}
+	return false
+}
+
+// matchSegment matches name against the first segment of the paths, reports whether a path ends on it
+// and returns the remaining segments of the other matching paths
+func matchSegment(paths []yamlPath, name string) (bool, []yamlPath) {
+	matched := false
+	var rest []yamlPath
+	for _, path := range paths {
+		if !path[0].MatchString(name) {
+			continue
+		}
+		if len(path) == 1 {
+			matched = true
+			continue
+		}
+		rest = append(rest, path[1:])
+	}
+	return matched, rest
+}
@@ -0,0 +1,184 @@
+package manifest
+
+import (
+	"testing"
+)
+
+// TestStripPaths tests the removal of the fields matching the paths from every document, nested and globbed
+func TestStripPaths(t *testing.T) {
+	const manifest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"kind":"ConfigMap"}'
+  name: my-config
+data:
+  key: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    checksum/config: abc123
+    checksum/secret: def456
+    team: platform
+  name: my-app
+spec:
+  template:
+    metadata:
+      annotations:
+        checksum/config: abc123
+    spec:
+      containers:
+      - image: my-app:1.0.0
+        name: my-app
+        env:
+        - name: BUILD_ID
+          value: "42"
+`
+	tests := []struct {
+		name     string
+		patterns []string
+		want     string
+	}{
+		{
+			name:     "no path",
+			patterns: nil,
+			want:     manifest,
+		},
+		{
+			name:     "quoted key with dots, empty mappings removed",
+			patterns: []string{`metadata.annotations."kubectl.kubernetes.io/last-applied-configuration"`},
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-config
+data:
+  key: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    checksum/config: abc123
+    checksum/secret: def456
+    team: platform
+  name: my-app
+spec:
+  template:
+    metadata:
+      annotations:
+        checksum/config: abc123
+    spec:
+      containers:
+        - image: my-app:1.0.0
+          name: my-app
+          env:
+            - name: BUILD_ID
+              value: "42"
+`,
+		},
+		{
+			name: "globs and list items",
+			patterns: []string{
+				`metadata.annotations."checksum/*"`,
+				`spec.template.metadata.annotations.*`,
+				`spec.template.spec.containers.*.env`,
+			},
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: '{"kind":"ConfigMap"}'
+  name: my-config
+data:
+  key: value
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    team: platform
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+        - image: my-app:1.0.0
+          name: my-app
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := StripPaths([]byte(manifest), tt.patterns)
+			if err != nil {
+				t.Fatalf("StripPaths() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("StripPaths() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStripPaths_SameResultBothSides tests that a field only present on one side does not diff once stripped
+func TestStripPaths_SameResultBothSides(t *testing.T) {
+	const before = "kind: Deployment\nmetadata:\n  name: my-app\nspec:\n  containers:\n  - name: my-app\n"
+	const after = "kind: Deployment\nmetadata:\n  annotations:\n    checksum/config: abc123\n  name: my-app\nspec:\n  containers:\n  - name: my-app\n"
+	patterns := []string{`metadata.annotations."checksum/*"`}
+
+	strippedBefore, err := StripPaths([]byte(before), patterns)
+	if err != nil {
+		t.Fatalf("StripPaths() error = %v", err)
+	}
+	strippedAfter, err := StripPaths([]byte(after), patterns)
+	if err != nil {
+		t.Fatalf("StripPaths() error = %v", err)
+	}
+	if string(strippedBefore) != string(strippedAfter) {
+		t.Errorf("StripPaths() before =\n%s\nafter =\n%s\nwant them equal", strippedBefore, strippedAfter)
+	}
+}
+
+// TestParseIgnorePath tests the segments of the path patterns and the rejection of malformed ones
+func TestParseIgnorePath(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		segments int
+		match    []string
+		wantErr  bool
+	}{
+		{pattern: "metadata.labels", segments: 2, match: []string{"metadata", "labels"}},
+		{pattern: `metadata.annotations."kubectl.kubernetes.io/*"`, segments: 3,
+			match: []string{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"}},
+		{pattern: "spec.containers.?.image", segments: 4, match: []string{"spec", "containers", "0", "image"}},
+		{pattern: `metadata."annotations`, wantErr: true},
+		{pattern: "metadata..labels", wantErr: true},
+		{pattern: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		path, err := ParseIgnorePath(tt.pattern)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseIgnorePath(%q) error = nil, want an error", tt.pattern)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseIgnorePath(%q) error = %v", tt.pattern, err)
+			continue
+		}
+		if len(path) != tt.segments {
+			t.Errorf("ParseIgnorePath(%q) = %d segments, want %d", tt.pattern, len(path), tt.segments)
+			continue
+		}
+		for i, name := range tt.match {
+			if !path[i].MatchString(name) {
+				t.Errorf("ParseIgnorePath(%q) segment %d does not match %q", tt.pattern, i, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsJSON returns true if the manifest is JSON: one or more JSON objects or arrays, e.g. the output of
+// kubectl -o json. A YAML manifest written in flow style, e.g. "{kind: Pod}", is not valid JSON
+func IsJSON(manifest []byte) bool {
+	trimmed := bytes.TrimSpace(manifest)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	_, err := jsonValues(trimmed)
+	return err == nil
+}
+
+// ToYAML converts a JSON manifest to a multi-document YAML manifest in block style, keeping the key order.
+// Arrays and List kinds are split into one document per item. A YAML manifest is returned as is
+func ToYAML(manifest []byte) ([]byte, error) {
+	if !IsJSON(manifest) {
+		return manifest, nil
+	}
+	values, err := jsonValues(bytes.TrimSpace(manifest))
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []string{}
+	for _, value := range values {
+		// JSON is YAML, parsing it as YAML keeps the key order lost by a JSON map
+		var node yaml.Node
+		if err := yaml.Unmarshal(value, &node); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON manifest: %w", err)
+		}
+		for _, item := range jsonItems(&node) {
+			blockStyle(item)
+			// indented like the kustomize output
+			var out bytes.Buffer
+			encoder := yaml.NewEncoder(&out)
+			encoder.SetIndent(2)
+			if err := encoder.Encode(item); err != nil {
+				return nil, fmt.Errorf("failed to convert JSON manifest to YAML: %w", err)
+			}
+			if err := encoder.Close(); err != nil {
+				return nil, fmt.Errorf("failed to convert JSON manifest to YAML: %w", err)
+			}
+			documents = append(documents, out.String())
+		}
+	}
+	return JoinDocuments(documents), nil
+}
+
+// jsonValues splits a stream of JSON values, e.g. one object per line
+func jsonValues(content []byte) ([]json.RawMessage, error) {
+	values := []json.RawMessage{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	for {
+		var value json.RawMessage
+		err := decoder.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+}
+
+// jsonItems returns the resources of a parsed JSON document: the elements of an array, the items of a List kind,
+// the document itself otherwise
+func jsonItems(doc *yaml.Node) []*yaml.Node {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) == 1 {
+		node = node.Content[0]
+	}
+	if node.Kind == yaml.SequenceNode {
+		return node.Content
+	}
+	if node.Kind == yaml.MappingNode {
+		var kind string
+		var items *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			switch node.Content[i].Value {
+			case "kind":
+				kind = node.Content[i+1].Value
+			case "items":
+				items = node.Content[i+1]
+			}
+		}
+		if kind == "List" && items != nil && items.Kind == yaml.SequenceNode {
+			return items.Content
+		}
+	}
+	return []*yaml.Node{node}
+}
+
+// blockStyle clears the flow style of the node and its children, so JSON objects and arrays are printed as YAML blocks
+// and strings are only quoted where YAML needs it
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import "testing"
+
+// TestToYAML tests the conversion of JSON manifests to YAML documents
+func TestToYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "single object keeps the key order",
+			manifest: `{"kind":"ConfigMap","metadata":{"name":"my-app"},"data":{"replicas":"2","enabled":"true"}}`,
+			want:     "kind: ConfigMap\nmetadata:\n  name: my-app\ndata:\n  replicas: \"2\"\n  enabled: \"true\"\n",
+		},
+		{
+			name: "list kind is split per item",
+			manifest: `{"apiVersion":"v1","kind":"List","items":[
+  {"kind":"Service","metadata":{"name":"my-app"}},
+  {"kind":"Deployment","spec":{"replicas":2,"args":["--port","8080"]}}
+]}`,
+			want: "kind: Service\nmetadata:\n  name: my-app\n---\nkind: Deployment\nspec:\n  replicas: 2\n  args:\n    - --port\n    - \"8080\"\n",
+		},
+		{
+			name:     "array and stream of objects",
+			manifest: "[{\"kind\":\"Service\"},{\"kind\":\"Secret\"}]\n{\"kind\":\"ConfigMap\"}\n",
+			want:     "kind: Service\n---\nkind: Secret\n---\nkind: ConfigMap\n",
+		},
+		{
+			name:     "yaml is unchanged",
+			manifest: "kind: ConfigMap\nmetadata:\n    name: my-app\n",
+			want:     "kind: ConfigMap\nmetadata:\n    name: my-app\n",
+		},
+		{
+			name:     "yaml flow mapping is unchanged",
+			manifest: "{kind: ConfigMap}\n",
+			want:     "{kind: ConfigMap}\n",
+		},
+		{
+			name:     "empty manifest",
+			manifest: "",
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToYAML([]byte(tt.manifest))
+			if err != nil {
+				t.Fatalf("ToYAML() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ToYAML() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package manifest
+
+import "sort"
+
+// JoinResources joins the files of a split build, one resource per file, into a multi-document manifest
+// in file name order, so the manifest is deterministic whatever the order the files were read in
+func JoinResources(resources map[string][]byte) []byte {
+	if resources == nil {
+		return nil
+	}
+	names := make([]string, 0, len(resources))
+	for name := range resources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	documents := make([]string, 0, len(names))
+	for _, name := range names {
+		documents = append(documents, string(resources[name]))
+	}
+	return JoinDocuments(documents)
+}
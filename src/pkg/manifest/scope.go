@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clusterScopedKinds are the well-known kinds that are not namespaced
+var clusterScopedKinds = map[string]bool{
+	"APIService":                       true,
+	"CertificateSigningRequest":        true,
+	"ClusterIssuer":                    true,
+	"ClusterRole":                      true,
+	"ClusterRoleBinding":               true,
+	"ComponentStatus":                  true,
+	"CSIDriver":                        true,
+	"CSINode":                          true,
+	"CustomResourceDefinition":         true,
+	"FlowSchema":                       true,
+	"IngressClass":                     true,
+	"MutatingWebhookConfiguration":     true,
+	"Namespace":                        true,
+	"Node":                             true,
+	"PersistentVolume":                 true,
+	"PodSecurityPolicy":                true,
+	"PriorityClass":                    true,
+	"PriorityLevelConfiguration":       true,
+	"RuntimeClass":                     true,
+	"StorageClass":                     true,
+	"ValidatingAdmissionPolicy":        true,
+	"ValidatingAdmissionPolicyBinding": true,
+	"ValidatingWebhookConfiguration":   true,
+	"VolumeAttachment":                 true,
+}
+
+// IsClusterScoped reports whether a single YAML document is a cluster-scoped resource
+// Well-known cluster-scoped kinds always are, other kinds are namespaced if they set metadata.namespace.
+// Without namespace, custom kinds prefixed with "Cluster" (e.g. ClusterPolicy) are considered cluster-scoped,
+// the others are considered namespaced as kustomize often leaves the namespace to the default one
+func IsClusterScoped(document string) (bool, error) {
+	var header struct {
+		Kind     string `yaml:"kind"`
+		Metadata struct {
+			Namespace string `yaml:"namespace"`
+		} `yaml:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(document), &header); err != nil {
+		return false, fmt.Errorf("failed to parse manifest document: %w", err)
+	}
+	if clusterScopedKinds[header.Kind] {
+		return true, nil
+	}
+	if header.Metadata.Namespace != "" {
+		return false, nil
+	}
+	return strings.HasPrefix(header.Kind, "Cluster"), nil
+}
+
+// FilterScope keeps only the cluster-scoped documents if clusterScoped is set, the namespaced ones otherwise
+func FilterScope(manifest []byte, clusterScoped bool) ([]byte, error) {
+	kept := []string{}
+	for _, doc := range SplitDocuments(manifest) {
+		isClusterScoped, err := IsClusterScoped(doc)
+		if err != nil {
+			return nil, err
+		}
+		if isClusterScoped == clusterScoped {
+			kept = append(kept, doc)
+		}
+	}
+	return JoinDocuments(kept), nil
+}
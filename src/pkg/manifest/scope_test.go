@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestIsClusterScoped tests the scope detection from the kind and metadata.namespace
+func TestIsClusterScoped(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     bool
+	}{
+		{
+			name:     "well-known cluster-scoped kind",
+			document: "kind: ClusterRole\nmetadata:\n  name: reader\n",
+			want:     true,
+		},
+		{
+			name:     "namespace kind",
+			document: "kind: Namespace\nmetadata:\n  name: my-app\n",
+			want:     true,
+		},
+		{
+			name:     "namespaced kind with namespace",
+			document: "kind: Deployment\nmetadata:\n  name: my-app\n  namespace: my-app\n",
+			want:     false,
+		},
+		{
+			name:     "namespaced kind relying on the default namespace",
+			document: "kind: Deployment\nmetadata:\n  name: my-app\n",
+			want:     false,
+		},
+		{
+			name:     "custom cluster kind without namespace",
+			document: "kind: ClusterPolicy\nmetadata:\n  name: require-labels\n",
+			want:     true,
+		},
+		{
+			name:     "custom cluster-prefixed kind with namespace",
+			document: "kind: ClusterServiceVersion\nmetadata:\n  name: operator\n  namespace: operators\n",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := IsClusterScoped(tt.document)
+			if err != nil {
+				t.Fatalf("IsClusterScoped() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsClusterScoped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterScope tests keeping only the namespaced or cluster-scoped documents
+func TestFilterScope(t *testing.T) {
+	manifest := []byte(`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: my-app-reader
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  namespace: my-app
+`)
+	tests := []struct {
+		name          string
+		clusterScoped bool
+		wantKind      string
+		absentKind    string
+	}{
+		{name: "namespaced", clusterScoped: false, wantKind: "kind: Deployment", absentKind: "kind: ClusterRole"},
+		{name: "cluster", clusterScoped: true, wantKind: "kind: ClusterRole", absentKind: "kind: Deployment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FilterScope(manifest, tt.clusterScoped)
+			if err != nil {
+				t.Fatalf("FilterScope() error = %v", err)
+			}
+			if !strings.Contains(string(got), tt.wantKind) || strings.Contains(string(got), tt.absentKind) {
+				t.Errorf("FilterScope() = %q, want only %q", got, tt.wantKind)
+			}
+		})
+	}
+
+	if _, err := FilterScope([]byte("kind: [unclosed\n"), false); err == nil {
+		t.Error("FilterScope() error = nil, want error on invalid yaml")
+	}
+}
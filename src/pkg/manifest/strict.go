@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateNoDuplicateKeys parses every document of a (multi-document) YAML manifest
+// and returns an error listing each document containing duplicate mapping keys.
+// Most parsers silently keep the last value, which can hide mistakes that policies then miss.
+func ValidateNoDuplicateKeys(manifest []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(manifest))
+
+	problems := []string{}
+	for docIndex := 0; ; docIndex++ {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse manifest document #%d: %w", docIndex, err)
+		}
+
+		// decoding the node into a generic value enforces unique keys
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			problems = append(problems, fmt.Sprintf("document #%d (%s): %s",
+				docIndex, documentIdentity(&node), strings.TrimPrefix(err.Error(), "yaml: unmarshal errors:\n  ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("duplicate keys found in manifest:\n%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// documentIdentity returns "Kind/name" of a document node for error messages, best effort
+func documentIdentity(node *yaml.Node) string {
+	kind, name := "", ""
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return "unknown resource"
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "kind":
+			kind = value.Value
+		case "metadata":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				if value.Content[j].Value == "name" {
+					name = value.Content[j+1].Value
+				}
+			}
+		}
+	}
+	if kind == "" && name == "" {
+		return "unknown resource"
+	}
+	return kind + "/" + name
+}
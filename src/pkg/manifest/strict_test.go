@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+)
+
+const duplicateKeyManifest = `apiVersion: v1
+kind: Service
+metadata:
+  name: my-app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 3
+  replicas: 1
+`
+
+// TestValidateNoDuplicateKeys tests duplicate key detection across multi-document manifests
+func TestValidateNoDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  []string
+	}{
+		{
+			name:     "empty manifest",
+			manifest: "",
+		},
+		{
+			name: "valid multi-document manifest",
+			manifest: `apiVersion: v1
+kind: Service
+metadata:
+  name: my-app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  replicas: 3
+`,
+		},
+		{
+			name:     "duplicate key in second document",
+			manifest: duplicateKeyManifest,
+			wantErr:  []string{"document #1 (Deployment/my-app)", `"replicas" already defined`},
+		},
+		{
+			name:     "invalid yaml",
+			manifest: "kind: [Deployment\n",
+			wantErr:  []string{"failed to parse manifest document #0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNoDuplicateKeys([]byte(tt.manifest))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("ValidateNoDuplicateKeys() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateNoDuplicateKeys() error = nil, want error containing %v", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ValidateNoDuplicateKeys() error = %v, want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
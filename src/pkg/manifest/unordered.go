@@ -0,0 +1,134 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unorderedSortKeys are the fields identifying a list item, the first one set is its sort key, e.g. the name of an env var
+var unorderedSortKeys = []string{"name", "key", "mountPath", "containerPort"}
+
+// SortUnorderedFields sorts canonically the items of the block lists under the given field names, e.g. env or volumes,
+// by their name (or key, mountPath, containerPort) then their text, so a reordering without semantic change does not diff.
+// Lines are moved as-is, keeping the original formatting of the manifest
+func SortUnorderedFields(manifest []byte, fields []string) ([]byte, error) {
+	if len(fields) == 0 {
+		return manifest, nil
+	}
+	unordered := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		unordered[field] = true
+	}
+
+	documents := SplitDocuments(manifest)
+	for i, doc := range documents {
+		if !strings.HasSuffix(doc, "\n") {
+			doc += "\n"
+		}
+		var node yaml.Node
+		if err := yaml.Unmarshal([]byte(doc), &node); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest document: %w", err)
+		}
+		lines := strings.SplitAfter(doc, "\n")
+		sortSequences(&node, unordered, lines)
+		documents[i] = strings.Join(lines, "")
+	}
+	return JoinDocuments(documents), nil
+}
+
+// sortSequences sorts the lines of the unordered block lists under node, nested lists first: sorting a nested list
+// only moves lines within one item of the enclosing list, so the line ranges of the parsed nodes stay valid
+func sortSequences(node *yaml.Node, unordered map[string]bool, lines []string) {
+	for _, child := range node.Content {
+		sortSequences(child, unordered, lines)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if unordered[key.Value] && value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle == 0 && len(value.Content) > 1 {
+			sortSequenceLines(value, lines)
+		}
+	}
+}
+
+// sortSequenceLines sorts the line blocks of the items of a block list. An item spans from its "- " line
+// to the line before the next one indented at most as much as its dash, e.g. the next item or the next key
+func sortSequenceLines(seq *yaml.Node, lines []string) {
+	type item struct {
+		key   string
+		lines []string
+	}
+
+	first := seq.Content[0].Line - 1
+	if first < 0 || first >= len(lines) {
+		return
+	}
+	dashIndent := indentOf(lines[first])
+	items := []item{}
+	end := first
+	for idx, itemNode := range seq.Content {
+		start := itemNode.Line - 1
+		if start != end || start >= len(lines) || !strings.HasPrefix(strings.TrimLeft(lines[start], " "), "-") {
+			// not a line per item block list, e.g. a multi-line item before its dash, left as is
+			return
+		}
+		end = start + 1
+		for end < len(lines) {
+			line := lines[end]
+			if strings.TrimSpace(line) != "" && indentOf(line) <= dashIndent {
+				break
+			}
+			end++
+		}
+		if idx < len(seq.Content)-1 && seq.Content[idx+1].Line-1 != end {
+			return
+		}
+		if idx == len(seq.Content)-1 {
+			// blank lines after the list are not part of its last item
+			for end > start+1 && strings.TrimSpace(lines[end-1]) == "" {
+				end--
+			}
+		}
+		items = append(items, item{key: itemSortKey(itemNode), lines: append([]string{}, lines[start:end]...)})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].key != items[j].key {
+			return items[i].key < items[j].key
+		}
+		return strings.Join(items[i].lines, "") < strings.Join(items[j].lines, "")
+	})
+	pos := first
+	for _, it := range items {
+		copy(lines[pos:], it.lines)
+		pos += len(it.lines)
+	}
+}
+
+// itemSortKey returns the value identifying a list item, empty if none so the item sorts by its text
+func itemSortKey(node *yaml.Node) string {
+	if node.Kind == yaml.ScalarNode {
+		return node.Value
+	}
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+	for _, field := range unorderedSortKeys {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == field && node.Content[i+1].Kind == yaml.ScalarNode {
+				return node.Content[i+1].Value
+			}
+		}
+	}
+	return ""
+}
+
+// indentOf returns the number of leading spaces of line
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
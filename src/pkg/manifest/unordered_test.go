@@ -0,0 +1,108 @@
+package manifest
+
+import (
+	"testing"
+)
+
+// deploymentWithEnv returns a Deployment whose container has the given env entries
+func deploymentWithEnv(env string) string {
+	return `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+spec:
+  template:
+    spec:
+      containers:
+      - args:
+        - --port=8080
+        - --verbose
+        env:
+` + env + `        image: my-app:1.0.0
+        name: my-app
+`
+}
+
+// TestSortUnorderedFields tests that the items of the unordered lists are sorted, keeping their formatting
+func TestSortUnorderedFields(t *testing.T) {
+	sorted := deploymentWithEnv(`        - name: LOG_LEVEL
+          value: info
+        - name: PORT
+          value: "8080"
+        - name: TOKEN
+          valueFrom:
+            secretKeyRef:
+              key: token
+              name: my-app
+`)
+	tests := []struct {
+		name     string
+		manifest string
+		fields   []string
+		want     string
+	}{
+		{
+			name:     "no field",
+			manifest: "kind: ConfigMap\n",
+			want:     "kind: ConfigMap\n",
+		},
+		{
+			name: "reordered env",
+			manifest: deploymentWithEnv(`        - name: TOKEN
+          valueFrom:
+            secretKeyRef:
+              key: token
+              name: my-app
+        - name: PORT
+          value: "8080"
+        - name: LOG_LEVEL
+          value: info
+`),
+			fields: []string{"env"},
+			want:   sorted,
+		},
+		{
+			name:     "already sorted",
+			manifest: sorted,
+			fields:   []string{"env"},
+			want:     sorted,
+		},
+		{
+			name:     "scalar items",
+			manifest: "kind: Pod\nargs:\n- --verbose\n- --port=8080\nname: my-app",
+			fields:   []string{"args"},
+			want:     "kind: Pod\nargs:\n- --port=8080\n- --verbose\nname: my-app\n",
+		},
+		{
+			name:     "flow list left as is",
+			manifest: "kind: Pod\nargs: [--verbose, --port=8080]\n",
+			fields:   []string{"args"},
+			want:     "kind: Pod\nargs: [--verbose, --port=8080]\n",
+		},
+		{
+			name:     "every document",
+			manifest: "kind: Pod\nargs:\n- b\n- a\n---\nkind: Pod\nargs:\n  - d\n  - c\n",
+			fields:   []string{"args"},
+			want:     "kind: Pod\nargs:\n- a\n- b\n---\nkind: Pod\nargs:\n  - c\n  - d\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SortUnorderedFields([]byte(tt.manifest), tt.fields)
+			if err != nil {
+				t.Fatalf("SortUnorderedFields() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("SortUnorderedFields() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSortUnorderedFields_InvalidYaml tests that an unparsable document is reported
+func TestSortUnorderedFields_InvalidYaml(t *testing.T) {
+	if _, err := SortUnorderedFields([]byte("kind: [Pod\n"), []string{"env"}); err == nil {
+		t.Error("SortUnorderedFields() error = nil, want error for invalid YAML")
+	}
+}
@@ -16,6 +16,27 @@ type PolicyConfig struct {
 	FilePath     string            `yaml:"filePath"`
 	ExternalLink string            `yaml:"externalLink,omitempty"` // Optional link to policy documentation
 	Enforcement  EnforcementConfig `yaml:"enforcement"`
+
+	// Optional resources the policy applies to: "namespaced", "cluster" or "any" (default)
+	Scope string `yaml:"scope,omitempty"`
+
+	// Optional JSON/YAML files or directories, relative to the policies directory, exposed to the policy as `data`
+	// Directories are expanded to their .json/.yaml/.yml files in lexical order. Top-level keys of all files are
+	// merged, a key defined by several files is warned about and the last file in that order takes precedence
+	DataPaths []string `yaml:"dataPaths,omitempty"`
+
+	// Optional Go template wrapping each fail message, e.g. "[{{.PolicyId}}] {{.Message}} (see {{.ExternalLink}})"
+	// Available fields: see FailMessageTemplateData
+	MessageTemplate string `yaml:"messageTemplate,omitempty"`
+}
+
+// FailMessageTemplateData is the data passed to PolicyConfig.MessageTemplate
+type FailMessageTemplateData struct {
+	Message      string // raw fail message from rego
+	PolicyId     string
+	PolicyName   string
+	Description  string
+	ExternalLink string
 }
 
 // EnforcementConfig defines when and how a policy should be enforced
@@ -24,9 +45,43 @@ type EnforcementConfig struct {
 	IsWarningAfter  *time.Time     `yaml:"isWarningAfter,omitempty"`
 	IsBlockingAfter *time.Time     `yaml:"isBlockingAfter,omitempty"`
 	Override        OverrideConfig `yaml:"override"`
+
+	// Optional enforcement dates per environment, e.g. blocking in prod earlier than in stg
+	// A date not set for an environment falls back to the global one above
+	Environments map[string]EnvironmentEnforcementConfig `yaml:"environments,omitempty"`
+}
+
+// EnvironmentEnforcementConfig overrides the enforcement dates of a policy for one environment
+type EnvironmentEnforcementConfig struct {
+	InEffectAfter   *time.Time `yaml:"inEffectAfter,omitempty"`
+	IsWarningAfter  *time.Time `yaml:"isWarningAfter,omitempty"`
+	IsBlockingAfter *time.Time `yaml:"isBlockingAfter,omitempty"`
 }
 
 // OverrideConfig defines how a policy can be overridden
 type OverrideConfig struct {
 	Comment string `yaml:"comment"` // e.g., "/sp-override-ha"
+
+	// Optional number of distinct users that must post the override comment, a single one if not set
+	RequiredApprovals int `yaml:"requiredApprovals,omitempty"`
+
+	// Optional command overriding the policy temporarily, posted with a duration, e.g. "/sp-snooze-ha 7d".
+	// The policy is overridden until the duration after the comment, and enforced again afterwards
+	Snooze string `yaml:"snooze,omitempty"`
+}
+
+// EnforcementTransition is a scheduled change of a policy's enforcement level
+type EnforcementTransition struct {
+	PolicyId   string    `json:"policyId"`
+	PolicyName string    `json:"policyName"`
+	Level      string    `json:"level"` // enforcement level the policy moves to
+	At         time.Time `json:"at"`
+}
+
+// PolicyLevel is the current enforcement level of a policy
+type PolicyLevel struct {
+	PolicyId        string `json:"policyId"`
+	PolicyName      string `json:"policyName"`
+	Level           string `json:"level"`
+	OverrideCommand string `json:"overrideCommand,omitempty"`
 }
@@ -3,6 +3,7 @@ package models
 const (
 	DiffContentTypeText       = "text"
 	DiffContentTypeGHArtifact = "ext_ghartifact"
+	DiffContentTypeSuppressed = "suppressed" // diff not shown for the environment, Content is an optional link to it
 )
 
 type DiffResult struct {
@@ -22,6 +22,21 @@ type Comment struct {
 	ID        int64
 	Body      string
 	User      string
+	HTMLURL   string // link to the comment on the PR page
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Only set for the body of a PR review, its state, e.g. APPROVED or COMMENTED
+	ReviewState string
+}
+
+// FileViolation represents a policy violation mapped to the manifest file and line declaring the failing resource,
+// e.g. to annotate the PR "Files changed" view
+type FileViolation struct {
+	Path       string // repository path of the manifest file, e.g. services/my-app/base/deployment.yaml
+	Line       int    // line of the resource in the file, 1 if unknown
+	PolicyId   string
+	PolicyName string
+	Level      string // enforcement level of the policy, e.g. BLOCK
+	Message    string
 }
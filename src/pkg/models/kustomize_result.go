@@ -2,12 +2,28 @@ package models
 
 type BuildManifestResult struct {
 	EnvManifestBuild map[string]BuildEnvManifestResult
+
+	// Environments in the configured order, the policies are evaluated in this order.
+	// Built environments missing from it are evaluated after them in name order
+	Environments []string
 }
 
 type BuildEnvManifestResult struct {
 	Environment    string
 	BeforeManifest []byte
 	AfterManifest  []byte
+
+	// Overlay path built for the head manifest (the base one if the head has no overlay), comma-separated if the
+	// environment concatenates several overlays, e.g. "services/my-app/environments/prod"
+	OverlayPath string
+
+	// Warnings printed by kustomize while building the after manifest
+	AfterWarnings []string
+
+	// Files of the split build (--build-split-output), one resource per file by kustomize file name,
+	// diffed file by file. The manifests above are their concatenation. Nil without split build
+	BeforeResources map[string][]byte
+	AfterResources  map[string][]byte
 }
 
 type PolicyEvaluateResult struct {
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
 
 // ReportData represents the complete report data structure
 type ReportData struct {
@@ -10,11 +14,30 @@ type ReportData struct {
 	HeadCommit   string    `json:"headCommit"`
 	Environments []string  `json:"environments"`
 
+	// Files changed under the service path between base and head (github mode only)
+	ChangedFiles []string `json:"changedFiles,omitempty"`
+
+	// Warnings printed by kustomize while building the head manifests, per environment
+	BuildWarnings map[string][]string `json:"buildWarnings,omitempty"`
+
 	// Manifest changes per environment
 	ManifestChanges map[string]EnvironmentDiff `json:"manifestChanges"`
 
 	// Policy evaluation results
 	PolicyEvaluation PolicyEvaluation `json:"policyEvaluation"`
+
+	// Full rendered head manifest per environment, only set if enabled
+	FullManifests map[string]FullManifest `json:"fullManifests,omitempty"`
+
+	// Non-fatal issues met while processing (skipped environment, truncated diff, ...), rendered as notes
+	Warnings []string `json:"warnings,omitempty"`
+
+	// True if the base and head manifests of every environment are identical and the policy evaluation was skipped
+	ManifestsUnchanged bool `json:"manifestsUnchanged,omitempty"`
+
+	// Enforcement levels left out of the rendered comment (--comment-levels), e.g. RECOMMEND -> true,
+	// their policies are removed from the policy matrix of the rendered report only
+	HiddenPolicyLevels map[string]bool `json:"-"`
 }
 
 // EnvironmentDiff represents diff data for a single environment
@@ -24,8 +47,49 @@ type EnvironmentDiff struct {
 	DeletedLineCount int `json:"deletedLineCount"`
 
 	ContentGHFilePath *string `json:"contentGHFilePath"` // file path in the runner's output directory if the diff is too long
+	ContentType       string  `json:"contentType"`       // "text", "ext_ghartifact" or "suppressed"
+	Content           string  `json:"content"`           // diff text OR artifact URL OR link to the suppressed diff
+
+	ResourceStats []ResourceStat `json:"resourceStats,omitempty"` // added/deleted lines per changed resource, sums to the line counts
+
+	// Only set with --diff-per-resource on a text diff: the diff of each changed resource by identifier,
+	// e.g. Deployment/my-ns/my-app, rendered apart instead of the whole diff
+	PerResource map[string]string `json:"perResource,omitempty"`
+
+	OverlayPath string `json:"overlayPath,omitempty"` // overlay built for the environment, e.g. services/my-app/environments/prod
+}
+
+// ResourceStat represents the added and deleted lines of a single resource in an environment diff
+type ResourceStat struct {
+	Group     string `json:"group,omitempty"` // API group of a custom resource, empty for built-in kinds
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Added     int    `json:"added"`
+	Deleted   int    `json:"deleted"`
+
+	LineRanges []LineRange `json:"lineRanges,omitempty"` // changed lines in the after manifest, e.g. 50-57 and 138
+}
+
+// LineRange is an inclusive range of lines
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// String returns "start-end", or "start" for a single line
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// FullManifest represents the full rendered head manifest of a single environment
+type FullManifest struct {
+	ContentGHFilePath *string `json:"contentGHFilePath"` // file path in the runner's output directory if the manifest is too long
 	ContentType       string  `json:"contentType"`       // "text" or "ext_ghartifact"
-	Content           string  `json:"content"`           // diff text OR artifact URL
+	Content           string  `json:"content"`           // manifest text OR artifact URL
 }
 
 // PolicyEvaluationSummary represents the overall policy evaluation results
@@ -35,11 +99,28 @@ type PolicyEvaluation struct {
 
 	// Detailed policy matrix
 	PolicyMatrix map[string]PolicyMatrix `json:"policyMatrix"`
+
+	// Policy Id -> rego source of policies failing in any environment, only set if enabled
+	PolicySources map[string]string `json:"policySources,omitempty"`
+
+	// True if the evaluation stopped after the first blocking failure (--fail-fast), the results are partial
+	StoppedAfterBlockingFailure bool `json:"stoppedAfterBlockingFailure,omitempty"`
 }
 
 type EnvironmentSummaryEnv struct {
 	PassingStatus EnforcementPassingStatus `json:"passingStatus"`
 	PolicyCounts  PolicyCounts             `json:"policyCounts"`
+
+	// true if the base manifest already fails a blocking policy, only set if the base was evaluated
+	BaseFailsBlockingCheck bool `json:"baseFailsBlockingCheck,omitempty"`
+
+	// true if the base and head manifests are identical and the policies were not re-evaluated
+	// (--skip-eval-when-unchanged), the environment then has no counts nor policy matrix
+	Unchanged bool `json:"unchanged,omitempty"`
+
+	// true if the policy evaluation stopped after a blocking failure on an earlier environment (--fail-fast)
+	// before evaluating this one, the environment then has no counts nor policy matrix
+	NotEvaluated bool `json:"notEvaluated,omitempty"`
 }
 
 type EnforcementPassingStatus struct {
@@ -56,6 +137,7 @@ type PolicyCounts struct {
 	TotalOmitted        int `json:"totalOmitted"`        // total number of policies of level OVERRIDE, NOT_IN_EFFECT that either failed or passed
 	TotalOmittedFailed  int `json:"totalOmittedFailed"`  // total number of policies of level OVERRIDE, NOT_IN_EFFECT that failed
 	TotalOmittedSuccess int `json:"totalOmittedSuccess"` // total number of policies of level OVERRIDE, NOT_IN_EFFECT that passed
+	TotalErrored        int `json:"totalErrored"`        // total number of policies that could not be evaluated, of any level
 
 	BlockingSuccessCount    int `json:"blockingSuccessCount"`
 	BlockingFailedCount     int `json:"blockingFailedCount"`
@@ -77,6 +159,12 @@ type PolicyMatrix struct {
 	RecommendPolicies   []PolicyResult `json:"recommendPolicies"`
 	OverriddenPolicies  []PolicyResult `json:"overriddenPolicies"`
 	NotInEffectPolicies []PolicyResult `json:"notInEffectPolicies"`
+
+	// Policies of any level with violations of the base manifest fixed by the PR, only set with --report-fixed
+	FixedPolicies []PolicyResult `json:"fixedPolicies,omitempty"`
+
+	// Policies of any level that could not be evaluated, e.g. a rego compile error, they fail the blocking check
+	ErroredPolicies []PolicyResult `json:"erroredPolicies,omitempty"`
 }
 
 // PolicyResult represents the result of a single policy evaluation
@@ -86,6 +174,68 @@ type PolicyResult struct {
 	ExternalLink string   `json:"externalLink,omitempty"` // Optional link to policy documentation
 	IsPassing    bool     `json:"isPassing"`              // true or false, if false it means FailMessages is not empty
 	FailMessages []string `json:"failMessages"`
+
+	// Only set with --use-rego-severity on a failing policy: the enforcement level mapped from its rego rules,
+	// e.g. BLOCK for deny and WARNING for warn, the policy is reported at this level
+	Severity string `json:"severity,omitempty"`
+
+	// Only set if the base manifest was evaluated too
+	IsFailingOnBase         bool     `json:"isFailingOnBase,omitempty"`         // the policy already fails on the base manifest
+	PreExistingFailMessages []string `json:"preExistingFailMessages,omitempty"` // fail messages already present on the base manifest
+	NewFailMessages         []string `json:"newFailMessages,omitempty"`         // fail messages introduced by the PR
+	FixedFailMessages       []string `json:"fixedFailMessages,omitempty"`       // fail messages of the base manifest resolved by the PR
+
+	// Only set if the policy requires more than one override approval
+	OverrideApprovals int `json:"overrideApprovals,omitempty"` // distinct users who posted the override comment
+	RequiredApprovals int `json:"requiredApprovals,omitempty"` // distinct users required to override the policy
+
+	// Only set if the failing policy passes once resources exempted by annotation are left out,
+	// e.g. "timed-exemption (expires 2025-12-01)". The result then counts as overridden
+	OverrideReason string `json:"overrideReason,omitempty"`
+
+	// Only set with --baseline on a failing policy: the fail messages already in the baseline report.
+	// The policy counts as overridden if they are all of its fail messages
+	BaselineFailMessages []string `json:"baselineFailMessages,omitempty"`
+
+	// Only set if the policy is overridden by an active snooze comment and not by an override
+	Snooze *PolicySnooze `json:"snooze,omitempty"`
+
+	// Only set if the policy could not be evaluated, e.g. a rego compile error or a conftest timeout,
+	// the policy is then neither passing nor failing with messages
+	Error string `json:"error,omitempty"`
+}
+
+// PolicySnooze is a temporary override of a policy by a snooze comment
+type PolicySnooze struct {
+	User  string    `json:"user"`  // login of the user who posted the snooze comment
+	Until time.Time `json:"until"` // time the comment was posted plus the snooze duration
+}
+
+// MultiServiceReportData represents the consolidated report of several services checked in the same PR
+type MultiServiceReportData struct {
+	Timestamp  time.Time `json:"timestamp"`
+	BaseCommit string    `json:"baseCommit"`
+	HeadCommit string    `json:"headCommit"`
+
+	// Reports per service, sorted by service name
+	Services []ServiceReport `json:"services"`
+
+	// Overall gate, true if every service passes its blocking policies
+	PassBlockingCheck bool `json:"passBlockingCheck"`
+}
+
+// ServiceReport represents the gate and the report of a single service in a MultiServiceReportData
+type ServiceReport struct {
+	Service             string   `json:"service"`
+	PassBlockingCheck   bool     `json:"passBlockingCheck"`   // true if every environment passes its blocking policies
+	FailedEnvironments  []string `json:"failedEnvironments"`  // environments failing blocking policies
+	BlockingFailedCount int      `json:"blockingFailedCount"` // failing blocking policies summed over the environments
+	HasChanges          bool     `json:"hasChanges"`          // true if any environment has manifest changes
+
+	Report ReportData `json:"report"`
+
+	// Rendered single-service comment, set while rendering the consolidated comment
+	RenderedMarkdown string `json:"-"`
 }
 
 // ReportTemplateData represents the data structure for template rendering
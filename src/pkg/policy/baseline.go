@@ -0,0 +1,57 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// Override reason of a failing policy whose fail messages are all in the baseline report
+const BASELINE_REASON = "baseline (violations already in the baseline report)"
+
+// baselineViolations are the fail messages of a baseline report: environment -> policy id -> message -> true
+type baselineViolations map[string]map[string]map[string]bool
+
+// loadBaseline reads the fail messages of every policy of a report.json, or report.json.gz, written by a previous run
+func loadBaseline(path string) (baselineViolations, error) {
+	data, err := fileutil.ReadOutputFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline report %s: %w", path, err)
+	}
+	var report models.ReportData
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse baseline report %s: %w", path, err)
+	}
+
+	baseline := baselineViolations{}
+	for env, matrix := range report.PolicyEvaluation.PolicyMatrix {
+		baseline[env] = map[string]map[string]bool{}
+		for _, results := range [][]models.PolicyResult{
+			matrix.BlockingPolicies, matrix.WarningPolicies, matrix.RecommendPolicies,
+			matrix.OverriddenPolicies, matrix.NotInEffectPolicies,
+		} {
+			for _, result := range results {
+				for _, msg := range result.FailMessages {
+					if baseline[env][result.PolicyId] == nil {
+						baseline[env][result.PolicyId] = map[string]bool{}
+					}
+					baseline[env][result.PolicyId][msg] = true
+				}
+			}
+		}
+	}
+	return baseline, nil
+}
+
+// inBaseline returns the fail messages of the policy on env already in the baseline, nil if none
+func (b baselineViolations) inBaseline(env, policyId string, failMsgs []string) []string {
+	var known []string
+	for _, msg := range failMsgs {
+		if b[env][policyId][msg] {
+			known = append(known, msg)
+		}
+	}
+	return known
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// newTestBaselineReport writes a report.json where the ha policy fails on env with the messages, returns its path
+func newTestBaselineReport(t *testing.T, env string, failMsgs ...string) string {
+	t.Helper()
+	report := models.ReportData{
+		PolicyEvaluation: models.PolicyEvaluation{
+			PolicyMatrix: map[string]models.PolicyMatrix{
+				env: {BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", FailMessages: failMsgs}}},
+			},
+		},
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("failed to marshal baseline report: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write baseline report: %v", err)
+	}
+	return path
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Baseline tests that violations of the baseline are tolerated
+// and new ones block
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Baseline(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {Environment: "stg", BeforeManifest: []byte("base"), AfterManifest: []byte("head")},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		baselineEnv  string
+		headOutput   string
+		wantOverride string
+		wantBaseline []string
+		wantBlocking bool
+	}{
+		{
+			name:         "pre-existing violation is tolerated",
+			baselineEnv:  "stg",
+			headOutput:   `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`,
+			wantOverride: BASELINE_REASON,
+			wantBaseline: []string{"replicas too low"},
+			wantBlocking: false,
+		},
+		{
+			name:         "new violation blocks",
+			baselineEnv:  "stg",
+			headOutput:   `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"},{"msg":"no anti-affinity"}]}]`,
+			wantBaseline: []string{"replicas too low"},
+			wantBlocking: true,
+		},
+		{
+			name:         "baseline of another environment",
+			baselineEnv:  "prod",
+			headOutput:   `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`,
+			wantBlocking: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{
+				BaselinePath: newTestBaselineReport(t, tt.baselineEnv, "replicas too low"),
+			})
+			e.executor = &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					return &command.Result{Stdout: []byte(tt.headOutput)}, fmt.Errorf("exit status 1")
+				},
+			}
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+
+			matrix := got.PolicyMatrix["stg"]
+			var result models.PolicyResult
+			switch {
+			case len(matrix.BlockingPolicies) == 1:
+				result = matrix.BlockingPolicies[0]
+			case len(matrix.OverriddenPolicies) == 1:
+				result = matrix.OverriddenPolicies[0]
+			default:
+				t.Fatalf("GeneratePolicyEvalResultForManifests() matrix = %+v, want the ha policy", matrix)
+			}
+			if result.OverrideReason != tt.wantOverride {
+				t.Errorf("GeneratePolicyEvalResultForManifests() OverrideReason = %q, want %q", result.OverrideReason, tt.wantOverride)
+			}
+			if !reflect.DeepEqual(result.BaselineFailMessages, tt.wantBaseline) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() BaselineFailMessages = %v, want %v", result.BaselineFailMessages, tt.wantBaseline)
+			}
+			if pass := got.EnvironmentSummary["stg"].PassingStatus.PassBlockingCheck; pass == tt.wantBlocking {
+				t.Errorf("GeneratePolicyEvalResultForManifests() PassBlockingCheck = %v, want %v", pass, !tt.wantBlocking)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_LoadAndValidate_Baseline tests that an unreadable baseline report fails the load
+func TestPolicyEvaluator_LoadAndValidate_Baseline(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`)
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	if err := os.WriteFile(invalid, []byte("not json"), 0644); err != nil {
+		t.Fatalf("failed to write invalid baseline: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "missing report", path: filepath.Join(t.TempDir(), "missing.json"), wantErr: "failed to read baseline report"},
+		{name: "invalid report", path: invalid, wantErr: "failed to parse baseline report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{BaselinePath: tt.path})
+			err := e.LoadAndValidate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadAndValidate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
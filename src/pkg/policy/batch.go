@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/logctx"
+)
+
+// conftestBatches groups policies to evaluate in one conftest call each. conftest reports the failures
+// per rego namespace, so a batch holds at most one policy per rego package to attribute them back
+func (e *PolicyEvaluator) conftestBatches(policyIds []string) [][]string {
+	ids := append([]string{}, policyIds...)
+	sort.Strings(ids)
+
+	batches := [][]string{}
+	packagesOfBatch := []map[string]bool{}
+	for _, id := range ids {
+		regoPackage := e.data.regoPackageOfPolicy[id]
+		placed := false
+		for i := range batches {
+			if !packagesOfBatch[i][regoPackage] {
+				batches[i] = append(batches[i], id)
+				packagesOfBatch[i][regoPackage] = true
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			batches = append(batches, []string{id})
+			packagesOfBatch = append(packagesOfBatch, map[string]bool{regoPackage: true})
+		}
+	}
+	return batches
+}
+
+// evaluateBatchesWithConftest evaluates policies without external data against the manifest, one conftest call per batch.
+// A failed batch is evaluated again one policy at a time, to attribute the error to the policies causing it
+// returns: policyId -> failure messages, policyId -> evaluation error
+func (e *PolicyEvaluator) evaluateBatchesWithConftest(ctx context.Context, policyIds []string, manifestPath string) (map[string][]string, map[string]error, error) {
+	results := make(map[string][]string)
+	evalErrors := make(map[string]error)
+	evaluateOne := func(id string) error {
+		failMsgs, err := e.evaluatePolicyWithConftest(ctx, id, e.data.fullPathToPolicy[id], manifestPath, "")
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logctx.Entry(ctx, logger).WithField("policyId", id).WithError(err).Warn("Failed to evaluate policy, continuing with the others")
+			evalErrors[id] = err
+			return nil
+		}
+		results[id] = failMsgs
+		return nil
+	}
+
+	for _, batch := range e.conftestBatches(policyIds) {
+		if len(batch) == 1 {
+			if err := evaluateOne(batch[0]); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		batchResults, err := e.evaluateBatchWithConftest(ctx, batch, manifestPath)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			logctx.Entry(ctx, logger).WithField("policyIds", batch).WithError(err).Warn("Failed to evaluate policies in one conftest call, evaluating them one by one")
+			for _, id := range batch {
+				if err := evaluateOne(id); err != nil {
+					return nil, nil, err
+				}
+			}
+			continue
+		}
+		for id, failMsgs := range batchResults {
+			results[id] = failMsgs
+		}
+	}
+	return results, evalErrors, nil
+}
+
+// evaluateBatchWithConftest evaluates policies of distinct rego packages in a single conftest call,
+// the failures of each namespace are attributed to the policy declaring that package
+func (e *PolicyEvaluator) evaluateBatchWithConftest(ctx context.Context, batch []string, manifestPath string) (map[string][]string, error) {
+	logctx.Entry(ctx, logger).WithField("policyIds", batch).Info("evaluating policies in one conftest call")
+
+	policyOfNamespace := make(map[string]string, len(batch))
+	args := []string{"test", "--combine"}
+	for _, id := range batch {
+		regoPackage := e.data.regoPackageOfPolicy[id]
+		policyOfNamespace[regoPackage] = id
+		args = append(args, "--namespace", regoPackage, "--policy", e.data.fullPathToPolicy[id])
+	}
+	args = append(args, manifestPath, "-o", "json")
+
+	// If policy eval not passing, the program exit with code 1, we will omit error here
+	result, err := e.executor.Run(ctx, "", "conftest", args...)
+	if result == nil {
+		return nil, fmt.Errorf("failed to run conftest: %w", err)
+	}
+	logctx.Entry(ctx, logger).Debugf("conftest output: %s", string(result.Stdout))
+
+	outputJson := []conftestResult{}
+	if err := json.Unmarshal(result.Stdout, &outputJson); err != nil {
+		return nil, fmt.Errorf("failed to parse conftest output: %w\nStderr: %s", err, string(result.Stderr))
+	}
+
+	results := make(map[string][]string, len(batch))
+	for _, output := range outputJson {
+		id, ok := policyOfNamespace[output.Namespace]
+		if !ok {
+			logctx.Entry(ctx, logger).WithField("namespace", output.Namespace).Warn("Ignoring conftest result of a namespace without policy")
+			continue
+		}
+		if _, ok := results[id]; !ok {
+			results[id] = []string{}
+		}
+		results[id] = append(results[id], e.conftestFailureMessages(output)...)
+	}
+
+	for _, id := range batch {
+		if _, ok := results[id]; ok {
+			continue
+		}
+		if !e.options.EmptyResultsAsPass {
+			return nil, fmt.Errorf("no results found for policy %s in conftest output: %s\nStderr: %s", id, string(result.Stdout), string(result.Stderr))
+		}
+		logctx.Entry(ctx, logger).WithField("policyId", id).Info("conftest returned no results, nothing to check, treating as a pass")
+		results[id] = []string{}
+	}
+	return results, nil
+}
@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// TestPolicyEvaluator_Evaluate_BatchConftest tests that batched evaluation yields the per-policy results with fewer conftest calls
+func TestPolicyEvaluator_Evaluate_BatchConftest(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+  labels:
+    name: Labels
+    type: opa
+    filePath: labels.rego
+  limits:
+    name: Resource Limits
+    type: opa
+    filePath: limits.rego
+  tls:
+    name: Ingress TLS
+    type: opa
+    filePath: tls.rego
+`)
+	// ha and labels share the main package, they cannot be told apart in the same conftest call
+	for name, content := range map[string]string{
+		"labels.rego":      "package main\n",
+		"labels_test.rego": testPolicyTestRego,
+		"limits.rego":      "package limits\n",
+		"limits_test.rego": "package limits\n\ntest_limits if { true }\n",
+		"tls.rego":         "package tls\n",
+		"tls_test.rego":    "package tls\n\ntest_tls if { true }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	// every policy but tls fails with its file name, one result per policy package like conftest
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			outputs := []conftestResult{}
+			for i, arg := range args {
+				if arg != "--policy" {
+					continue
+				}
+				policyPath := args[i+1]
+				regoPackage, err := regoPackageOf(policyPath)
+				if err != nil {
+					return nil, err
+				}
+				output := conftestResult{Filename: "Combined", Namespace: regoPackage}
+				if base := filepath.Base(policyPath); base != "tls.rego" {
+					output.Failures = append(output.Failures, conftestFailure{Msg: strings.TrimSuffix(base, ".rego") + " failed"})
+				}
+				outputs = append(outputs, output)
+			}
+			stdout, err := json.Marshal(outputs)
+			if err != nil {
+				return nil, err
+			}
+			return &command.Result{Stdout: stdout}, fmt.Errorf("exit status 1")
+		},
+	}
+	manifest := []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: my-app\n")
+
+	evaluate := func(batch bool) (map[string][]string, int) {
+		t.Helper()
+		callsBefore := len(fake.Calls())
+		e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{BatchConftest: batch})
+		e.executor = fake
+		if err := e.LoadAndValidate(); err != nil {
+			t.Fatalf("LoadAndValidate() error = %v", err)
+		}
+		results, err := e.Evaluate(context.Background(), manifest)
+		if err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		return results, len(fake.Calls()) - callsBefore
+	}
+
+	perPolicy, perPolicyCalls := evaluate(false)
+	batched, batchedCalls := evaluate(true)
+
+	want := map[string][]string{
+		"ha":     {"ha failed"},
+		"labels": {"labels failed"},
+		"limits": {"limits failed"},
+		"tls":    {},
+	}
+	if !reflect.DeepEqual(perPolicy, want) {
+		t.Errorf("Evaluate() per policy = %v, want %v", perPolicy, want)
+	}
+	if !reflect.DeepEqual(batched, perPolicy) {
+		t.Errorf("Evaluate() batched = %v, want the per policy results %v", batched, perPolicy)
+	}
+	if perPolicyCalls != 4 {
+		t.Errorf("Evaluate() per policy ran conftest %d times, want 4", perPolicyCalls)
+	}
+	// {ha, limits, tls} then {labels}, as labels shares the main package with ha
+	if batchedCalls != 2 {
+		t.Errorf("Evaluate() batched ran conftest %d times, want 2", batchedCalls)
+	}
+}
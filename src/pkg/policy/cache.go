@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// evalCache caches policy evaluation results (fail messages) keyed by the content hash of
+// the policy file and of the manifest, so identical manifests don't re-invoke conftest.
+// Entries live in memory for the run and, if dir is set, are persisted on disk across runs.
+type evalCache struct {
+	mu      sync.Mutex
+	entries map[string][]string
+	dir     string
+	// version of the evaluation engine, e.g. "conftest 0.56.0", a version change invalidates all entries
+	version string
+	// evaluation settings changing the cached results, e.g. "rego-severity", empty by default
+	variant string
+}
+
+func newEvalCache(dir string) *evalCache {
+	return &evalCache{
+		entries: make(map[string][]string),
+		dir:     dir,
+	}
+}
+
+// setVersion sets the evaluation engine version included in the cache keys
+func (c *evalCache) setVersion(version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = version
+}
+
+// setVariant sets the evaluation settings included in the cache keys
+func (c *evalCache) setVariant(variant string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.variant = variant
+}
+
+// key computes the cache key of a policy evaluation, editing the policy file, its external data
+// or upgrading the evaluation engine invalidates it
+func (c *evalCache) key(policyPath string, manifest []byte, data []byte) (string, error) {
+	policyContent, err := os.ReadFile(policyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read policy file for cache key: %w", err)
+	}
+	policyHash := sha256.Sum256(policyContent)
+	manifestHash := sha256.Sum256(manifest)
+	dataHash := sha256.Sum256(data)
+	c.mu.Lock()
+	versionHash := sha256.Sum256([]byte(c.version))
+	variant := c.variant
+	c.mu.Unlock()
+
+	h := sha256.New()
+	h.Write(policyHash[:])
+	h.Write(manifestHash[:])
+	h.Write(dataHash[:])
+	h.Write(versionHash[:])
+	if variant != "" {
+		variantHash := sha256.Sum256([]byte(variant))
+		h.Write(variantHash[:])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get returns the cached fail messages of key, looking up memory first then disk
+func (c *evalCache) get(key string) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if failMsgs, ok := c.entries[key]; ok {
+		return failMsgs, true
+	}
+	if c.dir == "" {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(c.entryPath(key))
+	if err != nil {
+		return nil, false
+	}
+	failMsgs := []string{}
+	if err := json.Unmarshal(data, &failMsgs); err != nil {
+		logger.WithField("key", key).WithField("error", err).Warn("Ignoring corrupted policy cache entry")
+		return nil, false
+	}
+	c.entries[key] = failMsgs
+	return failMsgs, true
+}
+
+// put stores the fail messages of key, disk write failures are only logged
+func (c *evalCache) put(key string, failMsgs []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = failMsgs
+	if c.dir == "" {
+		return
+	}
+
+	data, err := json.Marshal(failMsgs)
+	if err != nil {
+		logger.WithField("key", key).WithField("error", err).Warn("Failed to marshal policy cache entry")
+		return
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		logger.WithField("dir", c.dir).WithField("error", err).Warn("Failed to create policy cache directory")
+		return
+	}
+	if err := os.WriteFile(c.entryPath(key), data, 0644); err != nil {
+		logger.WithField("key", key).WithField("error", err).Warn("Failed to write policy cache entry")
+	}
+}
+
+func (c *evalCache) entryPath(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
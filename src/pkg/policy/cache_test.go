@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// writeTestPolicyFile writes a policy file in a temp dir and returns its path
+func writeTestPolicyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ha.rego")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write policy file: %v", err)
+	}
+	return path
+}
+
+// TestEvalCache_HitMiss tests that identical policy/manifest pairs hit the cache
+func TestEvalCache_HitMiss(t *testing.T) {
+	policyPath := writeTestPolicyFile(t, testPolicyRego)
+	c := newEvalCache("")
+
+	key, err := c.key(policyPath, []byte("kind: Deployment"), nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	if _, ok := c.get(key); ok {
+		t.Fatal("get() on empty cache should miss")
+	}
+
+	c.put(key, []string{"failed"})
+	got, ok := c.get(key)
+	if !ok {
+		t.Fatal("get() after put() should hit")
+	}
+	if !reflect.DeepEqual(got, []string{"failed"}) {
+		t.Errorf("get() = %v, want [failed]", got)
+	}
+
+	otherKey, err := c.key(policyPath, []byte("kind: Service"), nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	if _, ok := c.get(otherKey); ok {
+		t.Error("get() with a different manifest should miss")
+	}
+}
+
+// TestEvalCache_PolicyChangeInvalidates tests that editing the policy file produces a cache miss
+func TestEvalCache_PolicyChangeInvalidates(t *testing.T) {
+	policyPath := writeTestPolicyFile(t, testPolicyRego)
+	manifest := []byte("kind: Deployment")
+	c := newEvalCache("")
+
+	key, err := c.key(policyPath, manifest, nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	c.put(key, []string{})
+
+	if err := os.WriteFile(policyPath, []byte(testPolicyRego+"\n# edited\n"), 0644); err != nil {
+		t.Fatalf("failed to edit policy file: %v", err)
+	}
+	newKey, err := c.key(policyPath, manifest, nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	if newKey == key {
+		t.Fatal("key() should change when the policy file changes")
+	}
+	if _, ok := c.get(newKey); ok {
+		t.Error("get() after policy change should miss")
+	}
+}
+
+// TestEvalCache_Disk tests that results persist across cache instances when a dir is set
+func TestEvalCache_Disk(t *testing.T) {
+	policyPath := writeTestPolicyFile(t, testPolicyRego)
+	dir := t.TempDir()
+
+	first := newEvalCache(dir)
+	key, err := first.key(policyPath, []byte("kind: Deployment"), nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	first.put(key, []string{"failed"})
+
+	second := newEvalCache(dir)
+	got, ok := second.get(key)
+	if !ok {
+		t.Fatal("get() on a new cache with the same dir should hit")
+	}
+	if !reflect.DeepEqual(got, []string{"failed"}) {
+		t.Errorf("get() = %v, want [failed]", got)
+	}
+}
+
+// TestEvalCache_VersionChangeInvalidates tests that a different engine version produces a cache miss
+func TestEvalCache_VersionChangeInvalidates(t *testing.T) {
+	policyPath := writeTestPolicyFile(t, testPolicyRego)
+	manifest := []byte("kind: Deployment")
+	dir := t.TempDir()
+
+	first := newEvalCache(dir)
+	first.setVersion("conftest 0.56.0")
+	key, err := first.key(policyPath, manifest, nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	first.put(key, []string{"failed"})
+
+	second := newEvalCache(dir)
+	second.setVersion("conftest 0.57.0")
+	newKey, err := second.key(policyPath, manifest, nil)
+	if err != nil {
+		t.Fatalf("key() error = %v", err)
+	}
+	if newKey == key {
+		t.Fatal("key() should change when the engine version changes")
+	}
+	if _, ok := second.get(newKey); ok {
+		t.Error("get() after a version change should miss")
+	}
+}
+
+// TestPolicyEvaluator_DetectCacheVersion tests that persisted results are only reused with the same conftest version
+func TestPolicyEvaluator_DetectCacheVersion(t *testing.T) {
+	policiesDir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`)
+	cacheDir := t.TempDir()
+
+	tests := []struct {
+		name          string
+		version       string
+		wantEvaluated bool
+	}{
+		{
+			name:          "first run",
+			version:       "0.56.0",
+			wantEvaluated: true,
+		},
+		{
+			name:    "same version",
+			version: "0.56.0",
+		},
+		{
+			name:          "upgraded conftest",
+			version:       "0.57.0",
+			wantEvaluated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated := 0
+			fake := &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					if args[0] == "--version" {
+						return &command.Result{Stdout: []byte("Conftest: " + tt.version + "\n")}, nil
+					}
+					evaluated++
+					return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main"}]`)}, nil
+				},
+			}
+			e := NewPolicyEvaluatorWithOptions(policiesDir, EvaluatorOptions{CacheDir: cacheDir})
+			e.executor = fake
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			if err := e.DetectCacheVersion(context.Background()); err != nil {
+				t.Fatalf("DetectCacheVersion() error = %v", err)
+			}
+			if _, err := e.Evaluate(context.Background(), []byte("kind: Deployment\n")); err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got := evaluated > 0; got != tt.wantEvaluated {
+				t.Errorf("Evaluate() ran conftest = %v, want %v", got, tt.wantEvaluated)
+			}
+		})
+	}
+}
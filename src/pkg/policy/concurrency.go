@@ -0,0 +1,53 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// forEachConcurrently calls fn for each index in [0, n) with at most limit calls running at once, one at a time
+// if limit is below 1. Once a call fails the remaining calls are cancelled, the error of the lowest failing index is
+// returned, preferring actual failures over the cancellation errors they caused
+func forEachConcurrently(ctx context.Context, n int, limit int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	sem := make(chan struct{}, max(limit, 1))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := fn(ctx, i); err != nil {
+				errs[i] = err
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var first error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return err
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestForEachConcurrently tests the concurrency bound and the error returned when a call fails
+func TestForEachConcurrently(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		failAt  int
+		wantMax int
+		wantErr string
+	}{
+		{name: "bounded", limit: 3, failAt: -1, wantMax: 3},
+		{name: "limit below 1 runs one at a time", limit: 0, failAt: -1, wantMax: 1},
+		{name: "first failure returned", limit: 2, failAt: 4, wantMax: 2, wantErr: "call 4 failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			inFlight, maxInFlight := 0, 0
+			err := forEachConcurrently(context.Background(), 10, tt.limit, func(ctx context.Context, i int) error {
+				mu.Lock()
+				inFlight++
+				maxInFlight = max(maxInFlight, inFlight)
+				mu.Unlock()
+				defer func() {
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}()
+
+				if i == tt.failAt {
+					return fmt.Errorf("call %d failed", i)
+				}
+				select {
+				case <-time.After(10 * time.Millisecond):
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			})
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("forEachConcurrently() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("forEachConcurrently() error = %v", err)
+			}
+			if maxInFlight > tt.wantMax {
+				t.Errorf("forEachConcurrently() ran %d calls at once, want at most %d", maxInFlight, tt.wantMax)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Concurrency tests that no more conftest calls than
+// the policy concurrency run at once and that results do not depend on the completion order
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Concurrency(t *testing.T) {
+	ids := []string{"ha", "pdb", "labels", "limits", "probes", "tls"}
+	config := "policies:\n"
+	for _, id := range ids {
+		config += fmt.Sprintf("  %s:\n    name: %s\n    type: opa\n    filePath: %s.rego\n    enforcement:\n      isBlockingAfter: 2020-01-01T00:00:00Z\n", id, id, id)
+	}
+	dir := newTestPoliciesDir(t, config)
+	for _, id := range ids[1:] {
+		// distinct sources so the evaluation cache does not share results between the policies
+		for name, content := range map[string]string{id + ".rego": testPolicyRego + "# " + id + "\n", id + "_test.rego": testPolicyTestRego} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	const limit = 2
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Concurrency: limit})
+	e.executor = &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			mu.Lock()
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+
+			// later policies complete first, the ha policy fails
+			policy := strings.TrimSuffix(filepath.Base(args[4]), ".rego")
+			for i, id := range ids {
+				if id == policy {
+					time.Sleep(time.Duration(len(ids)-i) * 5 * time.Millisecond)
+				}
+			}
+			if policy == "ha" {
+				return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`)}, fmt.Errorf("exit status 1")
+			}
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main"}]`)}, nil
+		},
+	}
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {Environment: "stg", BeforeManifest: []byte("base"), AfterManifest: []byte("head")},
+		},
+	}
+
+	got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+	if err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+	if maxInFlight > limit {
+		t.Errorf("GeneratePolicyEvalResultForManifests() ran %d conftest calls at once, want at most %d", maxInFlight, limit)
+	}
+	blocking := got.PolicyMatrix["stg"].BlockingPolicies
+	if len(blocking) != len(ids) {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() blocking policies = %d, want %d", len(blocking), len(ids))
+	}
+	for _, result := range blocking {
+		if wantPassing := result.PolicyId != "ha"; result.IsPassing != wantPassing {
+			t.Errorf("GeneratePolicyEvalResultForManifests() policy %s passing = %v, want %v", result.PolicyId, result.IsPassing, wantPassing)
+		}
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_FailFast tests that no further policy nor environment
+// is evaluated after the first blocking failure, in-flight evaluations being cancelled
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_FailFast(t *testing.T) {
+	// policies are evaluated in id order: a recommended failure does not stop, the blocking "c-ha" failure does
+	ids := []string{"a-labels", "b-pdb", "c-ha", "d-limits", "e-probes", "f-tls"}
+	config := "policies:\n"
+	for _, id := range ids {
+		enforcement := "isBlockingAfter"
+		if id == "a-labels" {
+			enforcement = "inEffectAfter"
+		}
+		config += fmt.Sprintf("  %s:\n    name: %s\n    type: opa\n    filePath: %s.rego\n    enforcement:\n      %s: 2020-01-01T00:00:00Z\n", id, id, id, enforcement)
+	}
+	dir := newTestPoliciesDir(t, config)
+	for _, id := range ids {
+		for name, content := range map[string]string{id + ".rego": testPolicyRego + "# " + id + "\n", id + "_test.rego": testPolicyTestRego} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name        string
+		failFast    bool
+		concurrency int
+		wantIds     []string
+	}{
+		{name: "disabled", concurrency: 1, wantIds: ids},
+		{name: "sequential", failFast: true, concurrency: 1, wantIds: []string{"a-labels", "b-pdb", "c-ha"}},
+		// "d-limits" starts along "c-ha" and is cancelled, "e-probes" and "f-tls" never start
+		{name: "concurrent", failFast: true, concurrency: 2, wantIds: []string{"a-labels", "b-pdb", "c-ha"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{FailFast: tt.failFast, Concurrency: tt.concurrency})
+			var mu sync.Mutex
+			called := map[string]bool{}
+			e.executor = &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					policy := strings.TrimSuffix(filepath.Base(args[4]), ".rego")
+					mu.Lock()
+					called[policy] = true
+					mu.Unlock()
+					switch policy {
+					case "a-labels", "c-ha":
+						return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"` + policy + ` fails"}]}]`)}, fmt.Errorf("exit status 1")
+					case "d-limits":
+						// slower than the failing policy evaluated along
+						select {
+						case <-ctx.Done():
+							return nil, fmt.Errorf("signal: killed")
+						case <-time.After(50 * time.Millisecond):
+						}
+					}
+					return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main"}]`)}, nil
+				},
+			}
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			build := models.BuildManifestResult{
+				EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+					"prod": {Environment: "prod", AfterManifest: []byte("kind: ConfigMap\n")},
+					"stg":  {Environment: "stg", AfterManifest: []byte("kind: Secret\n")},
+				},
+			}
+
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+			if got.StoppedAfterBlockingFailure != tt.failFast {
+				t.Errorf("GeneratePolicyEvalResultForManifests() StoppedAfterBlockingFailure = %v, want %v", got.StoppedAfterBlockingFailure, tt.failFast)
+			}
+			// without configured order environments are evaluated in name order, stg is not evaluated once prod stopped
+			if _, ok := got.PolicyMatrix["stg"]; ok == tt.failFast {
+				t.Errorf("GeneratePolicyEvalResultForManifests() stg evaluated = %v, want %v", ok, !tt.failFast)
+			}
+			if got.EnvironmentSummary["stg"].NotEvaluated != tt.failFast {
+				t.Errorf("GeneratePolicyEvalResultForManifests() stg NotEvaluated = %v, want %v", got.EnvironmentSummary["stg"].NotEvaluated, tt.failFast)
+			}
+			matrix := got.PolicyMatrix["prod"]
+			gotIds := []string{}
+			for _, results := range [][]models.PolicyResult{matrix.BlockingPolicies, matrix.RecommendPolicies} {
+				for _, result := range results {
+					gotIds = append(gotIds, result.PolicyId)
+				}
+			}
+			sort.Strings(gotIds)
+			if !reflect.DeepEqual(gotIds, tt.wantIds) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() prod policies = %v, want %v", gotIds, tt.wantIds)
+			}
+			if tt.failFast && (called["e-probes"] || called["f-tls"]) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() evaluated policies after the blocking failure: %v", called)
+			}
+			if !tt.failFast && got.EnvironmentSummary["prod"].PassingStatus.PassBlockingCheck {
+				t.Error("GeneratePolicyEvalResultForManifests() PassBlockingCheck = true, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// opaCoverageReport is the JSON coverage report of opa test --coverage
+type opaCoverageReport struct {
+	Files map[string]struct {
+		Coverage float64 `json:"coverage"`
+	} `json:"files"`
+	Coverage float64 `json:"coverage"`
+}
+
+// CheckCoverage runs the rego tests of every policy with opa test --coverage and fails if the coverage of
+// a policy file is below the MinCoverage option, listing every policy below it. No-op if MinCoverage is 0
+func (e *PolicyEvaluator) CheckCoverage(ctx context.Context) error {
+	if e.options.MinCoverage <= 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(e.data.fullPathToPolicy))
+	for id := range e.data.fullPathToPolicy {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	belowMin := []string{}
+	for _, id := range ids {
+		coverage, err := e.policyCoverage(ctx, e.data.fullPathToPolicy[id])
+		if err != nil {
+			return fmt.Errorf("policy %s: %w", id, err)
+		}
+		logger.WithField("policyId", id).WithField("coverage", coverage).Debug("Policy test coverage")
+		if coverage < e.options.MinCoverage {
+			belowMin = append(belowMin, fmt.Sprintf("%s (%.1f%%)", id, coverage))
+		}
+	}
+	if len(belowMin) > 0 {
+		return fmt.Errorf("policy test coverage below %.1f%%: %s", e.options.MinCoverage, strings.Join(belowMin, ", "))
+	}
+	return nil
+}
+
+// policyCoverage returns the coverage in percent of the policy file by its _test.rego file
+func (e *PolicyEvaluator) policyCoverage(ctx context.Context, policyPath string) (float64, error) {
+	testPath := strings.TrimSuffix(policyPath, ".rego") + "_test.rego"
+	result, err := e.executor.Run(ctx, "", "opa", "test", "--coverage", "--format=json", policyPath, testPath)
+	if err != nil {
+		if result != nil && len(result.Stderr) > 0 {
+			return 0, fmt.Errorf("opa test failed: %w\nStderr: %s", err, string(result.Stderr))
+		}
+		return 0, fmt.Errorf("opa test failed: %w", err)
+	}
+
+	var report opaCoverageReport
+	if err := json.Unmarshal(result.Stdout, &report); err != nil {
+		return 0, fmt.Errorf("failed to parse opa coverage report: %w", err)
+	}
+	// the test file is part of the report, only the coverage of the policy file counts
+	if file, ok := report.Files[policyPath]; ok {
+		return file.Coverage, nil
+	}
+	return report.Coverage, nil
+}
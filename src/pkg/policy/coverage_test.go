@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+)
+
+// TestPolicyEvaluator_CheckCoverage tests the coverage threshold against policies above and below it
+func TestPolicyEvaluator_CheckCoverage(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+  pdb:
+    name: Pod Disruption Budget
+    type: opa
+    filePath: pdb.rego
+`)
+	for name, content := range map[string]string{"pdb.rego": testPolicyRego, "pdb_test.rego": testPolicyTestRego} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	// the test files are fully covered, only the policy file coverage counts
+	coverageOf := map[string]float64{"ha.rego": 92.5, "pdb.rego": 60}
+	executor := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if name != "opa" || strings.Join(args[:3], " ") != "test --coverage --format=json" {
+				return nil, fmt.Errorf("unexpected command %s %v", name, args)
+			}
+			policyPath, testPath := args[3], args[4]
+			report := fmt.Sprintf(`{"files":{%q:{"coverage":%g},%q:{"coverage":100}},"coverage":80}`,
+				policyPath, coverageOf[filepath.Base(policyPath)], testPath)
+			return &command.Result{Stdout: []byte(report)}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		minCoverage float64
+		wantErr     string
+		wantCalls   int
+	}{
+		{name: "disabled", minCoverage: 0, wantCalls: 0},
+		{name: "every policy above", minCoverage: 60, wantCalls: 2},
+		{name: "policy below", minCoverage: 80, wantErr: "policy test coverage below 80.0%: pdb (60.0%)", wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{MinCoverage: tt.minCoverage})
+			fake := &testutil.FakeExecutor{Handler: executor.Handler}
+			e.SetExecutor(fake)
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+
+			err := e.CheckCoverage(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("CheckCoverage() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Errorf("CheckCoverage() error = %v", err)
+			}
+			if got := len(fake.Calls()); got != tt.wantCalls {
+				t.Errorf("CheckCoverage() ran opa %d times, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dataFileExtensions are the extensions of the files loaded from a policy's data directories
+var dataFileExtensions = map[string]bool{".json": true, ".yaml": true, ".yml": true}
+
+// resolveDataFiles expands the data paths of a policy, relative to policiesPath, into the list of data files
+// Files are kept in the listed order, directories are expanded recursively to their JSON/YAML files in lexical order
+func resolveDataFiles(policiesPath string, dataPaths []string) ([]string, error) {
+	files := []string{}
+	for _, dataPath := range dataPaths {
+		fullPath := filepath.Join(policiesPath, dataPath)
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("data path not found: %s", fullPath)
+		}
+		if !info.IsDir() {
+			files = append(files, fullPath)
+			continue
+		}
+
+		dirFiles := []string{}
+		err = filepath.WalkDir(fullPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && dataFileExtensions[strings.ToLower(filepath.Ext(path))] {
+				dirFiles = append(dirFiles, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list data directory %s: %w", fullPath, err)
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
+
+// mergeDataFiles merges the top-level keys of the data files into a single JSON document
+// Later files take precedence, the returned conflicts map each key defined by several files to these files
+func mergeDataFiles(files []string) ([]byte, map[string][]string, error) {
+	merged := make(map[string]any)
+	definedIn := make(map[string][]string)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read data file: %w", err)
+		}
+		// YAML is a superset of JSON, both are parsed the same way
+		data := make(map[string]any)
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse data file %s: %w", file, err)
+		}
+		for key, value := range data {
+			merged[key] = value
+			definedIn[key] = append(definedIn[key], file)
+		}
+	}
+
+	conflicts := make(map[string][]string)
+	for key, files := range definedIn {
+		if len(files) > 1 {
+			conflicts[key] = files
+		}
+	}
+
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to merge data files: %w", err)
+	}
+	return content, conflicts, nil
+}
@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// writeTestDataFiles writes the given files, relative to a new temp dir, returns the dir
+func writeTestDataFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir of %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestResolveDataFiles tests expanding files and directories into data files
+func TestResolveDataFiles(t *testing.T) {
+	dir := writeTestDataFiles(t, map[string]string{
+		"registries.yaml":     "registries: [ghcr.io]",
+		"data/teams/b.json":   `{"teamB": {}}`,
+		"data/teams/a.yml":    "teamA: {}",
+		"data/README.md":      "not data",
+		"data/zones.json":     `{"zones": []}`,
+		"other/ignored.json":  `{}`,
+		"other/nested/x.yaml": "x: 1",
+	})
+
+	got, err := resolveDataFiles(dir, []string{"registries.yaml", "data"})
+	if err != nil {
+		t.Fatalf("resolveDataFiles() error = %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "registries.yaml"),
+		filepath.Join(dir, "data/teams/a.yml"),
+		filepath.Join(dir, "data/teams/b.json"),
+		filepath.Join(dir, "data/zones.json"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveDataFiles() = %v, want %v", got, want)
+	}
+
+	if _, err := resolveDataFiles(dir, []string{"missing.json"}); err == nil || !strings.Contains(err.Error(), "data path not found") {
+		t.Errorf("resolveDataFiles() error = %v, want data path not found", err)
+	}
+}
+
+// TestMergeDataFiles tests merging data files with the later files taking precedence
+func TestMergeDataFiles(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         map[string]string
+		order         []string
+		want          map[string]any
+		wantConflicts map[string][]string
+	}{
+		{
+			name: "distinct keys are merged",
+			files: map[string]string{
+				"registries.yaml": "registries:\n- ghcr.io\n",
+				"teams.json":      `{"teams": {"payments": "team-a"}}`,
+			},
+			order: []string{"registries.yaml", "teams.json"},
+			want: map[string]any{
+				"registries": []any{"ghcr.io"},
+				"teams":      map[string]any{"payments": "team-a"},
+			},
+			wantConflicts: map[string][]string{},
+		},
+		{
+			name: "conflicting key is reported and the last file wins",
+			files: map[string]string{
+				"base.json":     `{"registries": ["docker.io"], "zones": ["a"]}`,
+				"override.yaml": "registries:\n- ghcr.io\n",
+			},
+			order: []string{"base.json", "override.yaml"},
+			want: map[string]any{
+				"registries": []any{"ghcr.io"},
+				"zones":      []any{"a"},
+			},
+			wantConflicts: map[string][]string{
+				"registries": {"base.json", "override.yaml"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestDataFiles(t, tt.files)
+			files := []string{}
+			for _, name := range tt.order {
+				files = append(files, filepath.Join(dir, name))
+			}
+
+			content, conflicts, err := mergeDataFiles(files)
+			if err != nil {
+				t.Fatalf("mergeDataFiles() error = %v", err)
+			}
+			got := map[string]any{}
+			if err := json.Unmarshal(content, &got); err != nil {
+				t.Fatalf("mergeDataFiles() returned invalid JSON: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeDataFiles() = %v, want %v", got, tt.want)
+			}
+			for key, conflictFiles := range conflicts {
+				for i := range conflictFiles {
+					conflictFiles[i] = filepath.Base(conflictFiles[i])
+				}
+				conflicts[key] = conflictFiles
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("mergeDataFiles() conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_Evaluate_DataPaths tests that the merged data is passed to conftest and conflicts are warned
+func TestPolicyEvaluator_Evaluate_DataPaths(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    dataPaths:
+    - data/base.json
+    - data/overrides
+`)
+	writeDataFiles := func(files map[string]string) {
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("failed to create dir of %s: %v", name, err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+	}
+	writeDataFiles(map[string]string{
+		"data/base.json":                 `{"registries": ["docker.io"], "teams": {"payments": "team-a"}}`,
+		"data/overrides/registries.yaml": "registries:\n- ghcr.io\n",
+	})
+
+	var gotData []byte
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			for i, arg := range args {
+				if arg == "--data" {
+					content, err := os.ReadFile(args[i+1])
+					if err != nil {
+						return nil, err
+					}
+					gotData = content
+				}
+			}
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[]}]`)}, nil
+		},
+	}
+
+	hook := logtest.NewLocal(logger.Logger)
+	e := NewPolicyEvaluator(dir)
+	e.executor = fake
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	warned := false
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.WarnLevel && strings.Contains(entry.Message, "Data key registries is defined in multiple files") {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("LoadAndValidate() should warn about the conflicting registries key")
+	}
+
+	if _, err := e.Evaluate(context.Background(), []byte("kind: Deployment\n")); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	got := map[string]any{}
+	if err := json.Unmarshal(gotData, &got); err != nil {
+		t.Fatalf("conftest --data file is not valid JSON: %v", err)
+	}
+	want := map[string]any{
+		"registries": []any{"ghcr.io"},
+		"teams":      map[string]any{"payments": "team-a"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("conftest --data = %v, want %v", got, want)
+	}
+
+	// editing the data invalidates the cached result
+	calls := len(fake.Calls())
+	writeDataFiles(map[string]string{"data/overrides/registries.yaml": "registries:\n- quay.io\n"})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	if _, err := e.Evaluate(context.Background(), []byte("kind: Deployment\n")); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(fake.Calls()) != calls+1 {
+		t.Errorf("Evaluate() ran conftest %d times after a data change, want 1", len(fake.Calls())-calls)
+	}
+}
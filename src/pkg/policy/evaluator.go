@@ -1,15 +1,24 @@
 package policy
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/logctx"
+	manifestpkg "github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
 	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
 	"gopkg.in/yaml.v2"
 
@@ -22,8 +31,31 @@ var logger *log.Entry = log.New().WithFields(log.Fields{
 
 const (
 	COMPLIANCE_CONFIG_FILENAME = "compliance-config.yaml"
+
+	POLICY_BACKEND_CONFTEST   = "conftest"   // evaluate with the conftest CLI
+	POLICY_BACKEND_OPA_SERVER = "opa-server" // evaluate with a running OPA server's REST data API
+
+	POLICY_SCOPE_ANY        = "any"        // evaluate against every document, the default
+	POLICY_SCOPE_NAMESPACED = "namespaced" // evaluate against namespaced resources only
+	POLICY_SCOPE_CLUSTER    = "cluster"    // evaluate against cluster-scoped resources only
+
+	// Rego sources longer than this are truncated in the report to keep the PR comment readable
+	POLICY_SOURCE_MAX_LENGTH = 5_000
+
+	// Default number of policies evaluated at once, conftest calls are CPU/IO heavy for small CI runners
+	POLICY_CONCURRENCY_DEFAULT = 4
 )
 
+// overrideCmdPattern is the accepted shape of an override command, e.g. "/sp-override-ha"
+// A strict shape avoids fragile matching against free-form PR comments
+var overrideCmdPattern = regexp.MustCompile(`^/[a-z0-9-]+$`)
+
+// testRulePattern matches the head of a rego test rule, e.g. "test_deny_deployment if {" or "test_x {"
+var testRulePattern = regexp.MustCompile(`(?m)^[ \t]*test_[A-Za-z0-9_]*\s*(if\b|\{|:?=|contains\b)`)
+
+// errEvaluationStopped cancels the policies left to evaluate once the evaluation is stopped, see evaluatePolicies
+var errEvaluationStopped = errors.New("policy evaluation stopped")
+
 // // PolicyEvaluator defines the interface for policy evaluation operations
 // type PolicyEvaluator interface {
 // 	// LoadAndValidate loads and validates the compliance configuration
@@ -43,7 +75,7 @@ type PolicyEvaluatorInterface interface {
 	GeneratePolicyEvalResultForManifests(
 		ctx context.Context,
 		envManifests map[string][]byte,
-		ghComments []string,
+		ghComments []*models.Comment,
 	) (*models.PolicyEvaluation, error)
 }
 
@@ -65,22 +97,156 @@ type EvaluatorData struct {
 
 	// enforcements levels of policies Ids
 	overrideCmdToPolicyId map[string]string
+	snoozeCmdToPolicyId   map[string]string
+
+	// parsed fail message templates of policies Ids, only set when configured
+	messageTemplateOfPolicy map[string]*template.Template
+
+	// rego package of policies Ids, only set with the opa-server backend
+	regoPackageOfPolicy map[string]string
+
+	// merged JSON data of policies Ids, only set when dataPaths are configured
+	dataOfPolicy map[string][]byte
+
+	// fail messages of the baseline report, only set when configured
+	baseline baselineViolations
+}
+
+// EvaluatorOptions holds the optional settings of PolicyEvaluator
+type EvaluatorOptions struct {
+	// Directory to persist policy evaluation results across runs, in-memory caching only if empty
+	// or with the opa-server backend
+	CacheDir string
+	// Include the rego source of failing policies in the evaluation result
+	ShowPolicySource bool
+	// Also evaluate the before (base) manifest, to tell pre-existing violations from ones introduced by the PR
+	RequireCleanBase bool
+	// Also evaluate the before (base) manifest, to report its violations resolved by the PR
+	ReportFixed bool
+	// Policy evaluation backend: POLICY_BACKEND_CONFTEST (default if empty) or POLICY_BACKEND_OPA_SERVER
+	Backend string
+	// Base URL of the OPA server, e.g. http://localhost:8181, required by the opa-server backend
+	OpaURL string
+	// Treat an empty conftest result (no document to check), or a missing OPA server result, as a pass instead of an error
+	EmptyResultsAsPass bool
+	// Optional compliance-config.yaml of the service, merged over the global one, ignored if the file does not exist
+	ServiceConfigPath string
+	// Evaluate policies of distinct rego packages in one conftest call per batch instead of one call per policy,
+	// policies with external data are still evaluated one by one
+	BatchConftest bool
+	// Prefix of the manifest/data temp file names passed to conftest, e.g. "gitops-kustomz-my-app-1234-"
+	TempPrefix string
+	// Stop evaluating policies after the first failing policy of BLOCK level (exemptions aside), cancelling
+	// in-flight evaluations. Environments are evaluated in their configured order, policies not evaluated yet are
+	// left out of the results and environments not evaluated yet are marked NotEvaluated in EnvironmentSummary,
+	// without a PolicyMatrix entry
+	FailFast bool
+	// Maximum number of policies evaluated at once (conftest calls or OPA server queries), one at a time if below 1.
+	// Batched conftest calls are run one after the other
+	Concurrency int
+	// Take the enforcement level of failing policies from their rego rules: deny/violation block, warn warns,
+	// unless a severity is set in the result metadata. Overridden policies and policies not in effect keep their level
+	UseRegoSeverity bool
+	// Minimum rego test coverage in percent of every policy, checked with opa test --coverage by CheckCoverage,
+	// not checked if 0
+	MinCoverage float64
+	// report.json of a previous run: failing policies whose fail messages are all in it count as overridden
+	// with BASELINE_REASON, so only new violations gate. Not used if empty
+	BaselinePath string
 }
 
 type PolicyEvaluator struct {
 	policiesPath string
+	options      EvaluatorOptions
 	data         EvaluatorData
+	cache        *evalCache
+	executor     command.CommandExecutor
+	httpClient   *http.Client
+
+	// installed conftest version, detected once
+	conftestVersion string
+
+	// clock returns the current time, used to determine enforcement levels
+	clock func() time.Time
 }
 
 func NewPolicyEvaluator(policiesPath string) *PolicyEvaluator {
-	return &PolicyEvaluator{
+	return NewPolicyEvaluatorWithOptions(policiesPath, EvaluatorOptions{})
+}
+
+func NewPolicyEvaluatorWithOptions(policiesPath string, options EvaluatorOptions) *PolicyEvaluator {
+	cacheDir := options.CacheDir
+	if cacheDir != "" && options.Backend == POLICY_BACKEND_OPA_SERVER {
+		// decisions come from the policies loaded in the OPA server, which can change between runs
+		// without the local rego files the cache keys are computed from
+		logger.WithField("cacheDir", cacheDir).Warn("Policy cache directory ignored with the opa-server backend, caching in memory only")
+		cacheDir = ""
+	}
+	e := &PolicyEvaluator{
 		policiesPath: policiesPath,
+		options:      options,
+		cache:        newEvalCache(cacheDir),
+		executor:     command.NewExecutor(),
+		httpClient:   &http.Client{Timeout: 30 * time.Second},
+		clock:        time.Now,
 		data: EvaluatorData{
 			fullPathToPolicy:      make(map[string]string),
 			evalFailMsgOfPolicy:   make(map[string][]string),
 			overrideCmdToPolicyId: make(map[string]string),
+			snoozeCmdToPolicyId:   make(map[string]string),
+
+			messageTemplateOfPolicy: make(map[string]*template.Template),
+			regoPackageOfPolicy:     make(map[string]string),
+			dataOfPolicy:            make(map[string][]byte),
 		},
 	}
+	if options.UseRegoSeverity {
+		// messages tagged with their severity must not be mixed up with untagged ones of other runs
+		e.cache.setVariant("rego-severity")
+	}
+	return e
+}
+
+// SetClock overrides the clock used to determine enforcement levels, mainly for tests
+func (e *PolicyEvaluator) SetClock(clock func() time.Time) {
+	e.clock = clock
+}
+
+// SetExecutor overrides the executor running conftest, mainly for tests
+func (e *PolicyEvaluator) SetExecutor(executor command.CommandExecutor) {
+	e.executor = executor
+}
+
+// ConftestVersion returns the installed conftest version, e.g. "0.56.0", conftest is only called the first time
+func (e *PolicyEvaluator) ConftestVersion(ctx context.Context) (string, error) {
+	if e.conftestVersion != "" {
+		return e.conftestVersion, nil
+	}
+	result, err := e.executor.Run(ctx, "", "conftest", "--version")
+	if err != nil {
+		return "", fmt.Errorf("failed to get conftest version: %w", err)
+	}
+	version, err := command.ParseVersion(string(result.Stdout))
+	if err != nil {
+		return "", err
+	}
+	e.conftestVersion = version
+	return version, nil
+}
+
+// DetectCacheVersion includes the conftest version in the evaluation cache keys, so cached results
+// are not reused across a conftest upgrade. No-op with the opa-server backend, whose results are not persisted
+func (e *PolicyEvaluator) DetectCacheVersion(ctx context.Context) error {
+	if e.options.Backend == POLICY_BACKEND_OPA_SERVER {
+		return nil
+	}
+	version, err := e.ConftestVersion(ctx)
+	if err != nil {
+		return err
+	}
+	e.cache.setVersion("conftest " + version)
+	logger.WithField("version", version).Debug("Policy evaluation cache keyed by conftest version")
+	return nil
 }
 
 // LoadAndValidate loads and validates the compliance configuration
@@ -118,34 +284,157 @@ func (e *PolicyEvaluator) LoadAndValidate() error {
 		if _, err := os.Stat(testPath); os.IsNotExist(err) {
 			return fmt.Errorf("each policy must have testpolicy %s: test file not found: %s", id, testPath)
 		}
+		if err := checkTestRules(testPath); err != nil {
+			return fmt.Errorf("policy %s: %w", id, err)
+		}
 
 		// Set full path to policy file
 		e.data.fullPathToPolicy[id] = policyPath
 
+		// resolve the rego package to query on the OPA server, or to attribute batched conftest results
+		if e.options.Backend == POLICY_BACKEND_OPA_SERVER || e.options.BatchConftest {
+			regoPackage, err := regoPackageOf(policyPath)
+			if err != nil {
+				return fmt.Errorf("policy %s: %w", id, err)
+			}
+			e.data.regoPackageOfPolicy[id] = regoPackage
+		}
+
+		// merge external data files
+		if len(policy.DataPaths) > 0 {
+			if err := e.loadPolicyData(id, policy.DataPaths); err != nil {
+				return fmt.Errorf("policy %s: %w", id, err)
+			}
+		}
+
+		// parse fail message template
+		if policy.MessageTemplate != "" {
+			tmpl, err := template.New(id).Option("missingkey=error").Parse(policy.MessageTemplate)
+			if err != nil {
+				return fmt.Errorf("policy %s: invalid messageTemplate: %w", id, err)
+			}
+			e.data.messageTemplateOfPolicy[id] = tmpl
+		}
+
+		// check snooze cmd
+		if snooze := policy.Enforcement.Override.Snooze; snooze != "" {
+			_, isOverride := e.data.overrideCmdToPolicyId[snooze]
+			if _, ok := e.data.snoozeCmdToPolicyId[snooze]; ok || isOverride {
+				return fmt.Errorf("policy %s: use another command, this snooze command already exists: %s", id, snooze)
+			}
+			e.data.snoozeCmdToPolicyId[snooze] = id
+		}
+
 		// check override cmd
 		if policy.Enforcement.Override.Comment == "" {
 			continue
 		}
-		if _, ok := e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment]; ok {
+		_, isSnooze := e.data.snoozeCmdToPolicyId[policy.Enforcement.Override.Comment]
+		if _, ok := e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment]; ok || isSnooze {
 			return fmt.Errorf("policy %s: use another command, this override command already exists: %s", id, policy.Enforcement.Override.Comment)
 		}
 		e.data.overrideCmdToPolicyId[policy.Enforcement.Override.Comment] = id
 	}
 
+	if e.options.BaselinePath != "" {
+		baseline, err := loadBaseline(e.options.BaselinePath)
+		if err != nil {
+			return err
+		}
+		e.data.baseline = baseline
+	}
+
 	logger.Infof("LoadAndValidate: done, loaded %d policies.", len(e.data.ComplianceConfig.Policies))
 	return nil
 }
 
+// checkTestRules checks that the test file defines at least one test_ rule, so an empty test file or one with only
+// helper rules does not pass for a tested policy
+func checkTestRules(testPath string) error {
+	content, err := os.ReadFile(testPath)
+	if err != nil {
+		return fmt.Errorf("failed to read test file: %w", err)
+	}
+	if !testRulePattern.Match(content) {
+		return fmt.Errorf("test file %s has no test rule, define at least one rule named test_<name>", testPath)
+	}
+	return nil
+}
+
+// loadPolicyData merges the data files of a policy, warning about top-level keys defined by several files
+func (e *PolicyEvaluator) loadPolicyData(id string, dataPaths []string) error {
+	if e.options.Backend == POLICY_BACKEND_OPA_SERVER {
+		logger.WithField("policyId", id).Warn("dataPaths are ignored by the opa-server backend, load the data on the OPA server instead")
+		return nil
+	}
+
+	files, err := resolveDataFiles(e.policiesPath, dataPaths)
+	if err != nil {
+		return err
+	}
+	data, conflicts, err := mergeDataFiles(files)
+	if err != nil {
+		return err
+	}
+	for key, files := range conflicts {
+		logger.WithField("policyId", id).WithField("key", key).WithField("files", files).
+			Warnf("Data key %s is defined in multiple files, the last one takes precedence", key)
+	}
+	e.data.dataOfPolicy[id] = data
+	return nil
+}
+
 // LoadComplianceConfig loads the compliance configuration from a YAML file
 func (e *PolicyEvaluator) loadComplianceConfig() error {
 	configPath := filepath.Join(e.policiesPath, COMPLIANCE_CONFIG_FILENAME)
 	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		// the policies directory is likely wrong, or was never set up
+		return fmt.Errorf("compliance config not found at %s: point --policies-path to the directory holding %s, "+
+			"or run `gitops-kustomz init` to scaffold one", configPath, COMPLIANCE_CONFIG_FILENAME)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to read compliance config: %w", err)
+		return fmt.Errorf("failed to read compliance config %s: %w", configPath, err)
 	}
 
 	if err := yaml.Unmarshal(data, &e.data.ComplianceConfig); err != nil {
-		return fmt.Errorf("failed to parse compliance config: %w", err)
+		// the file is found but invalid, fixing it is up to its maintainers
+		return fmt.Errorf("failed to parse compliance config %s: %w: fix its YAML syntax, "+
+			"`gitops-kustomz config-schema` prints the expected structure", configPath, err)
+	}
+	return e.loadServiceComplianceConfig()
+}
+
+// loadServiceComplianceConfig merges the optional service compliance config into the global one,
+// the service policies win on conflicting policy ids. Their filePath stays relative to the policies directory
+func (e *PolicyEvaluator) loadServiceComplianceConfig() error {
+	if e.options.ServiceConfigPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(e.options.ServiceConfigPath)
+	if os.IsNotExist(err) {
+		logger.WithField("path", e.options.ServiceConfigPath).Debug("No service compliance config")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read service compliance config: %w", err)
+	}
+
+	serviceConfig := models.ComplianceConfig{}
+	if err := yaml.Unmarshal(data, &serviceConfig); err != nil {
+		return fmt.Errorf("failed to parse service compliance config %s: %w", e.options.ServiceConfigPath, err)
+	}
+	if e.data.ComplianceConfig.Policies == nil {
+		e.data.ComplianceConfig.Policies = make(map[string]models.PolicyConfig)
+	}
+	for id, policy := range serviceConfig.Policies {
+		lg := logger.WithField("policyId", id).WithField("path", e.options.ServiceConfigPath)
+		if _, ok := e.data.ComplianceConfig.Policies[id]; ok {
+			lg.Info("Service compliance config overrides the global policy")
+		} else {
+			lg.Info("Service compliance config adds a policy")
+		}
+		e.data.ComplianceConfig.Policies[id] = policy
 	}
 	return nil
 }
@@ -153,7 +442,7 @@ func (e *PolicyEvaluator) loadComplianceConfig() error {
 // ValidateComplianceConfig validates the common fields
 func (e *PolicyEvaluator) validateComplianceConfig() error {
 	if len(e.data.ComplianceConfig.Policies) == 0 {
-		return fmt.Errorf("no policies defined in compliance config")
+		return fmt.Errorf("no policies defined in compliance config %s", filepath.Join(e.policiesPath, COMPLIANCE_CONFIG_FILENAME))
 	}
 
 	for id, policy := range e.data.ComplianceConfig.Policies {
@@ -169,23 +458,46 @@ func (e *PolicyEvaluator) validateComplianceConfig() error {
 		if policy.FilePath == "" {
 			return fmt.Errorf("policy %s: filePath is required", id)
 		}
+		switch policy.Scope {
+		case "", POLICY_SCOPE_ANY, POLICY_SCOPE_NAMESPACED, POLICY_SCOPE_CLUSTER:
+		default:
+			return fmt.Errorf("policy %s: unsupported scope %s (must be one of %s, %s, %s)",
+				id, policy.Scope, POLICY_SCOPE_NAMESPACED, POLICY_SCOPE_CLUSTER, POLICY_SCOPE_ANY)
+		}
 
-		// Validate enforcement dates are in order if set
-		if policy.Enforcement.InEffectAfter != nil && policy.Enforcement.IsWarningAfter != nil {
-			if policy.Enforcement.IsWarningAfter.Before(*policy.Enforcement.InEffectAfter) {
-				return fmt.Errorf("policy %s: isWarningAfter cannot be before inEffectAfter", id)
-			}
+		// Validate enforcement dates are in order if set, globally and once merged with each environment's dates
+		if err := checkEnforcementDates(policy.Enforcement); err != nil {
+			return fmt.Errorf("policy %s: %w", id, err)
 		}
-		if policy.Enforcement.IsWarningAfter != nil && policy.Enforcement.IsBlockingAfter != nil {
-			if policy.Enforcement.IsBlockingAfter.Before(*policy.Enforcement.IsWarningAfter) {
-				return fmt.Errorf("policy %s: isBlockingAfter cannot be before isWarningAfter", id)
+		for env := range policy.Enforcement.Environments {
+			if err := checkEnforcementDates(enforcementForEnv(policy.Enforcement, env)); err != nil {
+				return fmt.Errorf("policy %s: environment %s: %w", id, env, err)
 			}
 		}
 
+		if policy.Enforcement.Override.RequiredApprovals < 0 {
+			return fmt.Errorf("policy %s: override requiredApprovals cannot be negative", id)
+		}
+		if policy.Enforcement.Override.RequiredApprovals > 1 && policy.Enforcement.Override.Comment == "" {
+			return fmt.Errorf("policy %s: override requiredApprovals requires an override comment", id)
+		}
+
 		// override comment not too long
 		if policy.Enforcement.Override.Comment != "" && len(policy.Enforcement.Override.Comment) > 255 {
 			return fmt.Errorf("policy %s: override comment is too long (max 255 characters)", id)
 		}
+
+		// override comment must be a slash command without spaces, e.g. "/sp-override-ha"
+		if policy.Enforcement.Override.Comment != "" && !overrideCmdPattern.MatchString(policy.Enforcement.Override.Comment) {
+			return fmt.Errorf("policy %s: invalid override comment %q (must match %s, e.g. \"/sp-override-ha\")",
+				id, policy.Enforcement.Override.Comment, overrideCmdPattern.String())
+		}
+
+		// snooze comment has the shape of an override comment, its duration follows it, e.g. "/sp-snooze-ha 7d"
+		if snooze := policy.Enforcement.Override.Snooze; snooze != "" && (len(snooze) > 255 || !overrideCmdPattern.MatchString(snooze)) {
+			return fmt.Errorf("policy %s: invalid snooze comment %q (must match %s, e.g. \"/sp-snooze-ha\")",
+				id, policy.Enforcement.Override.Snooze, overrideCmdPattern.String())
+		}
 	}
 
 	return nil
@@ -194,77 +506,214 @@ func (e *PolicyEvaluator) validateComplianceConfig() error {
 func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 	ctx context.Context,
 	build models.BuildManifestResult,
-	ghComments []string,
+	ghComments []*models.Comment,
 ) (
 	*models.PolicyEvaluation,
 	error,
 ) {
-	logger.Info("GeneratePolicyEvalResultForManifests: starting...")
+	logctx.Entry(ctx, logger).Info("GeneratePolicyEvalResultForManifests: starting...")
 
 	envToPolicyIdToResult := make(map[string]map[string]models.PolicyResult)
 	envManifests := build.EnvManifestBuild
 
-	// 1. Evaluate policies for each environment and store results (can goroutine)
+	envs := evaluationOrder(build)
+
+	// 1. Get EnforcementLevel of each environment, needed upfront to stop on the first blocking failure with fail-fast
+	envToPolicyIdToEnforcementLevel := make(map[string]map[string]string, len(envs))
+	for _, env := range envs {
+		levels, err := e.DetermineEnforcementLevel(ghComments, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine enforcement level for environment %s: %w", env, err)
+		}
+		envToPolicyIdToEnforcementLevel[env] = levels
+	}
+	policyIdToOverrideAuthors := e.overrideAuthors(ghComments)
+	// a snooze is reported on the policies it overrides, an override without expiry takes precedence
+	policyIdToSnooze := e.activeSnoozes(ghComments, e.clock())
+	for policyId := range e.overriddenPolicies(ghComments) {
+		delete(policyIdToSnooze, policyId)
+	}
+
+	// 2. Evaluate policies for each environment in the configured order and store results
 	complianceCfg := e.data.ComplianceConfig
-	for env, manifest := range envManifests {
-		logger.WithField("env", env).Info("Evaluating policies for environment")
+	stopped := false
+	for _, env := range envs {
+		// the logs of the evaluation, concurrent per policy, carry the environment
+		ctx := logctx.WithFields(ctx, log.Fields{"environment": env})
+		var stopOn func(id string, failMsgs []string) bool
+		if e.options.FailFast {
+			policyIdToEnforcementLevel := envToPolicyIdToEnforcementLevel[env]
+			stopOn = func(id string, failMsgs []string) bool {
+				return isBlockingFailure(policyIdToEnforcementLevel[id], failMsgs)
+			}
+		}
+		manifest, err := manifestsToYAML(envManifests[env])
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate policy for environment %s: %w", env, err)
+		}
+		logctx.Entry(ctx, logger).Info("Evaluating policies for environment")
 		policyIdToResult := make(map[string]models.PolicyResult)
 
-		failMsgs, err := e.Evaluate(ctx, manifest.AfterManifest)
+		var failMsgs map[string][]string
+		var evalErrors map[string]error
+		failMsgs, evalErrors, stopped, err = e.evaluateWithSeverity(ctx, manifest.AfterManifest, stopOn)
 		if err != nil {
 			return nil, fmt.Errorf("failed to evaluate policy for environment %s: %w", env, err)
 		}
+		// a policy that could not be evaluated is reported apart from the violations and fails the blocking check
+		for policyId, evalErr := range evalErrors {
+			policy := complianceCfg.Policies[policyId]
+			policyIdToResult[policyId] = models.PolicyResult{
+				PolicyId:     policyId,
+				PolicyName:   policy.Name,
+				ExternalLink: policy.ExternalLink,
+				FailMessages: []string{},
+				Error:        evalErr.Error(),
+			}
+		}
+
+		var baseFailMsgs map[string][]string
+		if (e.options.RequireCleanBase || e.options.ReportFixed) && len(manifest.BeforeManifest) > 0 {
+			// only the policies evaluated on the head, all of them unless stopped
+			evaluatedIds := make([]string, 0, len(failMsgs))
+			for id := range failMsgs {
+				evaluatedIds = append(evaluatedIds, id)
+			}
+			sort.Strings(evaluatedIds)
+			var baseErrors map[string]error
+			baseFailMsgs, baseErrors, _, err = e.evaluatePolicies(ctx, manifest.BeforeManifest, evaluatedIds, nil)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate policy on base for environment %s: %w", env, err)
+			}
+			for policyId, baseErr := range baseErrors {
+				// the head result is still reported, without comparison to the base
+				logctx.Entry(ctx, logger).WithField("policyId", policyId).WithError(baseErr).Warn("Failed to evaluate policy on base")
+			}
+		}
 
 		for policyId, failMsgs := range failMsgs {
-			logger.WithField("policyId", policyId).WithField("failMsgs", failMsgs).Debug("Evaluated policy")
+			logctx.Entry(ctx, logger).WithField("policyId", policyId).WithField("failMsgs", failMsgs).Debug("Evaluated policy")
 			policy := complianceCfg.Policies[policyId]
+			severity, failMsgs := splitSeverity(failMsgs)
+			failMsgs, err := e.formatFailMessages(policyId, failMsgs)
+			if err != nil {
+				return nil, err
+			}
 			polResult := models.PolicyResult{
 				PolicyId:     policyId,
 				PolicyName:   policy.Name,
 				ExternalLink: policy.ExternalLink,
 				IsPassing:    len(failMsgs) == 0,
 				FailMessages: failMsgs,
+				Severity:     severity,
+			}
+			if baseFailMsgs != nil {
+				_, baseMsgs := splitSeverity(baseFailMsgs[policyId])
+				baseMsgs, err := e.formatFailMessages(policyId, baseMsgs)
+				if err != nil {
+					return nil, err
+				}
+				if e.options.RequireCleanBase {
+					polResult.PreExistingFailMessages, polResult.NewFailMessages = splitPreExisting(failMsgs, baseMsgs)
+					polResult.IsFailingOnBase = len(baseMsgs) > 0
+				}
+				if e.options.ReportFixed {
+					polResult.FixedFailMessages = fixedMessages(failMsgs, baseMsgs)
+				}
+			}
+			if !polResult.IsPassing {
+				polResult.OverrideReason, err = e.exemptionReason(ctx, policyId, manifest.AfterManifest)
+				if err != nil {
+					return nil, fmt.Errorf("failed to evaluate exemptions for environment %s: %w", env, err)
+				}
+			}
+			if !polResult.IsPassing && e.data.baseline != nil {
+				// pre-existing violations are tolerated, a single new one gates the policy normally
+				polResult.BaselineFailMessages = e.data.baseline.inBaseline(env, policyId, failMsgs)
+				if len(polResult.BaselineFailMessages) == len(failMsgs) && polResult.OverrideReason == "" {
+					polResult.OverrideReason = BASELINE_REASON
+				}
 			}
 			policyIdToResult[policyId] = polResult
 		}
 
 		envToPolicyIdToResult[env] = policyIdToResult
+		if stopped {
+			logctx.Entry(ctx, logger).Warn("Policy evaluation stopped after first blocking failure")
+			break
+		}
 	}
 
-	// 2. Get EnforcementLevel (can goroutine)
-	policyIdToEnforcementLevel, err := e.DetermineEnforcementLevel(ghComments)
+	policySources, err := e.failingPolicySources(envToPolicyIdToResult)
 	if err != nil {
-		return nil, fmt.Errorf("failed to determine enforcement level: %w", err)
+		return nil, err
 	}
 
-	// 3. Crafting PolicyEvaluation
+	// 3. Crafting PolicyEvaluation, environments left unevaluated after a fail-fast stop have no policy matrix
 	results := models.PolicyEvaluation{
-		EnvironmentSummary: make(map[string]models.EnvironmentSummaryEnv),
-		PolicyMatrix:       make(map[string]models.PolicyMatrix),
+		EnvironmentSummary:          make(map[string]models.EnvironmentSummaryEnv),
+		PolicyMatrix:                make(map[string]models.PolicyMatrix),
+		PolicySources:               policySources,
+		StoppedAfterBlockingFailure: stopped,
+	}
+	for _, env := range envs {
+		if _, ok := envToPolicyIdToResult[env]; !ok {
+			results.EnvironmentSummary[env] = models.EnvironmentSummaryEnv{NotEvaluated: true}
+		}
 	}
-	for env := range envManifests {
-		logger.WithField("env", env).Info("Crafting policy evaluation for environment")
+	for env := range envToPolicyIdToResult {
+		logctx.Entry(ctx, logger).WithField("environment", env).Info("Crafting policy evaluation for environment")
 
-		totalCnt, failedCnt, omittedCnt, successCnt := 0, 0, 0, 0
+		totalCnt, failedCnt, omittedCnt, successCnt, erroredCnt := 0, 0, 0, 0, 0
 		blockingSuccessCnt, warningSuccessCnt, recommendSuccessCnt, overriddenSuccessCnt, notInEffectSuccessCnt := 0, 0, 0, 0, 0
 		blockingFailedCnt, warningFailedCnt, recommendFailedCnt, overriddenFailedCnt, notInEffectFailedCnt := 0, 0, 0, 0, 0
+		baseFailsBlocking := false
 
 		blockingPolicies := []models.PolicyResult{}
 		warningPolicies := []models.PolicyResult{}
 		recommendPolicies := []models.PolicyResult{}
 		overriddenPolicies := []models.PolicyResult{}
 		notInEffectPolicies := []models.PolicyResult{}
+		var fixedPolicies, erroredPolicies []models.PolicyResult
 		for policyId, result := range envToPolicyIdToResult[env] {
 			totalCnt++
+			if result.Error != "" {
+				erroredPolicies = append(erroredPolicies, result)
+				erroredCnt++
+				continue
+			}
+			if len(result.FixedFailMessages) > 0 {
+				fixedPolicies = append(fixedPolicies, result)
+			}
 			if result.IsPassing {
 				successCnt++
 			}
 
-			enforcementLevel := policyIdToEnforcementLevel[policyId]
+			if required := complianceCfg.Policies[policyId].Enforcement.Override.RequiredApprovals; required > 1 {
+				result.OverrideApprovals = len(policyIdToOverrideAuthors[policyId])
+				result.RequiredApprovals = required
+			}
+			if snooze, ok := policyIdToSnooze[policyId]; ok {
+				result.Snooze = &snooze
+			}
+
+			// a failing policy exempted on this environment stays at its level but counts as overridden
+			exempted := !result.IsPassing && result.OverrideReason != ""
+
+			enforcementLevel := envToPolicyIdToEnforcementLevel[env][policyId]
+			if result.Severity != POLICY_LEVEL_UNKNOWN && regoSeverityApplies(enforcementLevel) {
+				enforcementLevel = result.Severity
+			}
 			switch enforcementLevel {
 			case POLICY_LEVEL_BLOCK:
 				blockingPolicies = append(blockingPolicies, result)
-				if !result.IsPassing {
+				if result.IsFailingOnBase {
+					baseFailsBlocking = true
+				}
+				if exempted {
+					overriddenFailedCnt++
+					omittedCnt++
+				} else if !result.IsPassing {
 					blockingFailedCnt++
 					failedCnt++
 				} else {
@@ -272,7 +721,10 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 				}
 			case POLICY_LEVEL_WARNING:
 				warningPolicies = append(warningPolicies, result)
-				if !result.IsPassing {
+				if exempted {
+					overriddenFailedCnt++
+					omittedCnt++
+				} else if !result.IsPassing {
 					warningFailedCnt++
 					failedCnt++
 				} else {
@@ -280,7 +732,10 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 				}
 			case POLICY_LEVEL_RECOMMEND:
 				recommendPolicies = append(recommendPolicies, result)
-				if !result.IsPassing {
+				if exempted {
+					overriddenFailedCnt++
+					omittedCnt++
+				} else if !result.IsPassing {
 					recommendFailedCnt++
 					failedCnt++
 				} else {
@@ -306,17 +761,21 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 				logger.Warnf("policy %s: unknown enforcement level: %s", policyId, enforcementLevel)
 			}
 		}
+		sort.Slice(erroredPolicies, func(i, j int) bool { return erroredPolicies[i].PolicyId < erroredPolicies[j].PolicyId })
 		results.PolicyMatrix[env] = models.PolicyMatrix{
 			BlockingPolicies:    blockingPolicies,
 			WarningPolicies:     warningPolicies,
 			RecommendPolicies:   recommendPolicies,
 			OverriddenPolicies:  overriddenPolicies,
 			NotInEffectPolicies: notInEffectPolicies,
+			FixedPolicies:       fixedPolicies,
+			ErroredPolicies:     erroredPolicies,
 		}
 
 		results.EnvironmentSummary[env] = models.EnvironmentSummaryEnv{
+			BaseFailsBlockingCheck: baseFailsBlocking,
 			PassingStatus: models.EnforcementPassingStatus{
-				PassBlockingCheck:  blockingFailedCnt == 0,
+				PassBlockingCheck:  blockingFailedCnt == 0 && erroredCnt == 0,
 				PassWarningCheck:   warningFailedCnt == 0,
 				PassRecommendCheck: recommendFailedCnt == 0,
 			},
@@ -327,6 +786,7 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 				TotalOmitted:        omittedCnt,
 				TotalOmittedFailed:  overriddenFailedCnt + notInEffectFailedCnt,
 				TotalOmittedSuccess: overriddenSuccessCnt + notInEffectSuccessCnt,
+				TotalErrored:        erroredCnt,
 
 				BlockingSuccessCount:    blockingSuccessCnt,
 				BlockingFailedCount:     blockingFailedCnt,
@@ -345,68 +805,447 @@ func (e *PolicyEvaluator) GeneratePolicyEvalResultForManifests(
 	return &results, nil
 }
 
-// Evaluate evaluates all policies against the manifest using conftest and store the evaluation results in the EvaluatorData
+// evaluationOrder returns the environments of the build in the configured order,
+// followed by the built environments missing from it in name order
+func evaluationOrder(build models.BuildManifestResult) []string {
+	envs := make([]string, 0, len(build.EnvManifestBuild))
+	seen := make(map[string]bool, len(build.EnvManifestBuild))
+	for _, env := range build.Environments {
+		if _, ok := build.EnvManifestBuild[env]; ok && !seen[env] {
+			envs = append(envs, env)
+			seen[env] = true
+		}
+	}
+	var rest []string
+	for env := range build.EnvManifestBuild {
+		if !seen[env] {
+			rest = append(rest, env)
+		}
+	}
+	sort.Strings(rest)
+	return append(envs, rest...)
+}
+
+// manifestsToYAML converts the JSON manifests of an environment to YAML, for the manifests to be scoped, exempted
+// and evaluated alike
+func manifestsToYAML(build models.BuildEnvManifestResult) (models.BuildEnvManifestResult, error) {
+	var err error
+	if build.BeforeManifest, err = manifestpkg.ToYAML(build.BeforeManifest); err != nil {
+		return build, fmt.Errorf("failed to convert base manifest: %w", err)
+	}
+	if build.AfterManifest, err = manifestpkg.ToYAML(build.AfterManifest); err != nil {
+		return build, fmt.Errorf("failed to convert head manifest: %w", err)
+	}
+	return build, nil
+}
+
+// exemptionReason returns the override reason of a failing policy if it passes once the resources exempted
+// by an unexpired annotation are left out, empty if no resource is exempted or other resources still fail
+func (e *PolicyEvaluator) exemptionReason(ctx context.Context, policyId string, manifest []byte) (string, error) {
+	remaining, expiresAt, exempted := withoutExemptedResources(manifest, policyId, e.clock())
+	if !exempted {
+		return "", nil
+	}
+	results, evalErrors, _, err := e.evaluatePolicies(ctx, remaining, []string{policyId}, nil)
+	if err != nil {
+		return "", err
+	}
+	if err, ok := evalErrors[policyId]; ok {
+		logctx.Entry(ctx, logger).WithField("policyId", policyId).WithError(err).Warn("Failed to evaluate policy without exempted resources, not exempting")
+		return "", nil
+	}
+	if len(results[policyId]) > 0 {
+		logctx.Entry(ctx, logger).WithField("policyId", policyId).Info("Policy still fails on resources that are not exempted")
+		return "", nil
+	}
+	return fmt.Sprintf(EXEMPTION_REASON_FORMAT, expiresAt.Format(time.DateOnly)), nil
+}
+
+// splitPreExisting splits the fail messages of the head manifest into those already present on the base manifest
+// and those introduced by the PR
+func splitPreExisting(headMsgs, baseMsgs []string) ([]string, []string) {
+	onBase := make(map[string]bool, len(baseMsgs))
+	for _, msg := range baseMsgs {
+		onBase[msg] = true
+	}
+	preExisting, introduced := []string{}, []string{}
+	for _, msg := range headMsgs {
+		if onBase[msg] {
+			preExisting = append(preExisting, msg)
+		} else {
+			introduced = append(introduced, msg)
+		}
+	}
+	return preExisting, introduced
+}
+
+// fixedMessages returns the fail messages of the base manifest that the head manifest no longer has, nil if none
+func fixedMessages(headMsgs, baseMsgs []string) []string {
+	onHead := make(map[string]bool, len(headMsgs))
+	for _, msg := range headMsgs {
+		onHead[msg] = true
+	}
+	var fixed []string
+	for _, msg := range baseMsgs {
+		if !onHead[msg] {
+			fixed = append(fixed, msg)
+		}
+	}
+	return fixed
+}
+
+// failingPolicySources reads the rego source of every policy failing in at least one environment
+// returns nil if the ShowPolicySource option is disabled or no policy is failing
+func (e *PolicyEvaluator) failingPolicySources(
+	envToPolicyIdToResult map[string]map[string]models.PolicyResult,
+) (map[string]string, error) {
+	if !e.options.ShowPolicySource {
+		return nil, nil
+	}
+
+	var sources map[string]string
+	for _, policyIdToResult := range envToPolicyIdToResult {
+		for policyId, result := range policyIdToResult {
+			if result.IsPassing {
+				continue
+			}
+			if _, ok := sources[policyId]; ok {
+				continue
+			}
+			content, err := os.ReadFile(e.data.fullPathToPolicy[policyId])
+			if err != nil {
+				return nil, fmt.Errorf("policy %s: failed to read policy source: %w", policyId, err)
+			}
+			if sources == nil {
+				sources = make(map[string]string)
+			}
+			sources[policyId] = truncatePolicySource(string(content))
+		}
+	}
+	return sources, nil
+}
+
+// truncatePolicySource cuts the source to POLICY_SOURCE_MAX_LENGTH, noting how much was left out
+func truncatePolicySource(source string) string {
+	if len(source) <= POLICY_SOURCE_MAX_LENGTH {
+		return source
+	}
+	return fmt.Sprintf("%s\n# ... truncated, %d more bytes", source[:POLICY_SOURCE_MAX_LENGTH], len(source)-POLICY_SOURCE_MAX_LENGTH)
+}
+
+// formatFailMessages wraps each fail message with the policy's messageTemplate if configured,
+// otherwise the messages are returned unchanged
+func (e *PolicyEvaluator) formatFailMessages(policyId string, failMsgs []string) ([]string, error) {
+	tmpl, ok := e.data.messageTemplateOfPolicy[policyId]
+	if !ok {
+		return failMsgs, nil
+	}
+
+	policy := e.data.ComplianceConfig.Policies[policyId]
+	formatted := make([]string, 0, len(failMsgs))
+	for _, msg := range failMsgs {
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, models.FailMessageTemplateData{
+			Message:      msg,
+			PolicyId:     policyId,
+			PolicyName:   policy.Name,
+			Description:  policy.Description,
+			ExternalLink: policy.ExternalLink,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: failed to render messageTemplate: %w", policyId, err)
+		}
+		formatted = append(formatted, buf.String())
+	}
+	return formatted, nil
+}
+
+// Evaluate evaluates all policies against the manifest using conftest and store the evaluation results in the EvaluatorData,
+// a JSON manifest is converted to YAML first
 // returns: policyId -> failure messages
 func (e *PolicyEvaluator) Evaluate(
 	ctx context.Context,
 	manifest []byte,
 ) (map[string][]string, error) {
-	logger.Info("Evaluate: starting...")
-	results := make(map[string][]string)
-
-	// Write manifest to temporary file for conftest
-	tmpFile, err := os.CreateTemp("", "manifest-*.yaml")
+	manifest, err := manifestpkg.ToYAML(manifest)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, err
+	}
+	results, evalErrors, _, err := e.evaluateWithSeverity(ctx, manifest, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := firstEvaluationError(evalErrors); err != nil {
+		return nil, err
+	}
+	for id, failMsgs := range results {
+		_, results[id] = splitSeverity(failMsgs)
+	}
+	return results, nil
+}
+
+// firstEvaluationError returns the evaluation error of the first policy in id order, nil if none
+func firstEvaluationError(evalErrors map[string]error) error {
+	ids := make([]string, 0, len(evalErrors))
+	for id := range evalErrors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	return fmt.Errorf("failed to evaluate policy %s: %w", ids[0], evalErrors[ids[0]])
+}
+
+// evaluateWithSeverity evaluates all policies against the manifest in policy id order, see Evaluate and
+// evaluatePolicies for stopOn, the fail messages are tagged with their rego severity with --use-rego-severity
+func (e *PolicyEvaluator) evaluateWithSeverity(
+	ctx context.Context,
+	manifest []byte,
+	stopOn func(id string, failMsgs []string) bool,
+) (map[string][]string, map[string]error, bool, error) {
+	logctx.Entry(ctx, logger).Info("Evaluate: starting...")
+	policyIds := make([]string, 0, len(e.data.ComplianceConfig.Policies))
+	for id := range e.data.ComplianceConfig.Policies {
+		policyIds = append(policyIds, id)
+	}
+	sort.Strings(policyIds)
+	return e.evaluatePolicies(ctx, manifest, policyIds, stopOn)
+}
+
+// evaluatePolicies evaluates the given policies against the manifest, see Evaluate. If stopOn is set, no further
+// policy is evaluated once it returns true for a result: in-flight evaluations are cancelled and the results
+// collected so far are returned with stopped true.
+// A policy that cannot be evaluated, e.g. a rego compile error or a conftest timeout, does not abort the others:
+// its error is returned in evalErrors instead of a result and is not cached
+func (e *PolicyEvaluator) evaluatePolicies(
+	ctx context.Context,
+	manifest []byte,
+	policyIds []string,
+	stopOn func(id string, failMsgs []string) bool,
+) (results map[string][]string, evalErrors map[string]error, stopped bool, err error) {
+	results = make(map[string][]string)
+	evalErrors = make(map[string]error)
+	shouldStop := func(id string, failMsgs []string) bool {
+		return stopOn != nil && stopOn(id, failMsgs)
 	}
-	defer func() {
-		if err := tmpFile.Close(); err != nil {
-			// Log error but don't fail the operation
-			fmt.Printf("Warning: failed to close temp file: %v\n", err)
+
+	// Policies are evaluated against the documents of their scope, each scoped manifest is written once for conftest
+	scopedManifests := make(map[string][]byte)
+	manifestPaths := make(map[string]string)
+	tempFiles := fileutil.NewTempFiles(e.options.TempPrefix)
+	defer tempFiles.Cleanup()
+
+	manifestPathOf := func(scope string) (string, error) {
+		if manifestPath, ok := manifestPaths[scope]; ok {
+			return manifestPath, nil
 		}
-		if err := os.Remove(tmpFile.Name()); err != nil {
-			// Log error but don't fail the operation
-			fmt.Printf("Warning: failed to remove temp file %s: %v\n", tmpFile.Name(), err)
+		manifestPath, err := tempFiles.Write("manifest-*.yaml", scopedManifests[scope])
+		if err != nil {
+			return "", err
 		}
-	}()
+		manifestPaths[scope] = manifestPath
+		return manifestPath, nil
+	}
 
-	if _, err := tmpFile.Write(manifest); err != nil {
-		return nil, fmt.Errorf("failed to write manifest to temp file: %w", err)
+	// uncached policies left for batched conftest calls, per scope
+	batchedPolicyIds := make(map[string][]string)
+	cacheKeyOfPolicy := make(map[string]string)
+	batching := e.options.BatchConftest && e.options.Backend != POLICY_BACKEND_OPA_SERVER
+	// uncached policies left for one by one evaluation
+	type evalJob struct {
+		id           string
+		cacheKey     string
+		scoped       []byte
+		manifestPath string
+		dataPath     string
 	}
+	jobs := []evalJob{}
 
-	// Evaluate each policy using conftest
-	for id := range e.data.ComplianceConfig.Policies {
-		failMsgs, err := e.evaluatePolicyWithConftest(
-			ctx, id, e.data.fullPathToPolicy[id], tmpFile.Name(),
-		)
+	// Evaluate each policy using conftest, reusing cached results of identical policy/manifest pairs
+	for _, id := range policyIds {
+		scope := e.data.ComplianceConfig.Policies[id].Scope
+		scoped, ok := scopedManifests[scope]
+		if !ok {
+			scoped, err = scopedManifest(manifest, scope)
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("failed to evaluate policy %s: %w", id, err)
+			}
+			scopedManifests[scope] = scoped
+		}
+		if len(manifestpkg.SplitDocuments(scoped)) == 0 {
+			logctx.Entry(ctx, logger).WithField("policyId", id).WithField("scope", scope).Debug("No manifest document in the policy's scope, skipping")
+			results[id] = nil
+			continue
+		}
+
+		policyPath := e.data.fullPathToPolicy[id]
+		policyData := e.data.dataOfPolicy[id]
+		cacheKey, err := e.cache.key(policyPath, scoped, policyData)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed to evaluate policy %s: %w", id, err)
+		}
+		if failMsgs, ok := e.cache.get(cacheKey); ok {
+			logctx.Entry(ctx, logger).WithField("policyId", id).Debug("Using cached policy evaluation result")
+			results[id] = failMsgs
+			if shouldStop(id, failMsgs) {
+				return results, evalErrors, true, nil
+			}
+			continue
+		}
+
+		if batching && policyData == nil {
+			batchedPolicyIds[scope] = append(batchedPolicyIds[scope], id)
+			cacheKeyOfPolicy[id] = cacheKey
+			continue
+		}
+
+		job := evalJob{id: id, cacheKey: cacheKey, scoped: scoped}
+		if e.options.Backend != POLICY_BACKEND_OPA_SERVER {
+			// temp files are written upfront, TempFiles is not safe for concurrent use
+			job.manifestPath, err = manifestPathOf(scope)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if policyData != nil {
+				job.dataPath, err = tempFiles.Write("data-*.json", policyData)
+				if err != nil {
+					return nil, nil, false, err
+				}
+			}
+		}
+		jobs = append(jobs, job)
+	}
+
+	// Uncached policies are evaluated concurrently up to the policy concurrency, results are collected per job
+	failMsgsOfJob := make([][]string, len(jobs))
+	errOfJob := make([]error, len(jobs))
+	evaluated := make([]bool, len(jobs))
+	err = forEachConcurrently(ctx, len(jobs), e.options.Concurrency, func(ctx context.Context, i int) error {
+		job := jobs[i]
+		var failMsgs []string
+		var err error
+		if e.options.Backend == POLICY_BACKEND_OPA_SERVER {
+			failMsgs, err = e.evaluatePolicyWithOpaServer(ctx, job.id, e.data.regoPackageOfPolicy[job.id], job.scoped)
+		} else {
+			failMsgs, err = e.evaluatePolicyWithConftest(ctx, job.id, e.data.fullPathToPolicy[job.id], job.manifestPath, job.dataPath)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				// cancelled after another policy stopped the evaluation, e.g. conftest killed
+				return ctx.Err()
+			}
+			logctx.Entry(ctx, logger).WithField("policyId", job.id).WithError(err).Warn("Failed to evaluate policy, continuing with the others")
+			errOfJob[i] = err
+			return nil
+		}
+		failMsgsOfJob[i], evaluated[i] = failMsgs, true
+		if shouldStop(job.id, failMsgs) {
+			return errEvaluationStopped
+		}
+		return nil
+	})
+	stopped = errors.Is(err, errEvaluationStopped)
+	if err != nil && !stopped {
+		return nil, nil, false, err
+	}
+	for i, job := range jobs {
+		if errOfJob[i] != nil {
+			evalErrors[job.id] = errOfJob[i]
+			continue
+		}
+		if !evaluated[i] {
+			continue
+		}
+		e.cache.put(job.cacheKey, failMsgsOfJob[i])
+		results[job.id] = failMsgsOfJob[i]
+	}
+	if stopped {
+		return results, evalErrors, true, nil
+	}
+
+	scopes := make([]string, 0, len(batchedPolicyIds))
+	for scope := range batchedPolicyIds {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	for _, scope := range scopes {
+		ids := batchedPolicyIds[scope]
+		manifestPath, err := manifestPathOf(scope)
 		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate policy %s: %w", id, err)
+			return nil, nil, false, err
+		}
+		batchResults, batchErrors, err := e.evaluateBatchesWithConftest(ctx, ids, manifestPath)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for id, err := range batchErrors {
+			evalErrors[id] = err
+		}
+		for id, failMsgs := range batchResults {
+			e.cache.put(cacheKeyOfPolicy[id], failMsgs)
+			results[id] = failMsgs
+			stopped = stopped || shouldStop(id, failMsgs)
+		}
+		if stopped {
+			return results, evalErrors, true, nil
 		}
-		results[id] = failMsgs
 	}
 
-	return results, nil
+	return results, evalErrors, false, nil
+}
+
+// scopedManifest returns the documents of the manifest a policy of the given scope applies to
+func scopedManifest(content []byte, scope string) ([]byte, error) {
+	switch scope {
+	case POLICY_SCOPE_NAMESPACED:
+		return manifestpkg.FilterScope(content, false)
+	case POLICY_SCOPE_CLUSTER:
+		return manifestpkg.FilterScope(content, true)
+	default:
+		return content, nil
+	}
 }
 
-// evaluatePolicyWithConftest evaluates a single policy using conftest
+// conftestResult is the JSON output of conftest for a file and a rego namespace
+type conftestResult struct {
+	Filename  string            `json:"filename"`
+	Namespace string            `json:"namespace"`
+	Successes int               `json:"successes"`
+	Failures  []conftestFailure `json:"failures"`
+	Warnings  []conftestFailure `json:"warnings"`
+}
+
+// evaluatePolicyWithConftest evaluates a single policy using conftest, with the external data file if dataPath is set
 // returns: failureMsgs, evalError
 func (e *PolicyEvaluator) evaluatePolicyWithConftest(
 	ctx context.Context,
 	id string,
-	singlePolicyPath string, manifestPath string,
+	singlePolicyPath string, manifestPath string, dataPath string,
 ) ([]string, error) {
-	logger.Infof("evaluating policy %s", id)
+	logctx.Entry(ctx, logger).Infof("evaluating policy %s", id)
 
-	cmd := exec.CommandContext(ctx,
-		"conftest", "test", "--all-namespaces", "--combine",
+	args := []string{
+		"test", "--all-namespaces", "--combine",
 		"--policy", singlePolicyPath,
 		manifestPath,
 		"-o", "json",
-	)
+	}
+	if dataPath != "" {
+		args = append(args, "--data", dataPath)
+	}
 
 	// If policy eval not passing, the program exit with code 1, we will omit error here
-	outputBytes, _ := cmd.CombinedOutput()
-	logger.Debugf("conftest output: %s", string(outputBytes))
+	// Only stdout holds the JSON results, stderr diagnostics are kept out of it to not break the parsing
+	result, err := e.executor.Run(ctx, "", "conftest", args...)
+	if result == nil {
+		return nil, fmt.Errorf("failed to run conftest: %w", err)
+	}
+	outputBytes := result.Stdout
+	logctx.Entry(ctx, logger).Debugf("conftest output: %s", string(outputBytes))
+	if len(result.Stderr) > 0 {
+		logctx.Entry(ctx, logger).WithField("policyId", id).Debugf("conftest stderr: %s", string(result.Stderr))
+	}
 
 	// Sample conftest output
 	// 	[
@@ -424,23 +1263,17 @@ func (e *PolicyEvaluator) evaluatePolicyWithConftest(
 	//     ]
 	//   }
 	// ]
-	outputJson := []struct {
-		Filename  string `json:"filename"`
-		Namespace string `json:"namespace"`
-		Successes int    `json:"successes"`
-		Failures  []struct {
-			Msg      string `json:"msg"`
-			Metadata struct {
-				Query string `json:"query"`
-			}
-		}
-	}{}
+	outputJson := []conftestResult{}
 	if err := json.Unmarshal(outputBytes, &outputJson); err != nil {
-		return nil, fmt.Errorf("failed to parse conftest output: %w", err)
+		return nil, fmt.Errorf("failed to parse conftest output: %w\nStderr: %s", err, string(result.Stderr))
 	}
 
 	if len(outputJson) == 0 {
-		return nil, fmt.Errorf("no results found in conftest output: %s", string(outputBytes))
+		if e.options.EmptyResultsAsPass {
+			logctx.Entry(ctx, logger).WithField("policyId", id).Info("conftest returned no results, nothing to check, treating as a pass")
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("no results found in conftest output: %s\nStderr: %s", string(outputBytes), string(result.Stderr))
 	}
 	// Success case: [
 	// 	 {
@@ -449,29 +1282,23 @@ func (e *PolicyEvaluator) evaluatePolicyWithConftest(
 	// 			"successes": 3
 	//	 }
 	// ]
-	if len(outputJson[0].Failures) == 0 {
-		return []string{}, nil
-	}
-
-	failureMsgs := []string{}
-	for _, failure := range outputJson[0].Failures {
-		failureMsgs = append(failureMsgs, failure.Msg)
-	}
-	return failureMsgs, nil
+	return e.conftestFailureMessages(outputJson[0]), nil
 }
 
-// DetermineEnforcementLevel determines the current enforcement level based on time and overrides
-// Set the results to internal struct data
+// DetermineEnforcementLevel determines the current enforcement level of each policy on the environment based on time
+// and overrides, the environment's enforcement dates take precedence over the global ones. An empty env uses the global dates
 func (e *PolicyEvaluator) DetermineEnforcementLevel(
-	comments []string,
+	comments []*models.Comment,
+	env string,
 ) (map[string]string, error) {
 	results := make(map[string]string)
-	now := time.Now()
+	now := e.clock()
 
-	for _, comment := range comments {
-		if _, ok := e.data.overrideCmdToPolicyId[comment]; ok {
-			results[e.data.overrideCmdToPolicyId[comment]] = POLICY_LEVEL_OVERRIDE
-		}
+	for policyId := range e.overriddenPolicies(comments) {
+		results[policyId] = POLICY_LEVEL_OVERRIDE
+	}
+	for policyId := range e.activeSnoozes(comments, now) {
+		results[policyId] = POLICY_LEVEL_OVERRIDE
 	}
 
 	for policyId, policy := range e.data.ComplianceConfig.Policies {
@@ -480,7 +1307,7 @@ func (e *PolicyEvaluator) DetermineEnforcementLevel(
 		}
 
 		enforcementLevel := POLICY_LEVEL_UNKNOWN
-		enforcement := policy.Enforcement
+		enforcement := enforcementForEnv(policy.Enforcement, env)
 
 		if enforcement.InEffectAfter != nil && now.Before(*enforcement.InEffectAfter) {
 			enforcementLevel = POLICY_LEVEL_NOT_IN_EFFECT
@@ -500,3 +1327,64 @@ func (e *PolicyEvaluator) DetermineEnforcementLevel(
 
 	return results, nil
 }
+
+// enforcementForEnv returns the enforcement with the dates set for env replacing the global ones
+func enforcementForEnv(enforcement models.EnforcementConfig, env string) models.EnforcementConfig {
+	override, ok := enforcement.Environments[env]
+	if !ok {
+		return enforcement
+	}
+	if override.InEffectAfter != nil {
+		enforcement.InEffectAfter = override.InEffectAfter
+	}
+	if override.IsWarningAfter != nil {
+		enforcement.IsWarningAfter = override.IsWarningAfter
+	}
+	if override.IsBlockingAfter != nil {
+		enforcement.IsBlockingAfter = override.IsBlockingAfter
+	}
+	return enforcement
+}
+
+// checkEnforcementDates checks the enforcement dates set are in order
+func checkEnforcementDates(enforcement models.EnforcementConfig) error {
+	if enforcement.InEffectAfter != nil && enforcement.IsWarningAfter != nil &&
+		enforcement.IsWarningAfter.Before(*enforcement.InEffectAfter) {
+		return fmt.Errorf("isWarningAfter cannot be before inEffectAfter")
+	}
+	if enforcement.IsWarningAfter != nil && enforcement.IsBlockingAfter != nil &&
+		enforcement.IsBlockingAfter.Before(*enforcement.IsWarningAfter) {
+		return fmt.Errorf("isBlockingAfter cannot be before isWarningAfter")
+	}
+	return nil
+}
+
+// overriddenPolicies returns the policies whose override comment was posted by enough distinct users
+func (e *PolicyEvaluator) overriddenPolicies(comments []*models.Comment) map[string]bool {
+	overridden := make(map[string]bool)
+	for policyId, authors := range e.overrideAuthors(comments) {
+		required := max(e.data.ComplianceConfig.Policies[policyId].Enforcement.Override.RequiredApprovals, 1)
+		if len(authors) < required {
+			logger.WithField("policyId", policyId).Infof("Override quorum not reached: %d/%d overrides received", len(authors), required)
+			continue
+		}
+		overridden[policyId] = true
+	}
+	return overridden
+}
+
+// overrideAuthors returns the distinct authors of the override comments of each policy, logins are case-insensitive
+func (e *PolicyEvaluator) overrideAuthors(comments []*models.Comment) map[string]map[string]bool {
+	authors := make(map[string]map[string]bool)
+	for _, comment := range comments {
+		policyId, ok := e.data.overrideCmdToPolicyId[comment.Body]
+		if !ok {
+			continue
+		}
+		if authors[policyId] == nil {
+			authors[policyId] = make(map[string]bool)
+		}
+		authors[policyId][strings.ToLower(comment.User)] = true
+	}
+	return authors
+}
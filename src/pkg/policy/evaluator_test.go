@@ -0,0 +1,1275 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/logctx"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// newTestPolicyConfig returns a minimal valid policy config for tests
+func newTestPolicyConfig() models.PolicyConfig {
+	return models.PolicyConfig{
+		Name:     "Service High Availability",
+		Type:     "opa",
+		FilePath: "ha.rego",
+	}
+}
+
+const testPolicyRego = `package main
+
+import rego.v1
+
+deny contains msg if {
+	input[_].contents.kind == "Deployment"
+	msg := "failed"
+}
+`
+
+const testPolicyTestRego = `package main
+
+import rego.v1
+
+test_deny_deployment if {
+	count(data.main.deny) > 0 with input as [{"contents": {"kind": "Deployment"}}]
+}
+`
+
+// newTestPoliciesDir writes a policies directory with the given compliance config
+// and a ha.rego / ha_test.rego pair, returns the directory path
+func newTestPoliciesDir(t *testing.T, complianceConfig string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		COMPLIANCE_CONFIG_FILENAME: complianceConfig,
+		"ha.rego":                  testPolicyRego,
+		"ha_test.rego":             testPolicyTestRego,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestPolicyEvaluator_validateComplianceConfig_OverrideComment tests the override command shape validation
+func TestPolicyEvaluator_validateComplianceConfig_OverrideComment(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		wantErr string
+	}{
+		{
+			name:    "empty comment is allowed",
+			comment: "",
+		},
+		{
+			name:    "valid slash command",
+			comment: "/sp-override-ha",
+		},
+		{
+			name:    "valid slash command with digits",
+			comment: "/override-v2",
+		},
+		{
+			name:    "contains spaces",
+			comment: "override please",
+			wantErr: "invalid override comment",
+		},
+		{
+			name:    "slash command with spaces",
+			comment: "/sp-override ha",
+			wantErr: "invalid override comment",
+		},
+		{
+			name:    "missing slash",
+			comment: "sp-override-ha",
+			wantErr: "invalid override comment",
+		},
+		{
+			name:    "uppercase letters",
+			comment: "/SP-Override-HA",
+			wantErr: "invalid override comment",
+		},
+		{
+			name:    "slash only",
+			comment: "/",
+			wantErr: "invalid override comment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newTestPolicyConfig()
+			policy.Enforcement.Override.Comment = tt.comment
+
+			e := NewPolicyEvaluator("")
+			e.data.ComplianceConfig = models.ComplianceConfig{
+				Policies: map[string]models.PolicyConfig{"ha": policy},
+			}
+
+			err := e.validateComplianceConfig()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateComplianceConfig() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateComplianceConfig() error = nil, want error containing %q", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateComplianceConfig() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_formatFailMessages tests the per-policy fail message templating
+func TestPolicyEvaluator_formatFailMessages(t *testing.T) {
+	t.Run("no template passes messages through", func(t *testing.T) {
+		dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`)
+		e := NewPolicyEvaluator(dir)
+		if err := e.LoadAndValidate(); err != nil {
+			t.Fatalf("LoadAndValidate() error = %v", err)
+		}
+
+		got, err := e.formatFailMessages("ha", []string{"replicas too low"})
+		if err != nil {
+			t.Fatalf("formatFailMessages() error = %v", err)
+		}
+		if len(got) != 1 || got[0] != "replicas too low" {
+			t.Errorf("formatFailMessages() = %v, want [replicas too low]", got)
+		}
+	})
+
+	t.Run("template wraps message", func(t *testing.T) {
+		dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    externalLink: https://example.com/ha
+    messageTemplate: "[{{.PolicyId}}] {{.Message}} (see {{.ExternalLink}})"
+`)
+		e := NewPolicyEvaluator(dir)
+		if err := e.LoadAndValidate(); err != nil {
+			t.Fatalf("LoadAndValidate() error = %v", err)
+		}
+
+		got, err := e.formatFailMessages("ha", []string{"replicas too low", "no anti-affinity"})
+		if err != nil {
+			t.Fatalf("formatFailMessages() error = %v", err)
+		}
+		want := []string{
+			"[ha] replicas too low (see https://example.com/ha)",
+			"[ha] no anti-affinity (see https://example.com/ha)",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("formatFailMessages() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("malformed template surfaces clear error", func(t *testing.T) {
+		dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    messageTemplate: "[{{.PolicyId}] {{.Message}}"
+`)
+		e := NewPolicyEvaluator(dir)
+		err := e.LoadAndValidate()
+		if err == nil {
+			t.Fatal("LoadAndValidate() error = nil, want error for malformed messageTemplate")
+		}
+		if !strings.Contains(err.Error(), "policy ha: invalid messageTemplate") {
+			t.Errorf("LoadAndValidate() error = %v, want error mentioning the policy and messageTemplate", err)
+		}
+	})
+}
+
+// TestPolicyEvaluator_evaluatePolicyWithConftest tests that only conftest stdout is parsed as JSON
+func TestPolicyEvaluator_evaluatePolicyWithConftest(t *testing.T) {
+	const failingOutput = `[{"filename":"Combined","namespace":"main","successes":1,"failures":[{"msg":"replicas too low","metadata":{"query":"data.main.deny"}}]}]`
+	tests := []struct {
+		name      string
+		stdout    string
+		stderr    string
+		err       error
+		wantMsgs  []string
+		wantErr   string
+		wantInErr string
+	}{
+		{
+			name:     "passing policy",
+			stdout:   `[{"filename":"Combined","namespace":"main","successes":2}]`,
+			wantMsgs: []string{},
+		},
+		{
+			name:     "failing policy with exit code 1",
+			stdout:   failingOutput,
+			err:      fmt.Errorf("exit status 1"),
+			wantMsgs: []string{"replicas too low"},
+		},
+		{
+			name:     "warning on stderr is not parsed",
+			stdout:   failingOutput,
+			stderr:   "WARNING: rego.v1 import is deprecated\n",
+			err:      fmt.Errorf("exit status 1"),
+			wantMsgs: []string{"replicas too low"},
+		},
+		{
+			name:      "invalid output includes stderr",
+			stdout:    "",
+			stderr:    "Error: running test: load: loading policies: 1 error occurred",
+			err:       fmt.Errorf("exit status 1"),
+			wantErr:   "failed to parse conftest output",
+			wantInErr: "loading policies",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluator("")
+			e.executor = &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					return &command.Result{Stdout: []byte(tt.stdout), Stderr: []byte(tt.stderr)}, tt.err
+				},
+			}
+
+			got, err := e.evaluatePolicyWithConftest(context.Background(), "ha", "ha.rego", "manifest.yaml", "")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), tt.wantInErr) {
+					t.Fatalf("evaluatePolicyWithConftest() error = %v, want error containing %q and %q", err, tt.wantErr, tt.wantInErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evaluatePolicyWithConftest() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.wantMsgs) {
+				t.Errorf("evaluatePolicyWithConftest() = %v, want %v", got, tt.wantMsgs)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_evaluatePolicyWithConftest_EmptyResults tests the strict and lenient handling of empty conftest results
+func TestPolicyEvaluator_evaluatePolicyWithConftest_EmptyResults(t *testing.T) {
+	tests := []struct {
+		name               string
+		emptyResultsAsPass bool
+		wantErr            bool
+	}{
+		{name: "strict", emptyResultsAsPass: false, wantErr: true},
+		{name: "lenient", emptyResultsAsPass: true, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{EmptyResultsAsPass: tt.emptyResultsAsPass})
+			e.executor = &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					return &command.Result{Stdout: []byte("[]")}, nil
+				},
+			}
+
+			got, err := e.evaluatePolicyWithConftest(context.Background(), "ha", "ha.rego", "manifest.yaml", "")
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "no results found") {
+					t.Errorf("evaluatePolicyWithConftest() error = %v, want no results found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evaluatePolicyWithConftest() error = %v", err)
+			}
+			if got == nil || len(got) != 0 {
+				t.Errorf("evaluatePolicyWithConftest() = %#v, want an empty pass", got)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_PolicySources tests that only failing policies have their source shown
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_PolicySources(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+  labels:
+    name: Required Labels
+    type: opa
+    filePath: labels.rego
+`)
+	for name, content := range map[string]string{"labels.rego": testPolicyRego + "\n# labels\n", "labels_test.rego": testPolicyTestRego} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {Environment: "stg", AfterManifest: []byte("kind: Deployment")},
+		},
+	}
+	// ha.rego fails, labels.rego passes
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			if strings.HasSuffix(args[4], "ha.rego") {
+				return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"failed"}]}]`)}, fmt.Errorf("exit status 1")
+			}
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","successes":1}]`)}, nil
+		},
+	}
+
+	tests := []struct {
+		name        string
+		showSource  bool
+		wantSources map[string]string
+	}{
+		{
+			name:        "disabled",
+			showSource:  false,
+			wantSources: nil,
+		},
+		{
+			name:        "enabled shows failing policies only",
+			showSource:  true,
+			wantSources: map[string]string{"ha": testPolicyRego},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{ShowPolicySource: tt.showSource})
+			e.executor = fake
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.PolicySources, tt.wantSources) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() PolicySources = %v, want %v", got.PolicySources, tt.wantSources)
+			}
+		})
+	}
+}
+
+// TestTruncatePolicySource tests that large policy sources are truncated
+func TestTruncatePolicySource(t *testing.T) {
+	short := "package main\n"
+	if got := truncatePolicySource(short); got != short {
+		t.Errorf("truncatePolicySource() = %q, want unchanged %q", got, short)
+	}
+
+	long := strings.Repeat("a", POLICY_SOURCE_MAX_LENGTH+10)
+	got := truncatePolicySource(long)
+	if !strings.HasPrefix(got, long[:POLICY_SOURCE_MAX_LENGTH]) {
+		t.Error("truncatePolicySource() should keep the beginning of the source")
+	}
+	if !strings.HasSuffix(got, "# ... truncated, 10 more bytes") {
+		t.Errorf("truncatePolicySource() = ...%q, want truncation note", got[len(got)-40:])
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_RequireCleanBase tests attribution of pre-existing violations
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_RequireCleanBase(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {Environment: "stg", BeforeManifest: []byte("base"), AfterManifest: []byte("head")},
+		},
+	}
+	// the base already fails with "replicas too low", the PR introduces "no anti-affinity"
+	outputs := map[string]string{
+		"base": `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`,
+		"head": `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"},{"msg":"no anti-affinity"}]}]`,
+	}
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			content, err := os.ReadFile(args[5])
+			if err != nil {
+				return nil, err
+			}
+			return &command.Result{Stdout: []byte(outputs[string(content)])}, fmt.Errorf("exit status 1")
+		},
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		e := NewPolicyEvaluator(dir)
+		e.executor = fake
+		if err := e.LoadAndValidate(); err != nil {
+			t.Fatalf("LoadAndValidate() error = %v", err)
+		}
+		got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+		if err != nil {
+			t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+		}
+		result := got.PolicyMatrix["stg"].BlockingPolicies[0]
+		if result.IsFailingOnBase || result.PreExistingFailMessages != nil || result.NewFailMessages != nil {
+			t.Errorf("GeneratePolicyEvalResultForManifests() should not attribute violations when disabled, got %+v", result)
+		}
+		if got.EnvironmentSummary["stg"].BaseFailsBlockingCheck {
+			t.Error("GeneratePolicyEvalResultForManifests() BaseFailsBlockingCheck = true, want false when disabled")
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{RequireCleanBase: true})
+		e.executor = fake
+		if err := e.LoadAndValidate(); err != nil {
+			t.Fatalf("LoadAndValidate() error = %v", err)
+		}
+		got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+		if err != nil {
+			t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+		}
+		result := got.PolicyMatrix["stg"].BlockingPolicies[0]
+		if !result.IsFailingOnBase {
+			t.Error("GeneratePolicyEvalResultForManifests() IsFailingOnBase = false, want true")
+		}
+		if !reflect.DeepEqual(result.PreExistingFailMessages, []string{"replicas too low"}) {
+			t.Errorf("GeneratePolicyEvalResultForManifests() PreExistingFailMessages = %v, want [replicas too low]", result.PreExistingFailMessages)
+		}
+		if !reflect.DeepEqual(result.NewFailMessages, []string{"no anti-affinity"}) {
+			t.Errorf("GeneratePolicyEvalResultForManifests() NewFailMessages = %v, want [no anti-affinity]", result.NewFailMessages)
+		}
+		if !got.EnvironmentSummary["stg"].BaseFailsBlockingCheck {
+			t.Error("GeneratePolicyEvalResultForManifests() BaseFailsBlockingCheck = false, want true")
+		}
+	})
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_UnchangedEnvironment tests that environments without changes are still evaluated
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_UnchangedEnvironment(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg":  {Environment: "stg", BeforeManifest: []byte("old"), AfterManifest: []byte("new")},
+			"prod": {Environment: "prod", BeforeManifest: []byte("same"), AfterManifest: []byte("same")},
+		},
+	}
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`)}, fmt.Errorf("exit status 1")
+		},
+	}
+	e := NewPolicyEvaluator(dir)
+	e.executor = fake
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+
+	got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+	if err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+	for _, env := range []string{"stg", "prod"} {
+		if got.EnvironmentSummary[env].PolicyCounts.BlockingFailedCount != 1 {
+			t.Errorf("GeneratePolicyEvalResultForManifests() [%s] BlockingFailedCount = %d, want 1", env, got.EnvironmentSummary[env].PolicyCounts.BlockingFailedCount)
+		}
+		if len(got.PolicyMatrix[env].BlockingPolicies) != 1 {
+			t.Errorf("GeneratePolicyEvalResultForManifests() [%s] has %d blocking results, want 1", env, len(got.PolicyMatrix[env].BlockingPolicies))
+		}
+	}
+}
+
+// TestPolicyEvaluator_validateComplianceConfig_Scope tests the policy scope validation
+func TestPolicyEvaluator_validateComplianceConfig_Scope(t *testing.T) {
+	tests := []struct {
+		scope   string
+		wantErr bool
+	}{
+		{scope: ""},
+		{scope: POLICY_SCOPE_ANY},
+		{scope: POLICY_SCOPE_NAMESPACED},
+		{scope: POLICY_SCOPE_CLUSTER},
+		{scope: "global", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			policy := newTestPolicyConfig()
+			policy.Scope = tt.scope
+
+			e := NewPolicyEvaluator("")
+			e.data.ComplianceConfig = models.ComplianceConfig{
+				Policies: map[string]models.PolicyConfig{"ha": policy},
+			}
+
+			err := e.validateComplianceConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateComplianceConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_Evaluate_Scope tests that policies only see the documents of their scope
+func TestPolicyEvaluator_Evaluate_Scope(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    scope: namespaced
+  rbac:
+    name: RBAC
+    type: opa
+    filePath: rbac.rego
+    scope: cluster
+  labels:
+    name: Labels
+    type: opa
+    filePath: labels.rego
+`)
+	for name, content := range map[string]string{
+		"rbac.rego":        "package main\n\ndeny contains msg if { false; msg := \"rbac\" }\n",
+		"rbac_test.rego":   testPolicyTestRego,
+		"labels.rego":      "package main\n\ndeny contains msg if { false; msg := \"labels\" }\n",
+		"labels_test.rego": testPolicyTestRego,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	// every policy fails with the kinds it was given
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			content, err := os.ReadFile(args[5])
+			if err != nil {
+				return nil, err
+			}
+			failures := []string{}
+			for _, line := range strings.Split(string(content), "\n") {
+				if kind, ok := strings.CutPrefix(line, "kind: "); ok {
+					failures = append(failures, fmt.Sprintf(`{"msg":%q}`, kind))
+				}
+			}
+			stdout := fmt.Sprintf(`[{"filename":"Combined","namespace":"main","failures":[%s]}]`, strings.Join(failures, ","))
+			return &command.Result{Stdout: []byte(stdout)}, fmt.Errorf("exit status 1")
+		},
+	}
+	e := NewPolicyEvaluator(dir)
+	e.executor = fake
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     map[string][]string
+	}{
+		{
+			name:     "namespaced and cluster-scoped resources",
+			manifest: "kind: ClusterRole\nmetadata:\n  name: reader\n---\nkind: Deployment\nmetadata:\n  name: my-app\n  namespace: my-app\n",
+			want: map[string][]string{
+				"ha":     {"Deployment"},
+				"rbac":   {"ClusterRole"},
+				"labels": {"ClusterRole", "Deployment"},
+			},
+		},
+		{
+			name:     "no cluster-scoped resource",
+			manifest: "kind: Service\nmetadata:\n  name: my-app\n",
+			want: map[string][]string{
+				"ha":     {"Service"},
+				"rbac":   nil,
+				"labels": {"Service"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := e.Evaluate(context.Background(), []byte(tt.manifest))
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_LoadAndValidate_ServiceConfig tests that a service compliance config is merged over the global one
+func TestPolicyEvaluator_LoadAndValidate_ServiceConfig(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2025-01-01T00:00:00Z
+      isBlockingAfter: 2027-01-01T00:00:00Z
+`)
+	serviceDir := t.TempDir()
+	serviceConfigPath := filepath.Join(serviceDir, COMPLIANCE_CONFIG_FILENAME)
+	serviceConfig := `
+policies:
+  ha:
+    name: Service High Availability (strict)
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2025-01-01T00:00:00Z
+      isBlockingAfter: 2025-06-01T00:00:00Z
+`
+	if err := os.WriteFile(serviceConfigPath, []byte(serviceConfig), 0644); err != nil {
+		t.Fatalf("failed to write service config: %v", err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		serviceConfigPath string
+		wantLevel         string
+		wantName          string
+	}{
+		{
+			name:      "global config only",
+			wantLevel: POLICY_LEVEL_RECOMMEND,
+			wantName:  "Service High Availability",
+		},
+		{
+			name:              "missing service config is ignored",
+			serviceConfigPath: filepath.Join(serviceDir, "missing", COMPLIANCE_CONFIG_FILENAME),
+			wantLevel:         POLICY_LEVEL_RECOMMEND,
+			wantName:          "Service High Availability",
+		},
+		{
+			name:              "service config tightens the enforcement date",
+			serviceConfigPath: serviceConfigPath,
+			wantLevel:         POLICY_LEVEL_BLOCK,
+			wantName:          "Service High Availability (strict)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{ServiceConfigPath: tt.serviceConfigPath})
+			e.SetClock(func() time.Time { return now })
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+
+			levels, err := e.DetermineEnforcementLevel(nil, "")
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.wantLevel {
+				t.Errorf("DetermineEnforcementLevel()[ha] = %q, want %q", levels["ha"], tt.wantLevel)
+			}
+			if got := e.data.ComplianceConfig.Policies["ha"].Name; got != tt.wantName {
+				t.Errorf("policy name = %q, want %q", got, tt.wantName)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_LoadAndValidate_ComplianceConfig tests the distinct errors of a missing, invalid and empty
+// compliance config
+func TestPolicyEvaluator_LoadAndValidate_ComplianceConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		missing  bool
+		config   string
+		wantErrs []string
+	}{
+		{
+			name:     "missing file",
+			missing:  true,
+			wantErrs: []string{"compliance config not found at ", "--policies-path", "gitops-kustomz init"},
+		},
+		{
+			name:     "parse error",
+			config:   "policies:\n  ha: [\n",
+			wantErrs: []string{"failed to parse compliance config ", "fix its YAML syntax"},
+		},
+		{
+			name:     "empty file",
+			config:   "",
+			wantErrs: []string{"no policies defined in compliance config "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			configPath := filepath.Join(dir, COMPLIANCE_CONFIG_FILENAME)
+			if !tt.missing {
+				if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+					t.Fatalf("failed to write compliance config: %v", err)
+				}
+			}
+
+			err := NewPolicyEvaluator(dir).LoadAndValidate()
+			if err == nil {
+				t.Fatal("LoadAndValidate() error = nil, want an error")
+			}
+			for _, want := range append(tt.wantErrs, configPath) {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("LoadAndValidate() error = %v, want error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_LoadAndValidate_FreshEvaluator tests that a newly created evaluator loads the policies
+// and determines their levels without any other setup
+func TestPolicyEvaluator_LoadAndValidate_FreshEvaluator(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	e := NewPolicyEvaluator(dir)
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	if got, want := e.data.fullPathToPolicy["ha"], filepath.Join(dir, "ha.rego"); got != want {
+		t.Errorf("LoadAndValidate() fullPathToPolicy[ha] = %q, want %q", got, want)
+	}
+
+	levels, err := e.DetermineEnforcementLevel(nil, "")
+	if err != nil {
+		t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+	}
+	if levels["ha"] != POLICY_LEVEL_BLOCK {
+		t.Errorf("DetermineEnforcementLevel() ha = %q, want %q", levels["ha"], POLICY_LEVEL_BLOCK)
+	}
+}
+
+// TestPolicyEvaluator_LoadAndValidate_TestRules tests that a policy test file must define at least one test_ rule
+func TestPolicyEvaluator_LoadAndValidate_TestRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		testRego string
+		wantErr  bool
+	}{
+		{
+			name:     "test rules",
+			testRego: testPolicyTestRego,
+		},
+		{
+			name:     "test rule without if",
+			testRego: "package main\n\ntest_deny_deployment {\n\tcount(data.main.deny) > 0\n}\n",
+		},
+		{
+			name:     "empty test file",
+			testRego: "",
+			wantErr:  true,
+		},
+		{
+			name:     "only helper rules",
+			testRego: "package main\n\nimport rego.v1\n\ndeployment := {\"contents\": {\"kind\": \"Deployment\"}}\n\nhas_deny if {\n\tcount(data.main.deny) > 0\n}\n",
+			wantErr:  true,
+		},
+		{
+			name:     "commented out test rule",
+			testRego: "package main\n\n# test_deny_deployment if {\n# \tcount(data.main.deny) > 0\n# }\n",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestPoliciesDir(t, "policies:\n  ha:\n    name: Service High Availability\n    type: opa\n    filePath: ha.rego\n")
+			testPath := filepath.Join(dir, "ha_test.rego")
+			if err := os.WriteFile(testPath, []byte(tt.testRego), 0644); err != nil {
+				t.Fatalf("failed to write test file: %v", err)
+			}
+
+			err := NewPolicyEvaluator(dir).LoadAndValidate()
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("LoadAndValidate() error = %v, want nil", err)
+				}
+				return
+			}
+			want := "policy ha: test file " + testPath + " has no test rule"
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Errorf("LoadAndValidate() error = %v, want error containing %q", err, want)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_DetermineEnforcementLevel_OverrideQuorum tests that a policy is overridden only once
+// enough distinct users posted its override comment
+func TestPolicyEvaluator_DetermineEnforcementLevel_OverrideQuorum(t *testing.T) {
+	blockingSince := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	override := func(user string) *models.Comment {
+		return &models.Comment{Body: "/sp-override-ha", User: user}
+	}
+
+	tests := []struct {
+		name              string
+		requiredApprovals int
+		comments          []*models.Comment
+		wantLevel         string
+		wantApprovals     int
+	}{
+		{
+			name:      "single override by default",
+			comments:  []*models.Comment{override("alice")},
+			wantLevel: POLICY_LEVEL_OVERRIDE,
+		},
+		{
+			name:              "no override",
+			requiredApprovals: 2,
+			comments:          []*models.Comment{{Body: "LGTM", User: "bob"}},
+			wantLevel:         POLICY_LEVEL_BLOCK,
+			wantApprovals:     0,
+		},
+		{
+			name:              "insufficient quorum",
+			requiredApprovals: 2,
+			comments:          []*models.Comment{override("alice")},
+			wantLevel:         POLICY_LEVEL_BLOCK,
+			wantApprovals:     1,
+		},
+		{
+			name:              "same user twice is counted once",
+			requiredApprovals: 2,
+			comments:          []*models.Comment{override("alice"), override("Alice")},
+			wantLevel:         POLICY_LEVEL_BLOCK,
+			wantApprovals:     1,
+		},
+		{
+			name:              "sufficient quorum",
+			requiredApprovals: 2,
+			comments:          []*models.Comment{override("alice"), {Body: "LGTM", User: "carol"}, override("bob")},
+			wantLevel:         POLICY_LEVEL_OVERRIDE,
+			wantApprovals:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newTestPolicyConfig()
+			policy.Enforcement.IsBlockingAfter = &blockingSince
+			policy.Enforcement.Override.Comment = "/sp-override-ha"
+			policy.Enforcement.Override.RequiredApprovals = tt.requiredApprovals
+
+			e := NewPolicyEvaluator("")
+			e.data.ComplianceConfig = models.ComplianceConfig{
+				Policies: map[string]models.PolicyConfig{"ha": policy},
+			}
+			e.data.overrideCmdToPolicyId = map[string]string{"/sp-override-ha": "ha"}
+
+			levels, err := e.DetermineEnforcementLevel(tt.comments, "")
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.wantLevel {
+				t.Errorf("DetermineEnforcementLevel()[ha] = %q, want %q", levels["ha"], tt.wantLevel)
+			}
+			if tt.requiredApprovals > 1 {
+				if got := len(e.overrideAuthors(tt.comments)["ha"]); got != tt.wantApprovals {
+					t.Errorf("overrideAuthors()[ha] has %d authors, want %d", got, tt.wantApprovals)
+				}
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_DetermineEnforcementLevel_Environments tests that the enforcement dates of an environment
+// take precedence over the global ones, at the same time a policy blocks in prod and only warns in stg
+func TestPolicyEvaluator_DetermineEnforcementLevel_Environments(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2025-01-01T00:00:00Z
+      isWarningAfter: 2025-02-01T00:00:00Z
+      isBlockingAfter: 2025-09-01T00:00:00Z
+      environments:
+        prod:
+          isBlockingAfter: 2025-03-01T00:00:00Z
+        dev:
+          isWarningAfter: 2025-12-01T00:00:00Z
+          isBlockingAfter: 2026-01-01T00:00:00Z
+`)
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluator(dir)
+	e.SetClock(func() time.Time { return now })
+	e.SetExecutor(&testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`)},
+				fmt.Errorf("exit status 1")
+		},
+	})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+
+	tests := []struct {
+		env       string
+		wantLevel string
+	}{
+		{env: "", wantLevel: POLICY_LEVEL_WARNING},
+		{env: "prod", wantLevel: POLICY_LEVEL_BLOCK},
+		{env: "stg", wantLevel: POLICY_LEVEL_WARNING},
+		{env: "dev", wantLevel: POLICY_LEVEL_RECOMMEND},
+	}
+	for _, tt := range tests {
+		t.Run("env "+tt.env, func(t *testing.T) {
+			levels, err := e.DetermineEnforcementLevel(nil, tt.env)
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.wantLevel {
+				t.Errorf("DetermineEnforcementLevel(%q)[ha] = %q, want %q", tt.env, levels["ha"], tt.wantLevel)
+			}
+		})
+	}
+
+	t.Run("policy matrix", func(t *testing.T) {
+		build := models.BuildManifestResult{
+			EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+				"stg":  {Environment: "stg", AfterManifest: []byte("kind: Deployment\n")},
+				"prod": {Environment: "prod", AfterManifest: []byte("kind: Deployment\n")},
+			},
+		}
+		got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+		if err != nil {
+			t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+		}
+		if n := len(got.PolicyMatrix["prod"].BlockingPolicies); n != 1 {
+			t.Errorf("prod has %d blocking policies, want 1", n)
+		}
+		if n := len(got.PolicyMatrix["stg"].WarningPolicies); n != 1 {
+			t.Errorf("stg has %d warning policies, want 1", n)
+		}
+		if n := got.EnvironmentSummary["prod"].PolicyCounts.BlockingFailedCount; n != 1 {
+			t.Errorf("prod BlockingFailedCount = %d, want 1", n)
+		}
+		if n := got.EnvironmentSummary["stg"].PolicyCounts.BlockingFailedCount; n != 0 {
+			t.Errorf("stg BlockingFailedCount = %d, want 0", n)
+		}
+	})
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_ReportFixed tests that violations of the base resolved by the PR are listed as fixed
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_ReportFixed(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	outputs := map[string]string{
+		"base":          `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"},{"msg":"no anti-affinity"}]}]`,
+		"head-resolved": `[{"filename":"Combined","namespace":"main","successes":1}]`,
+		"head-partial":  `[{"filename":"Combined","namespace":"main","failures":[{"msg":"no anti-affinity"}]}]`,
+	}
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			content, err := os.ReadFile(args[5])
+			if err != nil {
+				return nil, err
+			}
+			return &command.Result{Stdout: []byte(outputs[string(content)])}, fmt.Errorf("exit status 1")
+		},
+	}
+
+	tests := []struct {
+		name        string
+		reportFixed bool
+		head        string
+		wantPassing bool
+		wantFixed   []string
+	}{
+		{
+			name:        "PR resolves the failing policy",
+			reportFixed: true,
+			head:        "head-resolved",
+			wantPassing: true,
+			wantFixed:   []string{"replicas too low", "no anti-affinity"},
+		},
+		{
+			name:        "PR resolves part of the violations",
+			reportFixed: true,
+			head:        "head-partial",
+			wantFixed:   []string{"replicas too low"},
+		},
+		{
+			name:        "disabled",
+			head:        "head-resolved",
+			wantPassing: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{ReportFixed: tt.reportFixed})
+			e.executor = fake
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			build := models.BuildManifestResult{
+				EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+					"stg": {Environment: "stg", BeforeManifest: []byte("base"), AfterManifest: []byte(tt.head)},
+				},
+			}
+
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+			result := got.PolicyMatrix["stg"].BlockingPolicies[0]
+			if result.IsPassing != tt.wantPassing {
+				t.Errorf("GeneratePolicyEvalResultForManifests() IsPassing = %v, want %v", result.IsPassing, tt.wantPassing)
+			}
+			if !reflect.DeepEqual(result.FixedFailMessages, tt.wantFixed) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() FixedFailMessages = %v, want %v", result.FixedFailMessages, tt.wantFixed)
+			}
+			fixedPolicies := got.PolicyMatrix["stg"].FixedPolicies
+			if wantListed := tt.wantFixed != nil; (len(fixedPolicies) == 1) != wantListed {
+				t.Errorf("GeneratePolicyEvalResultForManifests() FixedPolicies = %v, want listed %v", fixedPolicies, wantListed)
+			}
+			if result.PreExistingFailMessages != nil || result.IsFailingOnBase {
+				t.Errorf("GeneratePolicyEvalResultForManifests() should not attribute pre-existing violations without RequireCleanBase, got %+v", result)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_Evaluate_JSON tests that a JSON manifest is evaluated as YAML documents
+func TestPolicyEvaluator_Evaluate_JSON(t *testing.T) {
+	dir := newTestPoliciesDir(t, "policies:\n  ha:\n    name: Service High Availability\n    type: opa\n    filePath: ha.rego\n")
+	var evaluated string
+	e := NewPolicyEvaluator(dir)
+	e.SetExecutor(&testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			content, err := os.ReadFile(args[5])
+			if err != nil {
+				return nil, err
+			}
+			evaluated = string(content)
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`)}, fmt.Errorf("exit status 1")
+		},
+	})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+
+	manifest := `{"apiVersion":"v1","kind":"List","items":[{"kind":"Deployment","metadata":{"name":"my-app"},"spec":{"replicas":1}},{"kind":"Service","metadata":{"name":"my-app"}}]}`
+	got, err := e.Evaluate(context.Background(), []byte(manifest))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !reflect.DeepEqual(got["ha"], []string{"replicas too low"}) {
+		t.Errorf("Evaluate()[ha] = %v, want [replicas too low]", got["ha"])
+	}
+	want := "kind: Deployment\nmetadata:\n  name: my-app\nspec:\n  replicas: 1\n---\nkind: Service\nmetadata:\n  name: my-app\n"
+	if evaluated != want {
+		t.Errorf("conftest evaluated manifest\n%s\nwant\n%s", evaluated, want)
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_EvaluationErrors tests that a policy failing to evaluate
+// is reported apart from the violations and fails the blocking check, while the other policies are still evaluated
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_EvaluationErrors(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+  pdb:
+    name: Pod Disruption Budget
+    type: opa
+    filePath: pdb.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	for name, content := range map[string]string{
+		"pdb.rego":      "package pdb\n",
+		"pdb_test.rego": "package pdb\n\ntest_pdb if { true }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	// pdb does not compile, failing every conftest call it is part of, ha passes
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			for _, arg := range args {
+				if filepath.Base(arg) == "pdb.rego" {
+					return &command.Result{Stderr: []byte("pdb.rego:1: rego_parse_error: unexpected eof token")}, fmt.Errorf("exit status 1")
+				}
+			}
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","successes":1}]`)}, nil
+		},
+	}
+
+	for _, batch := range []bool{false, true} {
+		t.Run(fmt.Sprintf("batch %v", batch), func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{BatchConftest: batch})
+			e.executor = fake
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			build := models.BuildManifestResult{
+				EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+					"stg": {Environment: "stg", AfterManifest: []byte("kind: Deployment\n")},
+				},
+			}
+
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+			matrix := got.PolicyMatrix["stg"]
+			if len(matrix.BlockingPolicies) != 1 || matrix.BlockingPolicies[0].PolicyId != "ha" || !matrix.BlockingPolicies[0].IsPassing {
+				t.Errorf("GeneratePolicyEvalResultForManifests() BlockingPolicies = %+v, want ha passing", matrix.BlockingPolicies)
+			}
+			if len(matrix.ErroredPolicies) != 1 || matrix.ErroredPolicies[0].PolicyId != "pdb" {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() ErroredPolicies = %+v, want pdb", matrix.ErroredPolicies)
+			}
+			if errored := matrix.ErroredPolicies[0]; errored.IsPassing || !strings.Contains(errored.Error, "rego_parse_error") {
+				t.Errorf("GeneratePolicyEvalResultForManifests() errored policy = %+v, want failing with the conftest error", errored)
+			}
+			summary := got.EnvironmentSummary["stg"]
+			if summary.PassingStatus.PassBlockingCheck {
+				t.Errorf("GeneratePolicyEvalResultForManifests() PassBlockingCheck = true, want false with an evaluation error")
+			}
+			if counts := summary.PolicyCounts; counts.TotalErrored != 1 || counts.TotalCount != 2 || counts.BlockingFailedCount != 0 {
+				t.Errorf("GeneratePolicyEvalResultForManifests() PolicyCounts = %+v, want 1 errored of 2 and no blocking failure", counts)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_LogFields tests that the logs of the concurrent policy
+// evaluations carry the environment they belong to and the fields of the caller
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_LogFields(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+  pdb:
+    name: Pod Disruption Budget
+    type: opa
+    filePath: pdb.rego
+`)
+	for name, content := range map[string]string{
+		"pdb.rego":      "package pdb\n",
+		"pdb_test.rego": "package pdb\n\ntest_pdb if { true }\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Concurrency: 2})
+	e.executor = &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","successes":1}]`)}, nil
+		},
+	}
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg":  {Environment: "stg", AfterManifest: []byte("kind: Deployment\nmetadata:\n  name: stg\n")},
+			"prod": {Environment: "prod", AfterManifest: []byte("kind: Deployment\nmetadata:\n  name: prod\n")},
+		},
+	}
+
+	hook := logtest.NewLocal(logger.Logger)
+	ctx := logctx.WithFields(context.Background(), log.Fields{"service": "my-app"})
+	if _, err := e.GeneratePolicyEvalResultForManifests(ctx, build, nil); err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+
+	evaluated := map[string]int{}
+	for _, entry := range hook.AllEntries() {
+		if !strings.HasPrefix(entry.Message, "evaluating policy") {
+			continue
+		}
+		if entry.Data["service"] != "my-app" {
+			t.Errorf("log %q has service %v, want my-app", entry.Message, entry.Data["service"])
+		}
+		env, _ := entry.Data["environment"].(string)
+		evaluated[env]++
+	}
+	if want := map[string]int{"stg": 2, "prod": 2}; !reflect.DeepEqual(evaluated, want) {
+		t.Errorf("policy evaluation logs per environment = %v, want %v", evaluated, want)
+	}
+}
+
+// TestEvaluationOrder tests that environments are evaluated in the configured order, the unlisted ones last in name order
+func TestEvaluationOrder(t *testing.T) {
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{"stg": {}, "prod": {}, "dev": {}, "qa": {}},
+		Environments:     []string{"stg", "prod", "missing", "stg"},
+	}
+	if got, want := evaluationOrder(build), []string{"stg", "prod", "dev", "qa"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("evaluationOrder() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	manifestpkg "github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// Annotation prefix exempting a resource from a policy until a date, e.g. gitops-kustomz.io/exempt-until.ha: 2025-12-01
+	EXEMPT_UNTIL_ANNOTATION_PREFIX = "gitops-kustomz.io/exempt-until."
+	// Override reason of a policy result passing once the exempted resources are left out
+	EXEMPTION_REASON_FORMAT = "timed-exemption (expires %s)"
+)
+
+// withoutExemptedResources removes the documents exempted from the policy at the given time, expired or invalid
+// exemptions and unparsable documents are kept. returns: remaining manifest, earliest expiry of the active exemptions,
+// any document exempted
+func withoutExemptedResources(manifest []byte, policyId string, now time.Time) ([]byte, time.Time, bool) {
+	if !bytes.Contains(manifest, []byte(EXEMPT_UNTIL_ANNOTATION_PREFIX+policyId)) {
+		return manifest, time.Time{}, false
+	}
+
+	kept := []string{}
+	var expiresAt time.Time
+	exempted := false
+	for _, doc := range manifestpkg.SplitDocuments(manifest) {
+		var header struct {
+			Metadata struct {
+				Name        string            `yaml:"name"`
+				Annotations map[string]string `yaml:"annotations"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc), &header); err != nil {
+			kept = append(kept, doc)
+			continue
+		}
+
+		value, ok := header.Metadata.Annotations[EXEMPT_UNTIL_ANNOTATION_PREFIX+policyId]
+		if !ok {
+			kept = append(kept, doc)
+			continue
+		}
+		lg := logger.WithField("policyId", policyId).WithField("resource", header.Metadata.Name).WithField("until", value)
+		until, err := parseExemptionDate(value)
+		if err != nil {
+			lg.WithField("error", err).Warn("Ignoring invalid policy exemption")
+			kept = append(kept, doc)
+			continue
+		}
+		if !now.Before(until) {
+			lg.Info("Ignoring expired policy exemption")
+			kept = append(kept, doc)
+			continue
+		}
+		if !exempted || until.Before(expiresAt) {
+			expiresAt = until
+		}
+		exempted = true
+	}
+	return manifestpkg.JoinDocuments(kept), expiresAt, exempted
+}
+
+// parseExemptionDate parses the date of an exemption annotation, a date (2025-12-01, midnight UTC) or an RFC3339 time
+func parseExemptionDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if until, err := time.Parse(time.DateOnly, value); err == nil {
+		return until, nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("exemption date must be YYYY-MM-DD or RFC3339, got: %s", value)
+	}
+	return until, nil
+}
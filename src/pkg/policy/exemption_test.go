@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Exemption tests that resource exemptions are only honored while unexpired
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Exemption(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+`)
+	const exemptApp = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+  annotations:
+    gitops-kustomz.io/exempt-until.ha: %s
+`
+	const otherApp = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other-app
+`
+	// every Deployment fails with its name
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			content, err := os.ReadFile(args[5])
+			if err != nil {
+				return nil, err
+			}
+			failures := []string{}
+			for _, line := range strings.Split(string(content), "\n") {
+				if name, ok := strings.CutPrefix(line, "  name: "); ok {
+					failures = append(failures, fmt.Sprintf(`{"msg":%q}`, name))
+				}
+			}
+			stdout := fmt.Sprintf(`[{"filename":"Combined","namespace":"main","failures":[%s]}]`, strings.Join(failures, ","))
+			if len(failures) == 0 {
+				return &command.Result{Stdout: []byte(stdout)}, nil
+			}
+			return &command.Result{Stdout: []byte(stdout)}, fmt.Errorf("exit status 1")
+		},
+	}
+
+	tests := []struct {
+		name         string
+		manifest     string
+		wantReason   string
+		wantBlocking int
+		wantOmitted  int
+	}{
+		{
+			name:        "active exemption",
+			manifest:    fmt.Sprintf(exemptApp, "2025-12-01"),
+			wantReason:  "timed-exemption (expires 2025-12-01)",
+			wantOmitted: 1,
+		},
+		{
+			name:         "expired exemption",
+			manifest:     fmt.Sprintf(exemptApp, "2025-11-01"),
+			wantBlocking: 1,
+		},
+		{
+			name:         "invalid exemption date",
+			manifest:     fmt.Sprintf(exemptApp, "next month"),
+			wantBlocking: 1,
+		},
+		{
+			name:         "another resource still fails",
+			manifest:     fmt.Sprintf(exemptApp, "2025-12-01") + "---\n" + otherApp,
+			wantBlocking: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluator(dir)
+			e.executor = fake
+			e.SetClock(func() time.Time { return time.Date(2025, 11, 15, 0, 0, 0, 0, time.UTC) })
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			build := models.BuildManifestResult{
+				EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+					"prod": {Environment: "prod", AfterManifest: []byte(tt.manifest)},
+				},
+			}
+
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, nil)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+			result := got.PolicyMatrix["prod"].BlockingPolicies[0]
+			if result.IsPassing {
+				t.Error("GeneratePolicyEvalResultForManifests() IsPassing = true, want the failure kept")
+			}
+			if result.OverrideReason != tt.wantReason {
+				t.Errorf("GeneratePolicyEvalResultForManifests() OverrideReason = %q, want %q", result.OverrideReason, tt.wantReason)
+			}
+			summary := got.EnvironmentSummary["prod"]
+			if summary.PolicyCounts.BlockingFailedCount != tt.wantBlocking {
+				t.Errorf("GeneratePolicyEvalResultForManifests() BlockingFailedCount = %d, want %d", summary.PolicyCounts.BlockingFailedCount, tt.wantBlocking)
+			}
+			if summary.PolicyCounts.TotalOmittedFailed != tt.wantOmitted {
+				t.Errorf("GeneratePolicyEvalResultForManifests() TotalOmittedFailed = %d, want %d", summary.PolicyCounts.TotalOmittedFailed, tt.wantOmitted)
+			}
+			if summary.PassingStatus.PassBlockingCheck != (tt.wantBlocking == 0) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() PassBlockingCheck = %v, want %v", summary.PassingStatus.PassBlockingCheck, tt.wantBlocking == 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/logctx"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+// regoPackagePattern matches the package declaration of a rego file, e.g. "package main"
+var regoPackagePattern = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z0-9_.]+)`)
+
+// opaServerInputPath is the path reported for manifest documents in the OPA server input,
+// mirroring the {path, contents} entries conftest builds with --combine
+const opaServerInputPath = "manifest.yaml"
+
+// regoPackageOf returns the package declared in the rego file at policyPath
+func regoPackageOf(policyPath string) (string, error) {
+	content, err := os.ReadFile(policyPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read policy file: %w", err)
+	}
+	match := regoPackagePattern.FindSubmatch(content)
+	if match == nil {
+		return "", fmt.Errorf("no package declaration found in %s", policyPath)
+	}
+	return string(match[1]), nil
+}
+
+// opaServerInput builds the same input conftest passes to policies with --combine:
+// a list of {path, contents} objects, one per manifest document
+func opaServerInput(mf []byte) ([]map[string]interface{}, error) {
+	input := []map[string]interface{}{}
+	for _, doc := range manifest.SplitDocuments(mf) {
+		var contents interface{}
+		if err := yaml.Unmarshal([]byte(doc), &contents); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest document: %w", err)
+		}
+		input = append(input, map[string]interface{}{
+			"path":     opaServerInputPath,
+			"contents": contents,
+		})
+	}
+	return input, nil
+}
+
+// evaluatePolicyWithOpaServer evaluates a single policy by querying the deny rule of its package
+// on a running OPA server's data API, the policy must be loaded in the server
+// returns: failureMsgs, evalError
+func (e *PolicyEvaluator) evaluatePolicyWithOpaServer(
+	ctx context.Context,
+	id string,
+	regoPackage string,
+	mf []byte,
+) ([]string, error) {
+	logctx.Entry(ctx, logger).Infof("evaluating policy %s on OPA server", id)
+
+	input, err := opaServerInput(mf)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode OPA input: %w", err)
+	}
+
+	url := strings.TrimSuffix(e.options.OpaURL, "/") + "/v1/data/" + strings.ReplaceAll(regoPackage, ".", "/") + "/deny"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OPA request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query OPA server: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OPA response: %w", err)
+	}
+	logctx.Entry(ctx, logger).Debugf("OPA server response: %s", string(respBody))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OPA server returned %s: %s", resp.Status, string(respBody))
+	}
+
+	// Sample OPA response, "result" is omitted if the deny rule is undefined, e.g. the package is not loaded
+	// {"result": ["Deployment 'my-app' must have at least 2 replicas", {"msg": "..."}]}
+	outputJson := struct {
+		Result *[]json.RawMessage `json:"result"`
+	}{}
+	if err := json.Unmarshal(respBody, &outputJson); err != nil {
+		return nil, fmt.Errorf("failed to parse OPA response: %w", err)
+	}
+	if outputJson.Result == nil {
+		if e.options.EmptyResultsAsPass {
+			logctx.Entry(ctx, logger).WithField("policyId", id).Info("OPA server returned no result, treating as a pass")
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("no result found for policy %s in OPA response, is package %s loaded in the server: %s", id, regoPackage, string(respBody))
+	}
+
+	failureMsgs := []string{}
+	for _, raw := range *outputJson.Result {
+		// deny rules return either plain messages or objects with a msg field, as supported by conftest
+		var msg string
+		if err := json.Unmarshal(raw, &msg); err == nil {
+			failureMsgs = append(failureMsgs, msg)
+			continue
+		}
+		var obj struct {
+			Msg string `json:"msg"`
+		}
+		if err := json.Unmarshal(raw, &obj); err != nil || obj.Msg == "" {
+			return nil, fmt.Errorf("unexpected deny decision in OPA response: %s", string(raw))
+		}
+		failureMsgs = append(failureMsgs, obj.Msg)
+	}
+	return failureMsgs, nil
+}
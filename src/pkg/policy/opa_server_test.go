@@ -0,0 +1,241 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const opaTestManifest = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: my-app
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: my-app
+`
+
+// newFakeOpaServer serves OPA data API decisions, recording the queried paths and inputs
+func newFakeOpaServer(t *testing.T, status int, response string) (*httptest.Server, *[]string, *[]interface{}) {
+	t.Helper()
+	paths := []string{}
+	inputs := []interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		var body struct {
+			Input interface{} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("fake OPA server failed to decode request: %v", err)
+		}
+		inputs = append(inputs, body.Input)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths, &inputs
+}
+
+// TestPolicyEvaluator_evaluatePolicyWithOpaServer tests mapping OPA deny decisions to fail messages
+func TestPolicyEvaluator_evaluatePolicyWithOpaServer(t *testing.T) {
+	tests := []struct {
+		name               string
+		status             int
+		response           string
+		emptyResultsAsPass bool
+		wantMsgs           []string
+		wantErr            string
+	}{
+		{
+			name:     "deny rule undefined, package not loaded",
+			status:   http.StatusOK,
+			response: `{}`,
+			wantErr:  "no result found for policy ha in OPA response",
+		},
+		{
+			name:               "deny rule undefined, empty results as pass",
+			status:             http.StatusOK,
+			response:           `{}`,
+			emptyResultsAsPass: true,
+			wantMsgs:           []string{},
+		},
+		{
+			name:     "allow, empty deny set",
+			status:   http.StatusOK,
+			response: `{"result": []}`,
+			wantMsgs: []string{},
+		},
+		{
+			name:     "deny with string messages",
+			status:   http.StatusOK,
+			response: `{"result": ["replicas too low", "no anti-affinity"]}`,
+			wantMsgs: []string{"replicas too low", "no anti-affinity"},
+		},
+		{
+			name:     "deny with msg objects",
+			status:   http.StatusOK,
+			response: `{"result": [{"msg": "replicas too low", "details": {}}]}`,
+			wantMsgs: []string{"replicas too low"},
+		},
+		{
+			name:     "unexpected decision",
+			status:   http.StatusOK,
+			response: `{"result": [42]}`,
+			wantErr:  "unexpected deny decision",
+		},
+		{
+			name:     "server error",
+			status:   http.StatusInternalServerError,
+			response: `{"code": "internal_error"}`,
+			wantErr:  "OPA server returned 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, paths, inputs := newFakeOpaServer(t, tt.status, tt.response)
+			e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{
+				Backend:            POLICY_BACKEND_OPA_SERVER,
+				OpaURL:             server.URL + "/",
+				EmptyResultsAsPass: tt.emptyResultsAsPass,
+			})
+
+			got, err := e.evaluatePolicyWithOpaServer(context.Background(), "ha", "gitops.ha", []byte(opaTestManifest))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("evaluatePolicyWithOpaServer() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("evaluatePolicyWithOpaServer() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.wantMsgs) {
+				t.Errorf("evaluatePolicyWithOpaServer() = %v, want %v", got, tt.wantMsgs)
+			}
+
+			if len(*paths) != 1 || (*paths)[0] != "POST /v1/data/gitops/ha/deny" {
+				t.Errorf("evaluatePolicyWithOpaServer() queried %v, want [POST /v1/data/gitops/ha/deny]", *paths)
+			}
+			// input mirrors conftest --combine: one {path, contents} entry per document
+			input, ok := (*inputs)[0].([]interface{})
+			if !ok || len(input) != 2 {
+				t.Fatalf("evaluatePolicyWithOpaServer() input = %v, want 2 documents", (*inputs)[0])
+			}
+			contents := input[1].(map[string]interface{})["contents"].(map[string]interface{})
+			if contents["kind"] != "Service" {
+				t.Errorf("evaluatePolicyWithOpaServer() input[1].contents.kind = %v, want Service", contents["kind"])
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_Evaluate_OpaServerBackend tests that the opa-server backend queries each policy's package
+func TestPolicyEvaluator_Evaluate_OpaServerBackend(t *testing.T) {
+	server, paths, _ := newFakeOpaServer(t, http.StatusOK, `{"result": ["failed"]}`)
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`)
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{Backend: POLICY_BACKEND_OPA_SERVER, OpaURL: server.URL})
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+
+	got, err := e.Evaluate(context.Background(), []byte(opaTestManifest))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, map[string][]string{"ha": {"failed"}}) {
+		t.Errorf("Evaluate() = %v, want map[ha:[failed]]", got)
+	}
+	if len(*paths) != 1 || (*paths)[0] != "POST /v1/data/main/deny" {
+		t.Errorf("Evaluate() queried %v, want [POST /v1/data/main/deny]", *paths)
+	}
+}
+
+// TestPolicyEvaluator_Evaluate_OpaServerBackendCacheDir tests that OPA server decisions are not persisted across
+// runs, the policies loaded in the server can change without the local rego files
+func TestPolicyEvaluator_Evaluate_OpaServerBackendCacheDir(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+`)
+	cacheDir := t.TempDir()
+
+	for _, response := range []string{`{"result": []}`, `{"result": ["failed"]}`} {
+		server, paths, _ := newFakeOpaServer(t, http.StatusOK, response)
+		e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{
+			Backend:  POLICY_BACKEND_OPA_SERVER,
+			OpaURL:   server.URL,
+			CacheDir: cacheDir,
+		})
+		if err := e.LoadAndValidate(); err != nil {
+			t.Fatalf("LoadAndValidate() error = %v", err)
+		}
+		if _, err := e.Evaluate(context.Background(), []byte(opaTestManifest)); err != nil {
+			t.Fatalf("Evaluate() error = %v", err)
+		}
+		if len(*paths) != 1 {
+			t.Errorf("Evaluate() queried the OPA server %d times, want 1 with the response %s", len(*paths), response)
+		}
+	}
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("failed to read cache dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Evaluate() persisted %d cache entries, want none with the opa-server backend", len(entries))
+	}
+}
+
+// TestRegoPackageOf tests parsing the package declaration of rego files
+func TestRegoPackageOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "simple package",
+			content: testPolicyRego,
+			want:    "main",
+		},
+		{
+			name:    "nested package after comments",
+			content: "# High Availability Policy\npackage gitops.policies.ha\n\nimport rego.v1\n",
+			want:    "gitops.policies.ha",
+		},
+		{
+			name:    "no package",
+			content: "import rego.v1\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := regoPackageOf(writeTestPolicyFile(t, tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("regoPackageOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("regoPackageOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
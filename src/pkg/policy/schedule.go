@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"sort"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// levelOrder orders transitions of the same policy happening at the same time
+var levelOrder = map[string]int{
+	POLICY_LEVEL_RECOMMEND: 0,
+	POLICY_LEVEL_WARNING:   1,
+	POLICY_LEVEL_BLOCK:     2,
+}
+
+// EnforcementSchedule lists the upcoming enforcement level transitions of all policies in chronological order,
+// computed from the enforcement dates only, without evaluating any manifest
+// Transitions that already happened according to the evaluator's clock are omitted
+func (e *PolicyEvaluator) EnforcementSchedule() []models.EnforcementTransition {
+	now := e.clock()
+	transitions := []models.EnforcementTransition{}
+
+	for policyId, policy := range e.data.ComplianceConfig.Policies {
+		enforcement := policy.Enforcement
+		for _, step := range []struct {
+			level string
+			date  *time.Time
+		}{
+			{POLICY_LEVEL_RECOMMEND, enforcement.InEffectAfter},
+			{POLICY_LEVEL_WARNING, enforcement.IsWarningAfter},
+			{POLICY_LEVEL_BLOCK, enforcement.IsBlockingAfter},
+		} {
+			if step.date == nil || !step.date.After(now) {
+				continue
+			}
+			transitions = append(transitions, models.EnforcementTransition{
+				PolicyId:   policyId,
+				PolicyName: policy.Name,
+				Level:      step.level,
+				At:         *step.date,
+			})
+		}
+	}
+
+	sort.Slice(transitions, func(i, j int) bool {
+		a, b := transitions[i], transitions[j]
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+		if a.PolicyId != b.PolicyId {
+			return a.PolicyId < b.PolicyId
+		}
+		return levelOrder[a.Level] < levelOrder[b.Level]
+	})
+	return transitions
+}
+
+// PolicyLevels lists the current enforcement level of all policies sorted by id, determined by the evaluator's clock
+// without any override comment
+func (e *PolicyEvaluator) PolicyLevels() ([]models.PolicyLevel, error) {
+	levels, err := e.DetermineEnforcementLevel(nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	policyLevels := make([]models.PolicyLevel, 0, len(levels))
+	for policyId, level := range levels {
+		policy := e.data.ComplianceConfig.Policies[policyId]
+		policyLevels = append(policyLevels, models.PolicyLevel{
+			PolicyId:        policyId,
+			PolicyName:      policy.Name,
+			Level:           level,
+			OverrideCommand: policy.Enforcement.Override.Comment,
+		})
+	}
+	sort.Slice(policyLevels, func(i, j int) bool { return policyLevels[i].PolicyId < policyLevels[j].PolicyId })
+	return policyLevels, nil
+}
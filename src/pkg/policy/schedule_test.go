@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestPolicyEvaluator_EnforcementSchedule tests the chronological list of upcoming enforcement transitions
+func TestPolicyEvaluator_EnforcementSchedule(t *testing.T) {
+	date := func(s string) *time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatalf("failed to parse date %s: %v", s, err)
+		}
+		return &d
+	}
+
+	e := NewPolicyEvaluator("")
+	e.SetClock(func() time.Time { return *date("2025-06-01T00:00:00Z") })
+	e.data.ComplianceConfig = models.ComplianceConfig{
+		Policies: map[string]models.PolicyConfig{
+			"ha": {
+				Name: "Service High Availability",
+				Enforcement: models.EnforcementConfig{
+					InEffectAfter:   date("2025-01-01T00:00:00Z"), // already in effect, omitted
+					IsWarningAfter:  date("2025-07-01T00:00:00Z"),
+					IsBlockingAfter: date("2025-09-01T00:00:00Z"),
+				},
+			},
+			"tls": {
+				Name: "Ingress TLS",
+				Enforcement: models.EnforcementConfig{
+					InEffectAfter:   date("2025-06-15T00:00:00Z"),
+					IsWarningAfter:  date("2025-07-01T00:00:00Z"),
+					IsBlockingAfter: date("2025-07-01T00:00:00Z"),
+				},
+			},
+			"labels": {
+				Name: "Required Labels",
+			},
+		},
+	}
+
+	got := e.EnforcementSchedule()
+	want := []struct {
+		policyId string
+		level    string
+		at       string
+	}{
+		{"tls", POLICY_LEVEL_RECOMMEND, "2025-06-15T00:00:00Z"},
+		{"ha", POLICY_LEVEL_WARNING, "2025-07-01T00:00:00Z"},
+		{"tls", POLICY_LEVEL_WARNING, "2025-07-01T00:00:00Z"},
+		{"tls", POLICY_LEVEL_BLOCK, "2025-07-01T00:00:00Z"},
+		{"ha", POLICY_LEVEL_BLOCK, "2025-09-01T00:00:00Z"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("EnforcementSchedule() returned %d transitions, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].PolicyId != w.policyId || got[i].Level != w.level || !got[i].At.Equal(*date(w.at)) {
+			t.Errorf("EnforcementSchedule()[%d] = %s %s %s, want %s %s %s",
+				i, got[i].PolicyId, got[i].Level, got[i].At.Format(time.RFC3339), w.policyId, w.level, w.at)
+		}
+	}
+	if got[0].PolicyName != "Ingress TLS" {
+		t.Errorf("EnforcementSchedule()[0].PolicyName = %q, want %q", got[0].PolicyName, "Ingress TLS")
+	}
+}
+
+// TestPolicyEvaluator_EnforcementSchedule_NoUpcoming tests that past transitions are omitted
+func TestPolicyEvaluator_EnforcementSchedule_NoUpcoming(t *testing.T) {
+	past := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewPolicyEvaluator("")
+	e.SetClock(func() time.Time { return past.AddDate(1, 0, 0) })
+	e.data.ComplianceConfig = models.ComplianceConfig{
+		Policies: map[string]models.PolicyConfig{
+			"ha": {Name: "Service High Availability", Enforcement: models.EnforcementConfig{IsBlockingAfter: &past}},
+		},
+	}
+
+	if got := e.EnforcementSchedule(); len(got) != 0 {
+		t.Errorf("EnforcementSchedule() = %+v, want no transitions", got)
+	}
+}
+
+// TestPolicyEvaluator_PolicyLevels tests the current enforcement level of policies with mixed enforcement dates
+func TestPolicyEvaluator_PolicyLevels(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	past, future := now.AddDate(0, -1, 0), now.AddDate(0, 1, 0)
+
+	e := NewPolicyEvaluator("")
+	e.SetClock(func() time.Time { return now })
+	e.data.ComplianceConfig = models.ComplianceConfig{
+		Policies: map[string]models.PolicyConfig{
+			"ha": {
+				Name: "Service High Availability",
+				Enforcement: models.EnforcementConfig{
+					InEffectAfter:   &past,
+					IsWarningAfter:  &past,
+					IsBlockingAfter: &past,
+					Override:        models.OverrideConfig{Comment: "/sp-override-ha"},
+				},
+			},
+			"tls": {
+				Name: "Ingress TLS",
+				Enforcement: models.EnforcementConfig{
+					InEffectAfter:   &past,
+					IsWarningAfter:  &past,
+					IsBlockingAfter: &future,
+				},
+			},
+			"labels": {
+				Name:        "Required Labels",
+				Enforcement: models.EnforcementConfig{InEffectAfter: &past, IsWarningAfter: &future},
+			},
+			"quota": {
+				Name:        "Resource Quota",
+				Enforcement: models.EnforcementConfig{InEffectAfter: &future},
+			},
+		},
+	}
+
+	got, err := e.PolicyLevels()
+	if err != nil {
+		t.Fatalf("PolicyLevels() error = %v", err)
+	}
+	want := []models.PolicyLevel{
+		{PolicyId: "ha", PolicyName: "Service High Availability", Level: POLICY_LEVEL_BLOCK, OverrideCommand: "/sp-override-ha"},
+		{PolicyId: "labels", PolicyName: "Required Labels", Level: POLICY_LEVEL_RECOMMEND},
+		{PolicyId: "quota", PolicyName: "Resource Quota", Level: POLICY_LEVEL_NOT_IN_EFFECT},
+		{PolicyId: "tls", PolicyName: "Ingress TLS", Level: POLICY_LEVEL_WARNING},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("PolicyLevels() returned %d policies, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("PolicyLevels()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package policy
+
+import _ "embed"
+
+// complianceConfigSchema is the JSON schema of compliance-config.yaml
+// It is maintained alongside validateComplianceConfig, keep both in sync
+//
+//go:embed compliance-config.schema.json
+var complianceConfigSchema []byte
+
+// ComplianceConfigSchema returns the JSON schema of compliance-config.yaml, for editors to validate configs against
+func ComplianceConfigSchema() []byte {
+	return complianceConfigSchema
+}
@@ -0,0 +1,270 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+// loadTestSchema parses the embedded compliance config schema
+func loadTestSchema(t *testing.T) map[string]any {
+	t.Helper()
+	var schema map[string]any
+	if err := json.Unmarshal(ComplianceConfigSchema(), &schema); err != nil {
+		t.Fatalf("ComplianceConfigSchema() is not valid JSON: %v", err)
+	}
+	return schema
+}
+
+// validateAgainstSchema checks value against the subset of JSON schema keywords used by the compliance config schema
+func validateAgainstSchema(root, schema map[string]any, value any, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def, ok := root["definitions"].(map[string]any)[strings.TrimPrefix(ref, "#/definitions/")].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: unresolvable $ref %s", path, ref)
+		}
+		return validateAgainstSchema(root, def, value, path)
+	}
+
+	switch schema["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", path, value)
+		}
+		if min, ok := schema["minProperties"].(float64); ok && len(obj) < int(min) {
+			return fmt.Errorf("%s: want at least %d properties", path, int(min))
+		}
+		required, _ := schema["required"].([]any)
+		for _, req := range required {
+			if _, ok := obj[req.(string)]; !ok {
+				return fmt.Errorf("%s: missing required property %s", path, req)
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for key, v := range obj {
+			if prop, ok := props[key].(map[string]any); ok {
+				if err := validateAgainstSchema(root, prop, v, path+"."+key); err != nil {
+					return err
+				}
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					return fmt.Errorf("%s: unknown property %s", path, key)
+				}
+			case map[string]any:
+				if err := validateAgainstSchema(root, additional, v, path+"."+key); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", path, value)
+		}
+		if min, ok := schema["minLength"].(float64); ok && len(s) < int(min) {
+			return fmt.Errorf("%s: shorter than %d", path, int(min))
+		}
+		if max, ok := schema["maxLength"].(float64); ok && len(s) > int(max) {
+			return fmt.Errorf("%s: longer than %d", path, int(max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(s) {
+			return fmt.Errorf("%s: %q does not match %s", path, s, pattern)
+		}
+		if enum, ok := schema["enum"].([]any); ok && !containsAny(enum, s) {
+			return fmt.Errorf("%s: %q is not one of %v", path, s, enum)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", path, s)
+			}
+		}
+	}
+	return nil
+}
+
+func containsAny(values []any, v any) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// yamlToJSONValue decodes a yaml document into the values a JSON decoder would produce
+func yamlToJSONValue(t *testing.T, doc string) any {
+	t.Helper()
+	var raw any
+	if err := yaml.Unmarshal([]byte(doc), &raw); err != nil {
+		t.Fatalf("invalid test yaml: %v", err)
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("failed to convert test yaml to json: %v", err)
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		t.Fatalf("failed to convert test yaml to json: %v", err)
+	}
+	return value
+}
+
+// TestComplianceConfigSchema tests that the schema is a draft-07 schema whose references resolve
+func TestComplianceConfigSchema(t *testing.T) {
+	schema := loadTestSchema(t)
+	if schema["$schema"] != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("ComplianceConfigSchema() $schema = %v, want draft-07", schema["$schema"])
+	}
+
+	refPattern := regexp.MustCompile(`"\$ref":\s*"#/definitions/([A-Za-z]+)"`)
+	definitions := schema["definitions"].(map[string]any)
+	for _, match := range refPattern.FindAllStringSubmatch(string(ComplianceConfigSchema()), -1) {
+		if _, ok := definitions[match[1]]; !ok {
+			t.Errorf("ComplianceConfigSchema() references undefined definition %q", match[1])
+		}
+	}
+}
+
+// TestComplianceConfigSchema_Validate tests known-good and known-bad configs against the schema
+func TestComplianceConfigSchema_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{
+			name: "valid config",
+			config: `
+policies:
+  service-high-availability:
+    name: Service High Availability
+    description: Deployments must have at least 2 replicas
+    type: opa
+    filePath: ha.rego
+    externalLink: https://example.com/ha
+    messageTemplate: "[{{.PolicyId}}] {{.Message}}"
+    enforcement:
+      inEffectAfter: 2025-01-01T00:00:00Z
+      isWarningAfter: 2025-02-01T00:00:00Z
+      isBlockingAfter: 2025-03-01T00:00:00Z
+      override:
+        comment: /sp-override-ha
+`,
+		},
+		{
+			name:    "no policies",
+			config:  "policies: {}",
+			wantErr: "want at least 1 properties",
+		},
+		{
+			name: "missing filePath",
+			config: `
+policies:
+  ha:
+    name: HA
+    type: opa
+`,
+			wantErr: "missing required property filePath",
+		},
+		{
+			name: "unsupported type",
+			config: `
+policies:
+  ha:
+    name: HA
+    type: kyverno
+    filePath: ha.rego
+`,
+			wantErr: "is not one of",
+		},
+		{
+			name: "invalid override comment",
+			config: `
+policies:
+  ha:
+    name: HA
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      override:
+        comment: please override
+`,
+			wantErr: "does not match",
+		},
+		{
+			name: "typo in a property",
+			config: `
+policies:
+  ha:
+    name: HA
+    type: opa
+    filePath: ha.rego
+    enforcment: {}
+`,
+			wantErr: "unknown property enforcment",
+		},
+	}
+
+	schema := loadTestSchema(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAgainstSchema(schema, schema, yamlToJSONValue(t, tt.config), "$")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestComplianceConfigSchema_MatchesModels tests that the schema properties match the config structs' yaml fields
+func TestComplianceConfigSchema_MatchesModels(t *testing.T) {
+	schema := loadTestSchema(t)
+	definitions := schema["definitions"].(map[string]any)
+	tests := []struct {
+		name       string
+		properties map[string]any
+		model      any
+	}{
+		{name: "ComplianceConfig", properties: schema["properties"].(map[string]any), model: models.ComplianceConfig{}},
+		{name: "PolicyConfig", properties: definitions["policy"].(map[string]any)["properties"].(map[string]any), model: models.PolicyConfig{}},
+		{name: "EnforcementConfig", properties: definitions["enforcement"].(map[string]any)["properties"].(map[string]any), model: models.EnforcementConfig{}},
+		{name: "OverrideConfig", properties: definitions["override"].(map[string]any)["properties"].(map[string]any), model: models.OverrideConfig{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			typ := reflect.TypeOf(tt.model)
+			for i := 0; i < typ.NumField(); i++ {
+				fields = append(fields, strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0])
+			}
+			var props []string
+			for prop := range tt.properties {
+				props = append(props, prop)
+			}
+			sort.Strings(fields)
+			sort.Strings(props)
+			if !reflect.DeepEqual(fields, props) {
+				t.Errorf("schema properties = %v, want yaml fields %v", props, fields)
+			}
+		})
+	}
+}
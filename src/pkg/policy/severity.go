@@ -0,0 +1,131 @@
+package policy
+
+import (
+	"strings"
+)
+
+// severityTagSeparator delimits the enforcement level tagged in front of a fail message with --use-rego-severity,
+// tagging the messages lets the level flow through the evaluation cache and the batched conftest calls unchanged
+const severityTagSeparator = "\x00"
+
+// severityRank orders the enforcement levels a rego severity maps onto, the most severe wins
+var severityRank = map[string]int{
+	POLICY_LEVEL_RECOMMEND: 1,
+	POLICY_LEVEL_WARNING:   2,
+	POLICY_LEVEL_BLOCK:     3,
+}
+
+// conftestFailure is a failure or warning of the conftest output
+type conftestFailure struct {
+	Msg      string `json:"msg"`
+	Metadata struct {
+		Query string `json:"query"`
+		// severity returned by a violation rule, e.g. {"msg": "...", "severity": "warning"}
+		Severity string `json:"severity"`
+		Details  struct {
+			Severity string `json:"severity"`
+		} `json:"details"`
+	} `json:"metadata"`
+}
+
+// regoSeverityLevel maps a severity set in rego onto an enforcement level, POLICY_LEVEL_UNKNOWN if not recognized
+func regoSeverityLevel(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "block", "error", "critical", "high":
+		return POLICY_LEVEL_BLOCK
+	case "warning", "warn", "medium":
+		return POLICY_LEVEL_WARNING
+	case "recommend", "info", "low":
+		return POLICY_LEVEL_RECOMMEND
+	default:
+		return POLICY_LEVEL_UNKNOWN
+	}
+}
+
+// conftestFailureLevel returns the enforcement level of a conftest result: the severity of its metadata if set,
+// otherwise the level of its rule category, deny/violation (failures) block and warn (warnings) warn
+func conftestFailureLevel(failure conftestFailure, isWarning bool) string {
+	for _, severity := range []string{failure.Metadata.Severity, failure.Metadata.Details.Severity} {
+		if severity == "" {
+			continue
+		}
+		if level := regoSeverityLevel(severity); level != POLICY_LEVEL_UNKNOWN {
+			return level
+		}
+		logger.WithField("severity", severity).Warn("Ignoring unknown rego severity, using the rule category")
+	}
+	if isWarning {
+		return POLICY_LEVEL_WARNING
+	}
+	return POLICY_LEVEL_BLOCK
+}
+
+// conftestFailureMessages returns the messages of the failures of a conftest result, with --use-rego-severity the
+// warnings are included too and every message is tagged with its enforcement level
+func (e *PolicyEvaluator) conftestFailureMessages(result conftestResult) []string {
+	msgs := []string{}
+	if !e.options.UseRegoSeverity {
+		for _, failure := range result.Failures {
+			msgs = append(msgs, failure.Msg)
+		}
+		return msgs
+	}
+	for _, failure := range result.Failures {
+		msgs = append(msgs, tagSeverity(conftestFailureLevel(failure, false), failure.Msg))
+	}
+	for _, warning := range result.Warnings {
+		msgs = append(msgs, tagSeverity(conftestFailureLevel(warning, true), warning.Msg))
+	}
+	return msgs
+}
+
+// tagSeverity prefixes the fail message with its enforcement level
+func tagSeverity(level string, msg string) string {
+	return severityTagSeparator + level + severityTagSeparator + msg
+}
+
+// splitSeverity returns the most severe level tagged on the fail messages, POLICY_LEVEL_UNKNOWN if none is tagged,
+// and the messages without their tag
+func splitSeverity(msgs []string) (string, []string) {
+	if msgs == nil {
+		return POLICY_LEVEL_UNKNOWN, nil
+	}
+	level := POLICY_LEVEL_UNKNOWN
+	untagged := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		rest, ok := strings.CutPrefix(msg, severityTagSeparator)
+		if !ok {
+			untagged = append(untagged, msg)
+			continue
+		}
+		msgLevel, rest, ok := strings.Cut(rest, severityTagSeparator)
+		if !ok {
+			untagged = append(untagged, msg)
+			continue
+		}
+		if severityRank[msgLevel] > severityRank[level] {
+			level = msgLevel
+		}
+		untagged = append(untagged, rest)
+	}
+	return level, untagged
+}
+
+// regoSeverityApplies tells if the rego severity replaces the configured enforcement level of a policy,
+// overridden policies and policies not in effect yet keep their level
+func regoSeverityApplies(configured string) bool {
+	return configured != POLICY_LEVEL_OVERRIDE && configured != POLICY_LEVEL_NOT_IN_EFFECT
+}
+
+// isBlockingFailure returns true if the tagged fail messages of a policy at the given enforcement level make it
+// fail at BLOCK level, taking the rego severity into account as the policy matrix does
+func isBlockingFailure(enforcementLevel string, failMsgs []string) bool {
+	severity, msgs := splitSeverity(failMsgs)
+	if len(msgs) == 0 {
+		return false
+	}
+	if severity != POLICY_LEVEL_UNKNOWN && regoSeverityApplies(enforcementLevel) {
+		enforcementLevel = severity
+	}
+	return enforcementLevel == POLICY_LEVEL_BLOCK
+}
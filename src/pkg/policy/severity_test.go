@@ -0,0 +1,181 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestPolicyEvaluator_evaluatePolicyWithConftest_RegoSeverity tests that the conftest rule categories and
+// metadata severities are tagged on the fail messages
+func TestPolicyEvaluator_evaluatePolicyWithConftest_RegoSeverity(t *testing.T) {
+	tests := []struct {
+		name            string
+		stdout          string
+		useRegoSeverity bool
+		wantLevel       string
+		wantMsgs        []string
+	}{
+		{
+			name:      "warnings ignored when disabled",
+			stdout:    `[{"filename":"Combined","namespace":"main","warnings":[{"msg":"no pdb"}]}]`,
+			wantLevel: POLICY_LEVEL_UNKNOWN,
+			wantMsgs:  []string{},
+		},
+		{
+			name:            "deny maps to block",
+			stdout:          `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low","metadata":{"query":"data.main.deny"}}]}]`,
+			useRegoSeverity: true,
+			wantLevel:       POLICY_LEVEL_BLOCK,
+			wantMsgs:        []string{"replicas too low"},
+		},
+		{
+			name:            "warn maps to warning",
+			stdout:          `[{"filename":"Combined","namespace":"main","warnings":[{"msg":"no pdb","metadata":{"query":"data.main.warn"}}]}]`,
+			useRegoSeverity: true,
+			wantLevel:       POLICY_LEVEL_WARNING,
+			wantMsgs:        []string{"no pdb"},
+		},
+		{
+			name:            "most severe wins",
+			stdout:          `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}],"warnings":[{"msg":"no pdb"}]}]`,
+			useRegoSeverity: true,
+			wantLevel:       POLICY_LEVEL_BLOCK,
+			wantMsgs:        []string{"replicas too low", "no pdb"},
+		},
+		{
+			name:            "violation severity in metadata",
+			stdout:          `[{"filename":"Combined","namespace":"main","failures":[{"msg":"no anti-affinity","metadata":{"details":{"severity":"recommend"}}}]}]`,
+			useRegoSeverity: true,
+			wantLevel:       POLICY_LEVEL_RECOMMEND,
+			wantMsgs:        []string{"no anti-affinity"},
+		},
+		{
+			name:            "unknown severity falls back to the category",
+			stdout:          `[{"filename":"Combined","namespace":"main","warnings":[{"msg":"no pdb","metadata":{"severity":"urgent"}}]}]`,
+			useRegoSeverity: true,
+			wantLevel:       POLICY_LEVEL_WARNING,
+			wantMsgs:        []string{"no pdb"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluatorWithOptions("", EvaluatorOptions{UseRegoSeverity: tt.useRegoSeverity})
+			e.executor = &testutil.FakeExecutor{
+				Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+					return &command.Result{Stdout: []byte(tt.stdout)}, fmt.Errorf("exit status 1")
+				},
+			}
+
+			got, err := e.evaluatePolicyWithConftest(context.Background(), "ha", "ha.rego", "manifest.yaml", "")
+			if err != nil {
+				t.Fatalf("evaluatePolicyWithConftest() error = %v", err)
+			}
+			level, msgs := splitSeverity(got)
+			if level != tt.wantLevel {
+				t.Errorf("evaluatePolicyWithConftest() level = %q, want %q", level, tt.wantLevel)
+			}
+			if !reflect.DeepEqual(msgs, tt.wantMsgs) {
+				t.Errorf("evaluatePolicyWithConftest() messages = %v, want %v", msgs, tt.wantMsgs)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_RegoSeverity tests that failing policies are reported
+// at the level of their rego rules, unless overridden
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_RegoSeverity(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      inEffectAfter: 2020-01-01T00:00:00Z
+  pdb:
+    name: Pod Disruption Budget
+    type: opa
+    filePath: pdb.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+  labels:
+    name: Required Labels
+    type: opa
+    filePath: labels.rego
+    enforcement:
+      inEffectAfter: 2020-01-01T00:00:00Z
+      override:
+        comment: /sp-override-labels
+`)
+	for _, id := range []string{"pdb", "labels"} {
+		// distinct sources so the evaluation cache does not share results between the policies
+		for name, content := range map[string]string{id + ".rego": testPolicyRego + "# " + id + "\n", id + "_test.rego": testPolicyTestRego} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+	}
+	outputs := map[string]string{
+		"ha":     `[{"filename":"Combined","namespace":"main","failures":[{"msg":"replicas too low"}]}]`,
+		"pdb":    `[{"filename":"Combined","namespace":"main","warnings":[{"msg":"no pdb"}]}]`,
+		"labels": `[{"filename":"Combined","namespace":"main","failures":[{"msg":"missing team label"}]}]`,
+	}
+	e := NewPolicyEvaluatorWithOptions(dir, EvaluatorOptions{UseRegoSeverity: true})
+	e.executor = &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			for id, output := range outputs {
+				if args[4] == e.data.fullPathToPolicy[id] {
+					return &command.Result{Stdout: []byte(output)}, fmt.Errorf("exit status 1")
+				}
+			}
+			return nil, fmt.Errorf("unexpected policy %s", args[4])
+		},
+	}
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	build := models.BuildManifestResult{
+		EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+			"stg": {Environment: "stg", BeforeManifest: []byte("base"), AfterManifest: []byte("head")},
+		},
+	}
+
+	got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, []*models.Comment{
+		{Body: "/sp-override-labels", User: "alice"},
+	})
+	if err != nil {
+		t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+	}
+	matrix := got.PolicyMatrix["stg"]
+	levels := map[string][]models.PolicyResult{
+		POLICY_LEVEL_BLOCK:    matrix.BlockingPolicies,
+		POLICY_LEVEL_WARNING:  matrix.WarningPolicies,
+		POLICY_LEVEL_OVERRIDE: matrix.OverriddenPolicies,
+	}
+	wantIds := map[string]string{
+		POLICY_LEVEL_BLOCK:    "ha",     // deny on a recommended policy blocks
+		POLICY_LEVEL_WARNING:  "pdb",    // warn on a blocking policy warns
+		POLICY_LEVEL_OVERRIDE: "labels", // overridden policies keep their level
+	}
+	for level, wantId := range wantIds {
+		if len(levels[level]) != 1 || levels[level][0].PolicyId != wantId {
+			t.Errorf("GeneratePolicyEvalResultForManifests() %s policies = %+v, want only %s", level, levels[level], wantId)
+		}
+	}
+	if len(matrix.WarningPolicies) == 1 && !reflect.DeepEqual(matrix.WarningPolicies[0].FailMessages, []string{"no pdb"}) {
+		msgs := matrix.WarningPolicies[0].FailMessages
+		t.Errorf("GeneratePolicyEvalResultForManifests() fail messages = %q, want untagged [no pdb]", msgs)
+	}
+	if got.EnvironmentSummary["stg"].PassingStatus.PassBlockingCheck {
+		t.Error("GeneratePolicyEvalResultForManifests() PassBlockingCheck = true, want false")
+	}
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// Longest snooze accepted, a longer one should be an override or an enforcement date change
+const SNOOZE_MAX_DURATION = 90 * 24 * time.Hour
+
+// activeSnoozes returns the snoozes of each policy active at the given time, the one expiring last when snoozed
+// several times. A snooze comment is the snooze command of the policy and a duration, e.g. "/sp-snooze-ha 7d",
+// it expires the duration after the comment was posted. Comments with an invalid duration are ignored
+func (e *PolicyEvaluator) activeSnoozes(comments []*models.Comment, now time.Time) map[string]models.PolicySnooze {
+	snoozes := make(map[string]models.PolicySnooze)
+	for _, comment := range comments {
+		fields := strings.Fields(comment.Body)
+		if len(fields) != 2 {
+			continue
+		}
+		policyId, ok := e.data.snoozeCmdToPolicyId[fields[0]]
+		if !ok {
+			continue
+		}
+		lg := logger.WithField("policyId", policyId).WithField("user", comment.User).WithField("comment", comment.Body)
+		duration, err := parseSnoozeDuration(fields[1])
+		if err != nil {
+			lg.WithField("error", err).Warn("Ignoring invalid policy snooze")
+			continue
+		}
+		until := comment.CreatedAt.Add(duration)
+		if !now.Before(until) {
+			lg.WithField("until", until).Info("Ignoring expired policy snooze")
+			continue
+		}
+		if snooze, ok := snoozes[policyId]; ok && !until.After(snooze.Until) {
+			continue
+		}
+		snoozes[policyId] = models.PolicySnooze{User: comment.User, Until: until}
+	}
+	return snoozes
+}
+
+// parseSnoozeDuration parses the duration of a snooze comment, days (7d) or a Go duration (12h),
+// positive and at most SNOOZE_MAX_DURATION
+func parseSnoozeDuration(value string) (time.Duration, error) {
+	var duration time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("snooze duration must be days (e.g. 7d) or a duration (e.g. 12h), got: %s", value)
+		}
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		duration, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("snooze duration must be days (e.g. 7d) or a duration (e.g. 12h), got: %s", value)
+		}
+	}
+	if duration <= 0 || duration > SNOOZE_MAX_DURATION {
+		return 0, fmt.Errorf("snooze duration must be positive and at most %s, got: %s", SNOOZE_MAX_DURATION, value)
+	}
+	return duration, nil
+}
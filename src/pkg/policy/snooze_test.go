@@ -0,0 +1,210 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/internal/testutil"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/command"
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+// TestPolicyEvaluator_DetermineEnforcementLevel_Snooze tests that a snooze comment overrides the policy until the
+// comment time plus the duration
+func TestPolicyEvaluator_DetermineEnforcementLevel_Snooze(t *testing.T) {
+	blockingSince := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2025, 11, 15, 12, 0, 0, 0, time.UTC)
+	snooze := func(body string, postedAt time.Time) *models.Comment {
+		return &models.Comment{Body: body, User: "alice", CreatedAt: postedAt}
+	}
+
+	tests := []struct {
+		name      string
+		comments  []*models.Comment
+		wantLevel string
+		wantUntil time.Time
+	}{
+		{
+			name:      "active snooze",
+			comments:  []*models.Comment{snooze("/sp-snooze-ha 7d", now.AddDate(0, 0, -2))},
+			wantLevel: POLICY_LEVEL_OVERRIDE,
+			wantUntil: now.AddDate(0, 0, 5),
+		},
+		{
+			name:      "expired snooze",
+			comments:  []*models.Comment{snooze("/sp-snooze-ha 7d", now.AddDate(0, 0, -8))},
+			wantLevel: POLICY_LEVEL_BLOCK,
+		},
+		{
+			name:      "expiring now",
+			comments:  []*models.Comment{snooze("/sp-snooze-ha 12h", now.Add(-12*time.Hour))},
+			wantLevel: POLICY_LEVEL_BLOCK,
+		},
+		{
+			name: "latest expiry of several snoozes",
+			comments: []*models.Comment{
+				snooze("/sp-snooze-ha 3d", now.AddDate(0, 0, -1)),
+				snooze("/sp-snooze-ha 1d", now.Add(-time.Hour)),
+			},
+			wantLevel: POLICY_LEVEL_OVERRIDE,
+			wantUntil: now.AddDate(0, 0, 2),
+		},
+		{
+			name:      "invalid duration",
+			comments:  []*models.Comment{snooze("/sp-snooze-ha soon", now)},
+			wantLevel: POLICY_LEVEL_BLOCK,
+		},
+		{
+			name:      "missing duration",
+			comments:  []*models.Comment{snooze("/sp-snooze-ha", now)},
+			wantLevel: POLICY_LEVEL_BLOCK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := newTestPolicyConfig()
+			policy.Enforcement.IsBlockingAfter = &blockingSince
+			policy.Enforcement.Override.Snooze = "/sp-snooze-ha"
+
+			e := NewPolicyEvaluator("")
+			e.SetClock(func() time.Time { return now })
+			e.data.ComplianceConfig = models.ComplianceConfig{
+				Policies: map[string]models.PolicyConfig{"ha": policy},
+			}
+			e.data.snoozeCmdToPolicyId = map[string]string{"/sp-snooze-ha": "ha"}
+
+			levels, err := e.DetermineEnforcementLevel(tt.comments, "")
+			if err != nil {
+				t.Fatalf("DetermineEnforcementLevel() error = %v", err)
+			}
+			if levels["ha"] != tt.wantLevel {
+				t.Errorf("DetermineEnforcementLevel()[ha] = %q, want %q", levels["ha"], tt.wantLevel)
+			}
+			got, ok := e.activeSnoozes(tt.comments, now)["ha"]
+			if ok != !tt.wantUntil.IsZero() || !got.Until.Equal(tt.wantUntil) {
+				t.Errorf("activeSnoozes()[ha] = %+v, %v, want until %v", got, ok, tt.wantUntil)
+			}
+		})
+	}
+}
+
+// TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Snooze tests that a snoozed failing policy is reported
+// overridden with its snooze, and an override without expiry takes precedence
+func TestPolicyEvaluator_GeneratePolicyEvalResultForManifests_Snooze(t *testing.T) {
+	dir := newTestPoliciesDir(t, `
+policies:
+  ha:
+    name: Service High Availability
+    type: opa
+    filePath: ha.rego
+    enforcement:
+      isBlockingAfter: 2020-01-01T00:00:00Z
+      override:
+        comment: /sp-override-ha
+        snooze: /sp-snooze-ha
+`)
+	fake := &testutil.FakeExecutor{
+		Handler: func(ctx context.Context, dir, name string, args ...string) (*command.Result, error) {
+			return &command.Result{Stdout: []byte(`[{"filename":"Combined","namespace":"main","failures":[{"msg":"my-app"}]}]`)},
+				fmt.Errorf("exit status 1")
+		},
+	}
+	now := time.Date(2025, 11, 15, 0, 0, 0, 0, time.UTC)
+	snoozed := &models.Comment{Body: "/sp-snooze-ha 7d", User: "alice", CreatedAt: now.AddDate(0, 0, -1)}
+
+	tests := []struct {
+		name           string
+		comments       []*models.Comment
+		wantOverridden bool
+		wantSnooze     *models.PolicySnooze
+	}{
+		{
+			name:           "snoozed",
+			comments:       []*models.Comment{snoozed},
+			wantOverridden: true,
+			wantSnooze:     &models.PolicySnooze{User: "alice", Until: now.AddDate(0, 0, 6)},
+		},
+		{
+			name:           "snoozed and overridden",
+			comments:       []*models.Comment{snoozed, {Body: "/sp-override-ha", User: "bob"}},
+			wantOverridden: true,
+		},
+		{
+			name: "snooze expired",
+			comments: []*models.Comment{
+				{Body: "/sp-snooze-ha 7d", User: "alice", CreatedAt: now.AddDate(0, 0, -7)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewPolicyEvaluator(dir)
+			e.executor = fake
+			e.SetClock(func() time.Time { return now })
+			if err := e.LoadAndValidate(); err != nil {
+				t.Fatalf("LoadAndValidate() error = %v", err)
+			}
+			build := models.BuildManifestResult{
+				EnvManifestBuild: map[string]models.BuildEnvManifestResult{
+					"prod": {Environment: "prod", AfterManifest: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: my-app\n")},
+				},
+			}
+
+			got, err := e.GeneratePolicyEvalResultForManifests(context.Background(), build, tt.comments)
+			if err != nil {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() error = %v", err)
+			}
+			matrix := got.PolicyMatrix["prod"]
+			if !tt.wantOverridden {
+				if len(matrix.BlockingPolicies) != 1 || got.EnvironmentSummary["prod"].PassingStatus.PassBlockingCheck {
+					t.Errorf("GeneratePolicyEvalResultForManifests() = %+v, want the policy blocking", matrix)
+				}
+				return
+			}
+			if len(matrix.OverriddenPolicies) != 1 {
+				t.Fatalf("GeneratePolicyEvalResultForManifests() overridden = %+v, want the policy overridden", matrix.OverriddenPolicies)
+			}
+			result := matrix.OverriddenPolicies[0]
+			if (result.Snooze == nil) != (tt.wantSnooze == nil) ||
+				(result.Snooze != nil && (result.Snooze.User != tt.wantSnooze.User || !result.Snooze.Until.Equal(tt.wantSnooze.Until))) {
+				t.Errorf("GeneratePolicyEvalResultForManifests() Snooze = %+v, want %+v", result.Snooze, tt.wantSnooze)
+			}
+		})
+	}
+}
+
+// TestParseSnoozeDuration tests the accepted snooze durations
+func TestParseSnoozeDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "12h", want: 12 * time.Hour},
+		{value: "90d", want: SNOOZE_MAX_DURATION},
+		{value: "91d", wantErr: "at most"},
+		{value: "0d", wantErr: "positive"},
+		{value: "-1h", wantErr: "positive"},
+		{value: "1w", wantErr: "days (e.g. 7d)"},
+		{value: "d", wantErr: "days (e.g. 7d)"},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSnoozeDuration(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSnoozeDuration(%q) error = %v, want error containing %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseSnoozeDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import rego.v1
+
+# High Availability Policy
+# Ensures deployments run at least 2 replicas
+
+deny contains msg if {
+	some i
+	input[i].contents.kind == "Deployment"
+	deployment := input[i].contents
+	replicas := object.get(deployment.spec, "replicas", 1)
+	replicas < 2
+	msg := sprintf("Deployment '%s' must have at least 2 replicas for high availability, found: %d", [deployment.metadata.name, replicas])
+}
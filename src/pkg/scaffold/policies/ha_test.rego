@@ -0,0 +1,34 @@
+package main
+
+import rego.v1
+
+test_deployment_with_2_replicas_passes if {
+	count(data.main.deny) == 0 with input as [{"contents": {
+		"kind": "Deployment",
+		"metadata": {"name": "my-app"},
+		"spec": {"replicas": 2},
+	}}]
+}
+
+test_deployment_with_1_replica_fails if {
+	count(data.main.deny) == 1 with input as [{"contents": {
+		"kind": "Deployment",
+		"metadata": {"name": "my-app"},
+		"spec": {"replicas": 1},
+	}}]
+}
+
+test_deployment_without_replicas_fails if {
+	count(data.main.deny) == 1 with input as [{"contents": {
+		"kind": "Deployment",
+		"metadata": {"name": "my-app"},
+		"spec": {},
+	}}]
+}
+
+test_other_kinds_pass if {
+	count(data.main.deny) == 0 with input as [{"contents": {
+		"kind": "Service",
+		"metadata": {"name": "my-app"},
+	}}]
+}
@@ -0,0 +1,83 @@
+// Package scaffold writes a working example policies and templates directory for new adopters
+package scaffold
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gh-nvat/gitops-kustomz/src/templates"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger = log.WithField("package", "scaffold")
+
+//go:embed policies
+var policiesFS embed.FS
+
+// file is a scaffolded file, its content and destination path
+type file struct {
+	path    string
+	content []byte
+}
+
+// Init scaffolds the example compliance config, rego policy and its test into policiesPath,
+// and the default markdown templates into templatesPath
+// Existing files are not overwritten unless force is set, if any exists nothing is written at all
+// returns the paths of the written files
+func Init(policiesPath, templatesPath string, force bool) ([]string, error) {
+	files, err := collectFiles(policiesFS, "policies", policiesPath)
+	if err != nil {
+		return nil, err
+	}
+	templateFiles, err := collectFiles(templates.FS, ".", templatesPath)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, templateFiles...)
+
+	// check every file first, so a refused run leaves no partial scaffold behind
+	if !force {
+		for _, f := range files {
+			if _, err := os.Stat(f.path); err == nil {
+				return nil, fmt.Errorf("refusing to overwrite existing file %s (use --force to overwrite)", f.path)
+			}
+		}
+	}
+
+	written := []string{}
+	for _, f := range files {
+		if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", f.path, err)
+		}
+		if err := os.WriteFile(f.path, f.content, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", f.path, err)
+		}
+		logger.WithField("path", f.path).Debug("Scaffolded file")
+		written = append(written, f.path)
+	}
+	return written, nil
+}
+
+// collectFiles reads the files directly under root of fsys, with their destination under destDir
+func collectFiles(fsys fs.FS, root, destDir string) ([]file, error) {
+	entries, err := fs.ReadDir(fsys, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded %s: %w", root, err)
+	}
+	files := []file{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := fs.ReadFile(fsys, path.Join(root, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded %s: %w", entry.Name(), err)
+		}
+		files = append(files, file{path: filepath.Join(destDir, entry.Name()), content: content})
+	}
+	return files, nil
+}
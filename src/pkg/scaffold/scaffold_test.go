@@ -0,0 +1,106 @@
+package scaffold
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/policy"
+)
+
+// TestInit tests that the scaffolded policies load and validate
+func TestInit(t *testing.T) {
+	dir := t.TempDir()
+	policiesPath := filepath.Join(dir, "policies")
+	templatesPath := filepath.Join(dir, "templates")
+
+	written, err := Init(policiesPath, templatesPath, false)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	for _, want := range []string{
+		filepath.Join(policiesPath, policy.COMPLIANCE_CONFIG_FILENAME),
+		filepath.Join(policiesPath, "ha.rego"),
+		filepath.Join(policiesPath, "ha_test.rego"),
+		filepath.Join(templatesPath, "comment.md.tmpl"),
+		filepath.Join(templatesPath, "diff.md.tmpl"),
+		filepath.Join(templatesPath, "multi_service.md.tmpl"),
+		filepath.Join(templatesPath, "policy.md.tmpl"),
+	} {
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("Init() did not create %s", want)
+		}
+	}
+	if len(written) != 7 {
+		t.Errorf("Init() wrote %d files, want 7: %v", len(written), written)
+	}
+
+	e := policy.NewPolicyEvaluator(policiesPath)
+	if err := e.LoadAndValidate(); err != nil {
+		t.Errorf("LoadAndValidate() on scaffolded policies error = %v", err)
+	}
+}
+
+// TestInit_Overwrite tests that existing files are only overwritten with force
+func TestInit_Overwrite(t *testing.T) {
+	dir := t.TempDir()
+	policiesPath := filepath.Join(dir, "policies")
+	templatesPath := filepath.Join(dir, "templates")
+	existing := filepath.Join(templatesPath, "diff.md.tmpl")
+	if err := os.MkdirAll(templatesPath, 0755); err != nil {
+		t.Fatalf("failed to create templates dir: %v", err)
+	}
+	if err := os.WriteFile(existing, []byte("custom"), 0644); err != nil {
+		t.Fatalf("failed to write existing template: %v", err)
+	}
+
+	_, err := Init(policiesPath, templatesPath, false)
+	if err == nil || !strings.Contains(err.Error(), "refusing to overwrite") {
+		t.Fatalf("Init() error = %v, want refusal to overwrite", err)
+	}
+	if _, err := os.Stat(policiesPath); !os.IsNotExist(err) {
+		t.Error("Init() should not write anything when refusing to overwrite")
+	}
+	if content, _ := os.ReadFile(existing); string(content) != "custom" {
+		t.Errorf("Init() overwrote existing file without force, content = %q", content)
+	}
+
+	if _, err := Init(policiesPath, templatesPath, true); err != nil {
+		t.Fatalf("Init() with force error = %v", err)
+	}
+	if content, _ := os.ReadFile(existing); string(content) == "custom" {
+		t.Error("Init() with force did not overwrite the existing file")
+	}
+}
+
+// TestInit_SamplePolicyEvaluates runs the scaffolded rego tests and evaluates the sample policy with conftest
+func TestInit_SamplePolicyEvaluates(t *testing.T) {
+	if _, err := exec.LookPath("conftest"); err != nil {
+		t.Skip("conftest not installed")
+	}
+	dir := t.TempDir()
+	policiesPath := filepath.Join(dir, "policies")
+	if _, err := Init(policiesPath, filepath.Join(dir, "templates"), false); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	out, err := exec.Command("conftest", "verify", "--policy", policiesPath).CombinedOutput()
+	if err != nil {
+		t.Fatalf("conftest verify failed: %v\n%s", err, out)
+	}
+
+	e := policy.NewPolicyEvaluator(policiesPath)
+	if err := e.LoadAndValidate(); err != nil {
+		t.Fatalf("LoadAndValidate() error = %v", err)
+	}
+	failMsgs, err := e.Evaluate(context.Background(), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: my-app\nspec:\n  replicas: 1\n"))
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if len(failMsgs["service-high-availability"]) != 1 {
+		t.Errorf("Evaluate() = %v, want one failure for service-high-availability", failMsgs)
+	}
+}
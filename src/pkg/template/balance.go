@@ -0,0 +1,68 @@
+package template
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	detailsOpenPattern  = regexp.MustCompile(`(?i)<details[\s>]`)
+	detailsClosePattern = regexp.MustCompile(`(?i)</details\s*>`)
+	// inline code spans, their content is rendered literally
+	codeSpanPattern = regexp.MustCompile("`[^`\n]*`")
+)
+
+// CheckBalanced returns the structural issues of rendered markdown that would break the rest of a comment:
+// a <details> tag never closed swallows everything after it, a code fence never closed shows it as code.
+// Tags inside code fences and inline code spans are rendered literally and not counted
+func CheckBalanced(markdown string) []string {
+	var issues []string
+	openDetails := 0
+	fence, fenceLine := "", 0
+	for i, line := range strings.Split(markdown, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]+" \t") == "" {
+				fence = ""
+			}
+			continue
+		}
+		if marker := fenceMarker(trimmed); marker != "" {
+			fence, fenceLine = marker, i+1
+			continue
+		}
+
+		line = codeSpanPattern.ReplaceAllString(line, "")
+		openDetails += len(detailsOpenPattern.FindAllString(line, -1))
+		for range detailsClosePattern.FindAllString(line, -1) {
+			if openDetails == 0 {
+				issues = append(issues, fmt.Sprintf("line %d: </details> without a matching <details>", i+1))
+				continue
+			}
+			openDetails--
+		}
+	}
+	if fence != "" {
+		issues = append(issues, fmt.Sprintf("line %d: code fence %s is never closed", fenceLine, fence))
+	}
+	if openDetails > 0 {
+		issues = append(issues, fmt.Sprintf("%d <details> tag(s) never closed", openDetails))
+	}
+	return issues
+}
+
+// fenceMarker returns the opening fence of a code block line, e.g. "```" or "~~~~", empty if the line opens none
+func fenceMarker(trimmed string) string {
+	for _, char := range []string{"`", "~"} {
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, char))
+		if n >= 3 {
+			// a backtick fence info string cannot contain backticks
+			if char == "`" && strings.Contains(trimmed[n:], "`") {
+				return ""
+			}
+			return trimmed[:n]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+// TestCheckBalanced tests the detection of unclosed details tags and code fences
+func TestCheckBalanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []string
+	}{
+		{
+			name:     "balanced",
+			markdown: "<details> <summary> Diff </summary>\n\n```diff\n-old\n+new\n```\n\n</details>\n",
+		},
+		{
+			name:     "nested details",
+			markdown: "<details>\n<details open>\ninner\n</details>\n</DETAILS>\n",
+		},
+		{
+			name:     "unclosed details",
+			markdown: "<details> <summary> Diff </summary>\n\ncontent\n",
+			want:     []string{"1 <details> tag(s) never closed"},
+		},
+		{
+			name:     "unexpected closing details",
+			markdown: "content\n</details>\n",
+			want:     []string{"line 2: </details> without a matching <details>"},
+		},
+		{
+			name:     "unclosed code fence",
+			markdown: "<details>\n\n```diff\n-old\n</details>\n",
+			want:     []string{"line 3: code fence ``` is never closed", "1 <details> tag(s) never closed"},
+		},
+		{
+			name:     "longer closing fence",
+			markdown: "````yaml\n```\nkind: Service\n`````\n",
+		},
+		{
+			name:     "tilde fence",
+			markdown: "~~~\n<details>\n~~~\n",
+		},
+		{
+			name:     "tags in inline code",
+			markdown: "Close `<details>` with `</details>`\n",
+		},
+		{
+			name:     "summary named details",
+			markdown: "<detailsx> is not a tag\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckBalanced(tt.markdown); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckBalanced() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderer_RenderWithTemplates_Unbalanced tests that unbalanced rendered markdown is warned about, or fails with StrictRender
+func TestRenderer_RenderWithTemplates_Unbalanced(t *testing.T) {
+	templateDir := t.TempDir()
+	templates := map[string]string{
+		FileNameCommentTemplate: "# {{.Service}}\n{{template \"diff\" .}}\n{{template \"policy\" .}}\n",
+		FileNameDiffTemplate:    "<details> <summary> Diff </summary>\n\nforgot to close\n",
+		FileNamePolicyTemplate:  "## Policies\n",
+	}
+	for name, content := range templates {
+		if err := os.WriteFile(filepath.Join(templateDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	t.Run("warning", func(t *testing.T) {
+		hook := logtest.NewLocal(logger.Logger)
+		got, err := NewRenderer().RenderWithTemplates(templateDir, newTestReportData())
+		if err != nil {
+			t.Fatalf("RenderWithTemplates() error = %v", err)
+		}
+		if !strings.Contains(got, "forgot to close") {
+			t.Errorf("RenderWithTemplates() = %q, want the rendered markdown", got)
+		}
+		entry := hook.LastEntry()
+		if entry == nil || entry.Level != log.WarnLevel || !strings.Contains(entry.Message, "unbalanced") {
+			t.Fatalf("RenderWithTemplates() logged %v, want an unbalanced markdown warning", entry)
+		}
+		if issues, _ := entry.Data["issues"].([]string); !reflect.DeepEqual(issues, []string{"1 <details> tag(s) never closed"}) {
+			t.Errorf("warning issues = %v, want the unclosed details tag", entry.Data["issues"])
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := NewRendererWithOptions(RendererOptions{StrictRender: true}).RenderWithTemplates(templateDir, newTestReportData())
+		if err == nil || !strings.Contains(err.Error(), "1 <details> tag(s) never closed") {
+			t.Errorf("RenderWithTemplates() error = %v, want the unclosed details tag", err)
+		}
+	})
+
+	t.Run("default templates are balanced", func(t *testing.T) {
+		if _, err := NewRendererWithOptions(RendererOptions{StrictRender: true}).RenderWithTemplates(testTemplatesDir, newTestReportData()); err != nil {
+			t.Errorf("RenderWithTemplates() error = %v", err)
+		}
+	})
+}
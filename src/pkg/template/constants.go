@@ -3,8 +3,13 @@ package template
 // DefaultCommentTemplate is the embedded default template for PR comments
 // This template supports MultiEnvCommentData structure
 const (
-	ToolCommentSignature    = `<!-- gitops-kustomz: {{.Service}} - auto-generated comment, please do not remove -->`
+	ToolCommentSignature = `<!-- gitops-kustomz: {{.Service}} - auto-generated comment, please do not remove -->`
+	// Signature of the comment of a single environment with --comment-per-environment, formatted with the environment
+	ToolCommentEnvSignature = `<!-- gitops-kustomz: {{.Service}} [%s] - auto-generated comment, please do not remove -->`
 	FileNameCommentTemplate = "comment.md.tmpl"
 	FileNameDiffTemplate    = "diff.md.tmpl"
 	FileNamePolicyTemplate  = "policy.md.tmpl"
+
+	// Consolidated comment of several services, wrapping their single-service comments
+	FileNameMultiServiceTemplate = "multi_service.md.tmpl"
 )
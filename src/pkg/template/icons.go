@@ -0,0 +1,59 @@
+package template
+
+import "fmt"
+
+// icon is the emoji of a report marker and its text label for --no-emoji, e.g. for screen readers
+type icon struct {
+	emoji string
+	label string
+}
+
+// icons are the report markers available to templates through the icon and label functions
+var icons = map[string]icon{
+	"check":       {"🔍", "[CHECK]"},
+	"diff":        {"📊", "[DIFF]"},
+	"policy":      {"🛡️", "[POLICY]"},
+	"manifest":    {"📄", "[MANIFEST]"},
+	"attachment":  {"📎", "[ATTACHMENT]"},
+	"pass":        {"✅", "[PASS]"},
+	"fail":        {"❌", "[FAIL]"},
+	"block":       {"🚫", "[BLOCK]"},
+	"warning":     {"⚠️", "[WARNING]"},
+	"recommend":   {"💡", "[RECOMMEND]"},
+	"omitted":     {"⏭️", "[OMITTED]"},
+	"exempt":      {"⏭️", "[EXEMPT]"},
+	"preexisting": {"⏮️", "[PRE-EXISTING]"},
+	"changed":     {"✏️", "[CHANGED]"},
+	"unchanged":   {"✅", "[NONE]"},
+	"added":       {"➕", "+"},
+	"deleted":     {"➖", "-"},
+	"error":       {"💥", "[ERROR]"},
+	"celebrate":   {"🙌", ""},
+}
+
+// iconFunc returns the template function printing the emoji of a marker, or its text label if noEmoji
+func iconFunc(noEmoji bool) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		i, ok := icons[name]
+		if !ok {
+			return "", fmt.Errorf("unknown icon %q", name)
+		}
+		if noEmoji {
+			return i.label, nil
+		}
+		return i.emoji, nil
+	}
+}
+
+// labelFunc returns the template function printing the emoji of a marker followed by text, e.g. "✅ PASS",
+// or only its text label if noEmoji, e.g. "[PASS]"
+func labelFunc(noEmoji bool) func(name, text string) (string, error) {
+	iconOf := iconFunc(noEmoji)
+	return func(name, text string) (string, error) {
+		s, err := iconOf(name)
+		if err != nil || noEmoji {
+			return s, err
+		}
+		return s + " " + text, nil
+	}
+}
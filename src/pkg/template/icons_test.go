@@ -0,0 +1,137 @@
+package template
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+// emojiPattern matches the emoji ranges used by the templates
+var emojiPattern = regexp.MustCompile("[\u2190-\u2BFF\U0001F300-\U0001FAFF]")
+
+// newTestIconsReportData returns report data rendering every marker of the templates
+func newTestIconsReportData() *models.ReportData {
+	data := newTestReportData()
+	data.Environments = []string{"stg", "prod"}
+	data.Warnings = []string{"diff base is the tip of the base branch"}
+	data.BuildWarnings = map[string][]string{"stg": {"# Warning: 'commonLabels' is deprecated."}}
+	data.ManifestChanges = map[string]models.EnvironmentDiff{
+		"stg":  {LineCount: 2, AddedLineCount: 1, DeletedLineCount: 1, ContentType: models.DiffContentTypeText, Content: "-  replicas: 2\n+  replicas: 3"},
+		"prod": {ContentType: models.DiffContentTypeText},
+	}
+	data.FullManifests = map[string]models.FullManifest{
+		"stg": {ContentType: models.DiffContentTypeGHArtifact, Content: "https://github.com/owner/repo/actions/runs/1"},
+	}
+	data.PolicyEvaluation.EnvironmentSummary = map[string]models.EnvironmentSummaryEnv{
+		"stg": {PolicyCounts: models.PolicyCounts{TotalSuccess: 2, TotalOmitted: 1, TotalOmittedFailed: 1}},
+		"prod": {
+			PolicyCounts:           models.PolicyCounts{TotalSuccess: 1, TotalFailed: 2, BlockingFailedCount: 1, RecommendFailedCount: 1},
+			BaseFailsBlockingCheck: true,
+		},
+	}
+	data.PolicyEvaluation.PolicyMatrix = map[string]models.PolicyMatrix{
+		"stg": {
+			BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", IsPassing: true}},
+			WarningPolicies: []models.PolicyResult{{
+				PolicyId: "tls", PolicyName: "TLS", FailMessages: []string{"ingress without tls"},
+				OverrideReason: "timed-exemption (expires 2025-12-01)",
+			}},
+			RecommendPolicies: []models.PolicyResult{{PolicyId: "labels", PolicyName: "Labels", IsPassing: true}},
+			FixedPolicies:     []models.PolicyResult{{PolicyId: "labels", PolicyName: "Labels", IsPassing: true, FixedFailMessages: []string{"missing team label"}}},
+		},
+		"prod": {
+			BlockingPolicies: []models.PolicyResult{{
+				PolicyId: "ha", PolicyName: "HA", FailMessages: []string{"replicas too low"},
+				IsFailingOnBase: true, PreExistingFailMessages: []string{"replicas too low"},
+			}},
+			WarningPolicies:   []models.PolicyResult{{PolicyId: "tls", PolicyName: "TLS", IsPassing: true}},
+			RecommendPolicies: []models.PolicyResult{{PolicyId: "labels", PolicyName: "Labels", FailMessages: []string{"missing team label"}}},
+		},
+	}
+	return data
+}
+
+// TestRenderer_RenderWithTemplates_Golden tests the emoji and no-emoji rendering of the same data against golden files
+// Run with -update to regenerate them
+func TestRenderer_RenderWithTemplates_Golden(t *testing.T) {
+	tests := []struct {
+		name    string
+		noEmoji bool
+		golden  string
+	}{
+		{
+			name:   "emoji",
+			golden: "report.golden.md",
+		},
+		{
+			name:    "no emoji",
+			noEmoji: true,
+			golden:  "report_no_emoji.golden.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newTestIconsReportData()
+			r := NewRendererWithOptions(RendererOptions{NoEmoji: tt.noEmoji})
+			r.SetClock(func() time.Time { return data.Timestamp.Add(3 * time.Minute) })
+			got, err := r.RenderWithTemplates(testTemplatesDir, data)
+			if err != nil {
+				t.Fatalf("RenderWithTemplates() error = %v", err)
+			}
+
+			goldenPath := filepath.Join("testdata", tt.golden)
+			if *updateGolden {
+				if err := os.WriteFile(goldenPath, []byte(got), 0644); err != nil {
+					t.Fatalf("failed to update %s: %v", goldenPath, err)
+				}
+			}
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatalf("failed to read %s: %v", goldenPath, err)
+			}
+			if got != string(want) {
+				t.Errorf("RenderWithTemplates() differs from %s, got:\n%s", goldenPath, got)
+			}
+
+			if hasEmoji := emojiPattern.MatchString(got); hasEmoji == tt.noEmoji {
+				t.Errorf("RenderWithTemplates() contains emoji = %v, want %v:\n%s", hasEmoji, !tt.noEmoji, got)
+			}
+		})
+	}
+}
+
+// TestRenderer_RenderMultiServiceWithTemplates_NoEmoji tests that the multi-service summary uses text labels
+func TestRenderer_RenderMultiServiceWithTemplates_NoEmoji(t *testing.T) {
+	data := &models.MultiServiceReportData{
+		Services: []models.ServiceReport{
+			{Service: "my-app", Report: *newTestIconsReportData(), HasChanges: true, BlockingFailedCount: 1, FailedEnvironments: []string{"prod"}},
+		},
+	}
+
+	got, err := NewRendererWithOptions(RendererOptions{NoEmoji: true}).RenderMultiServiceWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderMultiServiceWithTemplates() error = %v", err)
+	}
+	want := "| `my-app` | `stg`, `prod` | [CHANGED] | `1`[BLOCK] | [FAIL] (`prod`) |"
+	if !regexp.MustCompile(regexp.QuoteMeta(want)).MatchString(got) {
+		t.Errorf("RenderMultiServiceWithTemplates() missing %q in:\n%s", want, got)
+	}
+	if emojiPattern.MatchString(got) {
+		t.Errorf("RenderMultiServiceWithTemplates() contains emoji:\n%s", got)
+	}
+}
+
+// TestIconFunc_Unknown tests that an unknown icon name fails the rendering
+func TestIconFunc_Unknown(t *testing.T) {
+	if _, err := NewRenderer().RenderString(`{{icon "nope"}}`, nil); err == nil {
+		t.Error("RenderString() error = nil, want an unknown icon error")
+	}
+}
@@ -0,0 +1,91 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultMaxFailMessageLength is the default length in characters of a rendered fail message,
+// longer ones (e.g. a rego rule dumping a whole object) bloat the comment and break its tables
+const DefaultMaxFailMessageLength = 500
+
+// markdownEscaper escapes characters that would break a markdown table or inject formatting/HTML
+// Pipes are escaped so they do not split table cells, backticks so they do not open code spans,
+// and angle brackets are HTML-encoded so tags like <details> are rendered literally
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	"|", `\|`,
+	"<", "&lt;",
+	">", "&gt;",
+	"\r\n", " ",
+	"\n", " ",
+)
+
+// MarkdownEscape escapes s to be rendered literally in markdown, including inside table cells
+// It is registered as the `mdEscape` template function
+func MarkdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// codeSpanEscaper replaces the characters that would end an inline code span or split its line,
+// a code span has no escape sequence so backticks are replaced by single quotes
+var codeSpanEscaper = strings.NewReplacer(
+	"`", "'",
+	"\r\n", " ",
+	"\n", " ",
+)
+
+// CodeSpanEscape makes s safe to render inside an inline code span, e.g. `{{codeSpan .PolicyName}}`
+// It is registered as the `codeSpan` template function
+func CodeSpanEscape(s string) string {
+	return codeSpanEscaper.Replace(s)
+}
+
+// TruncateFailMessage cuts msg to maxLength characters with an ellipsis and a note giving its full length,
+// msg is returned as is if it fits or maxLength is 0
+func TruncateFailMessage(msg string, maxLength int) string {
+	runes := []rune(msg)
+	if maxLength <= 0 || len(runes) <= maxLength {
+		return msg
+	}
+	return fmt.Sprintf("%s… (truncated from %d characters, see report.json for the full message)", string(runes[:maxLength]), len(runes))
+}
+
+// failMessageFunc returns the `failMsg` template function, escaping a fail message truncated to maxLength characters
+func failMessageFunc(maxLength int) func(msg string) string {
+	return func(msg string) string {
+		return MarkdownEscape(TruncateFailMessage(msg, maxLength))
+	}
+}
+
+// RelativeTime formats t relative to now, e.g. "3 minutes ago", "just now" under a minute or "in 2 hours" for a future t
+// It is registered as the `relTime` template function, relative to the renderer's clock
+func RelativeTime(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	default:
+		n, unit = int(d/(24*time.Hour)), "day"
+	}
+	if n > 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
@@ -0,0 +1,157 @@
+package template
+
+import (
+	"testing"
+	"time"
+)
+
+// TestMarkdownEscape tests escaping of characters breaking tables or injecting formatting
+func TestMarkdownEscape(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain text is unchanged",
+			input: "Deployment 'my-app' must have at least 2 replicas",
+			want:  "Deployment 'my-app' must have at least 2 replicas",
+		},
+		{
+			name:  "pipes are escaped",
+			input: "a | b",
+			want:  `a \| b`,
+		},
+		{
+			name:  "backticks are escaped",
+			input: "set `replicas`",
+			want:  "set \\`replicas\\`",
+		},
+		{
+			name:  "html is encoded",
+			input: "<details><summary>x</summary>",
+			want:  "&lt;details&gt;&lt;summary&gt;x&lt;/summary&gt;",
+		},
+		{
+			name:  "backslashes are escaped before other characters",
+			input: `\|`,
+			want:  `\\\|`,
+		},
+		{
+			name:  "newlines are flattened",
+			input: "line1\nline2\r\nline3",
+			want:  "line1 line2 line3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MarkdownEscape(tt.input); got != tt.want {
+				t.Errorf("MarkdownEscape(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCodeSpanEscape tests that a string cannot end the inline code span it is rendered in
+func TestCodeSpanEscape(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain text is unchanged",
+			input: "HA | <replicas> @team",
+			want:  "HA | <replicas> @team",
+		},
+		{
+			name:  "backticks are replaced",
+			input: "HA` @team `x",
+			want:  "HA' @team 'x",
+		},
+		{
+			name:  "newlines are flattened",
+			input: "line1\nline2\r\nline3",
+			want:  "line1 line2 line3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CodeSpanEscape(tt.input); got != tt.want {
+				t.Errorf("CodeSpanEscape(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestTruncateFailMessage tests the truncation of oversized fail messages
+func TestTruncateFailMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       string
+		maxLength int
+		want      string
+	}{
+		{
+			name:      "short message is unchanged",
+			msg:       "replicas too low",
+			maxLength: 20,
+			want:      "replicas too low",
+		},
+		{
+			name:      "long message is cut with a note",
+			msg:       "object: {replicas: 1, strategy: {}}",
+			maxLength: 8,
+			want:      "object: … (truncated from 35 characters, see report.json for the full message)",
+		},
+		{
+			name:      "multi-byte characters are not split",
+			msg:       "héllo wörld",
+			maxLength: 2,
+			want:      "hé… (truncated from 11 characters, see report.json for the full message)",
+		},
+		{
+			name:      "disabled",
+			msg:       "object: {replicas: 1, strategy: {}}",
+			maxLength: 0,
+			want:      "object: {replicas: 1, strategy: {}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TruncateFailMessage(tt.msg, tt.maxLength); got != tt.want {
+				t.Errorf("TruncateFailMessage(%q, %d) = %q, want %q", tt.msg, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRelativeTime tests the relative formatting of a timestamp against a fixed clock
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		t    time.Time
+		want string
+	}{
+		{name: "same time", t: now, want: "just now"},
+		{name: "under a minute", t: now.Add(-59 * time.Second), want: "just now"},
+		{name: "one minute", t: now.Add(-time.Minute), want: "1 minute ago"},
+		{name: "minutes", t: now.Add(-3*time.Minute - 20*time.Second), want: "3 minutes ago"},
+		{name: "hours", t: now.Add(-5 * time.Hour), want: "5 hours ago"},
+		{name: "one day", t: now.Add(-30 * time.Hour), want: "1 day ago"},
+		{name: "days", t: now.Add(-72 * time.Hour), want: "3 days ago"},
+		{name: "future", t: now.Add(2 * time.Hour), want: "in 2 hours"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RelativeTime(tt.t, now); got != tt.want {
+				t.Errorf("RelativeTime(%s, %s) = %q, want %q", tt.t, now, got, tt.want)
+			}
+		})
+	}
+}
@@ -5,9 +5,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"text/template"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+	log "github.com/sirupsen/logrus"
 )
 
+var logger = log.WithField("package", "template")
+
 // TemplateRenderer defines the interface for rendering markdown templates
 type TemplateRenderer interface {
 	// RenderWithTemplates renders templates from a directory with support for includes
@@ -18,19 +26,59 @@ type TemplateRenderer interface {
 
 // Renderer handles template rendering
 type Renderer struct {
-	funcMap template.FuncMap
+	funcMap      template.FuncMap
+	clock        func() time.Time // current time of the relTime template function
+	strictRender bool             // fail instead of warning when the rendered markdown is unbalanced
 }
 
 // Ensure Renderer implements TemplateRenderer
 var _ TemplateRenderer = (*Renderer)(nil)
 
+// RendererOptions configures a Renderer
+type RendererOptions struct {
+	// Print text labels like "[PASS]" instead of emoji through the icon and label template functions,
+	// for screen readers and markdown renderers without emoji support
+	NoEmoji bool
+	// Fail messages longer than this many characters are cut with an ellipsis in the rendered markdown through
+	// the failMsg template function, the report JSON keeps them whole. 0 keeps messages whole
+	MaxFailMessageLength int
+	// Fail instead of warning when the rendered markdown has an unclosed <details> tag or code fence, see CheckBalanced
+	StrictRender bool
+}
+
 // NewRenderer creates a new template renderer
 func NewRenderer() *Renderer {
-	return &Renderer{
-		funcMap: template.FuncMap{
-			"gt": func(a, b int) bool { return a > b },
-		},
+	return NewRendererWithOptions(RendererOptions{})
+}
+
+// NewRendererWithOptions creates a new template renderer with the given options
+func NewRendererWithOptions(opts RendererOptions) *Renderer {
+	r := &Renderer{clock: time.Now, strictRender: opts.StrictRender}
+	r.funcMap = template.FuncMap{
+		"gt":       func(a, b int) bool { return a > b },
+		"mdEscape": MarkdownEscape,
+		"codeSpan": CodeSpanEscape,
+		"icon":     iconFunc(opts.NoEmoji),
+		"label":    labelFunc(opts.NoEmoji),
+		"failMsg":  failMessageFunc(opts.MaxFailMessageLength),
+		"relTime":  func(t time.Time) string { return RelativeTime(t, r.clock()) },
+		"hasKey":   hasKey,
+	}
+	return r
+}
+
+// hasKey reports whether the map has an entry for key, unlike index which returns the zero value of a missing entry
+func hasKey(m interface{}, key string) bool {
+	value := reflect.ValueOf(m)
+	if value.Kind() != reflect.Map || value.Type().Key().Kind() != reflect.String {
+		return false
 	}
+	return value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key())).IsValid()
+}
+
+// SetClock overrides the clock the relTime template function is relative to, mainly for tests
+func (r *Renderer) SetClock(clock func() time.Time) {
+	r.clock = clock
 }
 
 // RenderWithTemplates renders templates with support for includes
@@ -52,8 +100,8 @@ func (r *Renderer) RenderWithTemplates(templateDir string, data interface{}) (st
 		return "", fmt.Errorf("policy template not found at %s: %w", policyPath, err)
 	}
 
-	// Parse all templates with named templates
-	tmpl := template.New("").Funcs(r.funcMap)
+	// Parse all templates with named templates, a missing map key fails instead of rendering "<no value>"
+	tmpl := template.New("").Funcs(r.funcMap).Option("missingkey=error")
 
 	// Parse diff template as a named template
 	diffContent, err := os.ReadFile(diffPath)
@@ -83,14 +131,62 @@ func (r *Renderer) RenderWithTemplates(templateDir string, data interface{}) (st
 		return "", fmt.Errorf("failed to parse comment template: %w", err)
 	}
 
+	if err := validateFields(mainTmpl, data); err != nil {
+		return "", fmt.Errorf("invalid templates in %s: %w", templateDir, err)
+	}
+
+	// Render to a buffer, nothing is returned on error so a partial comment is never posted
 	var buf bytes.Buffer
 	if err := mainTmpl.Execute(&buf, data); err != nil {
 		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
+	if err := r.checkRendered(templateDir, buf.String()); err != nil {
+		return "", err
+	}
 
 	return buf.String(), nil
 }
 
+// RenderMultiServiceWithTemplates renders the consolidated comment of several services,
+// each service is rendered with the single-service templates then wrapped by the multi-service template
+func (r *Renderer) RenderMultiServiceWithTemplates(templateDir string, data *models.MultiServiceReportData) (string, error) {
+	multiServicePath := filepath.Join(templateDir, FileNameMultiServiceTemplate)
+	if _, err := os.Stat(multiServicePath); err != nil {
+		return "", fmt.Errorf("multi-service template not found at %s: %w", multiServicePath, err)
+	}
+
+	for i := range data.Services {
+		rendered, err := r.RenderWithTemplates(templateDir, &data.Services[i].Report)
+		if err != nil {
+			return "", fmt.Errorf("failed to render service %s: %w", data.Services[i].Service, err)
+		}
+		data.Services[i].RenderedMarkdown = rendered
+	}
+
+	rendered, err := r.Render(multiServicePath, data)
+	if err != nil {
+		return "", err
+	}
+	if err := r.checkRendered(multiServicePath, rendered); err != nil {
+		return "", err
+	}
+	return rendered, nil
+}
+
+// checkRendered checks that the markdown rendered from the templates at path is balanced before it is posted,
+// an issue fails the rendering with StrictRender and is logged as a warning otherwise
+func (r *Renderer) checkRendered(path, rendered string) error {
+	issues := CheckBalanced(rendered)
+	if len(issues) == 0 {
+		return nil
+	}
+	if r.strictRender {
+		return fmt.Errorf("rendered markdown of the templates in %s is unbalanced: %s", path, strings.Join(issues, "; "))
+	}
+	logger.WithField("templates", path).WithField("issues", issues).Warn("Rendered markdown is unbalanced, the comment may be broken")
+	return nil
+}
+
 // Render renders a template file with the provided data
 func (r *Renderer) Render(templatePath string, data interface{}) (string, error) {
 	// Read template file
@@ -104,10 +200,13 @@ func (r *Renderer) Render(templatePath string, data interface{}) (string, error)
 
 // RenderString renders a template string with the provided data
 func (r *Renderer) RenderString(templateStr string, data interface{}) (string, error) {
-	tmpl, err := template.New("template").Funcs(r.funcMap).Parse(templateStr)
+	tmpl, err := template.New("template").Funcs(r.funcMap).Option("missingkey=error").Parse(templateStr)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse template: %w", err)
 	}
+	if err := validateFields(tmpl, data); err != nil {
+		return "", err
+	}
 
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
@@ -0,0 +1,455 @@
+package template
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/models"
+)
+
+const testTemplatesDir = "../../templates"
+
+// newTestReportData returns a minimal report for rendering the default templates
+func newTestReportData() *models.ReportData {
+	return &models.ReportData{
+		Service:      "my-app",
+		Timestamp:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		BaseCommit:   "base",
+		HeadCommit:   "head",
+		Environments: []string{"stg"},
+		ManifestChanges: map[string]models.EnvironmentDiff{
+			"stg": {ContentType: models.DiffContentTypeText},
+		},
+		PolicyEvaluation: models.PolicyEvaluation{
+			EnvironmentSummary: map[string]models.EnvironmentSummaryEnv{
+				"stg":  {},
+				"prod": {},
+			},
+			PolicyMatrix: map[string]models.PolicyMatrix{
+				"stg":  {},
+				"prod": {},
+			},
+		},
+	}
+}
+
+// TestRenderer_RenderWithTemplates_FullManifests tests the collapsed full manifest section
+func TestRenderer_RenderWithTemplates_FullManifests(t *testing.T) {
+	tests := []struct {
+		name          string
+		fullManifests map[string]models.FullManifest
+		wantContains  []string
+		wantAbsent    []string
+	}{
+		{
+			name:       "disabled",
+			wantAbsent: []string{"Full manifest of"},
+		},
+		{
+			name: "inline manifest",
+			fullManifests: map[string]models.FullManifest{
+				"stg": {ContentType: models.DiffContentTypeText, Content: "kind: Deployment"},
+			},
+			wantContains: []string{
+				"<details> <summary> Full manifest of [`stg`]: </summary>",
+				"```yaml\nkind: Deployment\n```",
+			},
+		},
+		{
+			name: "manifest uploaded as artifact",
+			fullManifests: map[string]models.FullManifest{
+				"stg": {ContentType: models.DiffContentTypeGHArtifact, Content: "https://github.com/owner/repo/actions/runs/1"},
+			},
+			wantContains: []string{
+				"Full manifest of [`stg`]",
+				"[in the workflow run's artifacts](https://github.com/owner/repo/actions/runs/1)",
+			},
+			wantAbsent: []string{"```yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newTestReportData()
+			data.FullManifests = tt.fullManifests
+
+			got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+			if err != nil {
+				t.Fatalf("RenderWithTemplates() error = %v", err)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got, want) {
+					t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(got, absent) {
+					t.Errorf("RenderWithTemplates() should not contain %q in:\n%s", absent, got)
+				}
+			}
+		})
+	}
+}
+
+// TestRenderer_RenderWithTemplates_PerResourceDiff tests that the diff of each resource is rendered in its own section
+func TestRenderer_RenderWithTemplates_PerResourceDiff(t *testing.T) {
+	data := newTestReportData()
+	data.ManifestChanges["stg"] = models.EnvironmentDiff{
+		ContentType: models.DiffContentTypeText,
+		LineCount:   3,
+		Content:     "--- before\n+++ after\n@@ -1 +1 @@\n-a\n+b\n",
+		PerResource: map[string]string{
+			"Service/my-ns/my-svc":    "@@ -0,0 +1 @@\n+kind: Service\n",
+			"Deployment/my-ns/my-app": "@@ -1 +1 @@\n-  replicas: 2\n+  replicas: 3\n",
+		},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	deployment := strings.Index(got, "<details> <summary> Diff of `Deployment/my-ns/my-app`: </summary>\n\n```diff\n@@ -1 +1 @@\n-  replicas: 2\n")
+	service := strings.Index(got, "<details> <summary> Diff of `Service/my-ns/my-svc`: </summary>\n\n```diff\n@@ -0,0 +1 @@\n+kind: Service\n")
+	if deployment < 0 || service < 0 || service < deployment {
+		t.Errorf("RenderWithTemplates() missing the resource diffs in identifier order in:\n%s", got)
+	}
+	if strings.Contains(got, "--- before") {
+		t.Errorf("RenderWithTemplates() renders the whole diff with the resource diffs in:\n%s", got)
+	}
+	if issues := CheckBalanced(got); len(issues) > 0 {
+		t.Errorf("RenderWithTemplates() unbalanced markdown: %v", issues)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_BuildWarnings tests that kustomize warnings are shown under their environment
+func TestRenderer_RenderWithTemplates_BuildWarnings(t *testing.T) {
+	data := newTestReportData()
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if strings.Contains(got, "succeeded with warnings") {
+		t.Errorf("RenderWithTemplates() should not mention warnings when there are none:\n%s", got)
+	}
+
+	data.BuildWarnings = map[string][]string{
+		"stg": {"# Warning: 'commonLabels' is deprecated."},
+	}
+	got, err = NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	want := "⚠️ kustomize build succeeded with warnings:\n```\n# Warning: 'commonLabels' is deprecated.\n```"
+	if !strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_PolicySources tests the collapsed rego source of failing policies
+func TestRenderer_RenderWithTemplates_PolicySources(t *testing.T) {
+	data := newTestReportData()
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if strings.Contains(got, "Policy source of") {
+		t.Errorf("RenderWithTemplates() should not contain policy sources when none are set:\n%s", got)
+	}
+
+	data.PolicyEvaluation.PolicySources = map[string]string{"ha": "package main"}
+	got, err = NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	want := "<details> <summary> Policy source of `ha`: </summary>\n\n```rego\npackage main\n```\n</details>"
+	if !strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_EscapesFailMessages tests that rego fail messages cannot break the markdown
+func TestRenderer_RenderWithTemplates_EscapesFailMessages(t *testing.T) {
+	data := newTestReportData()
+	failing := models.PolicyResult{
+		PolicyId:     "ha",
+		PolicyName:   "HA | replicas",
+		FailMessages: []string{"use `replicas: 2` | got <details>1</details>"},
+	}
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = models.EnvironmentSummaryEnv{
+		PolicyCounts: models.PolicyCounts{BlockingFailedCount: 1},
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{failing},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	for _, want := range []string{
+		"| HA \\| replicas | 🚫 |",
+		"  * use \\`replicas: 2\\` \\| got &lt;details&gt;1&lt;/details&gt;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<details>1</details>") {
+		t.Errorf("RenderWithTemplates() should not render raw HTML from fail messages:\n%s", got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_PreExistingViolations tests the notice about violations already failing on the base
+func TestRenderer_RenderWithTemplates_PreExistingViolations(t *testing.T) {
+	data := newTestReportData()
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = models.EnvironmentSummaryEnv{
+		PolicyCounts:           models.PolicyCounts{BlockingFailedCount: 1},
+		BaseFailsBlockingCheck: true,
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{
+			PolicyId:                "ha",
+			PolicyName:              "HA",
+			FailMessages:            []string{"replicas too low", "no anti-affinity"},
+			IsFailingOnBase:         true,
+			PreExistingFailMessages: []string{"replicas too low"},
+			NewFailMessages:         []string{"no anti-affinity"},
+		}},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	for _, want := range []string{
+		"> ⏮️ [`stg`] The base commit already fails blocking policies",
+		"  * ⏮️ `1` of these messages are pre-existing on the base commit",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "[`prod`] The base commit") {
+		t.Errorf("RenderWithTemplates() should only flag environments whose base fails:\n%s", got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_UnchangedEnvironment tests that environments without changes still get policy results
+func TestRenderer_RenderWithTemplates_UnchangedEnvironment(t *testing.T) {
+	data := newTestReportData()
+	data.Environments = []string{"stg", "prod"}
+	data.ManifestChanges["prod"] = models.EnvironmentDiff{ContentType: models.DiffContentTypeText}
+	data.PolicyEvaluation.EnvironmentSummary["prod"] = models.EnvironmentSummaryEnv{
+		PolicyCounts: models.PolicyCounts{TotalFailed: 1, BlockingFailedCount: 1},
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", IsPassing: true}},
+	}
+	data.PolicyEvaluation.PolicyMatrix["prod"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", FailMessages: []string{"replicas too low"}}},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	for _, want := range []string{
+		"### [`prod`]: No changes detected.",
+		"policies are still evaluated against the head manifest",
+		"evaluated against the full head manifest (`head`) of every environment",
+		"| `prod` | `0`✅ | `0`⏭️ | `1`❌ | `1`🚫 |",
+		"| HA | 🚫 | ✅ PASS | ❌ FAIL |",
+		"##### [`prod`] environment\n\n* Policy `HA` failed with the following messages:\n  * replicas too low",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+// TestRenderer_RenderWithTemplates_OverrideQuorum tests that the override approvals are shown for quorum policies
+func TestRenderer_RenderWithTemplates_OverrideQuorum(t *testing.T) {
+	data := newTestReportData()
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{
+			PolicyId: "ha", PolicyName: "HA", IsPassing: true, OverrideApprovals: 1, RequiredApprovals: 2,
+		}},
+		OverriddenPolicies: []models.PolicyResult{
+			{PolicyId: "tls", PolicyName: "TLS", IsPassing: true, OverrideApprovals: 2, RequiredApprovals: 2},
+			{PolicyId: "limits", PolicyName: "Limits", IsPassing: true},
+		},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	for _, want := range []string{
+		"| HA | 🚫 (1/2 overrides received) |",
+		"| TLS | ⏭️ (2/2 overrides received) |",
+		"| Limits | ⏭️ |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+// TestRenderer_RenderWithTemplates_Exemption tests that an exempted failing policy is shown as omitted, not failing
+func TestRenderer_RenderWithTemplates_Exemption(t *testing.T) {
+	data := newTestReportData()
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{
+			PolicyId: "ha", PolicyName: "HA", FailMessages: []string{"my-app has 1 replica"},
+			OverrideReason: "timed-exemption (expires 2025-12-01)",
+		}},
+	}
+	summary := data.PolicyEvaluation.EnvironmentSummary["stg"]
+	summary.PolicyCounts.TotalOmittedFailed = 1
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = summary
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if !strings.Contains(got, "| HA | 🚫 | ⏭️ EXEMPT |") {
+		t.Errorf("RenderWithTemplates() missing the exempted matrix cell in:\n%s", got)
+	}
+	if !strings.Contains(got, "* Policy `HA` failed, omitted by timed-exemption (expires 2025-12-01), with the following messages:") {
+		t.Errorf("RenderWithTemplates() missing the exemption in the omitted policies in:\n%s", got)
+	}
+	if strings.Contains(got, "* Policy `HA` failed with the following messages:") {
+		t.Errorf("RenderWithTemplates() lists the exempted policy as failing in:\n%s", got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_Snooze tests that a snoozed failing policy is listed omitted with its snooze
+func TestRenderer_RenderWithTemplates_Snooze(t *testing.T) {
+	data := newTestReportData()
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		OverriddenPolicies: []models.PolicyResult{{
+			PolicyId: "ha", PolicyName: "HA", FailMessages: []string{"my-app has 1 replica"},
+			Snooze: &models.PolicySnooze{User: "alice", Until: time.Date(2025, 12, 8, 10, 0, 0, 0, time.UTC)},
+		}},
+	}
+	summary := data.PolicyEvaluation.EnvironmentSummary["stg"]
+	summary.PolicyCounts.TotalOmittedFailed = 1
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = summary
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if !strings.Contains(got, "* Policy `HA` failed, snoozed by @alice until 2025-12-08 10:00 UTC, with the following messages:") {
+		t.Errorf("RenderWithTemplates() missing the snooze in the omitted policies in:\n%s", got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_FixedPolicies tests the "Fixed by this PR" section
+func TestRenderer_RenderWithTemplates_FixedPolicies(t *testing.T) {
+	data := newTestReportData()
+	fixed := models.PolicyResult{PolicyId: "ha", PolicyName: "HA", IsPassing: true, FixedFailMessages: []string{"replicas too low"}}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{fixed},
+		FixedPolicies:    []models.PolicyResult{fixed},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	want := "#### ✅ Fixed by this PR [`stg`]\n\n* Policy `HA` no longer fails with:\n  * replicas too low\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+	}
+
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", IsPassing: true}}}
+	got, err = NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if strings.Contains(got, "Fixed by this PR") {
+		t.Errorf("RenderWithTemplates() should not render the fixed section without fixed policies:\n%s", got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_StoppedAfterBlockingFailure tests the partial report note of a fail-fast evaluation
+func TestRenderer_RenderWithTemplates_StoppedAfterBlockingFailure(t *testing.T) {
+	data := newTestReportData()
+	const want = "Partial report: the policy evaluation stopped after first blocking failure"
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() should not render the partial report note for a complete evaluation:\n%s", got)
+	}
+
+	data.PolicyEvaluation.StoppedAfterBlockingFailure = true
+	got, err = NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if !strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_EvaluationErrors tests the section of policies that could not be evaluated
+func TestRenderer_RenderWithTemplates_EvaluationErrors(t *testing.T) {
+	data := newTestReportData()
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = models.EnvironmentSummaryEnv{
+		PolicyCounts: models.PolicyCounts{TotalCount: 2, TotalSuccess: 1, TotalErrored: 1, BlockingSuccessCount: 1},
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{PolicyId: "ha", PolicyName: "HA", IsPassing: true}},
+		ErroredPolicies: []models.PolicyResult{{
+			PolicyId:   "pdb",
+			PolicyName: "PDB",
+			Error:      "failed to parse conftest output\nStderr: pdb.rego:1: rego_parse_error",
+		}},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	want := "#### 💥 Evaluation Errors [`stg`]\n\n" +
+		"> These policies could not be evaluated, the blocking check fails until they are fixed.\n\n" +
+		"* Policy `PDB`: failed to parse conftest output Stderr: pdb.rego:1: rego_parse_error\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+	}
+	if strings.Contains(got, "Policy `PDB` failed with") {
+		t.Errorf("RenderWithTemplates() should not list the errored policy as a violation:\n%s", got)
+	}
+}
+
+// TestRenderer_RenderWithTemplates_EscapesPolicyNameCodeSpan tests that a backtick in a policy name cannot end the
+// code span of the failure details
+func TestRenderer_RenderWithTemplates_EscapesPolicyNameCodeSpan(t *testing.T) {
+	data := newTestReportData()
+	data.PolicyEvaluation.EnvironmentSummary["stg"] = models.EnvironmentSummaryEnv{
+		PolicyCounts: models.PolicyCounts{BlockingFailedCount: 1},
+	}
+	data.PolicyEvaluation.PolicyMatrix["stg"] = models.PolicyMatrix{
+		BlockingPolicies: []models.PolicyResult{{
+			PolicyId:     "ha",
+			PolicyName:   "HA `@team` replicas",
+			FailMessages: []string{"replicas too low"},
+		}},
+	}
+
+	got, err := NewRenderer().RenderWithTemplates(testTemplatesDir, data)
+	if err != nil {
+		t.Fatalf("RenderWithTemplates() error = %v", err)
+	}
+	if want := "* Policy `HA '@team' replicas` failed with the following messages:"; !strings.Contains(got, want) {
+		t.Errorf("RenderWithTemplates() missing %q in:\n%s", want, got)
+	}
+	if strings.Contains(got, "`HA `@team` replicas`") {
+		t.Errorf("RenderWithTemplates() should not render the raw policy name in a code span:\n%s", got)
+	}
+}
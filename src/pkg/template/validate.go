@@ -0,0 +1,236 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+)
+
+// validateFields checks, before execution, that the field paths of the template (and of the templates it includes)
+// exist on the data type, so a typo like .Foo fails with the template location instead of a half-rendered comment.
+// Paths whose type cannot be known statically, e.g. the result of a function or an interface, are left to execution
+func validateFields(tmpl *template.Template, data interface{}) error {
+	if tmpl.Tree == nil {
+		return nil
+	}
+	v := &fieldValidator{tmpl: tmpl, visited: make(map[string]bool)}
+	return v.validateTemplate(tmpl.Tree, reflect.TypeOf(data))
+}
+
+type fieldValidator struct {
+	tmpl *template.Template
+	// named templates already validated for a dot type, e.g. "policy/*models.ReportData"
+	visited map[string]bool
+}
+
+// scope is the type of dot and of the declared variables, a nil type is unknown
+type scope struct {
+	dot  reflect.Type
+	vars map[string]reflect.Type
+}
+
+// with returns a copy of the scope for a nested list, variables declared in it don't leak out
+func (s scope) with(dot reflect.Type) scope {
+	vars := make(map[string]reflect.Type, len(s.vars))
+	for name, t := range s.vars {
+		vars[name] = t
+	}
+	return scope{dot: dot, vars: vars}
+}
+
+func (v *fieldValidator) validateTemplate(tree *parse.Tree, dot reflect.Type) error {
+	key := fmt.Sprintf("%s/%v", tree.Name, dot)
+	if v.visited[key] {
+		return nil
+	}
+	v.visited[key] = true
+	s := scope{dot: dot, vars: map[string]reflect.Type{"$": dot}}
+	return v.validateList(tree, tree.Root, s)
+}
+
+func (v *fieldValidator) validateList(tree *parse.Tree, list *parse.ListNode, s scope) error {
+	if list == nil {
+		return nil
+	}
+	for _, node := range list.Nodes {
+		if err := v.validateNode(tree, node, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *fieldValidator) validateNode(tree *parse.Tree, node parse.Node, s scope) error {
+	switch n := node.(type) {
+	case *parse.ActionNode:
+		t, err := v.validatePipe(tree, n.Pipe, s)
+		if err != nil {
+			return err
+		}
+		declare(n.Pipe, s, t, t)
+	case *parse.IfNode:
+		return v.validateBranch(tree, &n.BranchNode, s, func(scope, reflect.Type) reflect.Type { return s.dot })
+	case *parse.WithNode:
+		return v.validateBranch(tree, &n.BranchNode, s, func(inner scope, t reflect.Type) reflect.Type {
+			declare(n.Pipe, inner, t, t)
+			return t
+		})
+	case *parse.RangeNode:
+		return v.validateBranch(tree, &n.BranchNode, s, func(inner scope, t reflect.Type) reflect.Type {
+			key, elem := rangeTypes(t)
+			if len(n.Pipe.Decl) == 1 {
+				declare(n.Pipe, inner, elem, elem)
+			} else {
+				declare(n.Pipe, inner, key, elem)
+			}
+			return elem
+		})
+	case *parse.TemplateNode:
+		var dot reflect.Type
+		if n.Pipe != nil {
+			t, err := v.validatePipe(tree, n.Pipe, s)
+			if err != nil {
+				return err
+			}
+			dot = t
+		}
+		if included := v.tmpl.Lookup(n.Name); included != nil && included.Tree != nil && dot != nil {
+			return v.validateTemplate(included.Tree, dot)
+		}
+	}
+	return nil
+}
+
+// validateBranch validates the pipeline of an if/with/range, then its list with the dot returned by inner
+// and its else list with the current dot
+func (v *fieldValidator) validateBranch(tree *parse.Tree, n *parse.BranchNode, s scope, inner func(scope, reflect.Type) reflect.Type) error {
+	t, err := v.validatePipe(tree, n.Pipe, s)
+	if err != nil {
+		return err
+	}
+	listScope := s.with(nil)
+	listScope.dot = inner(listScope, t)
+	if err := v.validateList(tree, n.List, listScope); err != nil {
+		return err
+	}
+	return v.validateList(tree, n.ElseList, s.with(s.dot))
+}
+
+// validatePipe validates the field paths of every command and returns the type of the pipeline, nil if unknown
+func (v *fieldValidator) validatePipe(tree *parse.Tree, pipe *parse.PipeNode, s scope) (reflect.Type, error) {
+	if pipe == nil {
+		return nil, nil
+	}
+	var t reflect.Type
+	for _, cmd := range pipe.Cmds {
+		t = nil
+		for _, arg := range cmd.Args {
+			argType, err := v.validateArg(tree, arg, s)
+			if err != nil {
+				return nil, err
+			}
+			if len(cmd.Args) == 1 {
+				t = argType
+			}
+		}
+	}
+	return t, nil
+}
+
+// validateArg validates a field path argument and returns its type, nil if unknown
+func (v *fieldValidator) validateArg(tree *parse.Tree, arg parse.Node, s scope) (reflect.Type, error) {
+	switch n := arg.(type) {
+	case *parse.DotNode:
+		return s.dot, nil
+	case *parse.FieldNode:
+		return resolveFields(tree, n, s.dot, n.Ident)
+	case *parse.VariableNode:
+		t, ok := s.vars[n.Ident[0]]
+		if !ok {
+			return nil, nil
+		}
+		return resolveFields(tree, n, t, n.Ident[1:])
+	case *parse.ChainNode:
+		base, err := v.validateArg(tree, n.Node, s)
+		if err != nil {
+			return nil, err
+		}
+		return resolveFields(tree, n, base, n.Field)
+	case *parse.PipeNode:
+		return v.validatePipe(tree, n, s)
+	default:
+		return nil, nil
+	}
+}
+
+// resolveFields returns the type of the field path on t, an error naming the template location if a field is undefined
+func resolveFields(tree *parse.Tree, node parse.Node, t reflect.Type, fields []string) (reflect.Type, error) {
+	for _, field := range fields {
+		if t == nil {
+			return nil, nil
+		}
+		if method, ok := reflect.PointerTo(t).MethodByName(field); ok {
+			if method.Type.NumOut() == 0 {
+				return nil, nil
+			}
+			t = method.Type.Out(0)
+			continue
+		}
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Interface:
+			return nil, nil
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return nil, nil
+			}
+			t = t.Elem()
+		case reflect.Struct:
+			structField, ok := t.FieldByName(field)
+			if !ok || !structField.IsExported() {
+				location, _ := tree.ErrorContext(node)
+				return nil, fmt.Errorf("template %s: undefined field %s, %s has no field or method %s", location, node, t, field)
+			}
+			t = structField.Type
+		default:
+			location, _ := tree.ErrorContext(node)
+			return nil, fmt.Errorf("template %s: undefined field %s, %s has no field %s", location, node, t, field)
+		}
+	}
+	return t, nil
+}
+
+// declare sets the type of the variables declared by the pipeline, first and second for "$k, $v :="
+func declare(pipe *parse.PipeNode, s scope, first, second reflect.Type) {
+	if pipe == nil || pipe.IsAssign {
+		return
+	}
+	for i, variable := range pipe.Decl {
+		if i == 0 {
+			s.vars[variable.Ident[0]] = first
+		} else {
+			s.vars[variable.Ident[0]] = second
+		}
+	}
+}
+
+// rangeTypes returns the key and element types of ranging over t, nil if unknown
+func rangeTypes(t reflect.Type) (reflect.Type, reflect.Type) {
+	if t == nil {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), t.Elem()
+	case reflect.Map:
+		return t.Key(), t.Elem()
+	default:
+		return nil, nil
+	}
+}
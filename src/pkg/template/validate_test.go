@@ -0,0 +1,140 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyTestTemplates copies the default templates to a temp dir, replacing the given files
+func copyTestTemplates(t *testing.T, replaced map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{FileNameCommentTemplate, FileNameDiffTemplate, FileNamePolicyTemplate} {
+		content, err := os.ReadFile(filepath.Join(testTemplatesDir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if r, ok := replaced[name]; ok {
+			content = []byte(r)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+// TestRenderer_RenderWithTemplates_UndefinedField tests that a template referencing an undefined field
+// fails before execution with its location, and renders nothing
+func TestRenderer_RenderWithTemplates_UndefinedField(t *testing.T) {
+	tests := []struct {
+		name     string
+		replaced map[string]string
+		wantErr  []string
+	}{
+		{
+			name:     "top-level field of the comment template",
+			replaced: map[string]string{FileNameCommentTemplate: "# {{.Service}}\n\n{{.Foo}}\n"},
+			wantErr:  []string{"comment:3:2", "undefined field .Foo", "models.ReportData has no field or method Foo"},
+		},
+		{
+			name: "nested field of an included template",
+			replaced: map[string]string{
+				FileNamePolicyTemplate: "## Policies\n{{.PolicyEvaluation.Matrix}}\n",
+			},
+			wantErr: []string{"policy:2:", "undefined field .PolicyEvaluation.Matrix", "models.PolicyEvaluation has no field or method Matrix"},
+		},
+		{
+			name: "field of a range element",
+			replaced: map[string]string{
+				FileNameDiffTemplate: "{{range $env := .Environments}}{{$env.Name}}{{end}}\n",
+			},
+			wantErr: []string{"diff:1:", "undefined field $env.Name", "string has no field Name"},
+		},
+		{
+			name: "root variable inside a range",
+			replaced: map[string]string{
+				FileNameDiffTemplate: "{{range .Environments}}{{$.Envs}}{{end}}\n",
+			},
+			wantErr: []string{"undefined field $.Envs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := copyTestTemplates(t, tt.replaced)
+
+			got, err := NewRenderer().RenderWithTemplates(dir, newTestReportData())
+			if err == nil {
+				t.Fatalf("RenderWithTemplates() error = nil, want undefined field error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("RenderWithTemplates() error = %v, want it to contain %q", err, want)
+				}
+			}
+			if got != "" {
+				t.Errorf("RenderWithTemplates() = %q, want no partial output", got)
+			}
+		})
+	}
+}
+
+// TestRenderer_RenderString_Validation tests the validation of field paths and missing map keys
+func TestRenderer_RenderString_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "valid nested fields and methods",
+			template: "{{.Service}} {{.Timestamp.Year}} {{range $env, $diff := .ManifestChanges}}{{$env}}={{$diff.LineCount}}{{end}}",
+			data:     newTestReportData(),
+			want:     "my-app 2025 stg=0",
+		},
+		{
+			name:     "unknown type after a function is left to execution",
+			template: "{{with index .PolicyEvaluation.EnvironmentSummary \"stg\"}}{{.PolicyCounts.TotalFailed}}{{end}}",
+			data:     newTestReportData(),
+			want:     "0",
+		},
+		{
+			name:     "undefined field",
+			template: "{{if .Environments}}{{.Enviroments}}{{end}}",
+			data:     newTestReportData(),
+			wantErr:  "undefined field .Enviroments",
+		},
+		{
+			name:     "missing map key",
+			template: "{{.service}}",
+			data:     map[string]string{"name": "my-app"},
+			wantErr:  "map has no entry for key \"service\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRenderer().RenderString(tt.template, tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("RenderString() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				if got != "" {
+					t.Errorf("RenderString() = %q, want no partial output", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderString() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"sort"
 	"time"
 
+	"github.com/gh-nvat/gitops-kustomz/src/pkg/fileutil"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
@@ -19,6 +21,7 @@ import (
 var tracer trace.Tracer
 var spanRecorder *SpanRecorder
 var outputDir string
+var compressReport bool
 
 // SpanRecorder records spans for human-readable reporting
 type SpanRecorder struct {
@@ -48,8 +51,8 @@ type PerformanceReport struct {
 	Timestamp       string     `json:"timestamp"`
 }
 
-// InitTracer initializes OpenTelemetry tracing
-func InitTracer(serviceName string, enabled bool, outDir string) (func(), error) {
+// InitTracer initializes OpenTelemetry tracing, the performance report is gzip-compressed if compress
+func InitTracer(serviceName string, enabled bool, outDir string, compress bool) (func(), error) {
 	if !enabled {
 		// Return no-op shutdown
 		return func() {}, nil
@@ -57,6 +60,7 @@ func InitTracer(serviceName string, enabled bool, outDir string) (func(), error)
 
 	spanRecorder = &SpanRecorder{spans: make([]spanRecord, 0)}
 	outputDir = outDir
+	compressReport = compress
 
 	// Create resource
 	res, err := resource.New(
@@ -163,19 +167,35 @@ func ExportReport() error {
 	}
 
 	// Write to file
-	reportPath := filepath.Join(outputDir, "performance-report.json")
+	reportPath := filepath.Join(outputDir, "performance-report"+fileutil.OutputExt(".json", compressReport))
 	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal report: %w", err)
 	}
 
-	if err := os.WriteFile(reportPath, data, 0644); err != nil {
+	if err := fileutil.WriteOutputFile(reportPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write report: %w", err)
 	}
 
 	return nil
 }
 
+// SpanDurations returns the total duration of the spans ended so far by name, only the spans started at or after since,
+// empty if tracing is disabled
+func SpanDurations(since time.Time) map[string]time.Duration {
+	durations := make(map[string]time.Duration)
+	if spanRecorder == nil {
+		return durations
+	}
+	for _, record := range spanRecorder.spans {
+		if record.Start.Before(since) {
+			continue
+		}
+		durations[record.Name] += record.Duration
+	}
+	return durations
+}
+
 // buildHierarchy converts flat span records into a hierarchical structure
 func buildHierarchy(records []spanRecord) []SpanInfo {
 	// Create a map of spanID to SpanInfo
@@ -0,0 +1,170 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+var logger *log.Entry = log.New().WithFields(log.Fields{
+	"package": "watch",
+})
+
+// DEFAULT_DEBOUNCE is the quiet period after the last change before re-running, editors often write a file several times
+const DEFAULT_DEBOUNCE = 300 * time.Millisecond
+
+// Watcher reports the paths changed under the watched directories
+type Watcher interface {
+	// Events returns the changed paths, closed once the watcher is closed
+	Events() <-chan string
+	// Errors returns the watch errors, they are logged and do not stop the loop
+	Errors() <-chan error
+	Close() error
+}
+
+// FsWatcher watches directory trees with fsnotify, directories created later are watched too
+type FsWatcher struct {
+	watcher *fsnotify.Watcher
+	events  chan string
+	done    chan struct{}
+	// changes under these paths are not reported, e.g. the output directory written by each run
+	ignored []string
+}
+
+// make FsWatcher implement Watcher
+var _ Watcher = (*FsWatcher)(nil)
+
+// NewFsWatcher watches the directory trees of roots, changes under the ignored paths are not reported
+func NewFsWatcher(roots []string, ignored []string) (*FsWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	w := &FsWatcher{
+		watcher: watcher,
+		events:  make(chan string),
+		done:    make(chan struct{}),
+	}
+	for _, path := range ignored {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			_ = watcher.Close()
+			return nil, fmt.Errorf("failed to resolve ignored path %s: %w", path, err)
+		}
+		w.ignored = append(w.ignored, abs)
+	}
+	for _, root := range roots {
+		if err := w.addTree(root); err != nil {
+			_ = watcher.Close()
+			return nil, err
+		}
+	}
+	go w.forward()
+	return w, nil
+}
+
+func (w *FsWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *FsWatcher) Errors() <-chan error {
+	return w.watcher.Errors
+}
+
+func (w *FsWatcher) Close() error {
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+	return w.watcher.Close()
+}
+
+// addTree watches root and its sub directories, fsnotify is not recursive
+func (w *FsWatcher) addTree(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if w.isIgnored(path) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// forward reports the changed paths until the fsnotify watcher is closed, new directories are watched on the way
+func (w *FsWatcher) forward() {
+	defer close(w.events)
+	for event := range w.watcher.Events {
+		if w.isIgnored(event.Name) || event.Op == fsnotify.Chmod {
+			continue
+		}
+		if event.Op.Has(fsnotify.Create) {
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if err := w.addTree(event.Name); err != nil {
+					logger.WithField("error", err).Warn("Failed to watch new directory")
+				}
+			}
+		}
+		select {
+		case w.events <- event.Name:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *FsWatcher) isIgnored(path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for _, ignored := range w.ignored {
+		if abs == ignored || strings.HasPrefix(abs, ignored+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run calls fn each time the watcher reports changes, once no change was reported for the debounce period,
+// until ctx is done or the watcher is closed
+func Run(ctx context.Context, watcher Watcher, debounce time.Duration, fn func()) error {
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case path, ok := <-watcher.Events():
+			if !ok {
+				return nil
+			}
+			logger.WithField("path", path).Debug("Change detected")
+			timer.Reset(debounce)
+		case err, ok := <-watcher.Errors():
+			if !ok {
+				return nil
+			}
+			logger.WithField("error", err).Warn("File watch error")
+		case <-timer.C:
+			fn()
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeWatcher reports the paths sent on its events channel
+type fakeWatcher struct {
+	events chan string
+	errors chan error
+}
+
+func newFakeWatcher() *fakeWatcher {
+	return &fakeWatcher{events: make(chan string), errors: make(chan error)}
+}
+
+func (w *fakeWatcher) Events() <-chan string { return w.events }
+func (w *fakeWatcher) Errors() <-chan error  { return w.errors }
+func (w *fakeWatcher) Close() error          { return nil }
+
+// TestRun tests that bursts of changes trigger a single run each, once quiet for the debounce period
+func TestRun(t *testing.T) {
+	watcher := newFakeWatcher()
+	runs := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Run(ctx, watcher, 20*time.Millisecond, func() { runs <- struct{}{} })
+	}()
+
+	for burst := 1; burst <= 2; burst++ {
+		for i := 0; i < 3; i++ {
+			watcher.events <- "services/my-app/base/deployment.yaml"
+		}
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("Run() did not run after burst %d", burst)
+		}
+		select {
+		case <-runs:
+			t.Fatalf("Run() ran more than once for burst %d", burst)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+// TestRun_ClosedWatcher tests that the loop ends once the watcher is closed
+func TestRun_ClosedWatcher(t *testing.T) {
+	watcher := newFakeWatcher()
+	close(watcher.events)
+	if err := Run(context.Background(), watcher, time.Millisecond, func() {}); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+// TestFsWatcher tests that changes in nested and new directories are reported, but not under ignored paths
+func TestFsWatcher(t *testing.T) {
+	root := t.TempDir()
+	output := filepath.Join(root, "output")
+	nested := filepath.Join(root, "services", "my-app")
+	for _, dir := range []string{output, nested} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+	watcher, err := NewFsWatcher([]string{root}, []string{output})
+	if err != nil {
+		t.Fatalf("NewFsWatcher() error = %v", err)
+	}
+	defer watcher.Close()
+
+	var reported atomic.Value
+	reported.Store([]string{})
+	go func() {
+		for path := range watcher.Events() {
+			reported.Store(append(reported.Load().([]string), path))
+		}
+	}()
+
+	write := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("kind: Deployment\n"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	write(filepath.Join(output, "report.md"))
+	write(filepath.Join(nested, "deployment.yaml"))
+	created := filepath.Join(nested, "overlays")
+	if err := os.Mkdir(created, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", created, err)
+	}
+	// let the new directory be watched before writing in it
+	time.Sleep(50 * time.Millisecond)
+	write(filepath.Join(created, "kustomization.yaml"))
+
+	want := map[string]bool{
+		filepath.Join(nested, "deployment.yaml"):     true,
+		filepath.Join(created, "kustomization.yaml"): true,
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := map[string]bool{}
+		for _, path := range reported.Load().([]string) {
+			if filepath.Dir(path) == output {
+				t.Fatalf("FsWatcher reported %s under the ignored output directory", path)
+			}
+			got[path] = true
+		}
+		missing := false
+		for path := range want {
+			missing = missing || !got[path]
+		}
+		if !missing {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("FsWatcher reported %v, want %v", reported.Load(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
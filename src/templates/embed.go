@@ -0,0 +1,9 @@
+// Package templates embeds the default markdown templates, so they can be scaffolded by the binary
+package templates
+
+import "embed"
+
+// FS holds the default comment, diff and policy templates
+//
+//go:embed *.md.tmpl
+var FS embed.FS